@@ -0,0 +1,147 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Praatibh/xang/run"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// ComparisonResult is the answer of one model to a compared prompt.
+type ComparisonResult struct {
+	Model            string
+	Output           EngineExecOutput
+	Latency          time.Duration
+	PromptTokens     int
+	CandidatesTokens int
+	Error            error
+	content          string
+}
+
+// EngineCompareOutput holds the answers of several models to the same prompt,
+// in the order the models were given.
+type EngineCompareOutput struct {
+	input   string
+	results []ComparisonResult
+}
+
+func (co EngineCompareOutput) GetInput() string {
+	return co.input
+}
+
+func (co EngineCompareOutput) GetResults() []ComparisonResult {
+	return co.results
+}
+
+// CompareCompletion sends input, along with the conversation so far, to each
+// of models concurrently. Nothing is recorded until one answer is chosen with
+// ChooseComparison.
+func (e *Engine) CompareCompletion(input string, models []string) (*EngineCompareOutput, error) {
+	if len(models) == 0 {
+		return nil, errors.New("no models to compare")
+	}
+
+	ctx, cancel := context.WithTimeout(e.ctx, 60*time.Second)
+	defer cancel()
+
+	e.mu.Lock()
+	e.running = true
+	e.mu.Unlock()
+
+	defer func() {
+		e.mu.Lock()
+		e.running = false
+		e.mu.Unlock()
+	}()
+
+	e.setSystemInstruction()
+	conversation := e.GetConversation()
+
+	results := make([]ComparisonResult, len(models))
+	var wg sync.WaitGroup
+	for i, name := range models {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			results[i] = e.compare(ctx, validateModelName(name), conversation, input)
+		}(i, name)
+	}
+	wg.Wait()
+
+	return &EngineCompareOutput{
+		input:   input,
+		results: results,
+	}, nil
+}
+
+func (e *Engine) compare(ctx context.Context, name string, conversation *Conversation, input string) ComparisonResult {
+	model := newGenerativeModel(e.client, name)
+	model.SystemInstruction = e.model.SystemInstruction
+
+	cs := model.StartChat()
+	history, parts := conversation.ToRequest(input)
+	cs.History = history
+
+	start := time.Now()
+	resp, err := cs.SendMessage(ctx, parts...)
+	result := ComparisonResult{
+		Model:   name,
+		Latency: time.Since(start),
+	}
+	if err != nil {
+		result.Error = fmt.Errorf("failed to send message to Gemini API: %w", err)
+		return result
+	}
+
+	result.content = extractResponseContent(resp)
+	if result.content == "" {
+		result.Error = errors.New("empty response from Gemini API")
+		return result
+	}
+	result.Output, _ = parseExecOutput(result.content)
+	// Side by side answers are not asked again, only marked invalid
+	if result.Output.Executable {
+		if err := run.ValidateSyntax(result.Output.Command, e.shellDialect()); err != nil {
+			result.Output.Executable = false
+			result.Output.SyntaxError = err.Error()
+		}
+	}
+	e.usage.add(name, resp.UsageMetadata, false)
+	if resp.UsageMetadata != nil {
+		result.PromptTokens = int(resp.UsageMetadata.PromptTokenCount)
+		result.CandidatesTokens = int(resp.UsageMetadata.CandidatesTokenCount)
+	}
+
+	return result
+}
+
+// ChooseComparison records the answer at index of comparison in the
+// conversation, as if that model alone had been asked, and returns it.
+func (e *Engine) ChooseComparison(comparison EngineCompareOutput, index int) (*EngineExecOutput, error) {
+	if index < 0 || index >= len(comparison.results) {
+		return nil, fmt.Errorf("no answer %d to choose", index+1)
+	}
+
+	result := comparison.results[index]
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	usage := &genai.UsageMetadata{
+		PromptTokenCount:     int32(result.PromptTokens),
+		CandidatesTokenCount: int32(result.CandidatesTokens),
+	}
+	e.recordExchange(e.GetConversation(), comparison.input, result.content, usage, map[string]string{
+		"model":      result.Model,
+		"command":    result.Output.Command,
+		"executable": fmt.Sprintf("%t", result.Output.Executable),
+	})
+
+	output := result.Output
+	return &output, nil
+}
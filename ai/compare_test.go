@@ -0,0 +1,67 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChooseComparison(t *testing.T) {
+	t.Run("Records", testChooseComparisonRecords)
+	t.Run("Invalid", testChooseComparisonInvalid)
+}
+
+func newComparison() EngineCompareOutput {
+	return EngineCompareOutput{
+		input: "list files",
+		results: []ComparisonResult{
+			{Model: "gemini-2.5-flash", Error: errors.New("quota exceeded")},
+			{
+				Model:            "gemini-2.5-pro",
+				Output:           EngineExecOutput{Command: "ls -la", Executable: true},
+				PromptTokens:     120,
+				CandidatesTokens: 14,
+				content:          `{"cmd":"ls -la", "exp":"lists files", "exec":true}`,
+			},
+		},
+	}
+}
+
+func testChooseComparisonRecords(t *testing.T) {
+	engine := &Engine{
+		mode:          ExecEngineMode,
+		modelName:     "gemini-2.5-flash",
+		conversations: make(map[EngineMode]*Conversation),
+		ctx:           context.Background(),
+	}
+
+	output, err := engine.ChooseComparison(newComparison(), 1)
+
+	require.NoError(t, err)
+	assert.Equal(t, "ls -la", output.GetCommand())
+
+	turns := engine.GetConversation().GetTurns()
+	require.Len(t, turns, 2)
+	assert.Equal(t, "list files", turns[0].Content)
+	assert.Equal(t, 120, turns[0].Tokens)
+	assert.Equal(t, "gemini-2.5-pro", turns[1].Metadata["model"], "The chosen model should be recorded.")
+	assert.Equal(t, 14, turns[1].Tokens)
+}
+
+func testChooseComparisonInvalid(t *testing.T) {
+	engine := &Engine{
+		mode:          ExecEngineMode,
+		conversations: make(map[EngineMode]*Conversation),
+		ctx:           context.Background(),
+	}
+
+	_, err := engine.ChooseComparison(newComparison(), 0)
+	assert.EqualError(t, err, "quota exceeded")
+
+	_, err = engine.ChooseComparison(newComparison(), 2)
+	assert.Error(t, err)
+	assert.Equal(t, 0, engine.GetConversation().Len(), "Nothing should be recorded.")
+}
@@ -0,0 +1,213 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// Turn is a single message of a conversation.
+type Turn struct {
+	Role      Role      `json:"role"`
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
+	// Tokens is the number of tokens billed for the turn: the whole prompt
+	// for user turns, the generated text for model turns.
+	Tokens   int               `json:"tokens,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+func NewTurn(role Role, content string) Turn {
+	return Turn{
+		Role:      role,
+		Content:   content,
+		Timestamp: time.Now(),
+		Metadata:  map[string]string{},
+	}
+}
+
+// WithTokens returns a copy of the turn with its token count set.
+func (t Turn) WithTokens(tokens int) Turn {
+	t.Tokens = tokens
+	return t
+}
+
+// WithMetadata returns a copy of the turn with key set to value.
+func (t Turn) WithMetadata(key string, value string) Turn {
+	metadata := make(map[string]string, len(t.Metadata)+1)
+	for k, v := range t.Metadata {
+		metadata[k] = v
+	}
+	metadata[key] = value
+	t.Metadata = metadata
+	return t
+}
+
+// Conversation is the ordered list of turns exchanged in one engine mode,
+// along with the piped input they are about.
+type Conversation struct {
+	mu      sync.RWMutex
+	mode    EngineMode
+	context string
+	turns   []Turn
+}
+
+type conversationJSON struct {
+	Mode    string `json:"mode"`
+	Context string `json:"context,omitempty"`
+	Turns   []Turn `json:"turns"`
+}
+
+func NewConversation(mode EngineMode) *Conversation {
+	return &Conversation{
+		mode:  mode,
+		turns: make([]Turn, 0),
+	}
+}
+
+func (c *Conversation) GetMode() EngineMode {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.mode
+}
+
+func (c *Conversation) GetContext() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.context
+}
+
+// SetContext sets the input, usually piped, the conversation is about.
+func (c *Conversation) SetContext(context string) *Conversation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.context = context
+	return c
+}
+
+func (c *Conversation) Append(turns ...Turn) *Conversation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.turns = append(c.turns, turns...)
+	return c
+}
+
+// GetTurns returns a copy of the turns, oldest first.
+func (c *Conversation) GetTurns() []Turn {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	turns := make([]Turn, len(c.turns))
+	copy(turns, c.turns)
+	return turns
+}
+
+// GetLastTurn returns the most recent turn with the given role.
+func (c *Conversation) GetLastTurn(role Role) (Turn, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for i := len(c.turns) - 1; i >= 0; i-- {
+		if c.turns[i].Role == role {
+			return c.turns[i], true
+		}
+	}
+	return Turn{}, false
+}
+
+func (c *Conversation) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.turns)
+}
+
+// GetTokens returns the number of tokens billed for the whole conversation.
+func (c *Conversation) GetTokens() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	tokens := 0
+	for _, turn := range c.turns {
+		tokens += turn.Tokens
+	}
+	return tokens
+}
+
+func (c *Conversation) Clear() *Conversation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.turns = make([]Turn, 0)
+	return c
+}
+
+// ToHistory converts the turns to the chat history Gemini expects. Tool and
+// observation turns are reported by the user, and consecutive turns of the
+// same role are merged since the API wants roles to alternate.
+func (c *Conversation) ToHistory() []*genai.Content {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	history := make([]*genai.Content, 0, len(c.turns))
+	for _, turn := range c.turns {
+		role, text := "user", turn.Content
+		switch turn.Role {
+		case ModelRole:
+			role = "model"
+		case ToolRole:
+			text = fmt.Sprintf("I ran the command: %s", turn.Content)
+		case ObservationRole:
+			text = fmt.Sprintf("Observed result: %s", turn.Content)
+		}
+
+		if last := len(history) - 1; last >= 0 && history[last].Role == role {
+			history[last].Parts = append(history[last].Parts, genai.Text(text))
+			continue
+		}
+		history = append(history, &genai.Content{
+			Parts: []genai.Part{genai.Text(text)},
+			Role:  role,
+		})
+	}
+	return history
+}
+
+// ToRequest converts the turns to the chat history Gemini expects, along with
+// the parts of the message sending input. A history ending with a user turn,
+// such as a tool turn or a question whose answer was dropped, is merged into
+// the message since SendMessage adds a user message of its own.
+func (c *Conversation) ToRequest(input string) ([]*genai.Content, []genai.Part) {
+	history := c.ToHistory()
+	parts := []genai.Part{genai.Text(input)}
+	if last := len(history) - 1; last >= 0 && history[last].Role == "user" {
+		parts = append(history[last].Parts, parts...)
+		history = history[:last]
+	}
+	return history, parts
+}
+
+func (c *Conversation) MarshalJSON() ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return json.Marshal(conversationJSON{
+		Mode:    c.mode.String(),
+		Context: c.context,
+		Turns:   c.turns,
+	})
+}
+
+func (c *Conversation) UnmarshalJSON(data []byte) error {
+	var decoded conversationJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mode = GetEngineModeFromString(decoded.Mode)
+	c.context = decoded.Context
+	c.turns = decoded.Turns
+	if c.turns == nil {
+		c.turns = make([]Turn, 0)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package ai
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConversation(t *testing.T) {
+	t.Run("Append", testConversationAppend)
+	t.Run("GetLastTurn", testConversationGetLastTurn)
+	t.Run("GetTokens", testConversationGetTokens)
+	t.Run("Clear", testConversationClear)
+	t.Run("ToHistory", testConversationToHistory)
+	t.Run("ToRequest", testConversationToRequest)
+	t.Run("JSON", testConversationJSON)
+}
+
+func testConversationAppend(t *testing.T) {
+	c := NewConversation(ExecEngineMode)
+	c.Append(NewTurn(UserRole, "list files"), NewTurn(ModelRole, "ls"))
+
+	turns := c.GetTurns()
+	require.Len(t, turns, 2)
+	assert.Equal(t, UserRole, turns[0].Role)
+	assert.Equal(t, "ls", turns[1].Content)
+	assert.False(t, turns[0].Timestamp.IsZero(), "Turns should be timestamped.")
+}
+
+func testConversationGetLastTurn(t *testing.T) {
+	c := NewConversation(ExecEngineMode)
+	c.Append(NewTurn(UserRole, "first"), NewTurn(ModelRole, "answer"), NewTurn(UserRole, "second"))
+
+	turn, ok := c.GetLastTurn(UserRole)
+	assert.True(t, ok)
+	assert.Equal(t, "second", turn.Content)
+
+	_, ok = c.GetLastTurn(ToolRole)
+	assert.False(t, ok)
+}
+
+func testConversationGetTokens(t *testing.T) {
+	c := NewConversation(ChatEngineMode)
+	c.Append(NewTurn(UserRole, "hi").WithTokens(12), NewTurn(ModelRole, "hello").WithTokens(3))
+
+	assert.Equal(t, 15, c.GetTokens())
+}
+
+func testConversationClear(t *testing.T) {
+	c := NewConversation(ChatEngineMode).SetContext("piped")
+	c.Append(NewTurn(UserRole, "hi"))
+	c.Clear()
+
+	assert.Equal(t, 0, c.Len())
+	assert.Equal(t, "piped", c.GetContext(), "Clearing should keep the context.")
+}
+
+func testConversationToHistory(t *testing.T) {
+	c := NewConversation(ExecEngineMode)
+	c.Append(
+		NewTurn(UserRole, "list files"),
+		NewTurn(ModelRole, "ls"),
+		NewTurn(ToolRole, "ls"),
+		NewTurn(ObservationRole, "exit status 0"),
+		NewTurn(UserRole, "now hidden ones"),
+	)
+
+	history := c.ToHistory()
+	require.Len(t, history, 3, "Consecutive user side turns should be merged.")
+	assert.Equal(t, "user", history[0].Role)
+	assert.Equal(t, "model", history[1].Role)
+	assert.Equal(t, "user", history[2].Role)
+	assert.Equal(t, []genai.Part{
+		genai.Text("I ran the command: ls"),
+		genai.Text("Observed result: exit status 0"),
+		genai.Text("now hidden ones"),
+	}, history[2].Parts)
+}
+
+func testConversationToRequest(t *testing.T) {
+	c := NewConversation(ExecEngineMode)
+	c.Append(
+		NewTurn(UserRole, "list files"),
+		NewTurn(ModelRole, "ls"),
+		NewTurn(ToolRole, "ls"),
+		NewTurn(ObservationRole, "exit status 0"),
+	)
+
+	history, parts := c.ToRequest("now hidden ones")
+	require.Len(t, history, 2, "The trailing tool turn should leave the history.")
+	assert.Equal(t, "user", history[0].Role)
+	assert.Equal(t, "model", history[1].Role)
+	assert.Equal(t, []genai.Part{
+		genai.Text("I ran the command: ls"),
+		genai.Text("Observed result: exit status 0"),
+		genai.Text("now hidden ones"),
+	}, parts, "The tool turn should be sent along with the next request.")
+
+	history, parts = NewConversation(ExecEngineMode).Append(NewTurn(UserRole, "list files"), NewTurn(ModelRole, "ls")).ToRequest("again")
+	assert.Len(t, history, 2, "A history ending with the model should be kept.")
+	assert.Equal(t, []genai.Part{genai.Text("again")}, parts)
+}
+
+func testConversationJSON(t *testing.T) {
+	c := NewConversation(TranslateEngineMode).SetContext("piped")
+	c.Append(NewTurn(UserRole, "export A=1").WithTokens(7), NewTurn(ModelRole, "set -gx A 1").WithMetadata("model", "gemini-2.5-flash"))
+
+	data, err := json.Marshal(c)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"mode":"translate"`)
+	assert.Contains(t, string(data), `"role":"model"`)
+
+	decoded := NewConversation(ExecEngineMode)
+	require.NoError(t, json.Unmarshal(data, decoded))
+	assert.Equal(t, TranslateEngineMode, decoded.GetMode())
+	assert.Equal(t, "piped", decoded.GetContext())
+	require.Equal(t, 2, decoded.Len())
+	assert.Equal(t, 7, decoded.GetTurns()[0].Tokens)
+	assert.Equal(t, "gemini-2.5-flash", decoded.GetTurns()[1].Metadata["model"])
+}
@@ -0,0 +1,140 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+const (
+	gemini_embedding_model = "text-embedding-004"
+	local_embedding_size   = 256
+)
+
+// Embedder turns a text into a vector, similar texts giving close vectors.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+	// GetName identifies the embedding space, vectors of different
+	// embedders cannot be compared.
+	GetName() string
+}
+
+// NewEmbedder returns the embedder named by backend: "local" for the offline
+// stand-in, anything else for Gemini.
+func NewEmbedder(backend string, client *genai.Client) Embedder {
+	if strings.ToLower(backend) == "local" {
+		return NewLocalEmbedder(local_embedding_size)
+	}
+
+	return NewGeminiEmbedder(client, gemini_embedding_model)
+}
+
+// GeminiEmbedder embeds texts with a Gemini embedding model.
+type GeminiEmbedder struct {
+	model *genai.EmbeddingModel
+	name  string
+}
+
+func NewGeminiEmbedder(client *genai.Client, name string) *GeminiEmbedder {
+	model := client.EmbeddingModel(name)
+	model.TaskType = genai.TaskTypeSemanticSimilarity
+
+	return &GeminiEmbedder{
+		model: model,
+		name:  name,
+	}
+}
+
+func (e *GeminiEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	resp, err := e.model.EmbedContent(ctx, genai.Text(text))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Embedding == nil || len(resp.Embedding.Values) == 0 {
+		return nil, errors.New("empty embedding from Gemini API")
+	}
+
+	return resp.Embedding.Values, nil
+}
+
+func (e *GeminiEmbedder) GetName() string {
+	return e.name
+}
+
+// LocalEmbedder hashes the words and word pairs of a text into a fixed size
+// vector. It needs no network, which makes it fit for tests and offline use,
+// but only matches texts sharing words.
+type LocalEmbedder struct {
+	size int
+}
+
+func NewLocalEmbedder(size int) *LocalEmbedder {
+	return &LocalEmbedder{size: size}
+}
+
+func (e *LocalEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	vector := make([]float32, e.size)
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		vector[e.bucket(word)]++
+		if i > 0 {
+			vector[e.bucket(words[i-1]+" "+word)] += 0.5
+		}
+	}
+
+	return normalize(vector), nil
+}
+
+func (e *LocalEmbedder) GetName() string {
+	return "local"
+}
+
+func (e *LocalEmbedder) bucket(token string) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(token))
+	return int(hash.Sum32() % uint32(e.size))
+}
+
+func normalize(vector []float32) []float32 {
+	var norm float64
+	for _, value := range vector {
+		norm += float64(value) * float64(value)
+	}
+	if norm == 0 {
+		return vector
+	}
+
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+	return vector
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b, or 0 when
+// they cannot be compared.
+func cosineSimilarity(a []float32, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
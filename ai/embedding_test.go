@@ -0,0 +1,58 @@
+package ai
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalEmbedder(t *testing.T) {
+	t.Run("Deterministic", testLocalEmbedderDeterministic)
+	t.Run("Similarity", testLocalEmbedderSimilarity)
+}
+
+func testLocalEmbedderDeterministic(t *testing.T) {
+	embedder := NewLocalEmbedder(64)
+
+	first, err := embedder.Embed(context.Background(), "deploy the staging cluster")
+	require.NoError(t, err)
+	second, err := embedder.Embed(context.Background(), "deploy the staging cluster")
+	require.NoError(t, err)
+
+	assert.Len(t, first, 64)
+	assert.Equal(t, first, second, "The same text should give the same vector.")
+	assert.InDelta(t, 1, cosineSimilarity(first, second), 1e-6)
+}
+
+func testLocalEmbedderSimilarity(t *testing.T) {
+	embedder := NewLocalEmbedder(local_embedding_size)
+
+	query, _ := embedder.Embed(context.Background(), "deploy the staging cluster")
+	near, _ := embedder.Embed(context.Background(), "deploy staging cluster now")
+	far, _ := embedder.Embed(context.Background(), "compress old log files")
+
+	assert.Greater(t, cosineSimilarity(query, near), cosineSimilarity(query, far), "Texts sharing words should be closer.")
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	testCases := []struct {
+		name     string
+		a        []float32
+		b        []float32
+		expected float64
+	}{
+		{"Same", []float32{1, 2}, []float32{2, 4}, 1},
+		{"Orthogonal", []float32{1, 0}, []float32{0, 1}, 0},
+		{"Opposite", []float32{1, 0}, []float32{-1, 0}, -1},
+		{"DifferentSizes", []float32{1, 0}, []float32{1, 0, 0}, 0},
+		{"Zero", []float32{0, 0}, []float32{1, 0}, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.InDelta(t, tc.expected, cosineSimilarity(tc.a, tc.b), 1e-6)
+		})
+	}
+}
@@ -6,34 +6,48 @@ import (
 	"errors"
 	"fmt"
 	// "io"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/Praatibh/xang/config"
+	"github.com/Praatibh/xang/history"
+	"github.com/Praatibh/xang/run"
 	"github.com/Praatibh/xang/system"
 
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/iterator"
-	"google.golang.org/api/option"
 )
 
 const noexec = "[noexec]"
 
+// session_value_max_length is the length past which the variables of the
+// session are shortened in the system prompt.
+const session_value_max_length = 200
+
 type Engine struct {
-	mode         EngineMode
-	config       *config.Config
-	client       *genai.Client  // Changed to store client, not model
-	model        *genai.GenerativeModel
-	execMessages []*genai.Content
-	chatMessages []*genai.Content
-	channel      chan EngineChatStreamOutput
-	pipe         string
-	running      bool
-	mu           sync.RWMutex  // Added mutex for thread safety
-	ctx          context.Context
-	cancel       context.CancelFunc
+	mode          EngineMode
+	config        *config.Config
+	client        *genai.Client  // Changed to store client, not model
+	clients       []*genai.Client
+	model         *genai.GenerativeModel
+	modelName     string
+	chain         *modelChain
+	usage         *Usage
+	conversations map[EngineMode]*Conversation
+	embedder      Embedder
+	recall        *RecallStore
+	examples      []RecallMatch
+	channel       chan EngineChatStreamOutput
+	source        run.Dialect
+	target        run.Dialect
+	session       *run.Session
+	running       bool
+	mu            sync.RWMutex  // Added mutex for thread safety
+	ctx           context.Context
+	cancel        context.CancelFunc
 }
 
 
@@ -43,37 +57,47 @@ func NewEngine(mode EngineMode, config *config.Config) (*Engine, error) {
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	
-	client, err := genai.NewClient(ctx, option.WithAPIKey(config.GetAiConfig().GetKey()))
-	if err != nil {
-		cancel()
-		return nil, fmt.Errorf("failed to create Gemini client: %w", err)
+
+	// One client per API key, the first being the main one
+	keys := append([]string{config.GetAiConfig().GetKey()}, config.GetAiConfig().GetFallbackKeys()...)
+	clients := make([]*genai.Client, 0, len(keys))
+	for _, key := range keys {
+		client, err := newClient(ctx, key, config.GetApiConfig())
+		if err != nil {
+			for _, client := range clients {
+				client.Close()
+			}
+			cancel()
+			return nil, fmt.Errorf("failed to create Gemini client: %w", err)
+		}
+		clients = append(clients, client)
 	}
 
 	// Model name validation and fallback
 	modelName := config.GetAiConfig().GetModel()
 	modelName = validateModelName(modelName)
-	
-	model := client.GenerativeModel(modelName)
-	
-	// Configure model parameters
-	model.SetTemperature(0.7)
-	model.SetTopK(40)
-	model.SetTopP(0.95)
-	model.SetMaxOutputTokens(2048)
+
+	chain := newModelChain(clients[0], newModelEntries(clients, modelName, config.GetAiConfig().GetFallbackModels()), config.GetAiConfig().GetFallbackCooldown())
 
 	engine := &Engine{
-		mode:         mode,
-		config:       config,
-		client:       client,
-		model:        model,
-		execMessages: make([]*genai.Content, 0),
-		chatMessages: make([]*genai.Content, 0),
-		channel:      make(chan EngineChatStreamOutput, 10), // Buffered channel
-		pipe:         "",
-		running:      false,
-		ctx:          ctx,
-		cancel:       cancel,
+		mode:          mode,
+		config:        config,
+		client:        clients[0],
+		clients:       clients,
+		model:         chain.primary().model,
+		modelName:     modelName,
+		chain:         chain,
+		usage:         NewUsage(),
+		conversations: make(map[EngineMode]*Conversation),
+		channel:       make(chan EngineChatStreamOutput, 10), // Buffered channel
+		running:       false,
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+
+	if recallConfig := config.GetRecallConfig(); recallConfig.IsEnabled() {
+		engine.embedder = NewEmbedder(recallConfig.GetEmbedder(), clients[0])
+		engine.recall = NewRecallStore(filepath.Join(config.GetSystemConfig().GetDataDirectory(), "recall.jsonl"))
 	}
 
 	// Set initial system instruction
@@ -82,6 +106,46 @@ func NewEngine(mode EngineMode, config *config.Config) (*Engine, error) {
 	return engine, nil
 }
 
+// newModelEntries returns the fallback chain: the model then the fallback
+// models, with the main key and then with each fallback key.
+func newModelEntries(clients []*genai.Client, model string, fallbackModels []string) []*modelEntry {
+	names := []string{model}
+	seen := map[string]bool{model: true}
+	for _, name := range fallbackModels {
+		name = validateModelName(strings.TrimSpace(name))
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	entries := make([]*modelEntry, 0, len(clients)*len(names))
+	for keyIndex, client := range clients {
+		for _, name := range names {
+			entries = append(entries, &modelEntry{
+				name:     name,
+				keyIndex: keyIndex,
+				model:    newGenerativeModel(client, name),
+			})
+		}
+	}
+	return entries
+}
+
+// newGenerativeModel returns the model named name, configured the way every
+// request of the engine expects.
+func newGenerativeModel(client *genai.Client, name string) *genai.GenerativeModel {
+	model := client.GenerativeModel(name)
+
+	// Configure model parameters
+	model.SetTemperature(0.7)
+	model.SetTopK(40)
+	model.SetTopP(0.95)
+	model.SetMaxOutputTokens(2048)
+
+	return model
+}
+
 // validateModelName ensures the model name is valid for September 2025
 func validateModelName(modelName string) string {
 	validModels := map[string]string{
@@ -116,8 +180,8 @@ func (e *Engine) Close() error {
 		e.cancel()
 	}
 	
-	if e.client != nil {
-		if err := e.client.Close(); err != nil {
+	for _, client := range e.clients {
+		if err := client.Close(); err != nil {
 			return fmt.Errorf("failed to close Gemini client: %w", err)
 		}
 	}
@@ -145,19 +209,167 @@ func (e *Engine) GetChannel() chan EngineChatStreamOutput {
 	return e.channel
 }
 
+// SetPipe sets the piped input every conversation is about.
 func (e *Engine) SetPipe(pipe string) *Engine {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	e.pipe = pipe
+	for _, mode := range []EngineMode{ExecEngineMode, ChatEngineMode, TranslateEngineMode} {
+		e.getConversation(mode).SetContext(pipe)
+	}
+	e.setSystemInstruction()
 	return e
 }
 
+// GetConversation returns the conversation of the current mode.
+func (e *Engine) GetConversation() *Conversation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.getConversation(e.mode)
+}
+
+// SetConversation restores a conversation, replacing the one of its mode.
+func (e *Engine) SetConversation(conversation *Conversation) *Engine {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.conversations[conversation.GetMode()] = conversation
+	e.setSystemInstruction()
+	return e
+}
+
+// AppendToolTurn records a command xang ran on the user's behalf, and what
+// came of it, in the exec conversation the command comes from, whatever the
+// current mode is once it ends.
+func (e *Engine) AppendToolTurn(command string, observation string) *Engine {
+	e.mu.Lock()
+	conversation := e.getConversation(ExecEngineMode)
+	e.mu.Unlock()
+	conversation.Append(
+		NewTurn(ToolRole, command),
+		NewTurn(ObservationRole, observation),
+	)
+	return e
+}
+
+// GetUsage returns the models used during the session, along with their
+// requests, tokens, fallbacks and escalations.
+func (e *Engine) GetUsage() []ModelUsage {
+	return e.usage.GetModels()
+}
+
+// SetRecall sets the embedder and the store successful commands are recalled
+// from. A nil store disables recall.
+func (e *Engine) SetRecall(embedder Embedder, store *RecallStore) *Engine {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.embedder = embedder
+	e.recall = store
+	return e
+}
+
+// Remember indexes command, which ran successfully, under prompt, the one it
+// was suggested for, so it can be recalled for similar prompts.
+func (e *Engine) Remember(prompt string, command string) error {
+	e.mu.Lock()
+	embedder, store := e.embedder, e.recall
+	e.mu.Unlock()
+
+	if store == nil || embedder == nil || strings.TrimSpace(prompt) == "" || strings.TrimSpace(command) == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(e.ctx, 10*time.Second)
+	defer cancel()
+
+	vector, err := embedder.Embed(ctx, prompt)
+	if err != nil {
+		return fmt.Errorf("failed to embed prompt: %w", err)
+	}
+
+	return store.Add(RecallEntry{
+		Prompt:    prompt,
+		Command:   command,
+		Embedder:  embedder.GetName(),
+		Vector:    vector,
+		Timestamp: time.Now(),
+	})
+}
+
+// recallExamples returns the remembered commands most similar to input. Recall
+// is best effort: it returns nil on any error.
+func (e *Engine) recallExamples(ctx context.Context, input string) []RecallMatch {
+	e.mu.RLock()
+	embedder, store, mode := e.embedder, e.recall, e.mode
+	e.mu.RUnlock()
+
+	if store == nil || embedder == nil || mode != ExecEngineMode {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	vector, err := embedder.Embed(ctx, input)
+	if err != nil {
+		return nil
+	}
+	matches, err := store.Search(vector, embedder.GetName(), e.config.GetRecallConfig().GetExamples())
+	if err != nil {
+		return nil
+	}
+	return matches
+}
+
+func (e *Engine) getConversation(mode EngineMode) *Conversation {
+	conversation, ok := e.conversations[mode]
+	if !ok {
+		conversation = NewConversation(mode)
+		e.conversations[mode] = conversation
+	}
+	return conversation
+}
+
+// SetSession sets the session the commands run in, for the model to know
+// the working directory and the variables they start with.
+func (e *Engine) SetSession(session *run.Session) *Engine {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.session = session
+	return e
+}
+
+// SetDialects sets the dialects translate mode converts between. An unknown
+// source is detected by the model, an unknown target falls back to the
+// dialect of the user's shell.
+func (e *Engine) SetDialects(source run.Dialect, target run.Dialect) *Engine {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.source = source
+	e.target = target
+	e.setSystemInstruction()
+	return e
+}
+
+// GetTargetDialect returns the dialect translate mode converts to.
+func (e *Engine) GetTargetDialect() run.Dialect {
+	if e.target != run.UnknownDialect {
+		return e.target
+	}
+	if dialect := e.shellDialect(); dialect != run.UnknownDialect {
+		return dialect
+	}
+	return run.BashDialect
+}
+
+func (e *Engine) shellDialect() run.Dialect {
+	return e.config.GetExecutionShell().GetDialect()
+}
+
 func (e *Engine) setSystemInstruction() {
 	systemPrompt := e.prepareSystemPrompt()
-	e.model.SystemInstruction = &genai.Content{
+	e.chain.setSystemInstruction(&genai.Content{
 		Parts: []genai.Part{genai.Text(systemPrompt)},
 		Role:  "user", // System instructions should have "user" role
-	}
+	})
 }
 
 func (e *Engine) Interrupt() *Engine {
@@ -187,11 +399,7 @@ func (e *Engine) Clear() *Engine {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	
-	if e.mode == ExecEngineMode {
-		e.execMessages = make([]*genai.Content, 0)
-	} else {
-		e.chatMessages = make([]*genai.Content, 0)
-	}
+	e.getConversation(e.mode).Clear()
 	return e
 }
 
@@ -199,12 +407,31 @@ func (e *Engine) Reset() *Engine {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	
-	e.execMessages = make([]*genai.Content, 0)
-	e.chatMessages = make([]*genai.Content, 0)
+	for _, conversation := range e.conversations {
+		conversation.Clear()
+	}
 	return e
 }
 
 func (e *Engine) ExecCompletion(input string) (*EngineExecOutput, error) {
+	return e.completion(input)
+}
+
+// TranslateCompletion translates input to the target dialect and adds a
+// warning for each construct of the result the target shell cannot run.
+func (e *Engine) TranslateCompletion(input string) (*EngineExecOutput, error) {
+	output, err := e.completion(input)
+	if err != nil {
+		return nil, err
+	}
+
+	output.Executable = false
+	output.Warnings = append(output.Warnings, run.CheckDialect(output.Command, e.GetTargetDialect())...)
+
+	return output, nil
+}
+
+func (e *Engine) completion(input string) (*EngineExecOutput, error) {
 	// Use context with timeout
 	ctx, cancel := context.WithTimeout(e.ctx, 30*time.Second)
 	defer cancel()
@@ -219,42 +446,290 @@ func (e *Engine) ExecCompletion(input string) (*EngineExecOutput, error) {
 		e.mu.Unlock()
 	}()
 
+	examples := e.recallExamples(ctx, input)
+	e.mu.Lock()
+	e.examples = examples
+	e.mu.Unlock()
+
 	// Set system instruction before execution
 	e.setSystemInstruction()
-	e.appendUserMessage(input)
+	conversation := e.GetConversation()
 
-	cs := e.model.StartChat()
-	cs.History = e.prepareCompletionMessages()
+	model := e.routeModel(input)
+	answer, err := e.answer(ctx, conversation, input, model)
+	if err != nil {
+		return nil, err
+	}
 
-	// Retry logic for API calls
-	var resp *genai.GenerateContentResponse
-	var err error
-	
-	for retries := 0; retries < 3; retries++ {
-		resp, err = cs.SendMessage(ctx, genai.Text(input))
+	// Ask a stronger model when the answer cannot be used
+	if escalation := e.escalation(answer); escalation != "" {
+		escalated, err := e.answer(ctx, conversation, input, escalation)
 		if err == nil {
-			break
+			e.usage.escalate(escalated.model)
+			escalated.output.Escalated = true
+			escalated.output.EscalatedFrom = answer.output.Model
+			answer = escalated
 		}
-		
-		if retries < 2 {
-			time.Sleep(time.Second * time.Duration(retries+1))
+	}
+
+	// Ask again once for a command that does not parse
+	if e.GetMode() == ExecEngineMode && answer.output.Executable {
+		if syntaxErr := run.ValidateSyntax(answer.output.Command, e.shellDialect()); syntaxErr != nil {
+			answer = e.reprompt(ctx, conversation, input, answer, syntaxErr)
 		}
 	}
-	
+
+	output := answer.output
+	metadata := map[string]string{
+		"model":      output.Model,
+		"command":    output.Command,
+		"executable": fmt.Sprintf("%t", output.Executable),
+	}
+	if output.IsVoted() {
+		metadata["samples"] = fmt.Sprintf("%d", output.Samples)
+		metadata["agreement"] = fmt.Sprintf("%.2f", output.Agreement)
+	}
+	if output.Escalated {
+		metadata["escalated_from"] = output.EscalatedFrom
+	}
+	if output.Reprompted {
+		metadata["reprompted"] = "true"
+	}
+	if output.IsInvalid() {
+		metadata["syntax_error"] = output.SyntaxError
+	}
+
+	e.recordExchange(conversation, input, answer.content, answer.usage, metadata)
+	return &output, nil
+}
+
+// answer asks model, or the default model when empty, for an answer to
+// input. It samples several answers in parallel and returns the one they vote
+// for when voting is enabled, along with the tokens billed for all of them.
+func (e *Engine) answer(ctx context.Context, conversation *Conversation, input string, model string) (completionSample, error) {
+	count := 1
+	if e.GetMode() == ExecEngineMode && e.config.GetVotingConfig().GetSamples() > 1 {
+		count = e.config.GetVotingConfig().GetSamples()
+	}
+
+	samples := make([]completionSample, count)
+	var wg sync.WaitGroup
+	for i := range samples {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			samples[i] = e.sample(ctx, conversation, input, model)
+		}(i)
+	}
+	wg.Wait()
+
+	var outputs []EngineExecOutput
+	var succeeded []completionSample
+	usage := &genai.UsageMetadata{}
+	var err error
+	for _, sample := range samples {
+		if sample.err != nil {
+			err = sample.err
+			continue
+		}
+		outputs = append(outputs, sample.output)
+		succeeded = append(succeeded, sample)
+		if sample.usage != nil {
+			usage.PromptTokenCount += sample.usage.PromptTokenCount
+			usage.CandidatesTokenCount += sample.usage.CandidatesTokenCount
+		}
+	}
+	if len(succeeded) == 0 {
+		return completionSample{}, err
+	}
+
+	answer := succeeded[0]
+	if count > 1 {
+		var winner int
+		answer.output, winner = vote(outputs)
+		answer.content = succeeded[winner].content
+		answer.model = succeeded[winner].model
+		answer.parsed = succeeded[winner].parsed
+	}
+	answer.usage = usage
+	return answer, nil
+}
+
+// reprompt asks the model that gave answer, whose command is not valid shell
+// syntax, for another answer to input, telling it syntaxErr. The command is
+// marked invalid, and not executable, when the new one does not parse either.
+func (e *Engine) reprompt(ctx context.Context, conversation *Conversation, input string, answer completionSample, syntaxErr error) completionSample {
+	// The invalid answer is only told to the model, the conversation keeps the
+	// one that replaces it
+	retry := NewConversation(conversation.GetMode()).SetContext(conversation.GetContext())
+	retry.Append(conversation.GetTurns()...)
+	retry.Append(NewTurn(UserRole, input), NewTurn(ModelRole, answer.content))
+
+	correction := fmt.Sprintf(
+		"The command %s is not valid shell syntax: %s. Answer again with a command that parses, on a single line.",
+		answer.output.Command,
+		syntaxErr,
+	)
+	reprompted, err := e.answer(ctx, retry, correction, answer.model)
+	if err == nil && reprompted.parsed {
+		reprompted.output.Reprompted = true
+		reprompted.output.Escalated = answer.output.Escalated
+		reprompted.output.EscalatedFrom = answer.output.EscalatedFrom
+		answer = reprompted
+		syntaxErr = nil
+		if answer.output.Executable {
+			syntaxErr = run.ValidateSyntax(answer.output.Command, e.shellDialect())
+		}
+	}
+
+	if syntaxErr != nil {
+		answer.output.Executable = false
+		answer.output.SyntaxError = syntaxErr.Error()
+	}
+	return answer
+}
+
+// routeModel returns the model the routing rules pick for input, or an empty
+// string for the default model.
+func (e *Engine) routeModel(input string) string {
+	return route(e.config.GetRoutingConfig().GetRules(), routeRequest{
+		mode:    e.GetMode(),
+		prompt:  input,
+		context: e.GetConversation().GetContext() != "",
+	})
+}
+
+// escalation returns the stronger model to ask when answer could not be
+// parsed or, in exec mode, is not executable. It returns an empty string when
+// there is no need or no model to escalate to.
+func (e *Engine) escalation(answer completionSample) string {
+	escalation := e.config.GetRoutingConfig().GetEscalationModel()
+	if escalation == "" {
+		return ""
+	}
+	escalation = validateModelName(escalation)
+	if escalation == answer.model {
+		return ""
+	}
+
+	if !answer.parsed || (e.GetMode() == ExecEngineMode && !answer.output.Executable) {
+		return escalation
+	}
+	return ""
+}
+
+// completionSample is one answer to a completion request.
+type completionSample struct {
+	output  EngineExecOutput
+	content string
+	model   string
+	parsed  bool
+	usage   *genai.UsageMetadata
+	err     error
+}
+
+// sample sends input after the history of conversation, retrying on errors.
+func (e *Engine) sample(ctx context.Context, conversation *Conversation, input string, model string) completionSample {
+	resp, entry, err := e.send(ctx, model, func(model *genai.GenerativeModel) (*genai.GenerateContentResponse, error) {
+		cs := model.StartChat()
+		var parts []genai.Part
+		cs.History, parts = conversation.ToRequest(input)
+		return cs.SendMessage(ctx, parts...)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to send message to Gemini API after retries: %w", err)
+		return completionSample{err: err}
 	}
 
 	content := extractResponseContent(resp)
 	if content == "" {
-		return nil, errors.New("empty response from Gemini API")
+		return completionSample{err: errors.New("empty response from Gemini API")}
 	}
 	
-	e.appendAssistantMessage(content)
-
 	// Parse the response
-	output := parseExecOutput(content)
-	return &output, nil
+	output, parsed := parseExecOutput(content)
+	output.Model = entry.String()
+	output.Fallback = e.isFallback(entry, model)
+	e.usage.add(entry.name, resp.UsageMetadata, output.Fallback)
+	return completionSample{
+		output:  output,
+		content: content,
+		model:   entry.name,
+		parsed:  parsed,
+		usage:   resp.UsageMetadata,
+	}
+}
+
+// isFallback reports whether entry answered a request meant for model, or the
+// default model when empty.
+func (e *Engine) isFallback(entry *modelEntry, model string) bool {
+	if model == "" {
+		model = e.modelName
+	}
+	return entry.name != model || entry.keyIndex != 0
+}
+
+// send calls request with model, or the default model when empty, then with
+// the models of the fallback chain in turn, moving on when one is out of quota
+// or unavailable. It returns the answer along with the model that gave it.
+func (e *Engine) send(ctx context.Context, model string, request func(model *genai.GenerativeModel) (*genai.GenerateContentResponse, error)) (*genai.GenerateContentResponse, *modelEntry, error) {
+	var err error
+	for _, entry := range e.chain.available(model) {
+		// Retry logic for API calls
+		for retries := 0; retries < 3; retries++ {
+			var resp *genai.GenerateContentResponse
+			resp, err = request(entry.model)
+			if err == nil {
+				return resp, entry, nil
+			}
+			if isUnavailable(err) || ctx.Err() != nil {
+				break
+			}
+
+			if retries < 2 {
+				time.Sleep(time.Second * time.Duration(retries+1))
+			}
+		}
+
+		if ctx.Err() != nil || !isUnavailable(err) {
+			break
+		}
+		e.chain.coolDown(entry)
+	}
+
+	return nil, nil, fmt.Errorf("failed to send message to Gemini API after retries: %w", err)
+}
+
+// startStream starts streaming the answer to input, falling back like send
+// as long as nothing was received. It returns the function reading the
+// stream, along with the model answering.
+func (e *Engine) startStream(ctx context.Context, conversation *Conversation, input string, model string) (func() (*genai.GenerateContentResponse, error), *modelEntry, error) {
+	var err error
+	for _, entry := range e.chain.available(model) {
+		cs := entry.model.StartChat()
+		var parts []genai.Part
+		cs.History, parts = conversation.ToRequest(input)
+
+		iter := cs.SendMessageStream(ctx, parts...)
+		first, firstErr := iter.Next()
+		if firstErr == nil || firstErr == iterator.Done {
+			peeked := true
+			return func() (*genai.GenerateContentResponse, error) {
+				if peeked {
+					peeked = false
+					return first, firstErr
+				}
+				return iter.Next()
+			}, entry, nil
+		}
+
+		err = firstErr
+		if ctx.Err() != nil || !isUnavailable(err) {
+			break
+		}
+		e.chain.coolDown(entry)
+	}
+
+	return nil, nil, err
 }
 
 func (e *Engine) ChatStreamCompletion(input string) error {
@@ -272,13 +747,24 @@ func (e *Engine) ChatStreamCompletion(input string) error {
 	}()
 
 	e.setSystemInstruction()
-	e.appendUserMessage(input)
+	conversation := e.GetConversation()
 
-	cs := e.model.StartChat()
-	cs.History = e.prepareCompletionMessages()
+	model := e.routeModel(input)
+	next, entry, err := e.startStream(ctx, conversation, input, model)
+	if err != nil {
+		select {
+		case e.channel <- EngineChatStreamOutput{
+			content:    fmt.Sprintf("Stream error: %v", err),
+			last:       true,
+			executable: false,
+		}:
+		case <-ctx.Done():
+		}
+		return fmt.Errorf("failed to stream from Gemini API: %w", err)
+	}
 
-	iter := cs.SendMessageStream(ctx, genai.Text(input))
 	var output strings.Builder
+	var usage *genai.UsageMetadata
 
 	// Using the official SDK pattern
 	for {
@@ -290,7 +776,7 @@ func (e *Engine) ChatStreamCompletion(input string) error {
 			break
 		}
 		
-		resp, err := iter.Next()
+		resp, err := next()
 		
 		// Check for normal termination
 		if err == iterator.Done {
@@ -312,6 +798,9 @@ func (e *Engine) ChatStreamCompletion(input string) error {
 
 		// Process the response chunk
 		if resp != nil {
+			if resp.UsageMetadata != nil {
+				usage = resp.UsageMetadata
+			}
 			delta := extractResponseContent(resp)
 			if delta != "" {
 				output.WriteString(delta)
@@ -343,94 +832,75 @@ func (e *Engine) ChatStreamCompletion(input string) error {
 		content:    "",
 		last:       true,
 		executable: executable,
+		model:      entry.String(),
+		fallback:   e.isFallback(entry, model),
 	}:
 	case <-ctx.Done():
 		return ctx.Err()
 	}
-	
-	e.appendAssistantMessage(finalOutput)
-	return nil
-}
-
-
-func (e *Engine) appendUserMessage(content string) *Engine {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-	
-	msg := &genai.Content{
-		Parts: []genai.Part{genai.Text(content)}, 
-		Role: "user",
-	}
-	
-	if e.mode == ExecEngineMode {
-		e.execMessages = append(e.execMessages, msg)
-	} else {
-		e.chatMessages = append(e.chatMessages, msg)
-	}
-	return e
-}
 
-func (e *Engine) appendAssistantMessage(content string) *Engine {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-	
-	msg := &genai.Content{
-		Parts: []genai.Part{genai.Text(content)}, 
-		Role: "model",
-	}
+	e.usage.add(entry.name, usage, e.isFallback(entry, model))
 	
-	if e.mode == ExecEngineMode {
-		e.execMessages = append(e.execMessages, msg)
-	} else {
-		e.chatMessages = append(e.chatMessages, msg)
-	}
-	return e
+	e.recordExchange(conversation, input, finalOutput, usage, map[string]string{
+		"model": entry.String(),
+	})
+	return nil
 }
 
-func (e *Engine) prepareCompletionMessages() []*genai.Content {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
-	
-	messages := make([]*genai.Content, 0)
 
-	if e.pipe != "" {
-		messages = append(
-			messages,
-			&genai.Content{
-				Parts: []genai.Part{genai.Text(e.preparePipePrompt())}, 
-				Role: "user",
-			},
-		)
+// recordExchange appends the user input and the model answer to conversation,
+// along with the tokens billed for them.
+func (e *Engine) recordExchange(conversation *Conversation, input string, answer string, usage *genai.UsageMetadata, metadata map[string]string) {
+	userTurn := NewTurn(UserRole, input)
+	modelTurn := NewTurn(ModelRole, answer).WithMetadata("model", e.modelName)
+	for key, value := range metadata {
+		modelTurn = modelTurn.WithMetadata(key, value)
 	}
-
-	if e.mode == ExecEngineMode {
-		messages = append(messages, e.execMessages...)
-	} else {
-		messages = append(messages, e.chatMessages...)
+	if usage != nil {
+		userTurn = userTurn.WithTokens(int(usage.PromptTokenCount))
+		modelTurn = modelTurn.WithTokens(int(usage.CandidatesTokenCount))
 	}
 
-	return messages
-}
-
-func (e *Engine) preparePipePrompt() string {
-	return fmt.Sprintf("I will work on the following input: %s", e.pipe)
+	conversation.Append(userTurn, modelTurn)
 }
 
 func (e *Engine) prepareSystemPrompt() string {
 	var bodyPart string
-	if e.mode == ExecEngineMode {
+	switch e.mode {
+	case ExecEngineMode:
 		bodyPart = e.prepareSystemPromptExecPart()
-	} else {
+		if dialectPart := e.prepareSystemPromptDialectPart(); dialectPart != "" {
+			bodyPart = fmt.Sprintf("%s\n%s", bodyPart, dialectPart)
+		}
+		if recallPart := e.prepareSystemPromptRecallPart(); recallPart != "" {
+			bodyPart = fmt.Sprintf("%s\n%s", bodyPart, recallPart)
+		}
+	case TranslateEngineMode:
+		bodyPart = e.prepareSystemPromptTranslatePart()
+	default:
 		bodyPart = e.prepareSystemPromptChatPart()
 	}
 
 	contextPart := e.prepareSystemPromptContextPart()
 	if contextPart != "" {
-		return fmt.Sprintf("%s\n%s", bodyPart, contextPart)
+		bodyPart = fmt.Sprintf("%s\n%s", bodyPart, contextPart)
+	}
+
+	if pipePart := e.prepareSystemPromptPipePart(); pipePart != "" {
+		bodyPart = fmt.Sprintf("%s\n%s", bodyPart, pipePart)
 	}
 	return bodyPart
 }
 
+func (e *Engine) prepareSystemPromptPipePart() string {
+	conversation, ok := e.conversations[e.mode]
+	if !ok || conversation.GetContext() == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("\nThe user works on the following piped input:\n%s", conversation.GetContext())
+}
+
 func (e *Engine) prepareSystemPromptExecPart() string {
 	return `You are Xang, a powerful terminal assistant that generates executable commands.
 You MUST always respond with ONLY a JSON object in this exact format: {"cmd":"the command", "exp":"explanation", "exec":true}.
@@ -438,6 +908,8 @@ NEVER include any text before or after the JSON. NEVER add explanations outside
 The 'cmd' field contains a single-line shell command (use && or ; for multiple commands, never newlines).
 The 'exp' field contains a brief explanation of what the command does.
 The 'exec' field is true if the command can be executed, false otherwise.
+Add "root":true when the command needs root privileges, such as installing packages, managing system services or editing system files.
+NEVER add sudo, doas or pkexec to the command yourself, the right one is added when it needs root.
 If you cannot generate a valid command, set cmd to empty string and exec to false.
 
 Examples:
@@ -445,10 +917,64 @@ User: make a folder named test
 Response: {"cmd":"mkdir test", "exp":"creates a directory named test", "exec":true}
 User: list files
 Response: {"cmd":"ls -la", "exp":"lists all files with details", "exec":true}
+User: restart nginx
+Response: {"cmd":"systemctl restart nginx", "exp":"restarts the nginx service", "exec":true, "root":true}
 User: how are you
 Response: {"cmd":"", "exp":"I cannot generate a command for casual conversation. Use chat mode.", "exec":false}`
 }
 
+func (e *Engine) prepareSystemPromptDialectPart() string {
+	if !e.config.GetUserConfig().GetMatchShellDialect() {
+		return ""
+	}
+
+	dialect := e.shellDialect()
+	if dialect == run.UnknownDialect {
+		return ""
+	}
+
+	return fmt.Sprintf("The 'cmd' field MUST be valid %s syntax, since it runs in the user's %s shell.", dialect, dialect)
+}
+
+// prepareSystemPromptRecallPart turns the recalled commands into examples, so
+// the model picks up the user's own tools, flags and paths.
+func (e *Engine) prepareSystemPromptRecallPart() string {
+	if len(e.examples) == 0 {
+		return ""
+	}
+
+	var examples strings.Builder
+	examples.WriteString("\nCommands that worked for this user on similar requests, reuse their tools, flags and paths when they fit:")
+	for _, example := range e.examples {
+		examples.WriteString(fmt.Sprintf("\nUser: %s\nCommand: %s", example.Entry.Prompt, example.Entry.Command))
+	}
+
+	return examples.String()
+}
+
+func (e *Engine) prepareSystemPromptTranslatePart() string {
+	source := "whichever of bash, zsh, fish or POSIX sh it is written in"
+	if e.source != run.UnknownDialect {
+		source = e.source.String()
+	}
+	target := e.GetTargetDialect()
+
+	return fmt.Sprintf(`You are Xang, a powerful terminal assistant that translates shell commands between dialects.
+You MUST always respond with ONLY a JSON object in this exact format: {"cmd":"the translated command", "exp":"explanation", "warn":["warning"]}.
+NEVER include any text before or after the JSON. NEVER add explanations outside the JSON structure.
+The user gives you a command or short script written in %s, and you translate it to %s.
+The 'cmd' field contains the translation, keeping the structure and line breaks of the original.
+The 'exp' field briefly explains the notable changes.
+The 'warn' field lists each construct that has no equivalent in %s and how you approximated it, or is empty.
+If the input is not a shell command, set cmd to empty string and explain why in exp.
+
+Examples translating bash to fish:
+User: export EDITOR=vim
+Response: {"cmd":"set -gx EDITOR vim", "exp":"fish exports variables with set -gx", "warn":[]}
+User: set -e; make && make install
+Response: {"cmd":"make; and make install", "exp":"chains the commands with and", "warn":["fish has no errexit (set -e), failures are only handled by the and chain"]}`, source, target, target)
+}
+
 func (e *Engine) prepareSystemPromptChatPart() string {
 	return `You are Xang, a helpful and friendly terminal assistant created by github.com/Praatibh.
 You assist users with terminal commands, programming, system administration, and technical questions.
@@ -460,6 +986,9 @@ When discussing commands, always explain what they do and any important consider
 func (e *Engine) prepareSystemPromptContextPart() string {
 	var parts []string
 
+	if target := e.config.GetTarget(); len(target) > 0 {
+		parts = append(parts, fmt.Sprintf("Remote hosts the command runs on over ssh: %s", strings.Join(target, ", ")))
+	}
 	if os := e.config.GetSystemConfig().GetOperatingSystem(); os != system.UnknownOperatingSystem {
 		parts = append(parts, fmt.Sprintf("OS: %s", os.String()))
 	}
@@ -469,15 +998,19 @@ func (e *Engine) prepareSystemPromptContextPart() string {
 	if home := e.config.GetSystemConfig().GetHomeDirectory(); home != "" {
 		parts = append(parts, fmt.Sprintf("Home: %s", home))
 	}
-	if shell := e.config.GetSystemConfig().GetShell(); shell != "" {
+	if shell := e.config.GetExecutionShell().GetName(); shell != "" {
 		parts = append(parts, fmt.Sprintf("Shell: %s", shell))
 	}
 	if editor := e.config.GetSystemConfig().GetEditor(); editor != "" {
 		parts = append(parts, fmt.Sprintf("Editor: %s", editor))
 	}
+	if manager := e.config.GetSystemConfig().GetPackageManager(); manager != system.UnknownPackageManager {
+		parts = append(parts, fmt.Sprintf("Package manager: %s", manager))
+	}
 	if prefs := e.config.GetUserConfig().GetPreferences(); prefs != "" {
 		parts = append(parts, fmt.Sprintf("User preferences: %s", prefs))
 	}
+	parts = append(parts, e.prepareSystemPromptSessionParts()...)
 
 	if len(parts) == 0 {
 		return ""
@@ -486,6 +1019,34 @@ func (e *Engine) prepareSystemPromptContextPart() string {
 	return "\nSystem context: " + strings.Join(parts, ", ")
 }
 
+// prepareSystemPromptSessionParts describes the session the commands run in:
+// its working directory and the variables earlier commands exported or
+// unset, their values redacted and shortened.
+func (e *Engine) prepareSystemPromptSessionParts() []string {
+	if e.session == nil {
+		return nil
+	}
+
+	parts := []string{fmt.Sprintf("Working directory: %s", e.session.GetDirectory())}
+	changes := e.session.GetChanges()
+	if set := changes.GetSet(); len(set) > 0 {
+		variables := make([]string, 0, len(set))
+		for _, name := range set {
+			variable := history.Redact(fmt.Sprintf("%s=%s", name, e.session.Getenv(name)))
+			if len(variable) > len(name)+1+session_value_max_length {
+				variable = variable[:len(name)+1+session_value_max_length] + "…"
+			}
+			variables = append(variables, variable)
+		}
+		parts = append(parts, fmt.Sprintf("Variables exported by earlier commands: %s", strings.Join(variables, " ")))
+	}
+	if unset := changes.GetUnset(); len(unset) > 0 {
+		parts = append(parts, fmt.Sprintf("Variables unset by earlier commands: %s", strings.Join(unset, " ")))
+	}
+
+	return parts
+}
+
 // Helper function to extract content from response
 func extractResponseContent(resp *genai.GenerateContentResponse) string {
 	if resp == nil {
@@ -503,13 +1064,13 @@ func extractResponseContent(resp *genai.GenerateContentResponse) string {
 	return content.String()
 }
 
-// Helper function to parse exec output
-func parseExecOutput(content string) EngineExecOutput {
+// Helper function to parse exec output, reporting whether it is valid JSON
+func parseExecOutput(content string) (EngineExecOutput, bool) {
 	var output EngineExecOutput
 	
 	// Try direct JSON unmarshal first
 	if err := json.Unmarshal([]byte(content), &output); err == nil {
-		return output
+		return output, true
 	}
 	
 	// Try to extract JSON from response if it contains other text
@@ -518,7 +1079,7 @@ func parseExecOutput(content string) EngineExecOutput {
 	
 	for _, match := range matches {
 		if err := json.Unmarshal([]byte(match), &output); err == nil {
-			return output
+			return output, true
 		}
 	}
 	
@@ -527,5 +1088,5 @@ func parseExecOutput(content string) EngineExecOutput {
 		Command:     "",
 		Explanation: content,
 		Executable:  false,
-	}
+	}, false
 }
@@ -0,0 +1,21 @@
+package ai
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Praatibh/xang/run"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineSystemPromptSession(t *testing.T) {
+	engine := &Engine{conversations: make(map[EngineMode]*Conversation)}
+	assert.Empty(t, engine.prepareSystemPromptSessionParts(), "Without a session, nothing should be described.")
+
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	engine.SetSession(run.NewSession())
+
+	assert.Equal(t, []string{"Working directory: " + cwd}, engine.prepareSystemPromptSessionParts(), "The working directory of the session should be described.")
+}
@@ -1,16 +1,77 @@
 package ai
 
+import "fmt"
+
 type EngineMode int
 
 const (
 	ExecEngineMode EngineMode = iota
 	ChatEngineMode
+	TranslateEngineMode
 )
 
 func (m EngineMode) String() string {
-	if m == ExecEngineMode {
+	switch m {
+	case ExecEngineMode:
 		return "exec"
-	} else {
+	case TranslateEngineMode:
+		return "translate"
+	default:
 		return "chat"
 	}
-}
\ No newline at end of file
+}
+
+func GetEngineModeFromString(s string) EngineMode {
+	switch s {
+	case "exec":
+		return ExecEngineMode
+	case "translate":
+		return TranslateEngineMode
+	default:
+		return ChatEngineMode
+	}
+}
+
+type Role int
+
+const (
+	UserRole Role = iota
+	ModelRole
+	ToolRole
+	ObservationRole
+)
+
+func (r Role) String() string {
+	switch r {
+	case UserRole:
+		return "user"
+	case ModelRole:
+		return "model"
+	case ToolRole:
+		return "tool"
+	case ObservationRole:
+		return "observation"
+	default:
+		return "unknown"
+	}
+}
+
+func (r Role) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Role) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "user":
+		*r = UserRole
+	case "model":
+		*r = ModelRole
+	case "tool":
+		*r = ToolRole
+	case "observation":
+		*r = ObservationRole
+	default:
+		return fmt.Errorf("unknown role %q", text)
+	}
+	return nil
+}
@@ -22,6 +22,11 @@ func TestEngineModeString(t *testing.T) {
 			mode:     ChatEngineMode,
 			expected: "chat",
 		},
+		{
+			name:     "TranslateEngineMode",
+			mode:     TranslateEngineMode,
+			expected: "translate",
+		},
 		{
 			name:     "UnknownEngineMode",
 			mode:     EngineMode(42),
@@ -36,3 +41,54 @@ func TestEngineModeString(t *testing.T) {
 		})
 	}
 }
+
+func TestGetEngineModeFromString(t *testing.T) {
+	assert.Equal(t, ExecEngineMode, GetEngineModeFromString("exec"))
+	assert.Equal(t, TranslateEngineMode, GetEngineModeFromString("translate"))
+	assert.Equal(t, ChatEngineMode, GetEngineModeFromString("chat"))
+	assert.Equal(t, ChatEngineMode, GetEngineModeFromString("unknown"))
+}
+
+func TestRoleText(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     Role
+		expected string
+	}{
+		{
+			name:     "UserRole",
+			role:     UserRole,
+			expected: "user",
+		},
+		{
+			name:     "ModelRole",
+			role:     ModelRole,
+			expected: "model",
+		},
+		{
+			name:     "ToolRole",
+			role:     ToolRole,
+			expected: "tool",
+		},
+		{
+			name:     "ObservationRole",
+			role:     ObservationRole,
+			expected: "observation",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text, err := test.role.MarshalText()
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, string(text))
+
+			var role Role
+			assert.NoError(t, role.UnmarshalText(text))
+			assert.Equal(t, test.role, role)
+		})
+	}
+
+	var role Role
+	assert.Error(t, role.UnmarshalText([]byte("system")))
+}
@@ -0,0 +1,194 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/googleapis/gax-go/v2/apierror"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+)
+
+const default_fallback_cooldown = 60 * time.Second
+
+// modelEntry is a model of the fallback chain, reached with one of the API
+// keys.
+type modelEntry struct {
+	name     string
+	keyIndex int
+	model    *genai.GenerativeModel
+	until    time.Time
+}
+
+// String names the model, along with the key when it is not the main one.
+func (m *modelEntry) String() string {
+	if m.keyIndex == 0 {
+		return m.name
+	}
+
+	return fmt.Sprintf("%s, key %d", m.name, m.keyIndex+1)
+}
+
+// modelChain is the ordered list of models a request falls back on when a
+// model is out of quota or unavailable. A failing model is skipped until its
+// cooldown ends.
+type modelChain struct {
+	mu          sync.Mutex
+	client      *genai.Client
+	entries     []*modelEntry
+	routed      map[string]*modelEntry
+	instruction *genai.Content
+	cooldown    time.Duration
+	now         func() time.Time
+}
+
+// newModelChain returns the chain of entries. client, the one of the main
+// key, reaches the models requests are routed to outside of the chain.
+func newModelChain(client *genai.Client, entries []*modelEntry, cooldown time.Duration) *modelChain {
+	if cooldown <= 0 {
+		cooldown = default_fallback_cooldown
+	}
+
+	return &modelChain{
+		client:   client,
+		entries:  entries,
+		routed:   make(map[string]*modelEntry),
+		cooldown: cooldown,
+		now:      time.Now,
+	}
+}
+
+// primary returns the first entry: the configured model with the main key.
+func (c *modelChain) primary() *modelEntry {
+	return c.entries[0]
+}
+
+// available returns the entries to try in order, starting with the model
+// named preferred, if any, with the main key: those not cooling down, or when
+// all are, every entry by end of cooldown.
+func (c *modelChain) available(preferred string) []*modelEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	candidates := c.entries
+	if first := c.entry(preferred); first != nil && first != c.entries[0] {
+		candidates = []*modelEntry{first}
+		for _, entry := range c.entries {
+			if entry != first {
+				candidates = append(candidates, entry)
+			}
+		}
+	}
+
+	now := c.now()
+	var entries []*modelEntry
+	for _, entry := range candidates {
+		if !now.Before(entry.until) {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) > 0 {
+		return entries
+	}
+
+	entries = append(entries, candidates...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].until.Before(entries[j].until)
+	})
+	return entries
+}
+
+// entry returns the entry of the model named name with the main key, adding
+// it when the model is not part of the chain.
+func (c *modelChain) entry(name string) *modelEntry {
+	if name == "" {
+		return nil
+	}
+	for _, entry := range c.entries {
+		if entry.name == name && entry.keyIndex == 0 {
+			return entry
+		}
+	}
+	if entry, ok := c.routed[name]; ok {
+		return entry
+	}
+	if c.client == nil {
+		return nil
+	}
+
+	entry := &modelEntry{
+		name:  name,
+		model: newGenerativeModel(c.client, name),
+	}
+	entry.model.SystemInstruction = c.instruction
+	c.routed[name] = entry
+	return entry
+}
+
+// coolDown skips entry for the cooldown of the chain.
+func (c *modelChain) coolDown(entry *modelEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.until = c.now().Add(c.cooldown)
+}
+
+// setSystemInstruction sets the system instruction of every model.
+func (c *modelChain) setSystemInstruction(instruction *genai.Content) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.instruction = instruction
+	for _, entry := range c.entries {
+		entry.model.SystemInstruction = instruction
+	}
+	for _, entry := range c.routed {
+		entry.model.SystemInstruction = instruction
+	}
+}
+
+// isUnavailable reports whether err means the model is out of quota, overloaded
+// or down, so that another one should be tried.
+func isUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var apiErr *apierror.APIError
+	if errors.As(err, &apiErr) {
+		if isUnavailableStatus(apiErr.HTTPCode()) {
+			return true
+		}
+		if status := apiErr.GRPCStatus(); status != nil {
+			switch status.Code() {
+			case codes.ResourceExhausted, codes.Unavailable:
+				return true
+			}
+		}
+	}
+
+	var googleErr *googleapi.Error
+	if errors.As(err, &googleErr) && isUnavailableStatus(googleErr.Code) {
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, hint := range []string{"resource_exhausted", "quota", "overloaded", "unavailable", "rate limit"} {
+		if strings.Contains(message, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+func isUnavailableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
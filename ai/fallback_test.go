@@ -0,0 +1,146 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/googleapis/gax-go/v2/apierror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+func TestModelChain(t *testing.T) {
+	t.Run("Entries", testModelChainEntries)
+	t.Run("Cooldown", testModelChainCooldown)
+	t.Run("Preferred", testModelChainPreferred)
+	t.Run("Send", testModelChainSend)
+	t.Run("SendError", testModelChainSendError)
+}
+
+func newTestChain() (*modelChain, *time.Time) {
+	now := time.Date(2025, 9, 1, 12, 0, 0, 0, time.UTC)
+	chain := newModelChain(nil, []*modelEntry{
+		{name: "gemini-2.5-flash", model: &genai.GenerativeModel{}},
+		{name: "gemini-2.0-flash", model: &genai.GenerativeModel{}},
+		{name: "gemini-2.5-flash", keyIndex: 1, model: &genai.GenerativeModel{}},
+	}, time.Minute)
+	chain.now = func() time.Time { return now }
+	return chain, &now
+}
+
+func testModelChainEntries(t *testing.T) {
+	var clients []*genai.Client
+	for _, key := range []string{"main_key", "second_key"} {
+		client, err := genai.NewClient(context.Background(), option.WithAPIKey(key))
+		require.NoError(t, err)
+		defer client.Close()
+		clients = append(clients, client)
+	}
+
+	entries := newModelEntries(clients, "gemini-2.5-flash", []string{"gemini-2.0-flash", "gemini-2.5-flash"})
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.String())
+	}
+	assert.Equal(t, []string{"gemini-2.5-flash", "gemini-2.0-flash", "gemini-2.5-flash, key 2", "gemini-2.0-flash, key 2"}, names)
+}
+
+func testModelChainCooldown(t *testing.T) {
+	chain, now := newTestChain()
+	entries := chain.entries
+
+	chain.coolDown(entries[0])
+	assert.Equal(t, []*modelEntry{entries[1], entries[2]}, chain.available(""), "A cooling down entry should be skipped.")
+
+	*now = now.Add(30 * time.Second)
+	chain.coolDown(entries[2])
+	*now = now.Add(10 * time.Second)
+	chain.coolDown(entries[1])
+	assert.Equal(t, []*modelEntry{entries[0], entries[2], entries[1]}, chain.available(""), "Entries should be tried by end of cooldown when all cool down.")
+
+	*now = now.Add(time.Minute)
+	assert.Len(t, chain.available(""), 3, "Entries should come back after their cooldown.")
+}
+
+func testModelChainPreferred(t *testing.T) {
+	chain, _ := newTestChain()
+	client, err := genai.NewClient(context.Background(), option.WithAPIKey("main_key"))
+	require.NoError(t, err)
+	defer client.Close()
+	chain.client = client
+	chain.setSystemInstruction(genai.NewUserContent(genai.Text("instruction")))
+
+	entries := chain.available("gemini-2.0-flash")
+	assert.Equal(t, []*modelEntry{chain.entries[1], chain.entries[0], chain.entries[2]}, entries, "The preferred model should come first.")
+
+	entries = chain.available("gemini-2.5-pro")
+	require.Len(t, entries, 4)
+	assert.Equal(t, "gemini-2.5-pro", entries[0].name, "A model outside the chain should be added.")
+	assert.Equal(t, chain.instruction, entries[0].model.SystemInstruction)
+	assert.Same(t, entries[0], chain.available("gemini-2.5-pro")[0], "The added model should be reused.")
+}
+
+func testModelChainSend(t *testing.T) {
+	chain, _ := newTestChain()
+	engine := &Engine{chain: chain}
+
+	var tried []*genai.GenerativeModel
+	resp, entry, err := engine.send(context.Background(), "", func(model *genai.GenerativeModel) (*genai.GenerateContentResponse, error) {
+		tried = append(tried, model)
+		if model == chain.entries[0].model {
+			return nil, &googleapi.Error{Code: 429, Message: "quota exceeded"}
+		}
+		return &genai.GenerateContentResponse{}, nil
+	})
+
+	require.NoError(t, err)
+	assert.NotNil(t, resp)
+	assert.Equal(t, chain.entries[1], entry, "The next model should answer.")
+	assert.Len(t, tried, 2, "An unavailable model should not be retried.")
+	assert.Equal(t, chain.entries[1:], chain.available(""), "The unavailable model should cool down.")
+}
+
+func testModelChainSendError(t *testing.T) {
+	chain, _ := newTestChain()
+	engine := &Engine{chain: chain}
+
+	calls := 0
+	_, _, err := engine.send(context.Background(), "", func(model *genai.GenerativeModel) (*genai.GenerateContentResponse, error) {
+		calls++
+		return nil, &googleapi.Error{Code: 400, Message: "invalid argument"}
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls, "Other errors should be retried on the same model only.")
+	assert.Len(t, chain.available(""), 3, "No model should cool down.")
+}
+
+func TestIsUnavailable(t *testing.T) {
+	apiErr, _ := apierror.FromError(&googleapi.Error{Code: 503, Message: "The model is overloaded."})
+
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"Nil", nil, false},
+		{"TooManyRequests", &googleapi.Error{Code: 429}, true},
+		{"APIError", apiErr, true},
+		{"Wrapped", errors.Join(errors.New("sending"), &googleapi.Error{Code: 503}), true},
+		{"Message", errors.New("rpc error: code = ResourceExhausted desc = RESOURCE_EXHAUSTED"), true},
+		{"BadRequest", &googleapi.Error{Code: 400, Message: "invalid argument"}, false},
+		{"Other", errors.New("connection refused"), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, isUnavailable(tc.err))
+		})
+	}
+}
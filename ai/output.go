@@ -1,9 +1,29 @@
 package ai
 
 type EngineExecOutput struct {
-	Command     string `json:"cmd"`
-	Explanation string `json:"exp"`
-	Executable  bool   `json:"exec"`
+	Command     string   `json:"cmd"`
+	Explanation string   `json:"exp"`
+	Executable  bool     `json:"exec"`
+	Warnings    []string `json:"warn,omitempty"`
+	// Root is set when the model says the command needs root privileges.
+	Root bool `json:"root,omitempty"`
+	// Samples, Agreement and Candidates are set when the command won a vote
+	// among several sampled answers.
+	Samples    int      `json:"-"`
+	Agreement  float64  `json:"-"`
+	Candidates []string `json:"-"`
+	// Model is the model that answered, Fallback is set when it is not the
+	// configured one.
+	Model    string `json:"-"`
+	Fallback bool   `json:"-"`
+	// Escalated is set when the model answered after EscalatedFrom gave an
+	// answer that could not be used.
+	Escalated     bool   `json:"-"`
+	EscalatedFrom string `json:"-"`
+	// Reprompted is set when the model was asked again because its command
+	// was not valid shell syntax, SyntaxError when the command still is not.
+	Reprompted  bool   `json:"-"`
+	SyntaxError string `json:"-"`
 }
 
 func (eo EngineExecOutput) GetCommand() string {
@@ -18,11 +38,78 @@ func (eo EngineExecOutput) IsExecutable() bool {
 	return eo.Executable
 }
 
+func (eo EngineExecOutput) GetWarnings() []string {
+	return eo.Warnings
+}
+
+// NeedsRoot reports whether the model says the command needs root
+// privileges.
+func (eo EngineExecOutput) NeedsRoot() bool {
+	return eo.Root
+}
+
+// IsVoted reports whether the command was chosen among several samples.
+func (eo EngineExecOutput) IsVoted() bool {
+	return eo.Samples > 1
+}
+
+func (eo EngineExecOutput) GetSamples() int {
+	return eo.Samples
+}
+
+// GetAgreement returns the share of samples that agreed on the command.
+func (eo EngineExecOutput) GetAgreement() float64 {
+	return eo.Agreement
+}
+
+func (eo EngineExecOutput) GetModel() string {
+	return eo.Model
+}
+
+// IsFallback reports whether a fallback model answered.
+func (eo EngineExecOutput) IsFallback() bool {
+	return eo.Fallback
+}
+
+// IsEscalated reports whether a stronger model answered after the answer of
+// the routed one could not be used.
+func (eo EngineExecOutput) IsEscalated() bool {
+	return eo.Escalated
+}
+
+func (eo EngineExecOutput) GetEscalatedFrom() string {
+	return eo.EscalatedFrom
+}
+
+// GetCandidates returns the commands the other samples proposed, most
+// popular first.
+func (eo EngineExecOutput) GetCandidates() []string {
+	return eo.Candidates
+}
+
+// IsReprompted reports whether the model was asked again for a command that
+// parses.
+func (eo EngineExecOutput) IsReprompted() bool {
+	return eo.Reprompted
+}
+
+// IsInvalid reports whether the command is not valid shell syntax, which
+// makes it not executable.
+func (eo EngineExecOutput) IsInvalid() bool {
+	return eo.SyntaxError != ""
+}
+
+func (eo EngineExecOutput) GetSyntaxError() string {
+	return eo.SyntaxError
+}
+
 type EngineChatStreamOutput struct {
 	content    string
 	last       bool
 	interrupt  bool
 	executable bool
+	model      string
+	fallback   bool
 }
 
 func (co EngineChatStreamOutput) GetContent() string {
@@ -40,3 +127,14 @@ func (co EngineChatStreamOutput) IsInterrupt() bool {
 func (co EngineChatStreamOutput) IsExecutable() bool {
 	return co.executable
 }
+
+// GetModel returns the model that answered, set on the last output.
+func (co EngineChatStreamOutput) GetModel() string {
+	return co.model
+}
+
+// IsFallback reports whether a fallback model answered, set on the last
+// output.
+func (co EngineChatStreamOutput) IsFallback() bool {
+	return co.fallback
+}
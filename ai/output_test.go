@@ -27,6 +27,36 @@ func TestEngineExecOutputIsExecutable(t *testing.T) {
 	assert.True(t, result)
 }
 
+func TestEngineExecOutputGetWarnings(t *testing.T) {
+	eo := EngineExecOutput{Warnings: []string{"testWarning"}}
+	result := eo.GetWarnings()
+
+	assert.Equal(t, []string{"testWarning"}, result)
+}
+
+func TestEngineExecOutputIsVoted(t *testing.T) {
+	assert.False(t, EngineExecOutput{}.IsVoted())
+	assert.False(t, EngineExecOutput{Samples: 1}.IsVoted())
+	assert.True(t, EngineExecOutput{Samples: 3}.IsVoted())
+}
+
+func TestEngineExecOutputGetAgreement(t *testing.T) {
+	eo := EngineExecOutput{Samples: 4, Agreement: 0.75, Candidates: []string{"testCandidate"}}
+
+	assert.Equal(t, 4, eo.GetSamples())
+	assert.Equal(t, 0.75, eo.GetAgreement())
+	assert.Equal(t, []string{"testCandidate"}, eo.GetCandidates())
+}
+
+func TestEngineExecOutputIsInvalid(t *testing.T) {
+	eo := EngineExecOutput{Reprompted: true, SyntaxError: "1:6: reached EOF without closing quote '"}
+
+	assert.False(t, EngineExecOutput{}.IsInvalid())
+	assert.True(t, eo.IsInvalid())
+	assert.True(t, eo.IsReprompted())
+	assert.Equal(t, "1:6: reached EOF without closing quote '", eo.GetSyntaxError())
+}
+
 func TestEngineChatStreamOutputGetContent(t *testing.T) {
 	co := EngineChatStreamOutput{content: "testContent"}
 	result := co.GetContent()
@@ -0,0 +1,150 @@
+package ai
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// recall_min_similarity is the similarity below which a past command is not
+// considered related to the prompt.
+const recall_min_similarity = 0.5
+
+// RecallEntry is a command that ran successfully, along with the prompt it was
+// generated from.
+type RecallEntry struct {
+	Prompt    string    `json:"prompt"`
+	Command   string    `json:"cmd"`
+	Embedder  string    `json:"embedder"`
+	Vector    []float32 `json:"vector"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// RecallMatch is an entry found similar to a prompt.
+type RecallMatch struct {
+	Entry      RecallEntry
+	Similarity float64
+}
+
+// RecallStore is a vector store kept in a JSONL file, one entry per line.
+type RecallStore struct {
+	mu      sync.Mutex
+	path    string
+	entries []RecallEntry
+	loaded  bool
+}
+
+func NewRecallStore(path string) *RecallStore {
+	return &RecallStore{path: path}
+}
+
+func (s *RecallStore) GetPath() string {
+	return s.path
+}
+
+// Add appends entry to the store, unless the same prompt already led to the
+// same command.
+func (s *RecallStore) Add(entry RecallEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	for _, existing := range s.entries {
+		if existing.Embedder == entry.Embedder &&
+			strings.EqualFold(strings.TrimSpace(existing.Prompt), strings.TrimSpace(entry.Prompt)) &&
+			existing.Command == entry.Command {
+			return nil
+		}
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create recall directory: %w", err)
+	}
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open recall store: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write recall store: %w", err)
+	}
+
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+// Search returns up to limit entries of the embedder most similar to vector,
+// most similar first.
+func (s *RecallStore) Search(vector []float32, embedder string, limit int) ([]RecallMatch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	var matches []RecallMatch
+	for _, entry := range s.entries {
+		if entry.Embedder != embedder {
+			continue
+		}
+		if similarity := cosineSimilarity(vector, entry.Vector); similarity >= recall_min_similarity {
+			matches = append(matches, RecallMatch{Entry: entry, Similarity: similarity})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Similarity > matches[j].Similarity
+	})
+	if limit < 0 {
+		limit = 0
+	}
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// load reads the store file once, skipping the lines it cannot decode.
+func (s *RecallStore) load() error {
+	if s.loaded {
+		return nil
+	}
+
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		s.loaded = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open recall store: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry RecallEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil {
+			s.entries = append(s.entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read recall store: %w", err)
+	}
+
+	s.loaded = true
+	return nil
+}
@@ -0,0 +1,119 @@
+package ai
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecallStore(t *testing.T) {
+	t.Run("Search", testRecallStoreSearch)
+	t.Run("Persist", testRecallStorePersist)
+	t.Run("Dedupe", testRecallStoreDedupe)
+}
+
+func TestEngineRecall(t *testing.T) {
+	t.Run("Remember", testEngineRemember)
+	t.Run("SystemPrompt", testEngineRecallSystemPrompt)
+	t.Run("ToolTurn", testEngineToolTurn)
+}
+
+func addRecallEntry(t *testing.T, store *RecallStore, embedder Embedder, prompt string, command string) {
+	vector, err := embedder.Embed(context.Background(), prompt)
+	require.NoError(t, err)
+	require.NoError(t, store.Add(RecallEntry{Prompt: prompt, Command: command, Embedder: embedder.GetName(), Vector: vector}))
+}
+
+func testRecallStoreSearch(t *testing.T) {
+	embedder := NewLocalEmbedder(local_embedding_size)
+	store := NewRecallStore(filepath.Join(t.TempDir(), "recall.jsonl"))
+	addRecallEntry(t, store, embedder, "deploy the staging cluster", "kubectl --context stg apply -k deploy/")
+	addRecallEntry(t, store, embedder, "compress old log files", "find /var/log -mtime +7 -exec gzip {} +")
+
+	vector, _ := embedder.Embed(context.Background(), "deploy staging")
+	matches, err := store.Search(vector, embedder.GetName(), 3)
+
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "kubectl --context stg apply -k deploy/", matches[0].Entry.Command)
+
+	matches, err = store.Search(vector, "other", 3)
+	require.NoError(t, err)
+	assert.Empty(t, matches, "Vectors of other embedders should not be compared.")
+
+	matches, err = store.Search(vector, embedder.GetName(), -1)
+	require.NoError(t, err)
+	assert.Empty(t, matches, "A negative limit should recall nothing.")
+}
+
+func testRecallStorePersist(t *testing.T) {
+	embedder := NewLocalEmbedder(local_embedding_size)
+	path := filepath.Join(t.TempDir(), "data", "recall.jsonl")
+	addRecallEntry(t, NewRecallStore(path), embedder, "deploy the staging cluster", "make deploy-stg")
+
+	vector, _ := embedder.Embed(context.Background(), "deploy the staging cluster")
+	matches, err := NewRecallStore(path).Search(vector, embedder.GetName(), 3)
+
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "make deploy-stg", matches[0].Entry.Command)
+}
+
+func testRecallStoreDedupe(t *testing.T) {
+	embedder := NewLocalEmbedder(local_embedding_size)
+	store := NewRecallStore(filepath.Join(t.TempDir(), "recall.jsonl"))
+	addRecallEntry(t, store, embedder, "deploy the staging cluster", "make deploy-stg")
+	addRecallEntry(t, store, embedder, "Deploy the staging cluster ", "make deploy-stg")
+
+	vector, _ := embedder.Embed(context.Background(), "deploy the staging cluster")
+	matches, err := store.Search(vector, embedder.GetName(), 3)
+
+	require.NoError(t, err)
+	assert.Len(t, matches, 1)
+}
+
+func newRecallTestEngine(t *testing.T) *Engine {
+	engine := &Engine{
+		mode:          ExecEngineMode,
+		conversations: make(map[EngineMode]*Conversation),
+		ctx:           context.Background(),
+	}
+	return engine.SetRecall(NewLocalEmbedder(local_embedding_size), NewRecallStore(filepath.Join(t.TempDir(), "recall.jsonl")))
+}
+
+func testEngineRemember(t *testing.T) {
+	engine := newRecallTestEngine(t)
+	require.NoError(t, engine.Remember("", "make deploy-stg"), "Nothing should be remembered without a prompt.")
+
+	engine.GetConversation().Append(NewTurn(UserRole, "list the pods"), NewTurn(ModelRole, "{}"))
+	require.NoError(t, engine.Remember("deploy the staging cluster", "make deploy-stg"))
+
+	vector, _ := engine.embedder.Embed(context.Background(), "deploy the staging cluster")
+	matches, err := engine.recall.Search(vector, engine.embedder.GetName(), 3)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "deploy the staging cluster", matches[0].Entry.Prompt, "The command should be remembered under its own prompt, not the last one.")
+	assert.Equal(t, "make deploy-stg", matches[0].Entry.Command)
+}
+
+func testEngineToolTurn(t *testing.T) {
+	engine := newRecallTestEngine(t)
+	engine.mode = ChatEngineMode
+
+	engine.AppendToolTurn("make deploy-stg", "exit status 0")
+
+	assert.Equal(t, 2, engine.getConversation(ExecEngineMode).Len(), "The tool turn should go to the exec conversation.")
+	assert.Equal(t, 0, engine.getConversation(ChatEngineMode).Len(), "The current conversation should be left alone.")
+}
+
+func testEngineRecallSystemPrompt(t *testing.T) {
+	engine := newRecallTestEngine(t)
+	assert.Empty(t, engine.prepareSystemPromptRecallPart())
+
+	engine.examples = []RecallMatch{{Entry: RecallEntry{Prompt: "deploy staging", Command: "make deploy-stg && echo ok"}}}
+
+	assert.Contains(t, engine.prepareSystemPromptRecallPart(), "User: deploy staging\nCommand: make deploy-stg && echo ok")
+}
@@ -0,0 +1,62 @@
+package ai
+
+import (
+	"strings"
+
+	"github.com/Praatibh/xang/config"
+)
+
+// routeRequest is what routing rules look at to pick a model.
+type routeRequest struct {
+	mode    EngineMode
+	prompt  string
+	context bool
+}
+
+// route returns the model of the first rule matching request, or an empty
+// string when none does.
+func route(rules []config.RoutingRule, request routeRequest) string {
+	for _, rule := range rules {
+		if rule.Model != "" && matchRule(rule, request) {
+			return validateModelName(rule.Model)
+		}
+	}
+
+	return ""
+}
+
+func matchRule(rule config.RoutingRule, request routeRequest) bool {
+	if rule.Mode != "" && GetEngineModeFromString(strings.ToLower(rule.Mode)) != request.mode {
+		return false
+	}
+
+	length := len([]rune(request.prompt))
+	if rule.MinLength > 0 && length < rule.MinLength {
+		return false
+	}
+	if rule.MaxLength > 0 && length > rule.MaxLength {
+		return false
+	}
+
+	switch strings.ToLower(rule.Context) {
+	case "yes", "true":
+		if !request.context {
+			return false
+		}
+	case "no", "false":
+		if request.context {
+			return false
+		}
+	}
+
+	if len(rule.Keywords) == 0 {
+		return true
+	}
+	prompt := strings.ToLower(request.prompt)
+	for _, keyword := range rule.Keywords {
+		if keyword != "" && strings.Contains(prompt, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
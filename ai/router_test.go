@@ -0,0 +1,42 @@
+package ai
+
+import (
+	"testing"
+
+	"github.com/Praatibh/xang/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoute(t *testing.T) {
+	rules := []config.RoutingRule{
+		{Mode: "exec", Keywords: []string{"kubectl", "terraform"}, Model: "gemini-2.5-pro"},
+		{Mode: "exec", MaxLength: 40, Context: "no", Model: "gemini-2.5-flash-lite"},
+		{Mode: "chat", MinLength: 200, Model: "gemini-2.5-pro"},
+		{Context: "yes", Model: "gemini-2.0-flash"},
+	}
+
+	testCases := []struct {
+		name     string
+		request  routeRequest
+		expected string
+	}{
+		{"Keyword", routeRequest{mode: ExecEngineMode, prompt: "scale the Kubectl deployment"}, "gemini-2.5-pro"},
+		{"Short", routeRequest{mode: ExecEngineMode, prompt: "list files"}, "gemini-2.5-flash-lite"},
+		{"ShortWithContext", routeRequest{mode: ExecEngineMode, prompt: "sort these", context: true}, "gemini-2.0-flash"},
+		{"LongChat", routeRequest{mode: ChatEngineMode, prompt: string(make([]rune, 250))}, "gemini-2.5-pro"},
+		{"NoMatch", routeRequest{mode: ChatEngineMode, prompt: "hello"}, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, route(rules, tc.request))
+		})
+	}
+}
+
+func TestRouteWithoutModel(t *testing.T) {
+	rules := []config.RoutingRule{{Mode: "exec"}}
+
+	assert.Equal(t, "", route(rules, routeRequest{mode: ExecEngineMode, prompt: "list files"}), "Rules without a model should be ignored.")
+}
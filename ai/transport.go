@@ -0,0 +1,103 @@
+package ai
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/Praatibh/xang/config"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+)
+
+// newClient returns a Gemini client for key that goes through the endpoint,
+// proxy, CA bundle and headers of apiConfig.
+func newClient(ctx context.Context, key string, apiConfig config.ApiConfig) (*genai.Client, error) {
+	httpClient, err := newHTTPClient(key, apiConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []option.ClientOption{option.WithAPIKey(key), option.WithHTTPClient(httpClient)}
+	if endpoint := apiConfig.GetEndpoint(); endpoint != "" {
+		options = append(options, option.WithEndpoint(endpoint))
+	}
+
+	return genai.NewClient(ctx, options...)
+}
+
+// newHTTPClient returns the HTTP client API calls made with key go through.
+// The API key is set by the client since a custom one bypasses the
+// authentication of the SDK.
+func newHTTPClient(key string, apiConfig config.ApiConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	transport.Proxy = http.ProxyFromEnvironment
+	if proxy := apiConfig.GetProxy(); proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid API proxy %q: %w", proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if bundle := apiConfig.GetCaBundle(); bundle != "" {
+		pool, err := loadCaBundle(bundle)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &http.Client{
+		Transport: &headerTransport{
+			base:    transport,
+			key:     key,
+			headers: apiConfig.GetHeaders(),
+		},
+	}, nil
+}
+
+// loadCaBundle returns the system certificates along with those of the PEM
+// file at path.
+func loadCaBundle(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in CA bundle %s", path)
+	}
+
+	return pool, nil
+}
+
+// headerTransport sets the API key and the configured headers on every
+// request.
+type headerTransport struct {
+	base    http.RoundTripper
+	key     string
+	headers map[string]string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+	if t.key != "" {
+		req.Header.Set("x-goog-api-key", t.key)
+	}
+
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,122 @@
+package ai
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Praatibh/xang/config"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransport(t *testing.T) {
+	t.Run("NewClient", testNewClient)
+	t.Run("NewClientCaBundle", testNewClientCaBundle)
+	t.Run("NewHTTPClientProxy", testNewHTTPClientProxy)
+	t.Run("LoadCaBundleInvalid", testLoadCaBundleInvalid)
+}
+
+// newTestApiConfig returns the api config read from a config file holding
+// settings, in a temporary home.
+func newTestApiConfig(t *testing.T, settings string) config.ApiConfig {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	homedir.Reset()
+	t.Cleanup(homedir.Reset)
+	require.NoError(t, os.MkdirAll(filepath.Join(home, ".config"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".config", "xang.json"), []byte(settings), 0600))
+
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	cfg, err := config.NewConfig()
+	require.NoError(t, err)
+
+	return cfg.GetApiConfig()
+}
+
+// newTestGeminiServer returns a stand-in for the Gemini API answering text,
+// recording the last request it got.
+func newTestGeminiServer(text string, last **http.Request) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*last = r
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"candidates":[{"content":{"role":"model","parts":[{"text":"` + text + `"}]}}]}`))
+	})
+}
+
+func testNewClient(t *testing.T) {
+	var request *http.Request
+	server := httptest.NewServer(newTestGeminiServer("pong", &request))
+	defer server.Close()
+
+	t.Setenv("XANG_API_ENDPOINT", server.URL)
+	apiConfig := newTestApiConfig(t, `{"api_headers": {"X-Team": "infra"}}`)
+
+	client, err := newClient(context.Background(), "test-key", apiConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	resp, err := client.GenerativeModel("gemini-2.5-flash").GenerateContent(context.Background(), genai.Text("ping"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "pong", extractResponseContent(resp), "The answer of the stand-in should be returned.")
+	require.NotNil(t, request, "The stand-in should be called.")
+	assert.Contains(t, request.URL.Path, "gemini-2.5-flash:generateContent", "The model should be called on the endpoint.")
+	assert.Equal(t, "test-key", request.Header.Get("x-goog-api-key"), "The API key should be sent.")
+	assert.Equal(t, "infra", request.Header.Get("X-Team"), "The configured header should be sent.")
+}
+
+func testNewClientCaBundle(t *testing.T) {
+	var request *http.Request
+	server := httptest.NewTLSServer(newTestGeminiServer("pong", &request))
+	defer server.Close()
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(bundle, certificate, 0600))
+
+	apiConfig := newTestApiConfig(t, `{"api_endpoint": "`+server.URL+`", "api_ca_bundle": "`+bundle+`"}`)
+
+	client, err := newClient(context.Background(), "test-key", apiConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.GenerativeModel("gemini-2.5-flash").GenerateContent(context.Background(), genai.Text("ping"))
+
+	assert.NoError(t, err, "The certificate of the CA bundle should be trusted.")
+}
+
+func testNewHTTPClientProxy(t *testing.T) {
+	apiConfig := newTestApiConfig(t, `{"api_proxy": "http://proxy.example.com:3128"}`)
+
+	httpClient, err := newHTTPClient("test-key", apiConfig)
+	require.NoError(t, err)
+
+	transport := httpClient.Transport.(*headerTransport).base.(*http.Transport)
+	request, _ := http.NewRequest(http.MethodGet, "https://generativelanguage.googleapis.com", nil)
+	proxy, err := transport.Proxy(request)
+
+	require.NoError(t, err)
+	assert.Equal(t, "http://proxy.example.com:3128", proxy.String(), "The configured proxy should be used.")
+}
+
+func testLoadCaBundleInvalid(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(bundle, []byte("not a certificate"), 0600))
+
+	_, err := loadCaBundle(bundle)
+	assert.Error(t, err, "A bundle without certificates should be rejected.")
+
+	_, err = loadCaBundle(filepath.Join(t.TempDir(), "missing.pem"))
+	assert.Error(t, err, "A missing bundle should be rejected.")
+}
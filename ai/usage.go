@@ -0,0 +1,75 @@
+package ai
+
+import (
+	"sync"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// ModelUsage sums up the requests answered by a model.
+type ModelUsage struct {
+	Model            string
+	Requests         int
+	PromptTokens     int
+	CandidatesTokens int
+	// Fallbacks counts the requests the model answered for another one,
+	// Escalations those it was asked after a weaker model failed.
+	Fallbacks   int
+	Escalations int
+}
+
+// Usage keeps track of the models the engine used during the session.
+type Usage struct {
+	mu     sync.Mutex
+	models map[string]*ModelUsage
+	order  []string
+}
+
+func NewUsage() *Usage {
+	return &Usage{models: make(map[string]*ModelUsage)}
+}
+
+// GetModels returns the usage of each model, in order of first use.
+func (u *Usage) GetModels() []ModelUsage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	models := make([]ModelUsage, 0, len(u.order))
+	for _, name := range u.order {
+		models = append(models, *u.models[name])
+	}
+	return models
+}
+
+// add records a request answered by model.
+func (u *Usage) add(model string, usage *genai.UsageMetadata, fallback bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	entry := u.get(model)
+	entry.Requests++
+	if usage != nil {
+		entry.PromptTokens += int(usage.PromptTokenCount)
+		entry.CandidatesTokens += int(usage.CandidatesTokenCount)
+	}
+	if fallback {
+		entry.Fallbacks++
+	}
+}
+
+// escalate records that a request was escalated to model.
+func (u *Usage) escalate(model string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.get(model).Escalations++
+}
+
+func (u *Usage) get(model string) *ModelUsage {
+	entry, ok := u.models[model]
+	if !ok {
+		entry = &ModelUsage{Model: model}
+		u.models[model] = entry
+		u.order = append(u.order, model)
+	}
+	return entry
+}
@@ -0,0 +1,21 @@
+package ai
+
+import (
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsage(t *testing.T) {
+	usage := NewUsage()
+	usage.add("gemini-2.5-flash-lite", &genai.UsageMetadata{PromptTokenCount: 100, CandidatesTokenCount: 20}, false)
+	usage.add("gemini-2.5-pro", &genai.UsageMetadata{PromptTokenCount: 110, CandidatesTokenCount: 30}, false)
+	usage.escalate("gemini-2.5-pro")
+	usage.add("gemini-2.5-flash-lite", nil, true)
+
+	assert.Equal(t, []ModelUsage{
+		{Model: "gemini-2.5-flash-lite", Requests: 2, PromptTokens: 100, CandidatesTokens: 20, Fallbacks: 1},
+		{Model: "gemini-2.5-pro", Requests: 1, PromptTokens: 110, CandidatesTokens: 30, Escalations: 1},
+	}, usage.GetModels())
+}
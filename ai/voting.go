@@ -0,0 +1,57 @@
+package ai
+
+import (
+	"sort"
+
+	"github.com/Praatibh/xang/run"
+)
+
+// vote groups outputs by equivalent command and returns the first output of
+// the largest group, along with how much the samples agreed, and its index.
+// Ties go to the group sampled first, non-executable outputs all count as the
+// same answer.
+func vote(outputs []EngineExecOutput) (EngineExecOutput, int) {
+	if len(outputs) == 0 {
+		return EngineExecOutput{}, -1
+	}
+
+	type group struct {
+		output EngineExecOutput
+		index  int
+		count  int
+	}
+
+	var groups []*group
+	index := make(map[string]*group)
+	for i, output := range outputs {
+		key := ""
+		if output.Executable && output.Command != "" {
+			key = run.NormalizeCommand(output.Command)
+		}
+		if g, ok := index[key]; ok {
+			g.count++
+			continue
+		}
+		g := &group{output: output, index: i, count: 1}
+		index[key] = g
+		groups = append(groups, g)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].count > groups[j].count
+	})
+
+	winner := groups[0].output
+	winner.Samples = len(outputs)
+	winner.Agreement = float64(groups[0].count) / float64(len(outputs))
+	winner.Candidates = nil
+	for _, g := range groups[1:] {
+		candidate := g.output.Command
+		if !g.output.Executable || candidate == "" {
+			candidate = noexec
+		}
+		winner.Candidates = append(winner.Candidates, candidate)
+	}
+
+	return winner, groups[0].index
+}
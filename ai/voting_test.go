@@ -0,0 +1,57 @@
+package ai
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVote(t *testing.T) {
+	t.Run("Majority", testVoteMajority)
+	t.Run("Tie", testVoteTie)
+	t.Run("NotExecutable", testVoteNotExecutable)
+}
+
+func testVoteMajority(t *testing.T) {
+	outputs := []EngineExecOutput{
+		{Command: "rsync -a src/ dst/", Executable: true},
+		{Command: "rsync -a --delete src/ dst/", Executable: true},
+		{Command: `rsync  -a "src/" dst/`, Executable: true},
+		{Command: "cp -r src/. dst/", Executable: true},
+	}
+
+	output, index := vote(outputs)
+
+	assert.Equal(t, "rsync -a src/ dst/", output.GetCommand())
+	assert.Equal(t, 0, index)
+	assert.Equal(t, 4, output.GetSamples())
+	assert.Equal(t, 0.5, output.GetAgreement())
+	assert.Equal(t, []string{"rsync -a --delete src/ dst/", "cp -r src/. dst/"}, output.GetCandidates())
+}
+
+func testVoteTie(t *testing.T) {
+	outputs := []EngineExecOutput{
+		{Command: "ls -la", Executable: true},
+		{Command: "ls -al", Executable: true},
+	}
+
+	output, index := vote(outputs)
+
+	assert.Equal(t, "ls -la", output.GetCommand(), "Ties should go to the first sample.")
+	assert.Equal(t, 0, index)
+	assert.Equal(t, 0.5, output.GetAgreement())
+}
+
+func testVoteNotExecutable(t *testing.T) {
+	outputs := []EngineExecOutput{
+		{Command: "ls", Executable: true},
+		{Explanation: "use chat mode", Executable: false},
+		{Explanation: "I cannot do that", Executable: false},
+	}
+
+	output, index := vote(outputs)
+
+	assert.False(t, output.IsExecutable())
+	assert.Equal(t, 1, index)
+	assert.Equal(t, []string{"ls"}, output.GetCandidates())
+}
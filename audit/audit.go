@@ -0,0 +1,243 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Praatibh/xang/system"
+)
+
+// Entry is a step in the life of a command offered by the model: generated,
+// then blocked, cancelled or confirmed, and once confirmed executed or
+// failed. The entries of a command share its Id.
+type Entry struct {
+	Id          string    `json:"id"`
+	Time        time.Time `json:"time"`
+	Status      Status    `json:"status"`
+	Prompt      string    `json:"prompt,omitempty"`
+	Model       string    `json:"model,omitempty"`
+	Command     string    `json:"command"`
+	Suggestion  string    `json:"suggestion,omitempty"`
+	Explanation string    `json:"explanation,omitempty"`
+	Edited      bool      `json:"edited,omitempty"`
+	Background  bool      `json:"background,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	Target      []string  `json:"target,omitempty"`
+	Root        bool      `json:"root,omitempty"`
+	ExitCode    *int      `json:"exit_code,omitempty"`
+	DurationMs  int64     `json:"duration_ms,omitempty"`
+	User        string    `json:"user"`
+	Host        string    `json:"host"`
+	Cwd         string    `json:"cwd"`
+}
+
+// NewId returns a random id for the entries of a command.
+func NewId() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(id)
+}
+
+// Filter selects entries: the ones since Since and until Until when they are
+// set, with one of Statuses when there are some, and containing Text in their
+// prompt, command or explanation, regardless of case, when it is set.
+type Filter struct {
+	Since    time.Time
+	Until    time.Time
+	Statuses []Status
+	Text     string
+}
+
+// Matches reports whether entry is selected by the filter.
+func (f Filter) Matches(entry Entry) bool {
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && entry.Time.After(f.Until) {
+		return false
+	}
+	if len(f.Statuses) > 0 {
+		matched := false
+		for _, status := range f.Statuses {
+			matched = matched || entry.Status == status
+		}
+		if !matched {
+			return false
+		}
+	}
+	if f.Text != "" {
+		text := strings.ToLower(f.Text)
+		return strings.Contains(strings.ToLower(entry.Prompt), text) ||
+			strings.Contains(strings.ToLower(entry.Command), text) ||
+			strings.Contains(strings.ToLower(entry.Explanation), text)
+	}
+
+	return true
+}
+
+// Log is an append-only JSONL audit log, one entry per line. Once it would go
+// over its size limit, it is rotated to path.1, the previous ones moving to
+// path.2 and so on up to its number of backups.
+type Log struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	forward    io.Writer
+}
+
+// NewLog returns the log kept in path, rotated once over maxSize bytes, a zero
+// value setting no bound, and keeping maxBackups rotated files. At least one
+// is kept, for rotating never to delete the entries just written.
+func NewLog(path string, maxSize int64, maxBackups int) *Log {
+	return &Log{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: max(maxBackups, 1),
+	}
+}
+
+func (l *Log) GetPath() string {
+	return l.path
+}
+
+// ForwardToSyslog sends the entries to the system logger as well.
+func (l *Log) ForwardToSyslog() error {
+	writer, err := newSyslogWriter()
+	if err != nil {
+		return fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	l.forward = writer
+
+	return nil
+}
+
+// Write appends entry to the log, filling its time, user, host and working
+// directory when they are not set.
+func (l *Log) Write(entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if entry.User == "" {
+		entry.User = system.GetUsername()
+	}
+	if entry.Host == "" {
+		entry.Host, _ = os.Hostname()
+	}
+	if entry.Cwd == "" {
+		entry.Cwd, _ = os.Getwd()
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return fmt.Errorf("failed to create the audit log directory: %w", err)
+	}
+	if err := l.rotate(int64(len(line))); err != nil {
+		return fmt.Errorf("failed to rotate the audit log: %w", err)
+	}
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open the audit log: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(line); err != nil {
+		return fmt.Errorf("failed to write the audit log: %w", err)
+	}
+
+	if l.forward != nil {
+		if _, err := l.forward.Write(line); err != nil {
+			return fmt.Errorf("failed to forward to syslog: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// rotate moves the log to its first backup when writing size more bytes
+// would take it over its limit.
+func (l *Log) rotate(size int64) error {
+	if l.maxSize <= 0 {
+		return nil
+	}
+	info, err := os.Stat(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 || info.Size()+size <= l.maxSize {
+		return nil
+	}
+
+	os.Remove(l.backupPath(l.maxBackups))
+	for i := l.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(l.backupPath(i), l.backupPath(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return os.Rename(l.path, l.backupPath(1))
+}
+
+func (l *Log) backupPath(i int) string {
+	return fmt.Sprintf("%s.%d", l.path, i)
+}
+
+// Query returns the entries of the log and of its backups that filter
+// selects, the oldest first. Lines that can't be read are skipped.
+func (l *Log) Query(filter Filter) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var entries []Entry
+	for i := l.maxBackups; i >= 0; i-- {
+		path := l.path
+		if i > 0 {
+			path = l.backupPath(i)
+		}
+
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to open the audit log: %w", err)
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var entry Entry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil && filter.Matches(entry) {
+				entries = append(entries, entry)
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the audit log: %w", err)
+		}
+	}
+
+	return entries, nil
+}
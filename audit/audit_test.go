@@ -0,0 +1,122 @@
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog(t *testing.T) {
+	t.Run("Write", testLogWrite)
+	t.Run("Rotate", testLogRotate)
+	t.Run("RotateWithoutBackups", testLogRotateWithoutBackups)
+	t.Run("Forward", testLogForward)
+	t.Run("Query", testLogQuery)
+}
+
+func testLogWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log := NewLog(path, 0, 0)
+	exitCode := 0
+
+	require.NoError(t, log.Write(Entry{Id: "1", Status: ExecutedStatus, Command: "ls", ExitCode: &exitCode, DurationMs: 12}))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `"status":"executed"`, "The status should be written by name.")
+	assert.Contains(t, string(content), `"exit_code":0`, "A zero exit code should be written.")
+	cwd, _ := os.Getwd()
+	entries, err := log.Query(Filter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, cwd, entries[0].Cwd, "The working directory should be filled.")
+	assert.False(t, entries[0].Time.IsZero(), "The time should be filled.")
+	assert.NotEmpty(t, entries[0].Host, "The host should be filled.")
+}
+
+func testLogRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log := NewLog(path, 500, 2)
+
+	for i := 0; i < 6; i++ {
+		require.NoError(t, log.Write(Entry{Id: NewId(), Status: GeneratedStatus, Command: strings.Repeat("x", 100), User: "u", Host: "h", Cwd: "/"}))
+	}
+
+	assert.FileExists(t, path+".1")
+	assert.FileExists(t, path+".2")
+	assert.NoFileExists(t, path+".3", "Only the configured number of backups should be kept.")
+	for _, file := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(file)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, info.Size(), int64(500), "No file should go over the size limit.")
+	}
+	entries, err := log.Query(Filter{})
+	require.NoError(t, err)
+	assert.Len(t, entries, 6, "The entries of the backups should be queried.")
+}
+
+func testLogRotateWithoutBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log := NewLog(path, 200, 0)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, log.Write(Entry{Id: NewId(), Status: GeneratedStatus, Command: strings.Repeat("x", 100), User: "u", Host: "h", Cwd: "/"}))
+	}
+
+	entries, err := log.Query(Filter{})
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "Rotating should keep the entries in a backup.")
+}
+
+func testLogForward(t *testing.T) {
+	log := NewLog(filepath.Join(t.TempDir(), "audit.jsonl"), 0, 0)
+	var forwarded bytes.Buffer
+	log.forward = &forwarded
+
+	require.NoError(t, log.Write(Entry{Id: "1", Status: CancelledStatus, Command: "rm -rf build"}))
+
+	assert.Contains(t, forwarded.String(), `"status":"cancelled"`, "The entry should be forwarded.")
+}
+
+func testLogQuery(t *testing.T) {
+	log := NewLog(filepath.Join(t.TempDir(), "audit.jsonl"), 0, 0)
+	day := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for _, entry := range []Entry{
+		{Id: "1", Time: day, Status: GeneratedStatus, Prompt: "clean the build", Command: "rm -rf build"},
+		{Id: "1", Time: day.Add(time.Minute), Status: CancelledStatus, Prompt: "clean the build", Command: "rm -rf build"},
+		{Id: "2", Time: day.AddDate(0, 0, 2), Status: ExecutedStatus, Prompt: "list pods", Command: "kubectl get pods"},
+	} {
+		require.NoError(t, log.Write(entry))
+	}
+
+	testCases := []struct {
+		name     string
+		filter   Filter
+		expected []Status
+	}{
+		{"All", Filter{}, []Status{GeneratedStatus, CancelledStatus, ExecutedStatus}},
+		{"Since", Filter{Since: day.AddDate(0, 0, 1)}, []Status{ExecutedStatus}},
+		{"Until", Filter{Until: day}, []Status{GeneratedStatus}},
+		{"Status", Filter{Statuses: []Status{CancelledStatus, ExecutedStatus}}, []Status{CancelledStatus, ExecutedStatus}},
+		{"Text", Filter{Text: "BUILD"}, []Status{GeneratedStatus, CancelledStatus}},
+		{"Combined", Filter{Text: "kubectl", Statuses: []Status{CancelledStatus}}, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, err := log.Query(tc.filter)
+			require.NoError(t, err)
+			var statuses []Status
+			for _, entry := range entries {
+				statuses = append(statuses, entry.Status)
+			}
+			assert.Equal(t, tc.expected, statuses)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Status is what became of a command offered by the model.
+type Status int
+
+const (
+	GeneratedStatus Status = iota
+	BlockedStatus
+	CancelledStatus
+	ConfirmedStatus
+	ExecutedStatus
+	FailedStatus
+)
+
+func (s Status) String() string {
+	switch s {
+	case BlockedStatus:
+		return "blocked"
+	case CancelledStatus:
+		return "cancelled"
+	case ConfirmedStatus:
+		return "confirmed"
+	case ExecutedStatus:
+		return "executed"
+	case FailedStatus:
+		return "failed"
+	default:
+		return "generated"
+	}
+}
+
+// ParseStatus returns the status named s, such as "cancelled".
+func ParseStatus(s string) (Status, error) {
+	for status := GeneratedStatus; status <= FailedStatus; status++ {
+		if strings.EqualFold(strings.TrimSpace(s), status.String()) {
+			return status, nil
+		}
+	}
+
+	return GeneratedStatus, fmt.Errorf("unknown audit status %q", s)
+}
+
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Status) UnmarshalText(text []byte) error {
+	status, err := ParseStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*s = status
+
+	return nil
+}
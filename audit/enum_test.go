@@ -0,0 +1,42 @@
+package audit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus(t *testing.T) {
+	t.Run("String", testStatusString)
+	t.Run("ParseStatus", testParseStatus)
+	t.Run("Text", testStatusText)
+}
+
+func testStatusString(t *testing.T) {
+	assert.Equal(t, "generated", GeneratedStatus.String())
+	assert.Equal(t, "blocked", BlockedStatus.String())
+	assert.Equal(t, "cancelled", CancelledStatus.String())
+	assert.Equal(t, "confirmed", ConfirmedStatus.String())
+	assert.Equal(t, "executed", ExecutedStatus.String())
+	assert.Equal(t, "failed", FailedStatus.String())
+}
+
+func testParseStatus(t *testing.T) {
+	status, err := ParseStatus(" Cancelled ")
+	assert.NoError(t, err)
+	assert.Equal(t, CancelledStatus, status, "The statuses should be the same.")
+
+	_, err = ParseStatus("skipped")
+	assert.Error(t, err, "An unknown status should be an error.")
+}
+
+func testStatusText(t *testing.T) {
+	text, err := FailedStatus.MarshalText()
+	assert.NoError(t, err)
+	assert.Equal(t, "failed", string(text))
+
+	var status Status
+	assert.NoError(t, status.UnmarshalText([]byte("executed")))
+	assert.Equal(t, ExecutedStatus, status, "The statuses should be the same.")
+	assert.Error(t, status.UnmarshalText([]byte("skipped")), "An unknown status should be an error.")
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package audit
+
+import (
+	"fmt"
+	"io"
+)
+
+// newSyslogWriter returns an error, there being no system logger on this
+// platform.
+func newSyslogWriter() (io.Writer, error) {
+	return nil, fmt.Errorf("syslog is not supported on this platform")
+}
@@ -1,13 +1,21 @@
 package config
 
+import "time"
+
 const (
-	gemini_key         = "GEMINI_KEY"
-	gemini_model       = "GEMINI_MODEL"
+	gemini_key               = "GEMINI_KEY"
+	gemini_model             = "GEMINI_MODEL"
+	gemini_fallback_models   = "GEMINI_FALLBACK_MODELS"
+	gemini_fallback_keys     = "GEMINI_FALLBACK_KEYS"
+	gemini_fallback_cooldown = "GEMINI_FALLBACK_COOLDOWN"
 )
 
 type AiConfig struct {
-	key         string
-	model       string
+	key              string
+	model            string
+	fallbackModels   []string
+	fallbackKeys     []string
+	fallbackCooldown time.Duration
 }
 
 func (c AiConfig) GetKey() string {
@@ -16,4 +24,21 @@ func (c AiConfig) GetKey() string {
 
 func (c AiConfig) GetModel() string {
 	return c.model
-}
\ No newline at end of file
+}
+
+// GetFallbackModels returns the models tried in order when the model is out
+// of quota or unavailable.
+func (c AiConfig) GetFallbackModels() []string {
+	return c.fallbackModels
+}
+
+// GetFallbackKeys returns the API keys tried in order when the key is out of
+// quota.
+func (c AiConfig) GetFallbackKeys() []string {
+	return c.fallbackKeys
+}
+
+// GetFallbackCooldown returns how long a failing model is skipped.
+func (c AiConfig) GetFallbackCooldown() time.Duration {
+	return c.fallbackCooldown
+}
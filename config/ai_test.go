@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -9,6 +10,9 @@ import (
 func TestAiConfig(t *testing.T) {
 	t.Run("GetKey", testGetKey)
 	t.Run("GetModel", testGetModel)
+	t.Run("GetFallbackModels", testGetFallbackModels)
+	t.Run("GetFallbackKeys", testGetFallbackKeys)
+	t.Run("GetFallbackCooldown", testGetFallbackCooldown)
 }
 
 func testGetKey(t *testing.T) {
@@ -27,4 +31,30 @@ func testGetModel(t *testing.T) {
 	actualModel := aiConfig.GetModel()
 
 	assert.Equal(t, expectedModel, actualModel, "The two models should be the same.")
-}
\ No newline at end of file
+}
+func testGetFallbackModels(t *testing.T) {
+	expectedModels := []string{"gemini-2.5-flash-lite", "gemini-2.0-flash"}
+	aiConfig := AiConfig{fallbackModels: expectedModels}
+
+	actualModels := aiConfig.GetFallbackModels()
+
+	assert.Equal(t, expectedModels, actualModels, "The two fallback models should be the same.")
+}
+
+func testGetFallbackKeys(t *testing.T) {
+	expectedKeys := []string{"second_key"}
+	aiConfig := AiConfig{fallbackKeys: expectedKeys}
+
+	actualKeys := aiConfig.GetFallbackKeys()
+
+	assert.Equal(t, expectedKeys, actualKeys, "The two fallback keys should be the same.")
+}
+
+func testGetFallbackCooldown(t *testing.T) {
+	expectedCooldown := 90 * time.Second
+	aiConfig := AiConfig{fallbackCooldown: expectedCooldown}
+
+	actualCooldown := aiConfig.GetFallbackCooldown()
+
+	assert.Equal(t, expectedCooldown, actualCooldown, "The two fallback cooldowns should be the same.")
+}
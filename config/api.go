@@ -0,0 +1,41 @@
+package config
+
+const (
+	api_endpoint  = "API_ENDPOINT"
+	api_proxy     = "API_PROXY"
+	api_ca_bundle = "API_CA_BUNDLE"
+	api_headers   = "API_HEADERS"
+)
+
+// api_env_prefix prefixes the environment variables overriding the api keys.
+const api_env_prefix = "XANG_"
+
+type ApiConfig struct {
+	endpoint string
+	proxy    string
+	caBundle string
+	headers  map[string]string
+}
+
+// GetEndpoint returns the base URL of the API, such as a gateway or a local
+// stand-in, or an empty string for the default one.
+func (c ApiConfig) GetEndpoint() string {
+	return c.endpoint
+}
+
+// GetProxy returns the URL of the proxy API calls go through. When empty the
+// HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables apply.
+func (c ApiConfig) GetProxy() string {
+	return c.proxy
+}
+
+// GetCaBundle returns the path of a PEM file of certificates trusted on top
+// of the system ones.
+func (c ApiConfig) GetCaBundle() string {
+	return c.caBundle
+}
+
+// GetHeaders returns the headers added to every API call.
+func (c ApiConfig) GetHeaders() map[string]string {
+	return c.headers
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiConfig(t *testing.T) {
+	t.Run("GetEndpoint", testGetEndpoint)
+	t.Run("GetProxy", testGetProxy)
+	t.Run("GetCaBundle", testGetCaBundle)
+	t.Run("GetHeaders", testGetHeaders)
+}
+
+func testGetEndpoint(t *testing.T) {
+	expectedEndpoint := "https://gateway.example.com"
+	apiConfig := ApiConfig{endpoint: expectedEndpoint}
+
+	actualEndpoint := apiConfig.GetEndpoint()
+
+	assert.Equal(t, expectedEndpoint, actualEndpoint, "The two endpoints should be the same.")
+}
+
+func testGetProxy(t *testing.T) {
+	expectedProxy := "http://proxy.example.com:3128"
+	apiConfig := ApiConfig{proxy: expectedProxy}
+
+	actualProxy := apiConfig.GetProxy()
+
+	assert.Equal(t, expectedProxy, actualProxy, "The two proxies should be the same.")
+}
+
+func testGetCaBundle(t *testing.T) {
+	expectedCaBundle := "/etc/ssl/corporate.pem"
+	apiConfig := ApiConfig{caBundle: expectedCaBundle}
+
+	actualCaBundle := apiConfig.GetCaBundle()
+
+	assert.Equal(t, expectedCaBundle, actualCaBundle, "The two CA bundles should be the same.")
+}
+
+func testGetHeaders(t *testing.T) {
+	expectedHeaders := map[string]string{"x-team": "infra"}
+	apiConfig := ApiConfig{headers: expectedHeaders}
+
+	actualHeaders := apiConfig.GetHeaders()
+
+	assert.Equal(t, expectedHeaders, actualHeaders, "The two header sets should be the same.")
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Praatibh/xang/run"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	audit_enabled     = "AUDIT_ENABLED"
+	audit_path        = "AUDIT_PATH"
+	audit_max_size    = "AUDIT_MAX_SIZE"
+	audit_max_backups = "AUDIT_MAX_BACKUPS"
+	audit_syslog      = "AUDIT_SYSLOG"
+)
+
+type AuditConfig struct {
+	enabled    bool
+	path       string
+	maxSize    int64
+	maxBackups int
+	syslog     bool
+}
+
+// IsEnabled reports whether the commands offered by the model, and what
+// became of them, are written to the audit log.
+func (c AuditConfig) IsEnabled() bool {
+	return c.enabled
+}
+
+// GetPath returns the path of the audit log, empty for the default one.
+func (c AuditConfig) GetPath() string {
+	return c.path
+}
+
+// GetMaxSize returns the size the audit log is rotated at, none being no
+// bound.
+func (c AuditConfig) GetMaxSize() int64 {
+	return c.maxSize
+}
+
+// GetMaxBackups returns how many rotated audit logs are kept, the log keeping
+// at least one.
+func (c AuditConfig) GetMaxBackups() int {
+	return c.maxBackups
+}
+
+// GetSyslog reports whether the audit entries are sent to syslog as well.
+func (c AuditConfig) GetSyslog() bool {
+	return c.syslog
+}
+
+// readAuditConfig reads the settings of the audit log.
+func readAuditConfig() (AuditConfig, error) {
+	config := AuditConfig{
+		enabled:    viper.GetBool(audit_enabled),
+		path:       viper.GetString(audit_path),
+		maxBackups: viper.GetInt(audit_max_backups),
+		syslog:     viper.GetBool(audit_syslog),
+	}
+
+	var err error
+	if config.maxSize, err = run.ParseSize(viper.GetString(audit_max_size)); err != nil {
+		return config, fmt.Errorf("invalid %s: %w", strings.ToLower(audit_max_size), err)
+	}
+	if config.maxBackups < 0 {
+		return config, fmt.Errorf("invalid %s: %d, expected zero or more", strings.ToLower(audit_max_backups), config.maxBackups)
+	}
+
+	return config, nil
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditConfig(t *testing.T) {
+	t.Run("IsEnabled", testAuditIsEnabled)
+	t.Run("GetPath", testAuditGetPath)
+	t.Run("GetMaxSize", testAuditGetMaxSize)
+	t.Run("GetMaxBackups", testAuditGetMaxBackups)
+	t.Run("GetSyslog", testAuditGetSyslog)
+	t.Run("ReadMaxBackups", testAuditReadMaxBackups)
+}
+
+func testAuditIsEnabled(t *testing.T) {
+	auditConfig := AuditConfig{enabled: true}
+
+	assert.True(t, auditConfig.IsEnabled(), "Audit should be enabled.")
+}
+
+func testAuditGetPath(t *testing.T) {
+	expectedPath := "/var/log/xang/audit.jsonl"
+	auditConfig := AuditConfig{path: expectedPath}
+
+	actualPath := auditConfig.GetPath()
+
+	assert.Equal(t, expectedPath, actualPath, "The two paths should be the same.")
+}
+
+func testAuditGetMaxSize(t *testing.T) {
+	expectedMaxSize := int64(10 << 20)
+	auditConfig := AuditConfig{maxSize: expectedMaxSize}
+
+	actualMaxSize := auditConfig.GetMaxSize()
+
+	assert.Equal(t, expectedMaxSize, actualMaxSize, "The two maximum sizes should be the same.")
+}
+
+func testAuditGetMaxBackups(t *testing.T) {
+	expectedMaxBackups := 3
+	auditConfig := AuditConfig{maxBackups: expectedMaxBackups}
+
+	actualMaxBackups := auditConfig.GetMaxBackups()
+
+	assert.Equal(t, expectedMaxBackups, actualMaxBackups, "The two numbers of backups should be the same.")
+}
+
+func testAuditGetSyslog(t *testing.T) {
+	auditConfig := AuditConfig{syslog: true}
+
+	assert.True(t, auditConfig.GetSyslog(), "Syslog forwarding should be enabled.")
+}
+
+func testAuditReadMaxBackups(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set(audit_max_size, "10M")
+
+	viper.Set(audit_max_backups, 2)
+	auditConfig, err := readAuditConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, auditConfig.GetMaxBackups(), "The number of backups should be read.")
+
+	viper.Set(audit_max_backups, -1)
+	_, err = readAuditConfig()
+	assert.Error(t, err, "A negative number of backups should be rejected.")
+}
@@ -3,29 +3,101 @@ package config
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/Praatibh/xang/run"
 	"github.com/Praatibh/xang/system"
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	ai     AiConfig
-	user   UserConfig
-	system *system.Analysis
+	ai      AiConfig
+	api     ApiConfig
+	user    UserConfig
+	run     RunConfig
+	recall  RecallConfig
+	voting  VotingConfig
+	routing RoutingConfig
+	undo    UndoConfig
+	audit   AuditConfig
+	history HistoryConfig
+	remote  RemoteConfig
+	system  *system.Analysis
+	target  []string
 }
 
 func (c *Config) GetAiConfig() AiConfig {
 	return c.ai
 }
 
+func (c *Config) GetApiConfig() ApiConfig {
+	return c.api
+}
+
 func (c *Config) GetUserConfig() UserConfig {
 	return c.user
 }
 
+func (c *Config) GetRunConfig() RunConfig {
+	return c.run
+}
+
+func (c *Config) GetRecallConfig() RecallConfig {
+	return c.recall
+}
+
+func (c *Config) GetVotingConfig() VotingConfig {
+	return c.voting
+}
+
+func (c *Config) GetRoutingConfig() RoutingConfig {
+	return c.routing
+}
+
+func (c *Config) GetUndoConfig() UndoConfig {
+	return c.undo
+}
+
+func (c *Config) GetAuditConfig() AuditConfig {
+	return c.audit
+}
+
+func (c *Config) GetHistoryConfig() HistoryConfig {
+	return c.history
+}
+
+func (c *Config) GetRemoteConfig() RemoteConfig {
+	return c.remote
+}
+
 func (c *Config) GetSystemConfig() *system.Analysis {
 	return c.system
 }
 
+// SetTarget makes hosts the ones generated commands run on, the system being
+// the one of analysis.
+func (c *Config) SetTarget(hosts []string, analysis *system.Analysis) {
+	c.target = hosts
+	c.system = analysis
+}
+
+// GetTarget returns the hosts generated commands run on, none for the local
+// system.
+func (c *Config) GetTarget() []string {
+	return c.target
+}
+
+// GetExecutionShell returns the shell generated commands run in: the
+// configured override, else the detected shell. On a remote host, it is the
+// login shell of the user.
+func (c *Config) GetExecutionShell() run.Shell {
+	if c.system.GetHost() != "" {
+		return run.NewShell(c.system.GetShell(), false)
+	}
+
+	return run.ResolveShell(c.system.GetShell(), c.run.GetShell(), c.run.GetInteractiveShell())
+}
+
 func NewConfig() (*Config, error) {
 	system := system.Analyse()
 
@@ -36,14 +108,100 @@ func NewConfig() (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	// the environment overrides the api settings of the file
+	for _, key := range []string{api_endpoint, api_proxy, api_ca_bundle, api_headers} {
+		if err := viper.BindEnv(key, api_env_prefix+key); err != nil {
+			return nil, fmt.Errorf("failed to bind %s: %w", key, err)
+		}
+	}
+
+	var routingRules []RoutingRule
+	if err := viper.UnmarshalKey(routing_rules, &routingRules); err != nil {
+		return nil, fmt.Errorf("failed to read routing rules: %w", err)
+	}
+
+	var riskRules []run.RiskRule
+	if err := viper.UnmarshalKey(run_risk_rules, &riskRules); err != nil {
+		return nil, fmt.Errorf("failed to read risk rules: %w", err)
+	}
+	for i, rule := range riskRules {
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid risk rule %d: %w", i+1, err)
+		}
+	}
+
+	limits, err := readRunLimits()
+	if err != nil {
+		return nil, err
+	}
+
+	elevationTool, err := readElevationTool()
+	if err != nil {
+		return nil, err
+	}
+
+	recall, err := readRecallConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	undo, err := readUndoConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	audit, err := readAuditConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	return &Config{
 		ai: AiConfig{
-			key:   viper.GetString(gemini_key),
-			model: viper.GetString(gemini_model),
+			key:              viper.GetString(gemini_key),
+			model:            viper.GetString(gemini_model),
+			fallbackModels:   viper.GetStringSlice(gemini_fallback_models),
+			fallbackKeys:     viper.GetStringSlice(gemini_fallback_keys),
+			fallbackCooldown: time.Duration(viper.GetInt(gemini_fallback_cooldown)) * time.Second,
+		},
+		api: ApiConfig{
+			endpoint: viper.GetString(api_endpoint),
+			proxy:    viper.GetString(api_proxy),
+			caBundle: viper.GetString(api_ca_bundle),
+			headers:  viper.GetStringMapString(api_headers),
 		},
 		user: UserConfig{
 			defaultPromptMode: viper.GetString(user_default_prompt_mode),
 			preferences:       viper.GetString(user_preferences),
+			matchShellDialect: viper.GetBool(user_match_shell_dialect),
+		},
+		run: RunConfig{
+			shell:            viper.GetString(run_shell),
+			interactiveShell: viper.GetBool(run_interactive_shell),
+			outputLimit:      viper.GetInt(run_output_limit),
+			riskRules:        riskRules,
+			limits:           limits,
+			elevationTool:    elevationTool,
+			forbidElevation:  viper.GetBool(run_forbid_elevation),
+		},
+		recall: recall,
+		voting: VotingConfig{
+			samples:      viper.GetInt(voting_samples),
+			minAgreement: viper.GetFloat64(voting_min_agreement),
+		},
+		routing: RoutingConfig{
+			rules:           routingRules,
+			escalationModel: viper.GetString(routing_escalation_model),
+		},
+		undo:  undo,
+		audit: audit,
+		history: HistoryConfig{
+			enabled: viper.GetBool(history_enabled),
+			path:    viper.GetString(history_path),
+			maxSize: viper.GetInt(history_max_size),
+		},
+		remote: RemoteConfig{
+			groups:   viper.GetStringMapStringSlice(remote_groups),
+			parallel: viper.GetInt(remote_parallel),
 		},
 		system: system,
 	}, nil
@@ -59,10 +217,67 @@ func WriteConfig(key string, write bool) (*Config, error) {
 	// ai defaults - use the most stable model name
 	viper.Set(gemini_key, key)
 	viper.Set(gemini_model, "gemini-1.5-flash")
+	viper.SetDefault(gemini_fallback_models, []string{})
+	viper.SetDefault(gemini_fallback_keys, []string{})
+	viper.SetDefault(gemini_fallback_cooldown, 60)
+
+	// api defaults
+	viper.SetDefault(api_endpoint, "")
+	viper.SetDefault(api_proxy, "")
+	viper.SetDefault(api_ca_bundle, "")
+	viper.SetDefault(api_headers, map[string]string{})
 
 	// user defaults
 	viper.SetDefault(user_default_prompt_mode, "exec")
 	viper.SetDefault(user_preferences, "")
+	viper.SetDefault(user_match_shell_dialect, false)
+
+	// run defaults
+	viper.SetDefault(run_shell, "")
+	viper.SetDefault(run_interactive_shell, false)
+	viper.SetDefault(run_output_limit, 65536)
+	viper.SetDefault(run_risk_rules, []run.RiskRule{})
+	viper.SetDefault(run_timeout, "none")
+	viper.SetDefault(run_cpu_limit, "none")
+	viper.SetDefault(run_memory_limit, "none")
+	viper.SetDefault(run_files_limit, "none")
+	viper.SetDefault(run_max_output, "none")
+	viper.SetDefault(run_elevation_tool, "sudo")
+	viper.SetDefault(run_forbid_elevation, false)
+
+	// recall defaults
+	viper.SetDefault(recall_enabled, false)
+	viper.SetDefault(recall_embedder, "gemini")
+	viper.SetDefault(recall_examples, 3)
+
+	// voting defaults
+	viper.SetDefault(voting_samples, 1)
+	viper.SetDefault(voting_min_agreement, 0.6)
+
+	// routing defaults
+	viper.SetDefault(routing_rules, []RoutingRule{})
+	viper.SetDefault(routing_escalation_model, "")
+
+	// undo defaults
+	viper.SetDefault(undo_enabled, true)
+	viper.SetDefault(undo_retention, "168h")
+	viper.SetDefault(undo_max_size, "1G")
+
+	// audit defaults
+	viper.SetDefault(audit_enabled, false)
+	viper.SetDefault(audit_path, "")
+	viper.SetDefault(audit_max_size, "10M")
+	viper.SetDefault(audit_max_backups, 5)
+	viper.SetDefault(audit_syslog, false)
+
+	// history defaults
+	viper.SetDefault(history_enabled, true)
+	viper.SetDefault(history_path, "")
+	viper.SetDefault(history_max_size, 10000)
+
+	// remote defaults
+	viper.SetDefault(remote_groups, map[string][]string{})
+	viper.SetDefault(remote_parallel, 10)
 
 	if write {
 		err := viper.WriteConfigAs(system.GetConfigFile())
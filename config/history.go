@@ -0,0 +1,28 @@
+package config
+
+const (
+	history_enabled  = "HISTORY_ENABLED"
+	history_path     = "HISTORY_PATH"
+	history_max_size = "HISTORY_MAX_SIZE"
+)
+
+type HistoryConfig struct {
+	enabled bool
+	path    string
+	maxSize int
+}
+
+// IsEnabled reports whether the prompts are kept in a file across sessions.
+func (c HistoryConfig) IsEnabled() bool {
+	return c.enabled
+}
+
+// GetPath returns the path of the history file, empty for the default one.
+func (c HistoryConfig) GetPath() string {
+	return c.path
+}
+
+// GetMaxSize returns how many prompts the history keeps, 0 being no bound.
+func (c HistoryConfig) GetMaxSize() int {
+	return c.maxSize
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryConfig(t *testing.T) {
+	t.Run("IsEnabled", testHistoryIsEnabled)
+	t.Run("GetPath", testHistoryGetPath)
+	t.Run("GetMaxSize", testHistoryGetMaxSize)
+}
+
+func testHistoryIsEnabled(t *testing.T) {
+	historyConfig := HistoryConfig{enabled: true}
+
+	assert.True(t, historyConfig.IsEnabled(), "History should be enabled.")
+}
+
+func testHistoryGetPath(t *testing.T) {
+	expectedPath := "/home/user/.xang_history"
+	historyConfig := HistoryConfig{path: expectedPath}
+
+	actualPath := historyConfig.GetPath()
+
+	assert.Equal(t, expectedPath, actualPath, "The two paths should be the same.")
+}
+
+func testHistoryGetMaxSize(t *testing.T) {
+	expectedMaxSize := 500
+	historyConfig := HistoryConfig{maxSize: expectedMaxSize}
+
+	actualMaxSize := historyConfig.GetMaxSize()
+
+	assert.Equal(t, expectedMaxSize, actualMaxSize, "The two max sizes should be the same.")
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	recall_enabled  = "RECALL_ENABLED"
+	recall_embedder = "RECALL_EMBEDDER"
+	recall_examples = "RECALL_EXAMPLES"
+)
+
+type RecallConfig struct {
+	enabled  bool
+	embedder string
+	examples int
+}
+
+// IsEnabled reports whether successful commands are remembered and recalled
+// as examples for similar prompts.
+func (c RecallConfig) IsEnabled() bool {
+	return c.enabled
+}
+
+// GetEmbedder returns the embedding backend: "gemini" or "local".
+func (c RecallConfig) GetEmbedder() string {
+	return c.embedder
+}
+
+// GetExamples returns how many past commands are recalled per prompt.
+func (c RecallConfig) GetExamples() int {
+	return c.examples
+}
+
+// readRecallConfig reads the recall settings, the number of examples being
+// zero or more.
+func readRecallConfig() (RecallConfig, error) {
+	config := RecallConfig{
+		enabled:  viper.GetBool(recall_enabled),
+		embedder: viper.GetString(recall_embedder),
+		examples: viper.GetInt(recall_examples),
+	}
+	if config.examples < 0 {
+		return config, fmt.Errorf("invalid %s: %d, expected zero or more", strings.ToLower(recall_examples), config.examples)
+	}
+
+	return config, nil
+}
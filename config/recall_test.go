@@ -0,0 +1,53 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecallConfig(t *testing.T) {
+	t.Run("IsEnabled", testRecallIsEnabled)
+	t.Run("GetEmbedder", testRecallGetEmbedder)
+	t.Run("GetExamples", testRecallGetExamples)
+	t.Run("ReadExamples", testRecallReadExamples)
+}
+
+func testRecallIsEnabled(t *testing.T) {
+	recallConfig := RecallConfig{enabled: true}
+
+	assert.True(t, recallConfig.IsEnabled(), "Recall should be enabled.")
+}
+
+func testRecallGetEmbedder(t *testing.T) {
+	expectedEmbedder := "local"
+	recallConfig := RecallConfig{embedder: expectedEmbedder}
+
+	actualEmbedder := recallConfig.GetEmbedder()
+
+	assert.Equal(t, expectedEmbedder, actualEmbedder, "The two embedders should be the same.")
+}
+
+func testRecallGetExamples(t *testing.T) {
+	expectedExamples := 5
+	recallConfig := RecallConfig{examples: expectedExamples}
+
+	actualExamples := recallConfig.GetExamples()
+
+	assert.Equal(t, expectedExamples, actualExamples, "The two example counts should be the same.")
+}
+
+func testRecallReadExamples(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	viper.Set(recall_examples, 2)
+	recallConfig, err := readRecallConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, recallConfig.GetExamples(), "The example count should be read.")
+
+	viper.Set(recall_examples, -1)
+	_, err = readRecallConfig()
+	assert.Error(t, err, "A negative example count should be rejected.")
+}
@@ -0,0 +1,29 @@
+package config
+
+import "github.com/Praatibh/xang/run"
+
+const (
+	remote_groups   = "REMOTE_GROUPS"
+	remote_parallel = "REMOTE_PARALLEL"
+)
+
+type RemoteConfig struct {
+	groups   map[string][]string
+	parallel int
+}
+
+// GetGroups returns the hosts of each group a target can name.
+func (c RemoteConfig) GetGroups() map[string][]string {
+	return c.groups
+}
+
+// GetParallel returns on how many hosts of a group a command runs at once.
+func (c RemoteConfig) GetParallel() int {
+	return c.parallel
+}
+
+// ResolveTarget returns the hosts target names, separated by commas, each
+// one a group or a host.
+func (c RemoteConfig) ResolveTarget(target string) []string {
+	return run.ResolveHosts(target, c.groups)
+}
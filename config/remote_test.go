@@ -0,0 +1,37 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoteConfig(t *testing.T) {
+	t.Run("GetGroups", testRemoteGetGroups)
+	t.Run("GetParallel", testRemoteGetParallel)
+	t.Run("ResolveTarget", testRemoteResolveTarget)
+}
+
+func testRemoteGetGroups(t *testing.T) {
+	expectedGroups := map[string][]string{"web": {"web-01", "web-02"}}
+	remoteConfig := RemoteConfig{groups: expectedGroups}
+
+	actualGroups := remoteConfig.GetGroups()
+
+	assert.Equal(t, expectedGroups, actualGroups, "The two groups should be the same.")
+}
+
+func testRemoteGetParallel(t *testing.T) {
+	expectedParallel := 4
+	remoteConfig := RemoteConfig{parallel: expectedParallel}
+
+	actualParallel := remoteConfig.GetParallel()
+
+	assert.Equal(t, expectedParallel, actualParallel, "The two parallel counts should be the same.")
+}
+
+func testRemoteResolveTarget(t *testing.T) {
+	remoteConfig := RemoteConfig{groups: map[string][]string{"web": {"web-01", "web-02"}}}
+
+	assert.Equal(t, []string{"web-01", "web-02", "db-01"}, remoteConfig.ResolveTarget("web,db-01"), "The group should be expanded.")
+}
@@ -0,0 +1,38 @@
+package config
+
+const (
+	routing_rules            = "ROUTING_RULES"
+	routing_escalation_model = "ROUTING_ESCALATION_MODEL"
+)
+
+// RoutingRule sends the requests it matches to Model. Conditions left empty
+// match every request.
+type RoutingRule struct {
+	// Mode is the engine mode: exec, chat or translate.
+	Mode string `mapstructure:"mode"`
+	// MinLength and MaxLength bound the length of the prompt, in characters.
+	MinLength int `mapstructure:"min_length"`
+	MaxLength int `mapstructure:"max_length"`
+	// Context is "yes" when the request must come with piped input, "no" when
+	// it must not.
+	Context string `mapstructure:"context"`
+	// Keywords match when the prompt contains any of them.
+	Keywords []string `mapstructure:"keywords"`
+	Model    string   `mapstructure:"model"`
+}
+
+type RoutingConfig struct {
+	rules           []RoutingRule
+	escalationModel string
+}
+
+// GetRules returns the rules, the first matching a request picking its model.
+func (c RoutingConfig) GetRules() []RoutingRule {
+	return c.rules
+}
+
+// GetEscalationModel returns the model a request is sent again to when the
+// answer is not executable or cannot be parsed, if any.
+func (c RoutingConfig) GetEscalationModel() string {
+	return c.escalationModel
+}
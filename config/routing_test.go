@@ -0,0 +1,30 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoutingConfig(t *testing.T) {
+	t.Run("GetRules", testRoutingGetRules)
+	t.Run("GetEscalationModel", testRoutingGetEscalationModel)
+}
+
+func testRoutingGetRules(t *testing.T) {
+	expectedRules := []RoutingRule{{Mode: "exec", MaxLength: 60, Model: "gemini-2.5-flash-lite"}}
+	routingConfig := RoutingConfig{rules: expectedRules}
+
+	actualRules := routingConfig.GetRules()
+
+	assert.Equal(t, expectedRules, actualRules, "The two rule lists should be the same.")
+}
+
+func testRoutingGetEscalationModel(t *testing.T) {
+	expectedModel := "gemini-2.5-pro"
+	routingConfig := RoutingConfig{escalationModel: expectedModel}
+
+	actualModel := routingConfig.GetEscalationModel()
+
+	assert.Equal(t, expectedModel, actualModel, "The two escalation models should be the same.")
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Praatibh/xang/run"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	run_shell             = "RUN_SHELL"
+	run_interactive_shell = "RUN_INTERACTIVE_SHELL"
+	run_output_limit      = "RUN_OUTPUT_LIMIT"
+	run_risk_rules        = "RUN_RISK_RULES"
+	run_timeout           = "RUN_TIMEOUT"
+	run_cpu_limit         = "RUN_CPU_LIMIT"
+	run_memory_limit      = "RUN_MEMORY_LIMIT"
+	run_files_limit       = "RUN_FILES_LIMIT"
+	run_max_output        = "RUN_MAX_OUTPUT"
+	run_elevation_tool    = "RUN_ELEVATION_TOOL"
+	run_forbid_elevation  = "RUN_FORBID_ELEVATION"
+)
+
+type RunConfig struct {
+	shell            string
+	interactiveShell bool
+	outputLimit      int
+	riskRules        []run.RiskRule
+	limits           run.Limits
+	elevationTool    string
+	forbidElevation  bool
+}
+
+// GetShell returns the shell that overrides the detected one, if any.
+func (c RunConfig) GetShell() string {
+	return c.shell
+}
+
+// GetInteractiveShell reports whether commands run in an interactive shell,
+// which loads the user's aliases and functions.
+func (c RunConfig) GetInteractiveShell() bool {
+	return c.interactiveShell
+}
+
+// GetOutputLimit returns how many bytes of the end of the stdout and of the
+// stderr of a command are kept.
+func (c RunConfig) GetOutputLimit() int {
+	return c.outputLimit
+}
+
+// GetRiskRules returns the user rules raising the risk of the commands they
+// match, on top of the built-in ones.
+func (c RunConfig) GetRiskRules() []run.RiskRule {
+	return c.riskRules
+}
+
+// GetLimits returns the limits commands run within, unless overridden when
+// confirming them.
+func (c RunConfig) GetLimits() run.Limits {
+	return c.limits
+}
+
+// GetElevationTool returns the tool commands that need root run with, one of
+// sudo, doas or pkexec.
+func (c RunConfig) GetElevationTool() string {
+	return c.elevationTool
+}
+
+// IsElevationForbidden reports whether commands that need root are refused
+// instead of being run with the elevation tool.
+func (c RunConfig) IsElevationForbidden() bool {
+	return c.forbidElevation
+}
+
+// readElevationTool reads the tool commands that need root run with.
+func readElevationTool() (string, error) {
+	tool := strings.TrimSpace(viper.GetString(run_elevation_tool))
+	if tool == "" {
+		return "sudo", nil
+	}
+	for _, known := range run.ElevationTools {
+		if tool == known {
+			return tool, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid %s: %q, expected one of %s", strings.ToLower(run_elevation_tool), tool, strings.Join(run.ElevationTools, ", "))
+}
+
+// readRunLimits reads the limits commands run within.
+func readRunLimits() (run.Limits, error) {
+	var limits run.Limits
+	var err error
+
+	if limits.Timeout, err = run.ParseDuration(viper.GetString(run_timeout)); err != nil {
+		return limits, fmt.Errorf("invalid %s: %w", strings.ToLower(run_timeout), err)
+	}
+	if limits.CPU, err = run.ParseDuration(viper.GetString(run_cpu_limit)); err != nil {
+		return limits, fmt.Errorf("invalid %s: %w", strings.ToLower(run_cpu_limit), err)
+	}
+	if limits.Memory, err = run.ParseSize(viper.GetString(run_memory_limit)); err != nil {
+		return limits, fmt.Errorf("invalid %s: %w", strings.ToLower(run_memory_limit), err)
+	}
+	if limits.Output, err = run.ParseSize(viper.GetString(run_max_output)); err != nil {
+		return limits, fmt.Errorf("invalid %s: %w", strings.ToLower(run_max_output), err)
+	}
+	if limits.Files, err = run.ParseCount(viper.GetString(run_files_limit)); err != nil {
+		return limits, fmt.Errorf("invalid %s: %w", strings.ToLower(run_files_limit), err)
+	}
+
+	return limits, nil
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Praatibh/xang/run"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunConfig(t *testing.T) {
+	t.Run("GetShell", testGetShell)
+	t.Run("GetInteractiveShell", testGetInteractiveShell)
+	t.Run("GetOutputLimit", testGetOutputLimit)
+	t.Run("GetRiskRules", testGetRiskRules)
+	t.Run("GetLimits", testGetLimits)
+	t.Run("ReadFilesLimit", testReadFilesLimit)
+	t.Run("GetElevationTool", testGetElevationTool)
+	t.Run("IsElevationForbidden", testIsElevationForbidden)
+}
+
+func testGetShell(t *testing.T) {
+	expectedShell := "fish"
+	runConfig := RunConfig{shell: expectedShell}
+
+	actualShell := runConfig.GetShell()
+
+	assert.Equal(t, expectedShell, actualShell, "The two shells should be the same.")
+}
+
+func testGetInteractiveShell(t *testing.T) {
+	runConfig := RunConfig{interactiveShell: true}
+
+	assert.True(t, runConfig.GetInteractiveShell(), "The shell should be interactive.")
+}
+
+func testGetOutputLimit(t *testing.T) {
+	expectedOutputLimit := 4096
+	runConfig := RunConfig{outputLimit: expectedOutputLimit}
+
+	actualOutputLimit := runConfig.GetOutputLimit()
+
+	assert.Equal(t, expectedOutputLimit, actualOutputLimit, "The two output limits should be the same.")
+}
+
+func testGetRiskRules(t *testing.T) {
+	expectedRiskRules := []run.RiskRule{{Program: "kubectl", Pattern: "delete", Level: "high"}}
+	runConfig := RunConfig{riskRules: expectedRiskRules}
+
+	actualRiskRules := runConfig.GetRiskRules()
+
+	assert.Equal(t, expectedRiskRules, actualRiskRules, "The two risk rule sets should be the same.")
+}
+
+func testGetLimits(t *testing.T) {
+	expectedLimits := run.Limits{Timeout: 10 * time.Minute, Memory: 2 << 30}
+	runConfig := RunConfig{limits: expectedLimits}
+
+	actualLimits := runConfig.GetLimits()
+
+	assert.Equal(t, expectedLimits, actualLimits, "The two limit sets should be the same.")
+}
+
+func testGetElevationTool(t *testing.T) {
+	expectedElevationTool := "doas"
+	runConfig := RunConfig{elevationTool: expectedElevationTool}
+
+	actualElevationTool := runConfig.GetElevationTool()
+
+	assert.Equal(t, expectedElevationTool, actualElevationTool, "The two elevation tools should be the same.")
+}
+
+func testIsElevationForbidden(t *testing.T) {
+	runConfig := RunConfig{forbidElevation: true}
+
+	assert.True(t, runConfig.IsElevationForbidden(), "Elevation should be forbidden.")
+}
+
+func testReadFilesLimit(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	for value, expected := range map[string]int{"none": 0, "0": 0, "256": 256} {
+		viper.Set(run_files_limit, value)
+		limits, err := readRunLimits()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, limits.Files, "The files limit should be read from %q.", value)
+	}
+
+	viper.Set(run_files_limit, "-1")
+	_, err := readRunLimits()
+	assert.Error(t, err, "A negative files limit should be rejected.")
+}
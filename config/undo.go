@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Praatibh/xang/run"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	undo_enabled   = "UNDO_ENABLED"
+	undo_retention = "UNDO_RETENTION"
+	undo_max_size  = "UNDO_MAX_SIZE"
+)
+
+type UndoConfig struct {
+	enabled   bool
+	retention time.Duration
+	maxSize   int64
+}
+
+// IsEnabled reports whether the files a command deletes, moves or overwrites
+// are saved before it runs, to be put back on undo.
+func (c UndoConfig) IsEnabled() bool {
+	return c.enabled
+}
+
+// GetRetention returns how long saved files are kept, none being forever.
+func (c UndoConfig) GetRetention() time.Duration {
+	return c.retention
+}
+
+// GetMaxSize returns how many bytes the saved files may take, none being no
+// bound.
+func (c UndoConfig) GetMaxSize() int64 {
+	return c.maxSize
+}
+
+// readUndoConfig reads the settings of the undo journal.
+func readUndoConfig() (UndoConfig, error) {
+	config := UndoConfig{enabled: viper.GetBool(undo_enabled)}
+	var err error
+
+	if config.retention, err = run.ParseDuration(viper.GetString(undo_retention)); err != nil {
+		return config, fmt.Errorf("invalid %s: %w", strings.ToLower(undo_retention), err)
+	}
+	if config.maxSize, err = run.ParseSize(viper.GetString(undo_max_size)); err != nil {
+		return config, fmt.Errorf("invalid %s: %w", strings.ToLower(undo_max_size), err)
+	}
+
+	return config, nil
+}
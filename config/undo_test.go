@@ -0,0 +1,38 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUndoConfig(t *testing.T) {
+	t.Run("IsEnabled", testUndoIsEnabled)
+	t.Run("GetRetention", testUndoGetRetention)
+	t.Run("GetMaxSize", testUndoGetMaxSize)
+}
+
+func testUndoIsEnabled(t *testing.T) {
+	undoConfig := UndoConfig{enabled: true}
+
+	assert.True(t, undoConfig.IsEnabled(), "Undo should be enabled.")
+}
+
+func testUndoGetRetention(t *testing.T) {
+	expectedRetention := 72 * time.Hour
+	undoConfig := UndoConfig{retention: expectedRetention}
+
+	actualRetention := undoConfig.GetRetention()
+
+	assert.Equal(t, expectedRetention, actualRetention, "The two retentions should be the same.")
+}
+
+func testUndoGetMaxSize(t *testing.T) {
+	expectedMaxSize := int64(512 << 20)
+	undoConfig := UndoConfig{maxSize: expectedMaxSize}
+
+	actualMaxSize := undoConfig.GetMaxSize()
+
+	assert.Equal(t, expectedMaxSize, actualMaxSize, "The two maximum sizes should be the same.")
+}
@@ -3,11 +3,13 @@ package config
 const (
 	user_default_prompt_mode = "USER_DEFAULT_PROMPT_MODE"
 	user_preferences         = "USER_PREFERENCES"
+	user_match_shell_dialect = "USER_MATCH_SHELL_DIALECT"
 )
 
 type UserConfig struct {
 	defaultPromptMode string
 	preferences       string
+	matchShellDialect bool
 }
 
 func (c UserConfig) GetDefaultPromptMode() string {
@@ -17,3 +19,9 @@ func (c UserConfig) GetDefaultPromptMode() string {
 func (c UserConfig) GetPreferences() string {
 	return c.preferences
 }
+
+// GetMatchShellDialect reports whether exec mode must generate commands in the
+// dialect of the user's shell.
+func (c UserConfig) GetMatchShellDialect() bool {
+	return c.matchShellDialect
+}
@@ -9,6 +9,7 @@ import (
 func TestUserConfig(t *testing.T) {
 	t.Run("GetDefaultPromptMode", testGetDefaultPromptMode)
 	t.Run("GetPreferences", testGetPreferences)
+	t.Run("GetMatchShellDialect", testGetMatchShellDialect)
 }
 
 func testGetDefaultPromptMode(t *testing.T) {
@@ -28,3 +29,9 @@ func testGetPreferences(t *testing.T) {
 
 	assert.Equal(t, expectedPreferences, actualPreferences, "The two preferences should be the same.")
 }
+
+func testGetMatchShellDialect(t *testing.T) {
+	userConfig := UserConfig{matchShellDialect: true}
+
+	assert.True(t, userConfig.GetMatchShellDialect(), "The shell dialect should be matched.")
+}
@@ -0,0 +1,23 @@
+package config
+
+const (
+	voting_samples       = "VOTING_SAMPLES"
+	voting_min_agreement = "VOTING_MIN_AGREEMENT"
+)
+
+type VotingConfig struct {
+	samples      int
+	minAgreement float64
+}
+
+// GetSamples returns how many candidate commands exec mode samples per
+// request. Voting is disabled below 2.
+func (c VotingConfig) GetSamples() int {
+	return c.samples
+}
+
+// GetMinAgreement returns the share of samples, between 0 and 1, the majority
+// command needs for the vote not to be flagged.
+func (c VotingConfig) GetMinAgreement() float64 {
+	return c.minAgreement
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVotingConfig(t *testing.T) {
+	t.Run("GetSamples", testVotingGetSamples)
+	t.Run("GetMinAgreement", testVotingGetMinAgreement)
+}
+
+func testVotingGetSamples(t *testing.T) {
+	expectedSamples := 5
+	votingConfig := VotingConfig{samples: expectedSamples}
+
+	actualSamples := votingConfig.GetSamples()
+
+	assert.Equal(t, expectedSamples, actualSamples, "The two sample counts should be the same.")
+}
+
+func testVotingGetMinAgreement(t *testing.T) {
+	expectedMinAgreement := 0.6
+	votingConfig := VotingConfig{minAgreement: expectedMinAgreement}
+
+	actualMinAgreement := votingConfig.GetMinAgreement()
+
+	assert.Equal(t, expectedMinAgreement, actualMinAgreement, "The two minimum agreements should be the same.")
+}
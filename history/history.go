@@ -1,53 +1,391 @@
 package history
 
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Outcome is what came of the command a prompt produced.
+type Outcome string
+
+const (
+	ExecutedOutcome  Outcome = "executed"
+	FailedOutcome    Outcome = "failed"
+	CancelledOutcome Outcome = "cancelled"
+	BlockedOutcome   Outcome = "blocked"
+)
+
+// Entry is a prompt of the history, along with the mode and the directory it
+// was typed in, and the command it produced and what came of it, if any.
+type Entry struct {
+	Prompt  string    `json:"prompt"`
+	Mode    string    `json:"mode,omitempty"`
+	Time    time.Time `json:"time"`
+	Cwd     string    `json:"cwd,omitempty"`
+	Command string    `json:"cmd,omitempty"`
+	Outcome Outcome   `json:"outcome,omitempty"`
+}
+
+// History is the list of the prompts typed, browsed with a cursor starting
+// past the most recent one, where the prompt being typed is. A history kept in
+// a file is loaded from it and shared with the other xang processes, each
+// change being written to the file under a lock.
 type History struct {
-	inputs map[int]string
-	cursor int
+	mu      sync.Mutex
+	entries []Entry
+	cursor  int
+	path    string
+	maxSize int
 }
 
+// NewHistory returns an empty history kept in memory.
 func NewHistory() *History {
-	return &History{
-		map[int]string{},
-		0,
+	return &History{}
+}
+
+// NewFileHistory returns the history kept in the JSONL file at path, one
+// entry per line, holding up to maxSize entries, none setting no bound.
+func NewFileHistory(path string, maxSize int) (*History, error) {
+	h := &History{path: path, maxSize: maxSize}
+
+	unlock, err := h.lock()
+	if err != nil {
+		return h, err
+	}
+	defer unlock()
+
+	entries, err := h.read()
+	if err != nil {
+		return h, err
 	}
+	h.entries = h.trim(entries)
+	h.cursor = len(h.entries)
+
+	return h, nil
+}
+
+func (h *History) GetPath() string {
+	return h.path
 }
 
+// Reset forgets the entries in memory, the file is kept.
 func (h *History) Reset() *History {
-	h.inputs = map[int]string{}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries = nil
 	h.cursor = 0
 
 	return h
 }
 
+// Rewind moves the cursor back past the most recent entry.
+func (h *History) Rewind() *History {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.cursor = len(h.entries)
+
+	return h
+}
+
+// Add adds input to the history in memory.
 func (h *History) Add(input string) *History {
-	h.cursor = len(h.inputs)
-	h.inputs[h.cursor] = input
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.add(Entry{Prompt: input, Time: time.Now()})
 
 	return h
 }
 
+// Record adds entry to the history, its secrets redacted, and appends it to
+// the file, if any. An entry repeating the prompt and mode of the previous
+// one replaces it. It returns the entry added.
+func (h *History) Record(entry Entry) (Entry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entry.Prompt = Redact(entry.Prompt)
+	entry.Command = Redact(entry.Command)
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	h.add(entry)
+
+	return entry, h.append(entry)
+}
+
+// SetOutcome sets the command produced by entry, a recorded one, and what
+// came of it.
+func (h *History) SetOutcome(entry Entry, command string, outcome Outcome) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	command = Redact(command)
+	complete := func(entries []Entry) []Entry {
+		for i := len(entries) - 1; i >= 0; i-- {
+			if isSameEntry(entries[i], entry) {
+				entries[i].Command = command
+				entries[i].Outcome = outcome
+				break
+			}
+		}
+		return entries
+	}
+	complete(h.entries)
+
+	return h.update(complete)
+}
+
+// GetAll returns the prompts of the history by position, the oldest first.
 func (h *History) GetAll() map[int]string {
-	return h.inputs
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	inputs := make(map[int]string, len(h.entries))
+	for i, entry := range h.entries {
+		inputs[i] = entry.Prompt
+	}
+
+	return inputs
+}
+
+// GetEntries returns the entries of the history, the oldest first.
+func (h *History) GetEntries() []Entry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]Entry(nil), h.entries...)
 }
 
 func (h *History) GetCursor() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
 	return h.cursor
 }
 
+// GetPrevious moves the cursor to the entry before it and returns its prompt,
+// nil when there is none.
 func (h *History) GetPrevious() *string {
-	if input, ok := h.inputs[h.cursor]; ok {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.cursor > 0 && h.cursor <= len(h.entries) {
 		h.cursor--
+		input := h.entries[h.cursor].Prompt
 		return &input
 	}
 
 	return nil
 }
 
+// GetNext moves the cursor to the entry after it and returns its prompt, an
+// empty one when it moves past the most recent entry, and nil when it is
+// already there.
 func (h *History) GetNext() *string {
-	if input, ok := h.inputs[h.cursor+1]; ok {
-		h.cursor++
-		return &input
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.cursor >= len(h.entries) {
+		return nil
+	}
+	h.cursor++
+	input := ""
+	if h.cursor < len(h.entries) {
+		input = h.entries[h.cursor].Prompt
+	}
+
+	return &input
+}
+
+// add adds entry to the entries in memory, replacing the last one when it
+// repeats it, and moves the cursor past it.
+func (h *History) add(entry Entry) {
+	if n := len(h.entries); n > 0 && isRepeated(h.entries[n-1], entry) {
+		h.entries = h.entries[:n-1]
+	}
+	h.entries = h.trim(append(h.entries, entry))
+	h.cursor = len(h.entries)
+}
+
+// update applies change to the entries of the file, read and written again
+// under a lock for the changes of other processes to be kept.
+func (h *History) update(change func([]Entry) []Entry) error {
+	if h.path == "" {
+		return nil
+	}
+
+	unlock, err := h.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := h.read()
+	if err != nil {
+		return err
+	}
+
+	return h.write(h.trim(change(entries)))
+}
+
+// append writes entry at the end of the file under a lock. The file is only
+// written again when entry replaces the last one, or when it goes over the
+// size of the history.
+func (h *History) append(entry Entry) error {
+	if h.path == "" {
+		return nil
+	}
+
+	unlock, err := h.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := h.read()
+	if err != nil {
+		return err
+	}
+	n := len(entries)
+	if n > 0 && isRepeated(entries[n-1], entry) {
+		return h.write(h.trim(append(entries[:n-1], entry)))
+	}
+	if h.maxSize > 0 && n >= h.maxSize {
+		return h.write(h.trim(append(entries, entry)))
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to write the history: %w", err)
+	}
+	file, err := os.OpenFile(h.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write the history: %w", err)
+	}
+	if info, err := file.Stat(); err == nil && info.Size() > 0 && !endsWithNewline(h.path, info.Size()) {
+		// A line left unfinished is not to swallow the entry
+		line = append([]byte("\n"), line...)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write the history: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write the history: %w", err)
+	}
+
+	return nil
+}
+
+// endsWithNewline reports whether the file at path, of size bytes, ends with
+// a newline.
+func endsWithNewline(path string, size int64) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, size-1); err != nil {
+		return false
+	}
+
+	return last[0] == '\n'
+}
+
+// read returns the entries of the file, skipping the lines that can't be
+// read.
+func (h *History) read() ([]Entry, error) {
+	file, err := os.Open(h.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the history: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil && entry.Prompt != "" {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the history: %w", err)
+	}
+
+	return entries, nil
+}
+
+// write replaces the file with entries, through a temporary file for it to
+// never be left half written.
+func (h *History) write(entries []Entry) error {
+	file, err := os.CreateTemp(filepath.Dir(h.path), filepath.Base(h.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write the history: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to write the history: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write the history: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write the history: %w", err)
+	}
+	if err := os.Rename(file.Name(), h.path); err != nil {
+		return fmt.Errorf("failed to write the history: %w", err)
 	}
 
 	return nil
 }
+
+// trim drops the oldest entries beyond the size of the history.
+func (h *History) trim(entries []Entry) []Entry {
+	if h.maxSize > 0 && len(entries) > h.maxSize {
+		return entries[len(entries)-h.maxSize:]
+	}
+
+	return entries
+}
+
+// lock takes the lock of the file, next to it, for the other processes to
+// wait until the file is read and written again.
+func (h *History) lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create the history directory: %w", err)
+	}
+
+	return lockFile(h.path + ".lock")
+}
+
+// isRepeated reports whether entry repeats the prompt of previous in the same
+// mode.
+func isRepeated(previous Entry, entry Entry) bool {
+	return previous.Prompt == entry.Prompt && previous.Mode == entry.Mode
+}
+
+func isSameEntry(a Entry, b Entry) bool {
+	return a.Prompt == b.Prompt && a.Time.Equal(b.Time)
+}
@@ -0,0 +1,37 @@
+//go:build !unix
+
+package history
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	lock_retry_interval = 10 * time.Millisecond
+	// lock_stale_age is the age past which a lock is taken to be left by a
+	// process that died holding it.
+	lock_stale_age = 10 * time.Second
+)
+
+// lockFile takes an exclusive lock by creating the file at path, waiting for
+// the other processes holding it. The lock is released by the function
+// returned.
+func lockFile(path string) (func(), error) {
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to lock the history: %w", err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > lock_stale_age {
+			os.Remove(path)
+			continue
+		}
+		time.Sleep(lock_retry_interval)
+	}
+}
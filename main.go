@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"math/rand"
+	"os"
 	"time"
 	"fmt"
 
@@ -12,6 +13,16 @@ import (
 )
 
 func main() {
+	if arguments := os.Args[1:]; ui.IsPolicyTest(arguments) {
+		os.Exit(ui.RunPolicyTest(os.Stdout, arguments[2]))
+	}
+	if arguments := os.Args[1:]; ui.IsUndo(arguments) {
+		os.Exit(ui.RunUndo(os.Stdout, arguments[1:]))
+	}
+	if arguments := os.Args[1:]; ui.IsAudit(arguments) {
+		os.Exit(ui.RunAudit(os.Stdout, arguments[1:]))
+	}
+
 	asciiArt := `
 ░██    ░██    ░███    ░███    ░██   ░██████  
  ░██  ░██    ░██░██   ░████   ░██  ░██   ░██ 
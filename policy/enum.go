@@ -0,0 +1,41 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Action is what a policy does with a command, from the least to the most
+// restrictive.
+type Action int
+
+const (
+	AllowAction Action = iota
+	WarnAction
+	ConfirmAction
+	BlockAction
+)
+
+func (a Action) String() string {
+	switch a {
+	case WarnAction:
+		return "warn"
+	case ConfirmAction:
+		return "confirm"
+	case BlockAction:
+		return "block"
+	default:
+		return "allow"
+	}
+}
+
+// ParseAction returns the action named s, such as "block".
+func ParseAction(s string) (Action, error) {
+	for action := AllowAction; action <= BlockAction; action++ {
+		if strings.EqualFold(strings.TrimSpace(s), action.String()) {
+			return action, nil
+		}
+	}
+
+	return AllowAction, fmt.Errorf("unknown policy action %q", s)
+}
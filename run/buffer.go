@@ -0,0 +1,48 @@
+package run
+
+import (
+	"sync"
+)
+
+// tailBuffer keeps the last limit bytes written to it.
+type tailBuffer struct {
+	mu        sync.Mutex
+	limit     int
+	data      []byte
+	truncated bool
+}
+
+func newTailBuffer(limit int) *tailBuffer {
+	return &tailBuffer{limit: limit}
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.limit <= 0 {
+		b.truncated = b.truncated || len(p) > 0
+		return len(p), nil
+	}
+
+	b.data = append(b.data, p...)
+	if overflow := len(b.data) - b.limit; overflow > 0 {
+		b.data = append(b.data[:0], b.data[overflow:]...)
+		b.truncated = true
+	}
+
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.data)
+}
+
+// IsTruncated reports whether the beginning of what was written was dropped.
+func (b *tailBuffer) IsTruncated() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.truncated
+}
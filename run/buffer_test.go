@@ -0,0 +1,33 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTailBuffer(t *testing.T) {
+	t.Run("Write", testTailBufferWrite)
+	t.Run("WriteOverLimit", testTailBufferWriteOverLimit)
+}
+
+func testTailBufferWrite(t *testing.T) {
+	buffer := newTailBuffer(16)
+
+	buffer.Write([]byte("hello "))
+	buffer.Write([]byte("world"))
+
+	assert.Equal(t, "hello world", buffer.String(), "Everything should be kept.")
+	assert.False(t, buffer.IsTruncated(), "The buffer should not be truncated.")
+}
+
+func testTailBufferWriteOverLimit(t *testing.T) {
+	buffer := newTailBuffer(5)
+
+	n, err := buffer.Write([]byte("hello world"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 11, n, "The whole write should be accepted.")
+	assert.Equal(t, "world", buffer.String(), "Only the end should be kept.")
+	assert.True(t, buffer.IsTruncated(), "The buffer should be truncated.")
+}
@@ -0,0 +1,208 @@
+package run
+
+import (
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Call is a program a command runs, along with its arguments.
+type Call struct {
+	program   string
+	path      string
+	args      []string
+	elevated  bool
+	redirects []string
+}
+
+// GetProgram returns the name of the program, past wrappers such as sudo, or
+// an empty string when it is an expansion.
+func (c Call) GetProgram() string {
+	return c.program
+}
+
+// GetPath returns the program as written in the command, such as a relative
+// or absolute path to it.
+func (c Call) GetPath() string {
+	return c.path
+}
+
+// GetArgs returns the arguments of the program, unquoted when they are made
+// of literals.
+func (c Call) GetArgs() []string {
+	return c.args
+}
+
+// IsElevated reports whether a wrapper runs the program as root.
+func (c Call) IsElevated() bool {
+	return c.elevated
+}
+
+// GetRedirects returns the files the output of the program is redirected to.
+func (c Call) GetRedirects() []string {
+	return c.redirects
+}
+
+// shellPrograms run the script given to their -c option.
+var shellPrograms = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true,
+}
+
+// xargsValueOptions are the options of xargs that take the next word as
+// their value.
+var xargsValueOptions = map[string]bool{
+	"-a": true, "-d": true, "-E": true, "-I": true, "-L": true, "-n": true, "-P": true, "-s": true,
+	"--arg-file": true, "--delimiter": true, "--max-args": true, "--max-lines": true, "--max-procs": true, "--max-chars": true,
+}
+
+// ParseCalls returns the programs cmd runs, including those of pipelines,
+// lists and substitutions, of the scripts given to sh -c and of xargs.
+func ParseCalls(cmd string) ([]Call, error) {
+	file, err := syntax.NewParser().Parse(strings.NewReader(cmd), "")
+	if err != nil {
+		return nil, err
+	}
+
+	var calls []Call
+	syntax.Walk(file, func(node syntax.Node) bool {
+		stmt, ok := node.(*syntax.Stmt)
+		if !ok || err != nil {
+			return err == nil
+		}
+		call, ok := stmt.Cmd.(*syntax.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+
+		words := make([]string, 0, len(call.Args))
+		for _, arg := range call.Args {
+			words = append(words, wordText(arg))
+		}
+		literals := literalWords(call)
+		program, args, elevated := callProgram(literals)
+		c := Call{
+			program:  program,
+			path:     program,
+			args:     words[len(words)-len(args):],
+			elevated: elevated,
+		}
+		if program != "" {
+			c.path = literals[len(literals)-len(args)-1]
+		}
+		for _, redirect := range stmt.Redirs {
+			if writeRedirectOperands[redirect.Op] && redirect.Word != nil {
+				c.redirects = append(c.redirects, wordText(redirect.Word))
+			}
+		}
+		calls = append(calls, c)
+
+		var nested []Call
+		nested, err = nestedCalls(c, call.Args[len(call.Args)-len(args):])
+		calls = append(calls, nested...)
+
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return calls, nil
+}
+
+// nestedCalls returns the programs call runs in turn, made of words: those of
+// the script of a shell run with -c, or the program xargs runs. Scripts that
+// are expansions can't be known and are left out.
+func nestedCalls(call Call, words []*syntax.Word) ([]Call, error) {
+	var calls []Call
+	switch {
+	case shellPrograms[call.program]:
+		script, ok := shellScript(call.args, words)
+		if !ok {
+			return nil, nil
+		}
+		nested, err := ParseCalls(script)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range nested {
+			c.elevated = c.elevated || call.elevated
+			calls = append(calls, c)
+		}
+
+	case call.program == "xargs":
+		i := 0
+		for ; i < len(words) && strings.HasPrefix(call.args[i], "-"); i++ {
+			if xargsValueOptions[call.args[i]] {
+				i++
+			}
+		}
+		if i >= len(words) {
+			return nil, nil
+		}
+
+		literals := make([]string, 0, len(words)-i)
+		for j := i; j < len(words); j++ {
+			literal := ""
+			if isStatic(words[j]) {
+				literal = call.args[j]
+			}
+			literals = append(literals, literal)
+		}
+		program, args, elevated := callProgram(literals)
+		if program == "" {
+			return nil, nil
+		}
+		c := Call{
+			program:  program,
+			path:     literals[len(literals)-len(args)-1],
+			args:     call.args[len(call.args)-len(args):],
+			elevated: elevated || call.elevated,
+		}
+		nested, err := nestedCalls(c, words[len(words)-len(args):])
+		if err != nil {
+			return nil, err
+		}
+		calls = append(append(calls, c), nested...)
+	}
+
+	return calls, nil
+}
+
+// shellScript returns the script given to the -c option of a shell among
+// args, made of words, and whether it is known.
+func shellScript(args []string, words []*syntax.Word) (string, bool) {
+	command := false
+	for i, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			continue
+		case strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "+"):
+			command = command || strings.ContainsRune(arg[1:], 'c')
+			continue
+		case command:
+			return arg, isStatic(words[i])
+		}
+	}
+
+	return "", false
+}
+
+// isStatic reports whether word is made of literals, quoted or not, so its
+// text is known before the command runs.
+func isStatic(word *syntax.Word) bool {
+	for _, part := range word.Parts {
+		switch part := part.(type) {
+		case *syntax.Lit, *syntax.SglQuoted:
+		case *syntax.DblQuoted:
+			for _, inner := range part.Parts {
+				if _, ok := inner.(*syntax.Lit); !ok {
+					return false
+				}
+			}
+		default:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,82 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCalls(t *testing.T) {
+	t.Run("Pipeline", testParseCallsPipeline)
+	t.Run("Substitution", testParseCallsSubstitution)
+	t.Run("Path", testParseCallsPath)
+	t.Run("Shell", testParseCallsShell)
+	t.Run("Xargs", testParseCallsXargs)
+	t.Run("Unparsable", testParseCallsUnparsable)
+}
+
+func testParseCallsPipeline(t *testing.T) {
+	calls, err := ParseCalls(`sudo -E kubectl --context "prod" get pods | grep api > /tmp/pods.txt`)
+	require.NoError(t, err)
+	require.Len(t, calls, 2)
+
+	assert.Equal(t, "kubectl", calls[0].GetProgram(), "The wrapper should be skipped.")
+	assert.Equal(t, []string{"--context", "prod", "get", "pods"}, calls[0].GetArgs(), "The arguments should be unquoted.")
+	assert.True(t, calls[0].IsElevated(), "The program should run as root.")
+	assert.Equal(t, "grep", calls[1].GetProgram())
+	assert.Equal(t, []string{"/tmp/pods.txt"}, calls[1].GetRedirects(), "The redirection should be kept.")
+}
+
+func testParseCallsSubstitution(t *testing.T) {
+	calls, err := ParseCalls(`echo "$(whoami)" && $EDITOR notes.txt`)
+	require.NoError(t, err)
+
+	var programs []string
+	for _, call := range calls {
+		programs = append(programs, call.GetProgram())
+	}
+	assert.ElementsMatch(t, []string{"echo", "whoami", ""}, programs, "Substituted and unknown programs should be listed.")
+}
+
+func testParseCallsPath(t *testing.T) {
+	calls, err := ParseCalls(`sudo ./scripts/deploy.sh --dry-run`)
+	require.NoError(t, err)
+	require.Len(t, calls, 1)
+
+	assert.Equal(t, "deploy.sh", calls[0].GetProgram(), "The name of the program should be its base name.")
+	assert.Equal(t, "./scripts/deploy.sh", calls[0].GetPath(), "The program should be kept as written.")
+}
+
+func testParseCallsShell(t *testing.T) {
+	calls, err := ParseCalls(`sudo bash -ec 'kubectl delete ns prod && rm -rf /tmp/x' && sh -c "$SCRIPT"`)
+	require.NoError(t, err)
+	require.Len(t, calls, 4)
+
+	assert.Equal(t, "bash", calls[0].GetProgram())
+	assert.Equal(t, "kubectl", calls[1].GetProgram(), "The programs of the script should be listed.")
+	assert.Equal(t, []string{"delete", "ns", "prod"}, calls[1].GetArgs())
+	assert.True(t, calls[1].IsElevated(), "The script should run as root along with its shell.")
+	assert.Equal(t, "rm", calls[2].GetProgram())
+	assert.Equal(t, "sh", calls[3].GetProgram(), "A script that is an expansion should be left out.")
+
+	_, err = ParseCalls(`bash -c 'echo "broken'`)
+	assert.Error(t, err, "An unparsable script should be an error.")
+}
+
+func testParseCallsXargs(t *testing.T) {
+	calls, err := ParseCalls(`echo prod | xargs -n 1 -I {} sudo -u deploy kubectl delete ns {}`)
+	require.NoError(t, err)
+	require.Len(t, calls, 3)
+
+	assert.Equal(t, "xargs", calls[1].GetProgram())
+	assert.Equal(t, "kubectl", calls[2].GetProgram(), "The program xargs runs should be listed.")
+	assert.Equal(t, []string{"delete", "ns", "{}"}, calls[2].GetArgs())
+	assert.True(t, calls[2].IsElevated())
+}
+
+func testParseCallsUnparsable(t *testing.T) {
+	_, err := ParseCalls("echo 'broken")
+
+	assert.Error(t, err, "An unparsable command should be an error.")
+}
@@ -0,0 +1,216 @@
+package run
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// bashOnlyCommands are builtins a POSIX sh does not provide.
+var bashOnlyCommands = map[string]string{
+	"[[":        "[[ ]] tests are not POSIX, use [ ]",
+	"source":    "source is not POSIX, use .",
+	"declare":   "declare is not POSIX",
+	"typeset":   "typeset is not POSIX",
+	"shopt":     "shopt is not POSIX",
+	"let":       "let is not POSIX, use $(( ))",
+	"pushd":     "pushd is not POSIX, use cd",
+	"popd":      "popd is not POSIX, use cd",
+	"mapfile":   "mapfile is not POSIX",
+	"readarray": "readarray is not POSIX",
+}
+
+// zshIncompatibleCommands are bash builtins, or bash flags of shared builtins,
+// that zsh does not understand.
+var zshIncompatibleCommands = map[string]string{
+	"shopt":      "shopt does not exist in zsh, use setopt",
+	"mapfile":    "mapfile does not exist in zsh",
+	"readarray":  "readarray does not exist in zsh",
+	"read -a":    "read -a is read -A in zsh",
+	"declare -n": "namerefs (declare -n) do not exist in zsh",
+}
+
+// fishIncompatiblePatterns match POSIX constructs that fish cannot run.
+var fishIncompatiblePatterns = []struct {
+	pattern *regexp.Regexp
+	warning string
+}{
+	{regexp.MustCompile(`<<`), "fish has no heredocs or herestrings"},
+	{regexp.MustCompile(`\$\(\(`), "fish has no $(( )) arithmetic, use math"},
+	{regexp.MustCompile(`\[\[`), "fish has no [[ ]] tests, use test"},
+	{regexp.MustCompile(`\$\{`), "fish has no ${...} parameter expansion, use string or set"},
+	{regexp.MustCompile(`<\(`), "fish has no <( ) process substitution, use (cmd | psub)"},
+	{regexp.MustCompile(`\$\?`), "fish has no $?, use $status"},
+	{regexp.MustCompile(`\$\$`), "fish has no $$, use $fish_pid"},
+	{regexp.MustCompile(`\$[@*#]`), "fish has no $@, $* or $#, use $argv and count"},
+	{regexp.MustCompile(`(^|[;&|]\s*)(then|fi|do|done|esac)\b`), "fish has no then/fi/do/done/esac, blocks close with end"},
+}
+
+// fishAssignmentWarning is the warning of the assignments fish can't run:
+// the ones not followed by a command, which fish 3.1 and later take as the
+// environment of the command like the other shells.
+const fishAssignmentWarning = "fish has no VAR=value assignments, use set"
+
+// fishAssignmentPattern matches the assignments not followed by a command,
+// for the commands that do not parse as POSIX.
+var fishAssignmentPattern = regexp.MustCompile(`(^|[;&|]\s*)([A-Za-z_][A-Za-z0-9_]*=\S*\s+)*[A-Za-z_][A-Za-z0-9_]*=\S*\s*($|[;&|])`)
+
+var braceExpansion = regexp.MustCompile(`\{[^{}\s]*(,|\.\.)[^{}\s]*\}`)
+
+var singleQuoted = regexp.MustCompile(`'[^']*'`)
+
+// CheckDialect returns a warning for each construct in cmd that the target
+// dialect cannot run as written. It returns nil when nothing was found.
+func CheckDialect(cmd string, target Dialect) []string {
+	if strings.TrimSpace(cmd) == "" {
+		return nil
+	}
+
+	switch target {
+	case PosixDialect:
+		return checkPosixDialect(cmd)
+	case BashDialect, ZshDialect:
+		return checkBashDialect(cmd, target)
+	case FishDialect:
+		return checkFishDialect(cmd)
+	default:
+		return nil
+	}
+}
+
+// parseDialect parses cmd with the parser of dialect, reporting whether there
+// is one. No parser knows the grammars of fish and zsh: there is none for
+// fish, and zsh is parsed as bash.
+func parseDialect(cmd string, dialect Dialect) (*syntax.File, bool, error) {
+	variant := syntax.LangBash
+	switch dialect {
+	case FishDialect:
+		return nil, false, nil
+	case PosixDialect:
+		variant = syntax.LangPOSIX
+	}
+
+	file, err := syntax.NewParser(syntax.Variant(variant)).Parse(strings.NewReader(cmd), "")
+	return file, true, err
+}
+
+func checkPosixDialect(cmd string) []string {
+	file, _, err := parseDialect(cmd, PosixDialect)
+	if err != nil {
+		return []string{fmt.Sprintf("not valid POSIX sh: %v", err)}
+	}
+
+	var warnings []string
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch n := node.(type) {
+		case *syntax.CallExpr:
+			if warning, ok := bashOnlyCommands[callName(n)]; ok {
+				warnings = append(warnings, warning)
+			}
+		case *syntax.Lit:
+			if braceExpansion.MatchString(n.Value) {
+				warnings = append(warnings, fmt.Sprintf("brace expansion %s is not POSIX", n.Value))
+			}
+		}
+		return true
+	})
+
+	return dedupe(warnings)
+}
+
+func checkBashDialect(cmd string, target Dialect) []string {
+	file, _, err := parseDialect(cmd, target)
+	if err != nil {
+		return []string{fmt.Sprintf("not valid %s syntax: %v", target, err)}
+	}
+	if target != ZshDialect {
+		return nil
+	}
+
+	var warnings []string
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if call, ok := node.(*syntax.CallExpr); ok {
+			name := callName(call)
+			if warning, ok := zshIncompatibleCommands[name]; ok {
+				warnings = append(warnings, warning)
+			}
+			if len(call.Args) > 1 {
+				if warning, ok := zshIncompatibleCommands[name+" "+call.Args[1].Lit()]; ok {
+					warnings = append(warnings, warning)
+				}
+			}
+		}
+		return true
+	})
+
+	return dedupe(warnings)
+}
+
+func checkFishDialect(cmd string) []string {
+	unquoted := singleQuoted.ReplaceAllString(cmd, "''")
+
+	var warnings []string
+	for _, line := range strings.Split(unquoted, "\n") {
+		for _, check := range fishIncompatiblePatterns {
+			if check.pattern.MatchString(strings.TrimSpace(line)) {
+				warnings = append(warnings, check.warning)
+			}
+		}
+	}
+	if hasStandaloneAssignment(cmd, unquoted) {
+		warnings = append(warnings, fishAssignmentWarning)
+	}
+
+	return dedupe(warnings)
+}
+
+// hasStandaloneAssignment reports whether cmd assigns a variable without
+// running a command, such as FOO=bar, rather than setting the environment of
+// one, such as FOO=bar make. Commands that do not parse as POSIX, like most
+// fish ones, are matched line by line once unquoted.
+func hasStandaloneAssignment(cmd string, unquoted string) bool {
+	file, _, err := parseDialect(cmd, PosixDialect)
+	if err != nil {
+		for _, line := range strings.Split(unquoted, "\n") {
+			if fishAssignmentPattern.MatchString(strings.TrimSpace(line)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	found := false
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if call, ok := node.(*syntax.CallExpr); ok && len(call.Assigns) > 0 && len(call.Args) == 0 {
+			found = true
+		}
+		return !found
+	})
+
+	return found
+}
+
+// callName returns the literal command name of call, or an empty string when
+// it is computed at runtime.
+func callName(call *syntax.CallExpr) string {
+	if len(call.Args) == 0 {
+		return ""
+	}
+
+	return call.Args[0].Lit()
+}
+
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var result []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+
+	return result
+}
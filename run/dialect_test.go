@@ -0,0 +1,43 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckDialect(t *testing.T) {
+	t.Run("Posix", testCheckPosixDialect)
+	t.Run("Bash", testCheckBashDialect)
+	t.Run("Zsh", testCheckZshDialect)
+	t.Run("Fish", testCheckFishDialect)
+}
+
+func testCheckPosixDialect(t *testing.T) {
+	assert.Empty(t, CheckDialect("for f in *.txt; do wc -l \"$f\"; done", PosixDialect), "Portable commands should not be flagged.")
+	assert.Len(t, CheckDialect("ls &> out.log", PosixDialect), 1, "Bash redirects should be flagged.")
+	assert.Contains(t, CheckDialect("[[ -f x ]] && source x", PosixDialect), "source is not POSIX, use .", "Bash builtins should be flagged.")
+	assert.Contains(t, CheckDialect("echo {1..3}", PosixDialect), "brace expansion {1..3} is not POSIX", "Brace expansion should be flagged.")
+}
+
+func testCheckBashDialect(t *testing.T) {
+	assert.Empty(t, CheckDialect("a=(1 2); echo ${a[0]}", BashDialect), "Bash commands should not be flagged.")
+	assert.Len(t, CheckDialect("echo 'unbalanced", BashDialect), 1, "Syntax errors should be flagged.")
+}
+
+func testCheckZshDialect(t *testing.T) {
+	assert.Empty(t, CheckDialect("ls -la | grep foo", ZshDialect), "Portable commands should not be flagged.")
+	assert.Equal(t, []string{"read -a is read -A in zsh"}, CheckDialect("read -a parts <<< \"$line\"", ZshDialect), "Bash flags should be flagged.")
+}
+
+func testCheckFishDialect(t *testing.T) {
+	assert.Empty(t, CheckDialect("for i in (seq 3); echo $i; end", FishDialect), "Fish commands should not be flagged.")
+	assert.Empty(t, CheckDialect("echo '$?'", FishDialect), "Single quoted text should not be flagged.")
+	assert.Equal(t, []string{"fish has no $?, use $status"}, CheckDialect("false; echo $?", FishDialect), "POSIX variables should be flagged.")
+	assert.Contains(t, CheckDialect("if true; then echo y; fi", FishDialect), "fish has no then/fi/do/done/esac, blocks close with end", "POSIX keywords should be flagged.")
+	assert.Contains(t, CheckDialect("FOO=bar; echo $FOO", FishDialect), "fish has no VAR=value assignments, use set", "POSIX assignments should be flagged.")
+	assert.Contains(t, CheckDialect("A=1 B=2", FishDialect), "fish has no VAR=value assignments, use set", "Several assignments should be flagged.")
+	for _, cmd := range []string{"GOOS=linux go build", "A=1 B='x y' make && ls", "export FOO=bar", "set -x FOO bar; FOO=baz env | grep FOO"} {
+		assert.NotContains(t, CheckDialect(cmd, FishDialect), "fish has no VAR=value assignments, use set", "%q should not be flagged, fish 3.1 runs it.", cmd)
+	}
+}
@@ -0,0 +1,304 @@
+package run
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// ElevationTools are the tools commands can be run as root with.
+var ElevationTools = []string{"sudo", "doas", "pkexec"}
+
+// systemDirectories are the directories only root can write to.
+var systemDirectories = []string{
+	"/bin", "/boot", "/etc", "/lib", "/lib64", "/opt", "/root", "/sbin", "/srv", "/sys", "/usr", "/var",
+}
+
+// rootPrograms change the system whatever their arguments.
+var rootPrograms = map[string]bool{
+	"mount": true, "umount": true, "swapon": true, "swapoff": true,
+	"fdisk": true, "sfdisk": true, "parted": true, "mkswap": true, "wipefs": true,
+	"modprobe": true, "insmod": true, "rmmod": true,
+	"useradd": true, "usermod": true, "userdel": true, "groupadd": true, "groupmod": true, "groupdel": true, "chpasswd": true,
+	"iptables": true, "ip6tables": true, "nft": true, "ufw": true,
+	"reboot": true, "shutdown": true, "poweroff": true, "halt": true,
+	"visudo": true, "update-grub": true, "grub-install": true, "chroot": true,
+}
+
+// rootSubcommands are the subcommands that change the system, by program.
+var rootSubcommands = map[string]map[string]bool{
+	"apt":         {"install": true, "remove": true, "purge": true, "update": true, "upgrade": true, "full-upgrade": true, "dist-upgrade": true, "autoremove": true},
+	"apt-get":     {"install": true, "remove": true, "purge": true, "update": true, "upgrade": true, "dist-upgrade": true, "autoremove": true},
+	"aptitude":    {"install": true, "remove": true, "purge": true, "update": true, "upgrade": true, "full-upgrade": true},
+	"dnf":         {"install": true, "remove": true, "erase": true, "update": true, "upgrade": true, "downgrade": true, "reinstall": true, "autoremove": true},
+	"yum":         {"install": true, "remove": true, "erase": true, "update": true, "upgrade": true, "downgrade": true, "reinstall": true, "autoremove": true},
+	"zypper":      {"install": true, "in": true, "remove": true, "rm": true, "update": true, "up": true, "dup": true, "refresh": true, "ref": true},
+	"apk":         {"add": true, "del": true, "update": true, "upgrade": true, "fix": true},
+	"snap":        {"install": true, "remove": true, "refresh": true},
+	"systemctl":   {"start": true, "stop": true, "restart": true, "reload": true, "enable": true, "disable": true, "mask": true, "unmask": true, "daemon-reload": true, "kill": true, "isolate": true},
+	"service":     {"start": true, "stop": true, "restart": true, "reload": true},
+	"hostnamectl": {"set-hostname": true},
+	"timedatectl": {"set-time": true, "set-timezone": true, "set-ntp": true},
+}
+
+// Elevation is whether a command needs root, and the command run with the
+// escalation tool where it does.
+type Elevation struct {
+	command  string
+	elevated bool
+	changed  bool
+	reasons  []string
+}
+
+// GetCommand returns the command to run, elevated where it needs root.
+func (e Elevation) GetCommand() string {
+	return e.command
+}
+
+// IsElevated reports whether the command runs something as root.
+func (e Elevation) IsElevated() bool {
+	return e.elevated
+}
+
+// IsChanged reports whether the escalation tool was added to the command, or
+// replaced the one it used.
+func (e Elevation) IsChanged() bool {
+	return e.changed
+}
+
+// NeedsRoot reports whether the command does something only root can do.
+func (e Elevation) NeedsRoot() bool {
+	return len(e.reasons) > 0
+}
+
+// GetReasons returns what the command does that only root can, such as
+// "apt-get install" or "writes to /etc/hosts".
+func (e Elevation) GetReasons() []string {
+	return e.reasons
+}
+
+// elevationEdit replaces the bytes of a command from start to end by text.
+type elevationEdit struct {
+	start uint
+	end   uint
+	text  string
+}
+
+// ElevateCommand finds what cmd does that only root can, such as installing
+// packages, managing services or writing to system directories, and runs
+// those programs with tool, which replaces the other escalation tools cmd
+// uses. hint is set when the model said the command needs root. A redirect to
+// a system file, or a hint on a command of several programs, runs the whole
+// command with tool. When the user is root, no tool is added, and plain uses
+// of the others are dropped. An empty tool leaves cmd as it is, only finding
+// whether it needs root.
+func ElevateCommand(cmd string, tool string, hint bool, root bool) Elevation {
+	elevation := Elevation{command: cmd}
+	file, err := syntax.NewParser().Parse(strings.NewReader(cmd), "")
+	if err != nil {
+		return elevation
+	}
+	rewrite := tool != ""
+
+	var edits []elevationEdit
+	wrap := false
+	seen := make(map[string]bool)
+	addReason := func(reason string) {
+		if !seen[reason] {
+			seen[reason] = true
+			elevation.reasons = append(elevation.reasons, reason)
+		}
+	}
+	insert := func(call *syntax.CallExpr) {
+		if rewrite && !root {
+			offset := call.Args[0].Pos().Offset()
+			edits = append(edits, elevationEdit{offset, offset, tool + " "})
+			elevation.elevated = true
+		}
+	}
+
+	syntax.Walk(file, func(node syntax.Node) bool {
+		stmt, ok := node.(*syntax.Stmt)
+		if !ok {
+			return true
+		}
+		for _, redirect := range stmt.Redirs {
+			if writeRedirectOperands[redirect.Op] && redirect.Word != nil && isSystemPath(wordText(redirect.Word)) {
+				addReason(fmt.Sprintf("writes to %s", wordText(redirect.Word)))
+				wrap = true
+			}
+		}
+		call, ok := stmt.Cmd.(*syntax.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+
+		words := make([]string, 0, len(call.Args))
+		for _, arg := range call.Args {
+			words = append(words, wordText(arg))
+		}
+		literals := literalWords(call)
+		name, args, elevated := callProgram(literals)
+		if name == "" {
+			return true
+		}
+		args = words[len(words)-len(args):]
+		reason := rootReason(name, args)
+		if reason != "" {
+			addReason(reason)
+		}
+
+		first := call.Args[0]
+		wrapper := filepath.Base(literals[0])
+		switch {
+		case elevatingWrappers[wrapper] && len(literals) > 1 && !strings.HasPrefix(literals[1], "-"):
+			// a plain use of an escalation tool, made the configured one
+			elevation.elevated = true
+			if rewrite && root {
+				edits = append(edits, elevationEdit{first.Pos().Offset(), call.Args[1].Pos().Offset(), ""})
+			} else if rewrite && wrapper != tool {
+				edits = append(edits, elevationEdit{first.Pos().Offset(), first.End().Offset(), tool})
+			}
+		case elevated:
+			elevation.elevated = true
+		case reason != "":
+			insert(call)
+		}
+
+		return true
+	})
+
+	if hint && len(elevation.reasons) == 0 {
+		elevation.reasons = append(elevation.reasons, "the model says it needs root")
+		if call, ok := singleCall(file); ok && !elevation.elevated {
+			insert(call)
+		} else if !elevation.elevated {
+			wrap = true
+		}
+	}
+
+	if wrap && rewrite && !root {
+		elevation.command = fmt.Sprintf("%s sh -c %s", tool, quoteWord(cmd))
+		elevation.elevated = true
+		elevation.changed = true
+		return elevation
+	}
+
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].start > edits[j].start
+	})
+	for _, edit := range edits {
+		cmd = cmd[:edit.start] + edit.text + cmd[edit.end:]
+	}
+	elevation.command = cmd
+	elevation.changed = len(edits) > 0
+
+	return elevation
+}
+
+// singleCall returns the call file is made of, when it is a single program
+// run without redirects.
+func singleCall(file *syntax.File) (*syntax.CallExpr, bool) {
+	if len(file.Stmts) != 1 {
+		return nil, false
+	}
+	stmt := file.Stmts[0]
+	call, ok := stmt.Cmd.(*syntax.CallExpr)
+	if !ok || len(call.Args) == 0 || len(stmt.Redirs) > 0 || stmt.Background || stmt.Negated {
+		return nil, false
+	}
+
+	return call, true
+}
+
+// rootReason returns what the program name does with args that only root
+// can, if anything.
+func rootReason(name string, args []string) string {
+	switch {
+	case rootPrograms[name] || strings.HasPrefix(name, "mkfs"):
+		if (name == "mount" || name == "umount" || name == "ufw" || name == "iptables") && len(operands(args)) == 0 && !hasFlag(args, "a", "A", "D", "I", "F", "--all") {
+			// listing the mounts or the rules
+			return ""
+		}
+		return name
+	case name == "pacman":
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "-R") || strings.HasPrefix(arg, "-U") ||
+				strings.HasPrefix(arg, "-S") && !strings.ContainsAny(arg[2:], "silgp") {
+				return fmt.Sprintf("pacman %s", arg)
+			}
+		}
+	case name == "systemctl" && hasFlag(args, "--user"):
+		return ""
+	case rootSubcommands[name] != nil:
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "-") {
+				continue
+			}
+			if rootSubcommands[name][arg] {
+				return fmt.Sprintf("%s %s", name, arg)
+			}
+			if name != "service" {
+				return ""
+			}
+		}
+	}
+
+	var targets []string
+	switch name {
+	case "tee", "touch", "mkdir", "rm", "rmdir", "truncate", "chmod", "ln":
+		targets = operands(args)
+	case "cp", "mv", "install", "rsync":
+		if all := operands(args); len(all) > 1 {
+			targets = all[len(all)-1:]
+		}
+	case "chown", "chgrp":
+		// the first operand is the owner or the group
+		if all := operands(args); len(all) > 1 {
+			targets = all[1:]
+		}
+	case "sed":
+		if hasInPlaceFlag(args) {
+			targets = editedFiles(args, "-e", "--expression", "-f", "--file")
+		}
+	case "perl":
+		if hasInPlaceFlag(args) {
+			targets = editedFiles(args, "-e", "-E")
+		}
+	}
+	for _, target := range targets {
+		if isSystemPath(target) {
+			return fmt.Sprintf("writes to %s", target)
+		}
+	}
+
+	return ""
+}
+
+// isSystemPath reports whether target is in one of the system directories,
+// the temporary ones left out.
+func isSystemPath(target string) bool {
+	if !filepath.IsAbs(target) || strings.ContainsAny(target, "$`") || harmlessWriteTargets[target] {
+		return false
+	}
+
+	target = filepath.Clean(target)
+	if isWithin(target, "/var/tmp") {
+		return false
+	}
+	for _, directory := range systemDirectories {
+		if isWithin(target, directory) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// quoteWord quotes s for the shells, fish included.
+func quoteWord(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,51 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestElevateCommand(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cmd      string
+		tool     string
+		hint     bool
+		root     bool
+		expected string
+		elevated bool
+		reasons  []string
+	}{
+		{"Install", "apt-get install -y nginx", "sudo", false, false, "sudo apt-get install -y nginx", true, []string{"apt-get install"}},
+		{"Service", "systemctl restart nginx && systemctl status nginx", "doas", false, false, "doas systemctl restart nginx && systemctl status nginx", true, []string{"systemctl restart"}},
+		{"UserService", "systemctl --user restart pipewire", "sudo", false, false, "systemctl --user restart pipewire", false, nil},
+		{"Pacman", "pacman -Syu", "sudo", false, false, "sudo pacman -Syu", true, []string{"pacman -Syu"}},
+		{"PacmanSearch", "pacman -Ss vim", "sudo", false, false, "pacman -Ss vim", false, nil},
+		{"SystemFile", "sed -i 's/a/b/' /etc/hosts", "sudo", false, false, "sudo sed -i 's/a/b/' /etc/hosts", true, []string{"writes to /etc/hosts"}},
+		{"TemporaryFile", "rm -f /var/tmp/cache", "sudo", false, false, "rm -f /var/tmp/cache", false, nil},
+		{"Redirect", "echo 1 > /proc/x; echo 127.0.0.1 x >> /etc/hosts", "sudo", false, false, `sudo sh -c 'echo 1 > /proc/x; echo 127.0.0.1 x >> /etc/hosts'`, true, []string{"writes to /etc/hosts"}},
+		{"Replace", "doas apt install git", "sudo", false, false, "sudo apt install git", true, []string{"apt install"}},
+		{"Keep", "sudo -u postgres psql", "doas", false, false, "sudo -u postgres psql", true, nil},
+		{"AlreadyElevated", "sudo apt install git", "sudo", false, false, "sudo apt install git", true, []string{"apt install"}},
+		{"Root", "sudo apt install git && mount /dev/sdb1 /mnt", "sudo", false, true, "apt install git && mount /dev/sdb1 /mnt", true, []string{"apt install", "mount"}},
+		{"Hint", "wg show", "pkexec", true, false, "pkexec wg show", true, []string{"the model says it needs root"}},
+		{"HintPipeline", "dmesg | tail", "sudo", true, false, `sudo sh -c 'dmesg | tail'`, true, []string{"the model says it needs root"}},
+		{"Unprivileged", "ls -la /etc", "sudo", false, false, "ls -la /etc", false, nil},
+		{"Chown", "chown -R www-data: /srv/www", "sudo", false, false, "sudo chown -R www-data: /srv/www", true, []string{"writes to /srv/www"}},
+		{"ChownHome", "chgrp -R staff ~/project", "sudo", false, false, "chgrp -R staff ~/project", false, nil},
+		{"Detect", "apt install git && sudo -u bob ls", "", false, false, "apt install git && sudo -u bob ls", true, []string{"apt install"}},
+		{"DetectOnly", "apt install git", "", false, false, "apt install git", false, []string{"apt install"}},
+		{"Unparsable", "apt install 'broken", "sudo", false, false, "apt install 'broken", false, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			elevation := ElevateCommand(tc.cmd, tc.tool, tc.hint, tc.root)
+			assert.Equal(t, tc.expected, elevation.GetCommand())
+			assert.Equal(t, tc.elevated, elevation.IsElevated(), "The command should be run as root or not.")
+			assert.Equal(t, tc.reasons, elevation.GetReasons())
+			assert.Equal(t, tc.cmd != tc.expected, elevation.IsChanged())
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package run
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+type Dialect int
+
+const (
+	UnknownDialect Dialect = iota
+	BashDialect
+	ZshDialect
+	FishDialect
+	PosixDialect
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case BashDialect:
+		return "bash"
+	case ZshDialect:
+		return "zsh"
+	case FishDialect:
+		return "fish"
+	case PosixDialect:
+		return "sh"
+	default:
+		return "unknown"
+	}
+}
+
+// GetDialectFromString accepts a dialect name, a shell name or a shell path
+// such as /usr/bin/zsh.
+func GetDialectFromString(s string) Dialect {
+	switch strings.ToLower(filepath.Base(strings.TrimSpace(s))) {
+	case "bash":
+		return BashDialect
+	case "zsh":
+		return ZshDialect
+	case "fish":
+		return FishDialect
+	case "sh", "posix", "dash", "ash":
+		return PosixDialect
+	default:
+		return UnknownDialect
+	}
+}
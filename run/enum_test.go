@@ -0,0 +1,54 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialect(t *testing.T) {
+	t.Run("String", testDialectString)
+	t.Run("GetDialectFromString", testGetDialectFromString)
+}
+
+func testDialectString(t *testing.T) {
+	testCases := []struct {
+		name     string
+		dialect  Dialect
+		expected string
+	}{
+		{"Unknown", UnknownDialect, "unknown"},
+		{"Bash", BashDialect, "bash"},
+		{"Zsh", ZshDialect, "zsh"},
+		{"Fish", FishDialect, "fish"},
+		{"Posix", PosixDialect, "sh"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.dialect.String(), "The string representation should match the expected value.")
+		})
+	}
+}
+
+func testGetDialectFromString(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected Dialect
+	}{
+		{"Bash", "bash", BashDialect},
+		{"ZshPath", "/usr/bin/zsh", ZshDialect},
+		{"Fish", "Fish", FishDialect},
+		{"Posix", "posix", PosixDialect},
+		{"Dash", "dash", PosixDialect},
+		{"Unknown", "pwsh", UnknownDialect},
+		{"Empty", "", UnknownDialect},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, GetDialectFromString(tc.input), "The dialect should match the expected value.")
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package run
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const (
+	// output_drain_timeout bounds the wait for the output of a command once
+	// it exited, in case a background process keeps its terminal open.
+	output_drain_timeout = 500 * time.Millisecond
+	// kill_grace is how long a command stopped for going over a limit has to
+	// exit before it is killed.
+	kill_grace = 2 * time.Second
+)
+
+// Execution runs a command in a shell, streaming its output to the terminal
+// while keeping the end of its stdout and stderr. Interactive programs are
+// handed the terminal as is, without capture, nor timeout.
+type Execution struct {
+	command      string
+	shell        Shell
+	interactive  bool
+	limits       Limits
+	session      *Session
+	host         string
+	change       SessionChange
+	stdin        io.Reader
+	stdout       io.Writer
+	stderr       io.Writer
+	stdoutBuffer *tailBuffer
+	stderrBuffer *tailBuffer
+	watch        *outputWatch
+	enforcer     limitEnforcer
+	exitCode     int
+	signal       string
+	duration     time.Duration
+}
+
+// NewExecution returns the execution of command in shell, keeping the last
+// limit bytes of its stdout and of its stderr.
+func NewExecution(command string, shell Shell, limit int) *Execution {
+	return &Execution{
+		command:      command,
+		shell:        shell,
+		interactive:  IsInteractiveProgram(command),
+		stdin:        os.Stdin,
+		stdout:       os.Stdout,
+		stderr:       os.Stderr,
+		stdoutBuffer: newTailBuffer(limit),
+		stderrBuffer: newTailBuffer(limit),
+		exitCode:     -1,
+	}
+}
+
+// SetLimits sets the limits the command runs within.
+func (e *Execution) SetLimits(limits Limits) {
+	e.limits = limits
+}
+
+// SetSession runs the command in session, starting from the working
+// directory and environment the previous commands left.
+func (e *Execution) SetSession(session *Session) {
+	e.session = session
+}
+
+// SetHost runs the command on host over ssh. The working directory and
+// environment of the session are not carried there, and the resource limits
+// are not set.
+func (e *Execution) SetHost(host string) {
+	e.host = host
+}
+
+func (e *Execution) SetStdin(r io.Reader) {
+	e.stdin = r
+}
+
+func (e *Execution) SetStdout(w io.Writer) {
+	e.stdout = w
+}
+
+func (e *Execution) SetStderr(w io.Writer) {
+	e.stderr = w
+}
+
+// Run runs the command between two blank lines. The error is the one of the
+// command, an *exec.ExitError when it did not exit with a zero status.
+func (e *Execution) Run() error {
+	cmd := e.shell.Command(e.command)
+	if e.host != "" {
+		cmd = RemoteCommand(e.host, e.command, true)
+	}
+	var state string
+	if e.session != nil && e.host == "" {
+		if file, err := os.CreateTemp("", "xang-state-*"); err == nil {
+			file.Close()
+			state = file.Name()
+			defer os.Remove(state)
+			cmd = e.shell.Command(e.session.wrap(e.command, e.shell.GetDialect()))
+			e.session.prepare(cmd)
+			cmd.Env = append(cmd.Env, session_state_variable+"="+state)
+		}
+	}
+	if e.limits.HasResourceLimits() && e.host == "" {
+		cmd = limitResources(cmd, e.limits)
+	}
+	e.watch = &outputWatch{
+		limit: e.limits.Output,
+		exceeded: func() {
+			e.stop(cmd, fmt.Sprintf("output limit of %s", formatSize(e.limits.Output)))
+		},
+	}
+
+	fmt.Fprint(e.stdout, "\n\n")
+	start := time.Now()
+
+	var err error
+	if e.interactive {
+		cmd.Stdin = e.stdin
+		cmd.Stdout = e.stdout
+		cmd.Stderr = e.stderr
+		err = cmd.Run()
+	} else {
+		err = e.runCaptured(cmd)
+	}
+	e.enforcer.finish()
+
+	e.duration = time.Since(start)
+	if cmd.ProcessState != nil {
+		e.exitCode = cmd.ProcessState.ExitCode()
+		e.signal = signalName(cmd.ProcessState)
+		if limit := exceededResourceLimit(e.limits, cmd.ProcessState, e.GetStdout()+e.GetStderr()); limit != "" {
+			e.enforcer.exceeded(limit)
+		}
+	}
+	if state != "" {
+		e.change, _ = e.session.apply(state)
+	}
+	fmt.Fprint(e.stdout, "\n\n")
+
+	return err
+}
+
+// enforceTimeout kills cmd, once started, when it runs longer than the
+// timeout. The returned function stops the timer.
+func (e *Execution) enforceTimeout(cmd *exec.Cmd) func() bool {
+	if e.limits.Timeout <= 0 {
+		return func() bool { return false }
+	}
+
+	timer := time.AfterFunc(e.limits.Timeout, func() {
+		e.stop(cmd, fmt.Sprintf("timeout of %s", e.limits.Timeout))
+	})
+	return timer.Stop
+}
+
+// stop terminates cmd, which went over limit, and kills it if it does not
+// exit within the grace period.
+func (e *Execution) stop(cmd *exec.Cmd, limit string) {
+	e.enforcer.stop(cmd, limit)
+}
+
+func (e *Execution) GetCommand() string {
+	return e.command
+}
+
+// IsCaptured reports whether the output of the command was captured, which it
+// is not for interactive programs.
+func (e *Execution) IsCaptured() bool {
+	return !e.interactive
+}
+
+// GetExitCode returns the exit status of the command, -1 when it did not exit
+// by itself.
+func (e *Execution) GetExitCode() int {
+	return e.exitCode
+}
+
+// GetSignal returns the name of the signal that killed the command, if any.
+func (e *Execution) GetSignal() string {
+	return e.signal
+}
+
+// GetLimit returns the limit the command went over, such as "timeout of 30s"
+// or "memory limit of 2G", if any.
+func (e *Execution) GetLimit() string {
+	return e.enforcer.getLimit()
+}
+
+func (e *Execution) GetDuration() time.Duration {
+	return e.duration
+}
+
+// GetStdout returns the end of what the command printed on its terminal.
+func (e *Execution) GetStdout() string {
+	return e.stdoutBuffer.String()
+}
+
+// GetStderr returns the end of what the command printed on its stderr.
+func (e *Execution) GetStderr() string {
+	return e.stderrBuffer.String()
+}
+
+// GetSessionChange returns what the command changed of the working
+// directory and environment of its session.
+func (e *Execution) GetSessionChange() SessionChange {
+	return e.change
+}
+
+// IsTruncated reports whether the beginning of the output was dropped.
+func (e *Execution) IsTruncated() bool {
+	return e.stdoutBuffer.IsTruncated() || e.stderrBuffer.IsTruncated()
+}
@@ -0,0 +1,55 @@
+//go:build !unix
+
+package run
+
+import (
+	"io"
+	"os"
+	"os/exec"
+)
+
+// runCaptured runs cmd, copying its output to the terminal and to the
+// buffers. Without pseudo terminals, the command sees pipes instead of a
+// terminal.
+func (e *Execution) runCaptured(cmd *exec.Cmd) error {
+	cmd.Stdin = e.stdin
+	cmd.Stdout = io.MultiWriter(e.stdout, e.stdoutBuffer, e.watch)
+	cmd.Stderr = io.MultiWriter(e.stderr, e.stderrBuffer, e.watch)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	defer e.enforceTimeout(cmd)()
+
+	return cmd.Wait()
+}
+
+// signalName returns an empty string, signals are not reported on this
+// platform.
+func signalName(state *os.ProcessState) string {
+	return ""
+}
+
+// isCPULimitExceeded returns false, resource limits are not set on this
+// platform.
+func isCPULimitExceeded(state *os.ProcessState) bool {
+	return false
+}
+
+// limitResources returns cmd as is, resource limits are not set on this
+// platform.
+func limitResources(cmd *exec.Cmd, limits Limits) *exec.Cmd {
+	return cmd
+}
+
+// terminate kills the process of cmd, there being no grace period on this
+// platform.
+func terminate(cmd *exec.Cmd) {
+	kill(cmd)
+}
+
+// kill kills the process of cmd.
+func kill(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+}
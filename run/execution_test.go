@@ -0,0 +1,142 @@
+//go:build unix
+
+package run
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecution(t *testing.T) {
+	t.Run("Run", testExecutionRun)
+	t.Run("RunExitCode", testExecutionRunExitCode)
+	t.Run("RunSignal", testExecutionRunSignal)
+	t.Run("RunInteractive", testExecutionRunInteractive)
+	t.Run("RunTimeout", testExecutionRunTimeout)
+	t.Run("RunOutputLimit", testExecutionRunOutputLimit)
+	t.Run("RunCPULimit", testExecutionRunCPULimit)
+	t.Run("RunFilesLimit", testExecutionRunFilesLimit)
+	t.Run("RunResourceLimitExceeded", testExecutionRunResourceLimitExceeded)
+}
+
+// newTestExecution returns the execution of cmd in sh, printing to stdout and
+// stderr.
+func newTestExecution(cmd string, stdout *bytes.Buffer, stderr *bytes.Buffer) *Execution {
+	execution := NewExecution(cmd, NewShell("sh", false), 1024)
+	execution.SetStdin(strings.NewReader(""))
+	execution.SetStdout(stdout)
+	execution.SetStderr(stderr)
+	return execution
+}
+
+func testExecutionRun(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	execution := newTestExecution("echo out; echo err >&2", &stdout, &stderr)
+
+	err := execution.Run()
+	require.NoError(t, err)
+
+	assert.True(t, execution.IsCaptured(), "The output should be captured.")
+	assert.Equal(t, 0, execution.GetExitCode(), "The command should exit with 0.")
+	assert.Empty(t, execution.GetSignal(), "The command should not be killed.")
+	assert.Positive(t, execution.GetDuration(), "The duration should be measured.")
+	assert.Contains(t, execution.GetStdout(), "out", "The stdout should be captured.")
+	assert.Equal(t, "err\n", execution.GetStderr(), "The stderr should be captured.")
+	assert.Contains(t, stdout.String(), "out", "The stdout should be streamed.")
+	assert.Equal(t, "err\n", stderr.String(), "The stderr should be streamed.")
+}
+
+func testExecutionRunExitCode(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	execution := newTestExecution("exit 3", &stdout, &stderr)
+
+	err := execution.Run()
+
+	assert.Error(t, err, "A non-zero status should be an error.")
+	assert.Equal(t, 3, execution.GetExitCode(), "The exit status should be kept.")
+}
+
+func testExecutionRunSignal(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	execution := newTestExecution("kill -TERM $$", &stdout, &stderr)
+
+	err := execution.Run()
+
+	assert.Error(t, err, "A killed command should be an error.")
+	assert.Equal(t, -1, execution.GetExitCode(), "A killed command has no exit status.")
+	assert.Equal(t, "terminated", execution.GetSignal(), "The signal should be named.")
+}
+
+func testExecutionRunTimeout(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	execution := newTestExecution("sleep 10", &stdout, &stderr)
+	execution.SetLimits(Limits{Timeout: 200 * time.Millisecond})
+
+	err := execution.Run()
+
+	assert.Error(t, err, "A command killed by its timeout should be an error.")
+	assert.Equal(t, "timeout of 200ms", execution.GetLimit(), "The timeout should be the limit.")
+	assert.Equal(t, "terminated", execution.GetSignal(), "The command should be terminated.")
+	assert.Less(t, execution.GetDuration(), 5*time.Second, "The command should not run to its end.")
+}
+
+func testExecutionRunOutputLimit(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	execution := newTestExecution("yes", &stdout, &stderr)
+	execution.SetLimits(Limits{Output: 64 << 10})
+
+	err := execution.Run()
+
+	assert.Error(t, err, "A command killed for its output should be an error.")
+	assert.Equal(t, "output limit of 64K", execution.GetLimit(), "The output limit should be the limit.")
+}
+
+func testExecutionRunCPULimit(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	execution := newTestExecution("while :; do :; done", &stdout, &stderr)
+	execution.SetLimits(Limits{CPU: time.Second, Timeout: 10 * time.Second})
+
+	err := execution.Run()
+
+	assert.Error(t, err, "A command killed for its CPU time should be an error.")
+	assert.Equal(t, "cpu limit of 1s", execution.GetLimit(), "The CPU limit should be the limit.")
+}
+
+func testExecutionRunFilesLimit(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	execution := newTestExecution("ulimit -n", &stdout, &stderr)
+	execution.SetLimits(Limits{Files: 42})
+
+	err := execution.Run()
+	require.NoError(t, err)
+
+	assert.Contains(t, execution.GetStdout(), "42", "The limit should be set for the command.")
+	assert.Empty(t, execution.GetLimit(), "A command within its limits should not be stopped.")
+}
+
+func testExecutionRunResourceLimitExceeded(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	execution := newTestExecution("echo 'fatal error: runtime: out of memory' >&2; exit 2", &stdout, &stderr)
+	execution.SetLimits(Limits{Memory: 1 << 30})
+
+	err := execution.Run()
+	require.Error(t, err)
+
+	assert.Equal(t, "memory limit of 1G", execution.GetLimit(), "Failing to allocate memory should be reported.")
+	assert.Equal(t, 2, execution.GetExitCode(), "The command should exit by itself.")
+}
+
+func testExecutionRunInteractive(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	execution := newTestExecution("less /dev/null", &stdout, &stderr)
+
+	execution.Run()
+
+	assert.False(t, execution.IsCaptured(), "The output of an interactive program should not be captured.")
+	assert.Empty(t, execution.GetStdout(), "Nothing should be captured.")
+}
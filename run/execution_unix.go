@@ -0,0 +1,165 @@
+//go:build unix
+
+package run
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+	"github.com/muesli/cancelreader"
+	"golang.org/x/term"
+)
+
+// runCaptured runs cmd in a pseudo terminal, so that it behaves as it would in
+// the user's one, copying what it prints there and on its stderr to the
+// terminal and to the buffers.
+func (e *Execution) runCaptured(cmd *exec.Cmd) error {
+	stderr := e.stderr
+
+	// Keystrokes go through the terminal of the command, the user's one only
+	// passes them along
+	if file, ok := e.stdin.(*os.File); ok && term.IsTerminal(int(file.Fd())) {
+		state, err := term.MakeRaw(int(file.Fd()))
+		if err == nil {
+			defer term.Restore(int(file.Fd()), state)
+			stderr = crlfWriter{stderr}
+		}
+	}
+	cmd.Stderr = io.MultiWriter(stderr, e.stderrBuffer, e.watch)
+
+	ptmx, err := pty.Start(cmd)
+	if err != nil {
+		return err
+	}
+	defer ptmx.Close()
+	defer e.enforceTimeout(cmd)()
+
+	if file, ok := e.stdin.(*os.File); ok && term.IsTerminal(int(file.Fd())) {
+		_ = pty.InheritSize(file, ptmx)
+
+		resize := make(chan os.Signal, 1)
+		signal.Notify(resize, syscall.SIGWINCH)
+		defer func() {
+			signal.Stop(resize)
+			close(resize)
+		}()
+		go func() {
+			for range resize {
+				_ = pty.InheritSize(file, ptmx)
+			}
+		}()
+	}
+
+	// The reader is cancelled once the command exits so that it does not keep
+	// input meant for the prompt
+	if reader, err := cancelreader.NewReader(e.stdin); err == nil {
+		defer reader.Cancel()
+		go io.Copy(ptmx, reader)
+	}
+
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(io.MultiWriter(e.stdout, e.stdoutBuffer, e.watch), ptmx)
+		close(copied)
+	}()
+
+	err = cmd.Wait()
+	select {
+	case <-copied:
+	case <-time.After(output_drain_timeout):
+	}
+
+	return err
+}
+
+// signalName returns the name of the signal that killed the process of state,
+// if any.
+func signalName(state *os.ProcessState) string {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return ""
+	}
+
+	return status.Signal().String()
+}
+
+// isCPULimitExceeded reports whether the process of state was killed for
+// going over its CPU time limit.
+func isCPULimitExceeded(state *os.ProcessState) bool {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	return ok && status.Signaled() && status.Signal() == syscall.SIGXCPU
+}
+
+// limitResources returns cmd run by a shell that first sets the resource
+// limits of its process. Only the soft CPU limit is set, so that going over
+// it sends SIGXCPU rather than SIGKILL.
+func limitResources(cmd *exec.Cmd, limits Limits) *exec.Cmd {
+	if cmd.Err != nil {
+		return cmd
+	}
+
+	var ulimits []string
+	if limits.CPU > 0 {
+		seconds := (limits.CPU + time.Second - 1) / time.Second
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -S -t %d", seconds))
+	}
+	if limits.Memory > 0 {
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -v %d", (limits.Memory+1023)/1024))
+	}
+	if limits.Files > 0 {
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -n %d", limits.Files))
+	}
+	script := strings.Join(ulimits, " && ") + ` && exec "$@"`
+
+	args := append([]string{"-c", script, "xang-limits", cmd.Path}, cmd.Args[1:]...)
+	limited := exec.Command("/bin/sh", args...)
+	limited.Env = cmd.Env
+	limited.Dir = cmd.Dir
+
+	return limited
+}
+
+// terminate sends SIGTERM to the process group of cmd, the one of the
+// terminal it runs in.
+func terminate(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM); err != nil {
+		cmd.Process.Signal(syscall.SIGTERM)
+	}
+}
+
+// kill sends SIGKILL to the process group of cmd.
+func kill(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+		cmd.Process.Kill()
+	}
+}
+
+// crlfWriter ends lines with a carriage return, which a terminal in raw mode
+// no longer adds.
+type crlfWriter struct {
+	w io.Writer
+}
+
+func (c crlfWriter) Write(p []byte) (int, error) {
+	if _, err := c.w.Write(bytes.ReplaceAll(p, []byte("\n"), []byte("\r\n"))); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,117 @@
+package run
+
+import (
+	"path"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// interactivePrograms take over the whole terminal, so their output is not
+// worth capturing.
+var interactivePrograms = map[string]bool{
+	"vi": true, "vim": true, "nvim": true, "view": true, "nano": true, "pico": true,
+	"emacs": true, "micro": true, "hx": true, "helix": true, "joe": true,
+	"less": true, "more": true, "most": true, "man": true,
+	"top": true, "htop": true, "btop": true, "atop": true, "iotop": true, "nmon": true, "watch": true,
+	"ssh": true, "mosh": true, "telnet": true, "ftp": true, "sftp": true,
+	"tmux": true, "screen": true,
+	"mc": true, "ranger": true, "nnn": true, "lf": true, "tig": true, "lazygit": true, "fzf": true,
+}
+
+// commandWrappers run the command given as their arguments.
+var commandWrappers = map[string]bool{
+	"sudo": true, "doas": true, "env": true, "exec": true, "command": true, "builtin": true,
+	"nice": true, "nohup": true, "time": true, "stdbuf": true, "pkexec": true,
+}
+
+// wrapperValueOptions are the options of the command wrappers that take the
+// next word as their value, such as the user of sudo -u.
+var wrapperValueOptions = map[string][]string{
+	"sudo":   {"-u", "-g", "-C", "-h", "-p", "-D", "--user", "--group", "--close-from", "--host", "--prompt", "--chdir"},
+	"doas":   {"-u", "-C"},
+	"env":    {"-u", "-C", "-S", "--unset", "--chdir", "--split-string"},
+	"nice":   {"-n", "--adjustment"},
+	"stdbuf": {"-i", "-o", "-e", "--input", "--output", "--error"},
+	"time":   {"-f", "-o", "--format", "--output"},
+	"pkexec": {"--user"},
+}
+
+// elevatingWrappers run the command given as their arguments as root.
+var elevatingWrappers = map[string]bool{
+	"sudo": true, "doas": true, "pkexec": true,
+}
+
+// IsInteractiveProgram reports whether cmd runs a program that takes over the
+// terminal, such as an editor, a pager, a monitor or a remote shell.
+func IsInteractiveProgram(cmd string) bool {
+	file, err := syntax.NewParser().Parse(strings.NewReader(cmd), "")
+	if err != nil {
+		return isInteractiveCall(strings.Fields(cmd))
+	}
+
+	interactive := false
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if call, ok := node.(*syntax.CallExpr); ok && !interactive {
+			words := make([]string, 0, len(call.Args))
+			for _, arg := range call.Args {
+				words = append(words, arg.Lit())
+			}
+			interactive = isInteractiveCall(words)
+		}
+		return !interactive
+	})
+
+	return interactive
+}
+
+// isInteractiveCall reports whether the program of the call made of words is
+// an interactive one.
+func isInteractiveCall(words []string) bool {
+	name, _, _ := callProgram(words)
+	return interactivePrograms[name]
+}
+
+// callProgram returns the name of the program the call made of words runs,
+// past its wrappers and their options, along with its arguments and whether a
+// wrapper runs it as root. The name is empty when it cannot be known, such as
+// when it is an expansion.
+func callProgram(words []string) (string, []string, bool) {
+	wrapper, elevated := "", false
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		switch {
+		case word == "":
+			return "", nil, elevated
+		case wrapper != "" && word == "--":
+			wrapper = ""
+			continue
+		case wrapper != "" && takesValue(wrapper, word):
+			i++
+			continue
+		case wrapper != "" && (strings.HasPrefix(word, "-") || strings.Contains(word, "=")):
+			continue
+		}
+
+		name := path.Base(word)
+		if commandWrappers[name] {
+			wrapper = name
+			elevated = elevated || elevatingWrappers[name]
+			continue
+		}
+		return name, words[i+1:], elevated
+	}
+
+	return "", nil, elevated
+}
+
+// takesValue reports whether option of wrapper takes the next word as its
+// value.
+func takesValue(wrapper string, option string) bool {
+	for _, candidate := range wrapperValueOptions[wrapper] {
+		if option == candidate {
+			return true
+		}
+	}
+	return false
+}
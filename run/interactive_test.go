@@ -0,0 +1,33 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsInteractiveProgram(t *testing.T) {
+	testCases := []struct {
+		name        string
+		cmd         string
+		interactive bool
+	}{
+		{"Editor", "vim /etc/hosts", true},
+		{"Path", "/usr/bin/htop", true},
+		{"Pipeline", "journalctl -u nginx | less", true},
+		{"RemoteShell", "ssh deploy@example.com", true},
+		{"Wrapped", "sudo -E nano /etc/fstab", true},
+		{"WrappedEnv", "env TERM=xterm top", true},
+		{"Chained", "cd /tmp && ls -la", false},
+		{"Plain", "ls -la", false},
+		{"Argument", "grep vim notes.txt", false},
+		{"Expansion", "$EDITOR notes.txt", false},
+		{"Unparsable", "vim 'broken", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.interactive, IsInteractiveProgram(tc.cmd))
+		})
+	}
+}
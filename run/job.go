@@ -0,0 +1,437 @@
+package run
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/muesli/cancelreader"
+)
+
+const (
+	// job_output_limit bounds how much of the end of the output of a job is
+	// kept in memory, the log file holding all of it.
+	job_output_limit = 8192
+	// job_follow_interval is how often the log of a job is checked for more
+	// output.
+	job_follow_interval = 200 * time.Millisecond
+)
+
+// Job is a command running in the background, its output going to a log
+// file.
+type Job struct {
+	mu       sync.Mutex
+	id       int
+	command  string
+	logPath  string
+	limits   Limits
+	cmd      *exec.Cmd
+	output   *tailBuffer
+	start    time.Time
+	end      time.Time
+	exitCode int
+	signal   string
+	err      error
+	enforcer limitEnforcer
+	done     chan struct{}
+}
+
+func (j *Job) GetId() int {
+	return j.id
+}
+
+func (j *Job) GetCommand() string {
+	return j.command
+}
+
+// GetLogPath returns the path of the file the output of the job goes to.
+func (j *Job) GetLogPath() string {
+	return j.logPath
+}
+
+func (j *Job) GetStart() time.Time {
+	return j.start
+}
+
+// GetDuration returns how long the job ran, or has been running.
+func (j *Job) GetDuration() time.Duration {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.end.IsZero() {
+		return time.Since(j.start)
+	}
+	return j.end.Sub(j.start)
+}
+
+// IsRunning reports whether the job is not over yet.
+func (j *Job) IsRunning() bool {
+	select {
+	case <-j.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Done returns a channel closed once the job is over.
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// GetOutput returns the outcome of the job, once it is over.
+func (j *Job) GetOutput() JobOutput {
+	<-j.done
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	output := RunOutput{
+		error:     j.err,
+		executed:  true,
+		captured:  true,
+		exitCode:  j.exitCode,
+		signal:    j.signal,
+		limit:     j.enforcer.getLimit(),
+		duration:  j.end.Sub(j.start),
+		stdout:    cleanOutput(j.output.String()),
+		truncated: j.output.IsTruncated(),
+	}
+
+	switch {
+	case output.limit != "":
+		if output.error == nil {
+			output.error = errors.New(describeLimit(output.limit, output.exitCode))
+		}
+		output.errorMessage = fmt.Sprintf("Job %d stopped (%s)", j.id, output.GetSummary())
+	case output.error != nil:
+		output.errorMessage = fmt.Sprintf("Job %d failed (%s)", j.id, output.GetSummary())
+	default:
+		output.successMessage = fmt.Sprintf("Job %d finished (%s)", j.id, output.GetSummary())
+	}
+
+	return JobOutput{
+		id:      j.id,
+		command: j.command,
+		logPath: j.logPath,
+		output:  output,
+	}
+}
+
+// GetStatus returns how the job is doing, such as "running" or "exit 0".
+func (j *Job) GetStatus() string {
+	if j.IsRunning() {
+		return "running"
+	}
+
+	output := j.GetOutput().GetRunOutput()
+	if output.limit != "" {
+		return describeLimit(output.limit, output.exitCode)
+	}
+	if output.signal != "" {
+		return fmt.Sprintf("killed by %s", output.signal)
+	}
+	return fmt.Sprintf("exit %d", output.exitCode)
+}
+
+// Kill stops the job, killing it if it does not exit within the grace period.
+func (j *Job) Kill() error {
+	if !j.IsRunning() {
+		return fmt.Errorf("job %d is not running", j.id)
+	}
+
+	j.enforcer.stop(j.cmd, "")
+	return nil
+}
+
+// Tail returns the last lines of the log of the job.
+func (j *Job) Tail(lines int) (string, error) {
+	file, err := os.Open(j.logPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	var tail []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		tail = append(tail, scanner.Text())
+		if len(tail) > lines {
+			tail = tail[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return cleanOutput(strings.Join(tail, "\n")), nil
+}
+
+// stop terminates the job, which went over limit.
+func (j *Job) stop(limit string) {
+	j.enforcer.stop(j.cmd, limit)
+}
+
+func (j *Job) wait() {
+	defer close(j.done)
+
+	stopTimeout := func() bool { return false }
+	if j.limits.Timeout > 0 {
+		timer := time.AfterFunc(j.limits.Timeout, func() {
+			j.stop(fmt.Sprintf("timeout of %s", j.limits.Timeout))
+		})
+		stopTimeout = timer.Stop
+	}
+	exited := make(chan struct{})
+	followed := make(chan struct{})
+	go func() {
+		defer close(followed)
+		j.follow(exited)
+	}()
+
+	err := j.cmd.Wait()
+	stopTimeout()
+	j.enforcer.finish()
+	close(exited)
+	<-followed
+	if limit := exceededResourceLimit(j.limits, j.cmd.ProcessState, j.output.String()); limit != "" {
+		j.enforcer.exceeded(limit)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.end = time.Now()
+	j.err = err
+	j.exitCode = j.cmd.ProcessState.ExitCode()
+	j.signal = signalName(j.cmd.ProcessState)
+}
+
+// follow reads the log of the job as it is written, keeping its end and
+// stopping the job when it goes over the output limit, until exited is
+// closed and what was left is read.
+func (j *Job) follow(exited <-chan struct{}) {
+	file, err := os.Open(j.logPath)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	watch := &outputWatch{
+		limit: j.limits.Output,
+		exceeded: func() {
+			j.stop(fmt.Sprintf("output limit of %s", formatSize(j.limits.Output)))
+		},
+	}
+	writer := io.MultiWriter(j.output, watch)
+
+	ticker := time.NewTicker(job_follow_interval)
+	defer ticker.Stop()
+	for {
+		io.Copy(writer, file)
+
+		select {
+		case <-exited:
+			io.Copy(writer, file)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// JobOutput is the outcome of a job.
+type JobOutput struct {
+	id      int
+	command string
+	logPath string
+	output  RunOutput
+}
+
+func (o JobOutput) GetId() int {
+	return o.id
+}
+
+func (o JobOutput) GetCommand() string {
+	return o.command
+}
+
+func (o JobOutput) GetLogPath() string {
+	return o.logPath
+}
+
+// GetRunOutput returns the outcome of the command of the job, as if it ran in
+// the foreground.
+func (o JobOutput) GetRunOutput() RunOutput {
+	return o.output
+}
+
+// Jobs are the commands started in the background during a session.
+type Jobs struct {
+	mu        sync.Mutex
+	directory string
+	session   *Session
+	jobs      []*Job
+}
+
+// NewJobs returns the jobs of a session, their logs going to directory.
+func NewJobs(directory string) *Jobs {
+	return &Jobs{directory: directory}
+}
+
+// SetSession starts the jobs from the working directory and environment of
+// session.
+func (j *Jobs) SetSession(session *Session) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.session = session
+}
+
+// Start starts command in shell in the background, within limits, its input
+// being empty and its output going to a log file.
+func (j *Jobs) Start(command string, shell Shell, limits Limits) (*Job, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	id := len(j.jobs) + 1
+	if err := os.MkdirAll(j.directory, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create the jobs directory: %w", err)
+	}
+	start := time.Now()
+	logPath := filepath.Join(j.directory, fmt.Sprintf("%s-%d.log", start.Format("20060102-150405"), id))
+	log, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the job log: %w", err)
+	}
+
+	job := &Job{
+		id:       id,
+		command:  command,
+		logPath:  logPath,
+		limits:   limits,
+		output:   newTailBuffer(job_output_limit),
+		start:    start,
+		exitCode: -1,
+		done:     make(chan struct{}),
+	}
+
+	cmd := shell.Command(command)
+	if j.session != nil {
+		j.session.prepare(cmd)
+	}
+	if limits.HasResourceLimits() {
+		cmd = limitResources(cmd, limits)
+	}
+	// The output goes to the log file itself, not through xang, for the job
+	// to keep running once xang exits
+	cmd.Stdout = log
+	cmd.Stderr = log
+	detach(cmd)
+	job.cmd = cmd
+
+	err = cmd.Start()
+	log.Close()
+	if err != nil {
+		os.Remove(logPath)
+		return nil, err
+	}
+
+	j.jobs = append(j.jobs, job)
+	go job.wait()
+
+	return job, nil
+}
+
+// GetJobs returns the jobs, in the order they were started.
+func (j *Jobs) GetJobs() []*Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]*Job(nil), j.jobs...)
+}
+
+// GetJob returns the job numbered id.
+func (j *Jobs) GetJob(id int) (*Job, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if id < 1 || id > len(j.jobs) {
+		return nil, fmt.Errorf("no job %d", id)
+	}
+	return j.jobs[id-1], nil
+}
+
+// JobAttachment follows the log of a job on the terminal, until the job is
+// over or the user detaches from it.
+type JobAttachment struct {
+	job    *Job
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// NewJobAttachment returns the attachment to job.
+func NewJobAttachment(job *Job) *JobAttachment {
+	return &JobAttachment{
+		job:    job,
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}
+}
+
+func (a *JobAttachment) SetStdin(r io.Reader) {
+	a.stdin = r
+}
+
+func (a *JobAttachment) SetStdout(w io.Writer) {
+	a.stdout = w
+}
+
+func (a *JobAttachment) SetStderr(w io.Writer) {
+	a.stderr = w
+}
+
+// Run prints the log of the job and what it prints next, until it is over or
+// enter is pressed.
+func (a *JobAttachment) Run() error {
+	file, err := os.Open(a.job.GetLogPath())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fmt.Fprintf(a.stdout, "\n\nattached to job %d, press enter to detach\n\n", a.job.GetId())
+	detached := make(chan struct{})
+	if reader, err := cancelreader.NewReader(a.stdin); err == nil {
+		// The reader is cancelled on return so that it does not keep input
+		// meant for the prompt
+		defer reader.Cancel()
+		go func() {
+			bufio.NewReader(reader).ReadString('\n')
+			close(detached)
+		}()
+	}
+
+	ticker := time.NewTicker(job_follow_interval)
+	defer ticker.Stop()
+	for {
+		if _, err := io.Copy(a.stdout, file); err != nil {
+			return err
+		}
+
+		select {
+		case <-a.job.Done():
+			io.Copy(a.stdout, file)
+			fmt.Fprint(a.stdout, "\n\n")
+			return nil
+		case <-detached:
+			fmt.Fprint(a.stdout, "\n\n")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package run
+
+import "os/exec"
+
+// detach leaves cmd as is, process groups are not set on this platform.
+func detach(cmd *exec.Cmd) {
+}
@@ -0,0 +1,159 @@
+//go:build unix
+
+package run
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobs(t *testing.T) {
+	t.Run("Start", testJobsStart)
+	t.Run("StartFailure", testJobsStartFailure)
+	t.Run("Kill", testJobKill)
+	t.Run("Timeout", testJobTimeout)
+	t.Run("OutputLimit", testJobOutputLimit)
+	t.Run("LogFile", testJobLogFile)
+	t.Run("Tail", testJobTail)
+	t.Run("GetJob", testJobsGetJob)
+	t.Run("Attachment", testJobAttachment)
+}
+
+func startTestJob(t *testing.T, jobs *Jobs, cmd string, limits Limits) *Job {
+	job, err := jobs.Start(cmd, NewShell("sh", false), limits)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if job.IsRunning() {
+			job.Kill()
+			<-job.Done()
+		}
+	})
+	return job
+}
+
+func waitTestJob(t *testing.T, job *Job) {
+	select {
+	case <-job.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatalf("job %d did not end", job.GetId())
+	}
+}
+
+func testJobsStart(t *testing.T) {
+	jobs := NewJobs(t.TempDir())
+	job := startTestJob(t, jobs, "echo out; echo err >&2", Limits{})
+
+	waitTestJob(t, job)
+	output := job.GetOutput()
+
+	assert.Equal(t, 1, output.GetId(), "The first job should be numbered 1.")
+	assert.False(t, job.IsRunning(), "The job should be over.")
+	assert.Equal(t, "exit 0", job.GetStatus())
+	assert.False(t, output.GetRunOutput().HasError(), "The job should succeed.")
+	assert.Equal(t, "out\nerr\n", output.GetRunOutput().GetStdout(), "The output should be kept.")
+	assert.True(t, strings.HasPrefix(output.GetRunOutput().GetSuccessMessage(), "Job 1 finished (exit 0, "))
+	assert.FileExists(t, output.GetLogPath(), "The output should be logged.")
+}
+
+func testJobsStartFailure(t *testing.T) {
+	jobs := NewJobs(t.TempDir())
+	job := startTestJob(t, jobs, "exit 4", Limits{})
+
+	waitTestJob(t, job)
+	output := job.GetOutput().GetRunOutput()
+
+	assert.True(t, output.HasError(), "The job should fail.")
+	assert.Equal(t, 4, output.GetExitCode(), "The exit status should be kept.")
+	assert.True(t, strings.HasPrefix(output.GetErrorMessage(), "Job 1 failed (exit 4, "))
+}
+
+func testJobKill(t *testing.T) {
+	jobs := NewJobs(t.TempDir())
+	job := startTestJob(t, jobs, "sleep 10", Limits{})
+
+	require.NoError(t, job.Kill())
+	waitTestJob(t, job)
+
+	assert.Equal(t, "killed by terminated", job.GetStatus())
+	assert.Error(t, job.Kill(), "A job that is over can't be killed.")
+}
+
+func testJobTimeout(t *testing.T) {
+	jobs := NewJobs(t.TempDir())
+	job := startTestJob(t, jobs, "sleep 10", Limits{Timeout: 100 * time.Millisecond})
+
+	waitTestJob(t, job)
+	output := job.GetOutput().GetRunOutput()
+
+	assert.Equal(t, "timeout of 100ms", output.GetLimit(), "The timeout should be the limit.")
+	assert.Equal(t, "killed by the timeout of 100ms", job.GetStatus())
+}
+
+func testJobOutputLimit(t *testing.T) {
+	jobs := NewJobs(t.TempDir())
+	job := startTestJob(t, jobs, "while :; do echo line; done", Limits{Output: 1024})
+
+	waitTestJob(t, job)
+	output := job.GetOutput().GetRunOutput()
+
+	assert.Equal(t, "output limit of 1K", output.GetLimit(), "The output limit should be the limit.")
+}
+
+func testJobLogFile(t *testing.T) {
+	jobs := NewJobs(t.TempDir())
+	job := startTestJob(t, jobs, "echo out", Limits{})
+
+	_, ok := job.cmd.Stdout.(*os.File)
+	assert.True(t, ok, "The job should write to its log file itself, not through a pipe of xang.")
+
+	waitTestJob(t, job)
+	assert.Equal(t, "out\n", job.GetOutput().GetRunOutput().GetStdout(), "The output should be read back from the log.")
+}
+
+func testJobTail(t *testing.T) {
+	jobs := NewJobs(t.TempDir())
+	job := startTestJob(t, jobs, "seq 1 100", Limits{})
+
+	waitTestJob(t, job)
+	tail, err := job.Tail(3)
+	require.NoError(t, err)
+
+	assert.Equal(t, "98\n99\n100", tail)
+}
+
+func testJobsGetJob(t *testing.T) {
+	jobs := NewJobs(t.TempDir())
+	first := startTestJob(t, jobs, "true", Limits{})
+	second := startTestJob(t, jobs, "true", Limits{})
+
+	job, err := jobs.GetJob(2)
+	require.NoError(t, err)
+	assert.Same(t, second, job)
+	assert.Equal(t, []*Job{first, second}, jobs.GetJobs())
+
+	_, err = jobs.GetJob(3)
+	assert.Error(t, err, "There is no third job.")
+}
+
+func testJobAttachment(t *testing.T) {
+	jobs := NewJobs(t.TempDir())
+	job := startTestJob(t, jobs, "echo before; sleep 0.5; echo after", Limits{})
+
+	stdin, _ := io.Pipe()
+	var stdout bytes.Buffer
+	attachment := NewJobAttachment(job)
+	attachment.SetStdin(stdin)
+	attachment.SetStdout(&stdout)
+
+	require.NoError(t, attachment.Run())
+
+	assert.False(t, job.IsRunning(), "The attachment should last until the job is over.")
+	assert.Contains(t, stdout.String(), "before\nafter\n", "The output of the job should be followed.")
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package run
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach runs cmd in a process group of its own, so that it does not get the
+// signals of the terminal and can be killed along with its children.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
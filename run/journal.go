@@ -0,0 +1,561 @@
+package run
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// journal_entry_file describes an entry of the journal, next to the
+	// snapshots of its paths.
+	journal_entry_file = "entry.json"
+	// journal_files_directory holds the snapshots of the paths of an entry,
+	// named after their index.
+	journal_files_directory = "files"
+)
+
+// FindAffectedPaths returns the absolute paths of the files cmd, run in cwd,
+// deletes, moves or overwrites: the operands of rm, shred, truncate and
+// unlink, the sources and targets of mv, the targets of cp, install and ln,
+// the files edited in place by sed and perl, the ones written by tee and dd,
+// and redirections. Globs are expanded, other expansions can't be known and
+// are left out, as are the paths of commands that do not parse.
+func FindAffectedPaths(cmd string, cwd string) []string {
+	calls, err := ParseCalls(cmd)
+	if err != nil {
+		return nil
+	}
+
+	home, _ := os.UserHomeDir()
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(targets ...string) {
+		for _, target := range targets {
+			for _, path := range resolvePaths(target, cwd, home) {
+				if !seen[path] {
+					seen[path] = true
+					paths = append(paths, path)
+				}
+			}
+		}
+	}
+
+	for _, call := range calls {
+		args := call.GetArgs()
+		switch call.GetProgram() {
+		case "rm", "shred", "truncate", "unlink":
+			add(operands(args)...)
+		case "mv":
+			targets := operands(args)
+			if len(targets) > 1 {
+				add(targets[:len(targets)-1]...)
+			}
+			add(copyTargets(targets, cwd, home)...)
+		case "cp", "install", "ln":
+			add(copyTargets(operands(args), cwd, home)...)
+		case "sed":
+			if hasInPlaceFlag(args) {
+				add(editedFiles(args, "-e", "--expression", "-f", "--file")...)
+			}
+		case "perl":
+			if hasInPlaceFlag(args) {
+				add(editedFiles(args, "-e", "-E")...)
+			}
+		case "tee":
+			add(operands(args)...)
+		case "dd":
+			for _, arg := range args {
+				if target, ok := strings.CutPrefix(arg, "of="); ok {
+					add(target)
+				}
+			}
+		}
+		for _, redirect := range call.GetRedirects() {
+			if !harmlessWriteTargets[redirect] && !strings.HasPrefix(redirect, "/dev/") {
+				add(redirect)
+			}
+		}
+	}
+
+	return paths
+}
+
+// copyTargets returns the files a copy to the last of targets overwrites: the
+// target itself, or the files named after the sources in it when it is an
+// existing directory.
+func copyTargets(targets []string, cwd string, home string) []string {
+	if len(targets) < 2 {
+		return nil
+	}
+
+	target := targets[len(targets)-1]
+	resolved := resolvePaths(target, cwd, home)
+	if len(resolved) != 1 {
+		return nil
+	}
+	if info, err := os.Stat(resolved[0]); err != nil || !info.IsDir() {
+		return []string{target}
+	}
+
+	var files []string
+	for _, source := range targets[:len(targets)-1] {
+		for _, path := range resolvePaths(source, cwd, home) {
+			files = append(files, filepath.Join(resolved[0], filepath.Base(path)))
+		}
+	}
+
+	return files
+}
+
+// hasInPlaceFlag reports whether args edit files in place, as with sed -i or
+// perl -pi.
+func hasInPlaceFlag(args []string) bool {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--in-place") ||
+			strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.Contains(arg, "i") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// editedFiles returns the files of args edited in place, the first operand
+// being the script unless one of scriptFlags gives it.
+func editedFiles(args []string, scriptFlags ...string) []string {
+	var files []string
+	script := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if strings.HasPrefix(arg, "-") {
+			for _, flag := range scriptFlags {
+				if strings.HasPrefix(flag, "--") && strings.HasPrefix(arg, flag+"=") {
+					script = true
+					break
+				}
+				if arg == flag || !strings.HasPrefix(flag, "--") && !strings.HasPrefix(arg, "--") &&
+					strings.HasSuffix(arg, flag[1:]) {
+					script = true
+					i++
+					break
+				}
+			}
+			continue
+		}
+		if !script {
+			script = true
+			continue
+		}
+		files = append(files, arg)
+	}
+
+	return files
+}
+
+// resolvePaths returns the absolute paths target, relative to cwd, stands
+// for, expanding ~ and globs. Other expansions can't be resolved.
+func resolvePaths(target string, cwd string, home string) []string {
+	switch {
+	case target == "" || strings.ContainsAny(target, "$`"):
+		return nil
+	case target == "~" || strings.HasPrefix(target, "~/"):
+		if home == "" {
+			return nil
+		}
+		target = filepath.Join(home, strings.TrimPrefix(target, "~"))
+	case !filepath.IsAbs(target):
+		if cwd == "" {
+			return nil
+		}
+		target = filepath.Join(cwd, target)
+	}
+
+	target = filepath.Clean(target)
+	if !strings.ContainsAny(target, "*?[") {
+		return []string{target}
+	}
+	matches, err := filepath.Glob(target)
+	if err != nil {
+		return nil
+	}
+
+	return matches
+}
+
+// JournalPath is a path saved before a command ran, or found absent then.
+type JournalPath struct {
+	Path   string `json:"path"`
+	Absent bool   `json:"absent,omitempty"`
+}
+
+// JournalEntry is a command whose affected paths were saved before it ran.
+type JournalEntry struct {
+	directory string
+	Command   string        `json:"command"`
+	Time      time.Time     `json:"time"`
+	Paths     []JournalPath `json:"paths"`
+	Size      int64         `json:"size"`
+}
+
+func (e JournalEntry) GetCommand() string {
+	return e.Command
+}
+
+func (e JournalEntry) GetTime() time.Time {
+	return e.Time
+}
+
+// GetPaths returns the paths saved before the command ran.
+func (e JournalEntry) GetPaths() []string {
+	paths := make([]string, 0, len(e.Paths))
+	for _, path := range e.Paths {
+		paths = append(paths, path.Path)
+	}
+
+	return paths
+}
+
+// GetSize returns the size of the snapshots of the entry, in bytes.
+func (e JournalEntry) GetSize() int64 {
+	return e.Size
+}
+
+// Journal saves the paths commands are about to change, to put them back on
+// undo. Each session writes its entries to its own directory, undo going
+// through the entries of every session, the most recent first.
+type Journal struct {
+	mu        sync.Mutex
+	directory string
+	session   string
+	retention time.Duration
+	maxSize   int64
+	entries   int
+}
+
+// NewJournal returns the journal kept in directory, its entries being removed
+// once older than retention or once all of them take more than maxSize bytes,
+// a zero value setting no bound.
+func NewJournal(directory string, retention time.Duration, maxSize int64) *Journal {
+	return &Journal{
+		directory: directory,
+		session:   fmt.Sprintf("%s-%d", time.Now().Format("20060102-150405"), os.Getpid()),
+		retention: retention,
+		maxSize:   maxSize,
+	}
+}
+
+// Record saves paths before command changes them, paths that do not exist
+// being removed on undo. It fails when the snapshots would take more than the
+// size limit of the journal, saving nothing.
+func (j *Journal) Record(command string, paths []string) (JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var size int64
+	for _, path := range paths {
+		pathSize, err := measurePath(path)
+		if err != nil {
+			return JournalEntry{}, fmt.Errorf("failed to measure %s: %w", path, err)
+		}
+		size += pathSize
+	}
+	if j.maxSize > 0 && size > j.maxSize {
+		return JournalEntry{}, fmt.Errorf("saving %s would go over the undo limit of %s", formatSize(size), formatSize(j.maxSize))
+	}
+
+	j.entries++
+	entry := JournalEntry{
+		directory: filepath.Join(j.directory, j.session, strconv.Itoa(j.entries)),
+		Command:   command,
+		Time:      time.Now(),
+		Size:      size,
+	}
+	files := filepath.Join(entry.directory, journal_files_directory)
+	if err := os.MkdirAll(files, 0700); err != nil {
+		return JournalEntry{}, fmt.Errorf("failed to create the undo journal: %w", err)
+	}
+
+	for i, path := range paths {
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			entry.Paths = append(entry.Paths, JournalPath{Path: path, Absent: true})
+			continue
+		}
+		if err := copyPath(path, filepath.Join(files, strconv.Itoa(i))); err != nil {
+			os.RemoveAll(entry.directory)
+			return JournalEntry{}, fmt.Errorf("failed to save %s: %w", path, err)
+		}
+		entry.Paths = append(entry.Paths, JournalPath{Path: path})
+	}
+
+	data, err := json.Marshal(entry)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(entry.directory, journal_entry_file), data, 0600)
+	}
+	if err != nil {
+		os.RemoveAll(entry.directory)
+		return JournalEntry{}, fmt.Errorf("failed to write the undo journal: %w", err)
+	}
+
+	j.prune()
+
+	return entry, nil
+}
+
+// GetEntries returns the entries of the journal, the most recent first.
+func (j *Journal) GetEntries() ([]JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.load()
+}
+
+// Undo puts back the paths of the count most recent entries as they were
+// before their commands ran, the most recent first, and drops the entries.
+// It returns the entries undone, stopping at the first one that fails.
+func (j *Journal) Undo(count int) ([]JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries, err := j.load()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("nothing to undo")
+	}
+	if count > len(entries) {
+		count = len(entries)
+	}
+
+	var undone []JournalEntry
+	for _, entry := range entries[:count] {
+		if err := entry.restore(); err != nil {
+			return undone, fmt.Errorf("failed to undo `%s`: %w", entry.Command, err)
+		}
+		os.RemoveAll(entry.directory)
+		undone = append(undone, entry)
+	}
+
+	return undone, nil
+}
+
+// Prune removes the entries older than the retention, then the oldest ones
+// until the journal fits its size limit.
+func (j *Journal) Prune() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.prune()
+}
+
+func (j *Journal) prune() {
+	entries, err := j.load()
+	if err != nil {
+		return
+	}
+
+	var size int64
+	for _, entry := range entries {
+		size += entry.Size
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		expired := j.retention > 0 && time.Since(entries[i].Time) > j.retention
+		if !expired && (j.maxSize <= 0 || size <= j.maxSize) {
+			continue
+		}
+		os.RemoveAll(entries[i].directory)
+		size -= entries[i].Size
+	}
+
+	// drop the directories of sessions left without entries
+	sessions, _ := os.ReadDir(j.directory)
+	for _, session := range sessions {
+		if session.Name() != j.session {
+			os.Remove(filepath.Join(j.directory, session.Name()))
+		}
+	}
+}
+
+// load reads the entries of every session, the most recent first. Entries
+// that can't be read are skipped.
+func (j *Journal) load() ([]JournalEntry, error) {
+	matches, err := filepath.Glob(filepath.Join(j.directory, "*", "*", journal_entry_file))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []JournalEntry
+	for _, match := range matches {
+		data, err := os.ReadFile(match)
+		if err != nil {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			continue
+		}
+		entry.directory = filepath.Dir(match)
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(a, b int) bool {
+		return entries[a].Time.After(entries[b].Time)
+	})
+
+	return entries, nil
+}
+
+// restore puts the paths of the entry back, the last one first, removing
+// those that did not exist. Only what was saved is put back: the files added
+// to a saved directory since are left in place.
+func (e JournalEntry) restore() error {
+	for i := len(e.Paths) - 1; i >= 0; i-- {
+		path := e.Paths[i]
+		if path.Absent {
+			if err := os.RemoveAll(path.Path); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path.Path), 0755); err != nil {
+			return err
+		}
+		if err := restorePath(filepath.Join(e.directory, journal_files_directory, strconv.Itoa(i)), path.Path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restorePath copies the snapshot at src back to dst, going into dst when
+// both are directories. It refuses to replace a directory by a file, which
+// would delete what was put in the directory since the snapshot.
+func restorePath(src string, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	current, err := os.Lstat(dst)
+	if os.IsNotExist(err) {
+		return copyPath(src, dst)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !current.IsDir() {
+		if err := os.Remove(dst); err != nil {
+			return err
+		}
+		return copyPath(src, dst)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is a directory since it was saved", dst)
+	}
+
+	children, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := restorePath(filepath.Join(src, child.Name()), filepath.Join(dst, child.Name())); err != nil {
+			return err
+		}
+	}
+	if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// measurePath returns the size of the regular files at path, 0 when it does
+// not exist.
+func measurePath(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	return size, err
+}
+
+// copyPath copies the file, directory or symbolic link at src to dst, along
+// with its permissions and modification time. Other kinds of files, such as
+// sockets, are skipped.
+func copyPath(src string, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	case info.IsDir():
+		if err := os.Mkdir(dst, 0700); err != nil {
+			return err
+		}
+		children, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			if err := copyPath(filepath.Join(src, child.Name()), filepath.Join(dst, child.Name())); err != nil {
+				return err
+			}
+		}
+	case info.Mode().IsRegular():
+		if err := copyFile(src, dst); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+
+	if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
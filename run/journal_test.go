@@ -0,0 +1,231 @@
+package run
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindAffectedPaths(t *testing.T) {
+	cwd := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(cwd, "backup"), 0755))
+	for _, name := range []string{"a.log", "b.log", "notes.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(cwd, name), []byte(name), 0644))
+	}
+	path := func(names ...string) []string {
+		var paths []string
+		for _, name := range names {
+			paths = append(paths, filepath.Join(cwd, name))
+		}
+		return paths
+	}
+
+	testCases := []struct {
+		name     string
+		cmd      string
+		expected []string
+	}{
+		{"Delete", "rm -f notes.txt /tmp/x", append(path("notes.txt"), "/tmp/x")},
+		{"Glob", "rm *.log", path("a.log", "b.log")},
+		{"Move", "mv notes.txt todo.txt", path("notes.txt", "todo.txt")},
+		{"MoveIntoDirectory", "mv a.log b.log backup", path("a.log", "b.log", "backup/a.log", "backup/b.log")},
+		{"Copy", "cp -r notes.txt backup", path("backup/notes.txt")},
+		{"InPlace", "sed -i 's/a/b/' notes.txt a.log", path("notes.txt", "a.log")},
+		{"InPlaceScript", "perl -pi -e 's/a/b/' notes.txt", path("notes.txt")},
+		{"NotInPlace", "sed 's/a/b/' notes.txt", nil},
+		{"Redirect", "sort notes.txt > sorted.txt 2>/dev/null", path("sorted.txt")},
+		{"Tee", "echo hi | sudo tee -a notes.txt", path("notes.txt")},
+		{"SudoUser", "sudo -u deploy rm notes.txt", path("notes.txt")},
+		{"Expansion", `rm "$FILE"`, nil},
+		{"ReadOnly", "cat notes.txt | grep a", nil},
+		{"Unparsable", "rm 'broken", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, FindAffectedPaths(tc.cmd, cwd))
+		})
+	}
+}
+
+func TestJournal(t *testing.T) {
+	t.Run("UndoDelete", testJournalUndoDelete)
+	t.Run("UndoMove", testJournalUndoMove)
+	t.Run("UndoCount", testJournalUndoCount)
+	t.Run("UndoKeepsNewFiles", testJournalUndoKeepsNewFiles)
+	t.Run("UndoDirectoryReplaced", testJournalUndoDirectoryReplaced)
+	t.Run("SizeLimit", testJournalSizeLimit)
+	t.Run("Retention", testJournalRetention)
+	t.Run("Sessions", testJournalSessions)
+}
+
+func testJournalUndoDelete(t *testing.T) {
+	cwd := t.TempDir()
+	dir := filepath.Join(cwd, "project")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "src"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("package main\n"), 0640))
+	require.NoError(t, os.Symlink("src/main.go", filepath.Join(dir, "main.go")))
+	journal := NewJournal(filepath.Join(t.TempDir(), "undo"), 0, 0)
+
+	entry, err := journal.Record("rm -rf project", []string{dir})
+	require.NoError(t, err)
+	require.NoError(t, os.RemoveAll(dir))
+
+	assert.Equal(t, int64(13), entry.GetSize(), "The size of the saved files should be kept.")
+	undone, err := journal.Undo(1)
+	require.NoError(t, err)
+	require.Len(t, undone, 1)
+	assert.Equal(t, "rm -rf project", undone[0].GetCommand())
+
+	content, err := os.ReadFile(filepath.Join(dir, "src", "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n", string(content), "The deleted file should be back.")
+	info, err := os.Stat(filepath.Join(dir, "src", "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm(), "The permissions should be restored.")
+	link, err := os.Readlink(filepath.Join(dir, "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "src/main.go", link, "The symbolic link should be restored.")
+
+	entries, err := journal.GetEntries()
+	require.NoError(t, err)
+	assert.Empty(t, entries, "The undone entry should be dropped.")
+}
+
+func testJournalUndoMove(t *testing.T) {
+	cwd := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(cwd, "notes.txt"), []byte("notes"), 0644))
+	journal := NewJournal(filepath.Join(t.TempDir(), "undo"), 0, 0)
+
+	_, err := journal.Record("mv notes.txt todo.txt", FindAffectedPaths("mv notes.txt todo.txt", cwd))
+	require.NoError(t, err)
+	require.NoError(t, os.Rename(filepath.Join(cwd, "notes.txt"), filepath.Join(cwd, "todo.txt")))
+
+	_, err = journal.Undo(1)
+	require.NoError(t, err)
+
+	assert.FileExists(t, filepath.Join(cwd, "notes.txt"), "The moved file should be back.")
+	assert.NoFileExists(t, filepath.Join(cwd, "todo.txt"), "The file created by the move should be removed.")
+}
+
+func testJournalUndoKeepsNewFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "notes")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644))
+	journal := NewJournal(filepath.Join(t.TempDir(), "undo"), 0, 0)
+
+	_, err := journal.Record("sed -i s/a/b/ notes/a.txt", []string{dir})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("b"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("new"), 0644))
+
+	_, err = journal.Undo(1)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Join(dir, "a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(content), "The saved file should be put back.")
+	assert.FileExists(t, filepath.Join(dir, "b.txt"), "The file added since the snapshot should be left.")
+}
+
+func testJournalUndoDirectoryReplaced(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "notes")
+	require.NoError(t, os.WriteFile(file, []byte("notes"), 0644))
+	journal := NewJournal(filepath.Join(t.TempDir(), "undo"), 0, 0)
+
+	_, err := journal.Record("rm notes", []string{file})
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(file))
+	require.NoError(t, os.MkdirAll(filepath.Join(file, "src"), 0755))
+
+	_, err = journal.Undo(1)
+	assert.Error(t, err, "A directory should not be replaced by the saved file.")
+	assert.DirExists(t, filepath.Join(file, "src"), "The directory should be left.")
+}
+
+func testJournalUndoCount(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "counter")
+	require.NoError(t, os.WriteFile(file, []byte("0"), 0644))
+	journal := NewJournal(filepath.Join(t.TempDir(), "undo"), 0, 0)
+
+	for _, value := range []string{"1", "2", "3"} {
+		_, err := journal.Record("echo "+value+" > counter", []string{file})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(file, []byte(value), 0644))
+		time.Sleep(time.Millisecond)
+	}
+
+	undone, err := journal.Undo(2)
+	require.NoError(t, err)
+	require.Len(t, undone, 2)
+	assert.Equal(t, "echo 3 > counter", undone[0].GetCommand(), "The most recent entry should be undone first.")
+
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "1", string(content), "The file should be as it was before the last two commands.")
+
+	_, err = journal.Undo(5)
+	require.NoError(t, err)
+	_, err = journal.Undo(1)
+	assert.EqualError(t, err, "nothing to undo")
+}
+
+func testJournalSizeLimit(t *testing.T) {
+	dir := t.TempDir()
+	big, small := filepath.Join(dir, "big"), filepath.Join(dir, "small")
+	require.NoError(t, os.WriteFile(big, make([]byte, 2048), 0644))
+	require.NoError(t, os.WriteFile(small, make([]byte, 600), 0644))
+	journal := NewJournal(filepath.Join(t.TempDir(), "undo"), 0, 1024)
+
+	_, err := journal.Record("rm big", []string{big})
+	assert.EqualError(t, err, "saving 2K would go over the undo limit of 1K")
+
+	_, err = journal.Record("rm small", []string{small})
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond)
+	_, err = journal.Record("truncate -s 0 small", []string{small})
+	require.NoError(t, err)
+
+	entries, err := journal.GetEntries()
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "The oldest entry should be dropped to fit the limit.")
+	assert.Equal(t, "truncate -s 0 small", entries[0].GetCommand())
+}
+
+func testJournalRetention(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, []byte("file"), 0644))
+	journal := NewJournal(filepath.Join(t.TempDir(), "undo"), time.Millisecond, 0)
+
+	_, err := journal.Record("rm file", []string{file})
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+	journal.Prune()
+
+	entries, err := journal.GetEntries()
+	require.NoError(t, err)
+	assert.Empty(t, entries, "Expired entries should be dropped.")
+}
+
+func testJournalSessions(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, []byte("before"), 0644))
+	directory := filepath.Join(t.TempDir(), "undo")
+
+	session := NewJournal(directory, 0, 0)
+	session.session = "previous"
+	_, err := session.Record("echo after > file", []string{file})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(file, []byte("after"), 0644))
+
+	_, err = NewJournal(directory, 0, 0).Undo(1)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "before", string(content), "The entries of other sessions should be undone.")
+}
@@ -0,0 +1,294 @@
+package run
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// no_limit is how a limit that is not set is written.
+const no_limit = "none"
+
+// Limits bounds the resources a command may use, a zero value setting no
+// bound. Timeout and Output are enforced by killing the command, CPU, Memory
+// and Files are resource limits of its processes.
+type Limits struct {
+	Timeout time.Duration
+	CPU     time.Duration
+	Memory  int64
+	Files   int
+	Output  int64
+}
+
+// HasResourceLimits reports whether one of the resource limits of the
+// processes is set.
+func (l Limits) HasResourceLimits() bool {
+	return l.CPU > 0 || l.Memory > 0 || l.Files > 0
+}
+
+// String returns the limits as ParseLimits reads them, such as
+// "timeout=5m0s cpu=none memory=2G files=none output=none".
+func (l Limits) String() string {
+	return fmt.Sprintf(
+		"timeout=%s cpu=%s memory=%s files=%s output=%s",
+		formatDuration(l.Timeout),
+		formatDuration(l.CPU),
+		formatSize(l.Memory),
+		formatCount(l.Files),
+		formatSize(l.Output),
+	)
+}
+
+// ParseLimits returns limits with the ones of spec overridden. spec is made of
+// key=value fields, the keys being timeout, cpu, memory, files and output, and
+// a value of none removing a limit.
+func ParseLimits(spec string, limits Limits) (Limits, error) {
+	for _, field := range strings.Fields(spec) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return limits, fmt.Errorf("invalid limit %q, expected key=value", field)
+		}
+
+		var err error
+		switch strings.ToLower(key) {
+		case "timeout":
+			limits.Timeout, err = ParseDuration(value)
+		case "cpu":
+			limits.CPU, err = ParseDuration(value)
+		case "memory":
+			limits.Memory, err = ParseSize(value)
+		case "files":
+			limits.Files, err = ParseCount(value)
+		case "output":
+			limits.Output, err = ParseSize(value)
+		default:
+			return limits, fmt.Errorf("unknown limit %q, expected timeout, cpu, memory, files or output", key)
+		}
+		if err != nil {
+			return limits, fmt.Errorf("invalid %s limit: %w", key, err)
+		}
+	}
+
+	return limits, nil
+}
+
+// ParseDuration parses a duration such as 90s or 5m, a plain number being a
+// number of seconds and none or an empty string no duration.
+func ParseDuration(s string) (time.Duration, error) {
+	if s == "" || s == no_limit {
+		return 0, nil
+	}
+	if seconds, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if duration < 0 {
+		return 0, fmt.Errorf("negative duration %s", s)
+	}
+
+	return duration, nil
+}
+
+// ParseSize parses a number of bytes, optionally followed by K, M or G for
+// powers of 1024, none or an empty string being no size.
+func ParseSize(s string) (int64, error) {
+	if s == "" || s == no_limit {
+		return 0, nil
+	}
+
+	number, unit := strings.ToUpper(s), int64(1)
+	for i, suffix := range []string{"K", "M", "G"} {
+		if trimmed, ok := strings.CutSuffix(number, suffix); ok {
+			number, unit = trimmed, int64(1)<<(10*(i+1))
+			break
+		}
+	}
+
+	size, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected bytes or a number followed by K, M or G", s)
+	}
+
+	return size * unit, nil
+}
+
+// ParseCount parses a number of zero or more, none or an empty string being no
+// number.
+func ParseCount(s string) (int, error) {
+	if s == "" || s == no_limit {
+		return 0, nil
+	}
+
+	count, err := strconv.Atoi(s)
+	if err != nil || count < 0 {
+		return 0, fmt.Errorf("invalid count %q", s)
+	}
+
+	return count, nil
+}
+
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return no_limit
+	}
+
+	return d.String()
+}
+
+func formatSize(size int64) string {
+	if size <= 0 {
+		return no_limit
+	}
+
+	for _, unit := range []struct {
+		suffix string
+		shift  int
+	}{{"G", 30}, {"M", 20}, {"K", 10}} {
+		if size%(1<<unit.shift) == 0 {
+			return fmt.Sprintf("%d%s", size>>unit.shift, unit.suffix)
+		}
+	}
+
+	return strconv.FormatInt(size, 10)
+}
+
+func formatCount(count int) string {
+	if count <= 0 {
+		return no_limit
+	}
+
+	return strconv.Itoa(count)
+}
+
+// outputWatch counts the bytes a command prints, calling exceeded once they
+// go over limit.
+type outputWatch struct {
+	mu       sync.Mutex
+	limit    int64
+	written  int64
+	exceeded func()
+}
+
+func (w *outputWatch) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.limit > 0 && w.written <= w.limit {
+		w.written += int64(len(p))
+		if w.written > w.limit {
+			w.exceeded()
+		}
+	}
+
+	return len(p), nil
+}
+
+// memoryErrors are what programs print, lowercased, when they fail to
+// allocate memory.
+var memoryErrors = []string{
+	"cannot allocate memory", "out of memory", "memoryerror", "bad_alloc", "memory exhausted", "allocation failed",
+}
+
+// exceededResourceLimit returns the resource limit a command that ended as
+// state, printing output, went over, if any. Going over the CPU time kills the
+// command; the memory and files limits make its allocations and the opening
+// of files fail, which it reports.
+func exceededResourceLimit(limits Limits, state *os.ProcessState, output string) string {
+	if state == nil || state.Success() {
+		return ""
+	}
+
+	output = strings.ToLower(output)
+	switch {
+	case limits.CPU > 0 && isCPULimitExceeded(state):
+		return fmt.Sprintf("cpu limit of %s", limits.CPU)
+	case limits.Memory > 0 && containsAny(output, memoryErrors):
+		return fmt.Sprintf("memory limit of %s", formatSize(limits.Memory))
+	case limits.Files > 0 && strings.Contains(output, "too many open files"):
+		return fmt.Sprintf("files limit of %d", limits.Files)
+	}
+
+	return ""
+}
+
+func containsAny(s string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// limitEnforcer stops a command going over a limit, sending SIGTERM then
+// SIGKILL once the grace period is over, and keeps the first limit it went
+// over. Once the command is waited for, it is left alone: its process group
+// may be another one's by then.
+type limitEnforcer struct {
+	mu    sync.Mutex
+	limit string
+	done  bool
+	kill  *time.Timer
+}
+
+// stop terminates cmd, which went over limit, unless it is stopping already.
+// Once cmd was waited for, the limit is only recorded. An empty limit stops it
+// on request.
+func (l *limitEnforcer) stop(cmd *exec.Cmd, limit string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.limit == "" {
+		l.limit = limit
+	}
+	if l.done || l.kill != nil {
+		return
+	}
+	terminate(cmd)
+	l.kill = time.AfterFunc(kill_grace, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if !l.done {
+			kill(cmd)
+		}
+	})
+}
+
+// exceeded records that the command went over limit, found once it ended,
+// unless it was stopped for another one.
+func (l *limitEnforcer) exceeded(limit string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.limit == "" {
+		l.limit = limit
+	}
+}
+
+// finish records that the command was waited for, cancelling its kill.
+func (l *limitEnforcer) finish() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.done = true
+	if l.kill != nil {
+		l.kill.Stop()
+	}
+}
+
+// getLimit returns the limit the command went over, if any.
+func (l *limitEnforcer) getLimit() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.limit
+}
@@ -0,0 +1,155 @@
+package run
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimits(t *testing.T) {
+	t.Run("ParseLimits", testParseLimits)
+	t.Run("ParseLimitsInvalid", testParseLimitsInvalid)
+	t.Run("ParseSize", testParseSize)
+	t.Run("ParseDuration", testParseDuration)
+	t.Run("String", testLimitsString)
+	t.Run("OutputWatch", testOutputWatch)
+	t.Run("ExceededResourceLimit", testExceededResourceLimit)
+	t.Run("LimitEnforcer", testLimitEnforcer)
+}
+
+func testParseLimits(t *testing.T) {
+	base := Limits{Timeout: time.Minute, Files: 1024}
+
+	limits, err := ParseLimits("timeout=none cpu=30 memory=2G output=10M", base)
+	require.NoError(t, err)
+
+	assert.Equal(t, Limits{CPU: 30 * time.Second, Memory: 2 << 30, Files: 1024, Output: 10 << 20}, limits)
+	assert.True(t, limits.HasResourceLimits(), "The CPU and memory limits are resource limits.")
+	assert.False(t, Limits{Timeout: time.Minute}.HasResourceLimits(), "A timeout is not a resource limit.")
+}
+
+func testParseLimitsInvalid(t *testing.T) {
+	testCases := []string{"timeout", "disk=1G", "memory=lots", "cpu=-5s", "files=-1"}
+
+	for _, tc := range testCases {
+		t.Run(tc, func(t *testing.T) {
+			_, err := ParseLimits(tc, Limits{})
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func testParseSize(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int64
+	}{
+		{"", 0},
+		{"none", 0},
+		{"512", 512},
+		{"4k", 4096},
+		{"10M", 10 << 20},
+		{"2G", 2 << 30},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			size, err := ParseSize(tc.input)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, size)
+		})
+	}
+}
+
+func testParseDuration(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected time.Duration
+	}{
+		{"none", 0},
+		{"90", 90 * time.Second},
+		{"5m", 5 * time.Minute},
+		{"1h30m", 90 * time.Minute},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			duration, err := ParseDuration(tc.input)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, duration)
+		})
+	}
+}
+
+func testLimitsString(t *testing.T) {
+	limits := Limits{Timeout: 5 * time.Minute, Memory: 1536 << 20, Output: 1000}
+
+	assert.Equal(t, "timeout=5m0s cpu=none memory=1536M files=none output=1000", limits.String())
+
+	parsed, err := ParseLimits(limits.String(), Limits{CPU: time.Second})
+	require.NoError(t, err)
+	assert.Equal(t, limits, parsed, "The limits should be parsed back as they were.")
+}
+
+func testOutputWatch(t *testing.T) {
+	exceeded := 0
+	watch := &outputWatch{limit: 10, exceeded: func() { exceeded++ }}
+
+	watch.Write([]byte("12345"))
+	assert.Equal(t, 0, exceeded, "The limit should not be exceeded yet.")
+
+	watch.Write([]byte("123456"))
+	watch.Write([]byte("more"))
+	assert.Equal(t, 1, exceeded, "Going over the limit should be reported once.")
+}
+
+func testExceededResourceLimit(t *testing.T) {
+	failed := exec.Command("sh", "-c", "exit 1")
+	failed.Run()
+	succeeded := exec.Command("true")
+	succeeded.Run()
+
+	testCases := []struct {
+		name     string
+		limits   Limits
+		state    *os.ProcessState
+		output   string
+		expected string
+	}{
+		{"Memory", Limits{Memory: 512 << 20}, failed.ProcessState, "MemoryError", "memory limit of 512M"},
+		{"Files", Limits{Files: 64}, failed.ProcessState, "open: Too many open files", "files limit of 64"},
+		{"NoLimit", Limits{}, failed.ProcessState, "Cannot allocate memory", ""},
+		{"Success", Limits{Memory: 512 << 20}, succeeded.ProcessState, "out of memory", ""},
+		{"OtherFailure", Limits{Memory: 512 << 20, Files: 64}, failed.ProcessState, "no such file", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, exceededResourceLimit(tc.limits, tc.state, tc.output))
+		})
+	}
+}
+
+func testLimitEnforcer(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	require.NoError(t, cmd.Start())
+
+	var enforcer limitEnforcer
+	enforcer.stop(cmd, "timeout of 1s")
+	cmd.Wait()
+	enforcer.finish()
+
+	assert.Equal(t, "timeout of 1s", enforcer.getLimit(), "The limit should be kept.")
+	assert.False(t, enforcer.kill.Stop(), "The kill should be cancelled once the command is waited for.")
+
+	enforcer.stop(cmd, "output limit of 1K")
+	enforcer.exceeded("memory limit of 1G")
+	assert.Equal(t, "timeout of 1s", enforcer.getLimit(), "Only the first limit should be kept.")
+}
@@ -0,0 +1,123 @@
+package run
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// shellBuiltins are the builtins and keywords of the shells, which are not
+// found on the PATH.
+var shellBuiltins = map[string]bool{
+	".": true, ":": true, "[": true, "[[": true, "alias": true, "bg": true, "bind": true,
+	"break": true, "builtin": true, "cd": true, "command": true, "continue": true,
+	"declare": true, "dirs": true, "disown": true, "echo": true, "enable": true, "eval": true,
+	"exec": true, "exit": true, "export": true, "false": true, "fc": true, "fg": true,
+	"getopts": true, "hash": true, "help": true, "history": true, "jobs": true, "kill": true,
+	"let": true, "local": true, "logout": true, "mapfile": true, "popd": true, "printf": true,
+	"pushd": true, "pwd": true, "read": true, "readarray": true, "readonly": true,
+	"return": true, "set": true, "shift": true, "shopt": true, "source": true, "suspend": true,
+	"test": true, "times": true, "trap": true, "true": true, "type": true, "typeset": true,
+	"ulimit": true, "umask": true, "unalias": true, "unset": true, "wait": true,
+	"autoload": true, "bindkey": true, "print": true, "setopt": true, "unsetopt": true,
+	"whence": true, "zmodload": true, "rehash": true,
+}
+
+// FindMissingPrograms returns the programs cmd, run in cwd with the PATH
+// path, runs that cannot be found, neither on the PATH nor at the path they
+// are given with. Builtins, the functions cmd declares and programs looked up
+// with command -v are left out, as are the ones of commands that do not
+// parse.
+func FindMissingPrograms(cmd string, cwd string, path string) []string {
+	return findMissingPrograms(cmd, func(program string) bool {
+		if strings.Contains(program, "/") {
+			if !filepath.IsAbs(program) {
+				program = filepath.Join(cwd, program)
+			}
+			_, err := os.Stat(program)
+			return err == nil
+		}
+		for _, dir := range filepath.SplitList(path) {
+			if dir == "" {
+				dir = "."
+			}
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(cwd, dir)
+			}
+			if isExecutable(filepath.Join(dir, program)) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// isExecutable reports whether the file at path is a program that can be
+// run.
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return true
+	}
+
+	return info.Mode()&0111 != 0
+}
+
+// findMissingPrograms returns the programs cmd runs for which exists is
+// false.
+func findMissingPrograms(cmd string, exists func(string) bool) []string {
+	calls, err := ParseCalls(cmd)
+	if err != nil {
+		return nil
+	}
+
+	skipped := skippedPrograms(cmd)
+
+	var missing []string
+	seen := make(map[string]bool)
+	for _, call := range calls {
+		program := call.GetPath()
+		if program == "" || seen[program] || shellBuiltins[program] || skipped[program] {
+			continue
+		}
+		seen[program] = true
+		if !exists(program) {
+			missing = append(missing, program)
+		}
+	}
+
+	return missing
+}
+
+// skippedPrograms returns the functions cmd declares and the programs it
+// looks up with command -v, which handles them being missing.
+func skippedPrograms(cmd string) map[string]bool {
+	skipped := make(map[string]bool)
+
+	file, err := syntax.NewParser().Parse(strings.NewReader(cmd), "")
+	if err != nil {
+		return skipped
+	}
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.FuncDecl:
+			skipped[node.Name.Value] = true
+		case *syntax.CallExpr:
+			words := literalWords(node)
+			if len(words) > 2 && words[0] == "command" && (words[1] == "-v" || words[1] == "-V") {
+				for _, word := range words[2:] {
+					skipped[word] = true
+				}
+			}
+		}
+		return true
+	})
+
+	return skipped
+}
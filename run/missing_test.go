@@ -0,0 +1,51 @@
+package run
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindMissingPrograms(t *testing.T) {
+	installed := map[string]bool{"ls": true, "grep": true, "sudo": true, "/usr/bin/env": true}
+	exists := func(program string) bool {
+		return installed[program]
+	}
+
+	testCases := []struct {
+		name     string
+		cmd      string
+		expected []string
+	}{
+		{"Installed", "ls -la | grep go", nil},
+		{"Missing", "curl -s example.com | jq .name", []string{"curl", "jq"}},
+		{"Wrapped", "sudo rg TODO /etc", []string{"rg"}},
+		{"Duplicated", "jq . a.json && jq . b.json", []string{"jq"}},
+		{"Builtins", "cd /tmp && export A=1 && echo $A && [ -f x ] && source .env", nil},
+		{"Substitution", `ls "$(fd main)"`, []string{"fd"}},
+		{"Expansion", "$EDITOR notes.txt", nil},
+		{"Function", "greet() { printf hi; }; greet", nil},
+		{"LookedUp", "command -v jq >/dev/null || ls", nil},
+		{"Path", "./deploy.sh && /usr/bin/env ls", []string{"./deploy.sh"}},
+		{"Unparsable", "jq 'broken", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, findMissingPrograms(tc.cmd, exists))
+		})
+	}
+}
+
+func TestFindMissingProgramsOnPath(t *testing.T) {
+	cwd := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(cwd, "bin"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(cwd, "bin", "deploy"), []byte("#!/bin/sh\n"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(cwd, "notes"), []byte("notes\n"), 0644))
+
+	assert.Nil(t, FindMissingPrograms("deploy && ./bin/deploy", cwd, "bin"), "The programs should be found from cwd and path.")
+	assert.Equal(t, []string{"deploy", "notes"}, FindMissingPrograms("deploy; notes", cwd, "."), "Programs off the path or not executable should be missing.")
+}
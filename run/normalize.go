@@ -0,0 +1,70 @@
+package run
+
+import (
+	"regexp"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// plainWord matches words that mean the same quoted or not.
+var plainWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// NormalizeCommand returns cmd in a canonical form, so that commands which only
+// differ in spacing, needless quotes or trailing separators compare equal.
+// Commands that do not parse are only stripped of extra whitespace.
+func NormalizeCommand(cmd string) string {
+	cmd = strings.TrimSpace(cmd)
+
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(cmd), "")
+	if err != nil {
+		return strings.Join(strings.Fields(cmd), " ")
+	}
+
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if word, ok := node.(*syntax.Word); ok {
+			unquoteWord(word)
+		}
+		return true
+	})
+
+	var normalized strings.Builder
+	printer := syntax.NewPrinter(syntax.Minify(true))
+	if err := printer.Print(&normalized, file); err != nil {
+		return strings.Join(strings.Fields(cmd), " ")
+	}
+
+	return strings.TrimSpace(normalized.String())
+}
+
+// unquoteWord replaces a word made of a single quoted string by the bare string
+// when quoting it has no effect.
+func unquoteWord(word *syntax.Word) {
+	if len(word.Parts) != 1 {
+		return
+	}
+
+	var value string
+	switch part := word.Parts[0].(type) {
+	case *syntax.SglQuoted:
+		if part.Dollar {
+			return
+		}
+		value = part.Value
+	case *syntax.DblQuoted:
+		if len(part.Parts) != 1 {
+			return
+		}
+		lit, ok := part.Parts[0].(*syntax.Lit)
+		if !ok {
+			return
+		}
+		value = lit.Value
+	default:
+		return
+	}
+
+	if plainWord.MatchString(value) {
+		word.Parts = []syntax.WordPart{&syntax.Lit{Value: value}}
+	}
+}
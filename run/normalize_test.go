@@ -0,0 +1,34 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeCommand(t *testing.T) {
+	testCases := []struct {
+		name  string
+		left  string
+		right string
+		equal bool
+	}{
+		{"Spacing", "ls   -la  |  grep  foo", "ls -la | grep foo", true},
+		{"TrailingSeparator", "make && make install;", "make && make install", true},
+		{"NeedlessQuotes", `grep "TODO" 'src/main.go'`, "grep TODO src/main.go", true},
+		{"MeaningfulQuotes", `echo "$HOME"`, "echo $HOME", false},
+		{"QuotedSpaces", `rm "my file"`, "rm my file", false},
+		{"DifferentFlags", "rsync -a --delete src/ dst/", "rsync -a src/ dst/", false},
+		{"Unparsable", "echo 'broken   here", "echo 'broken here", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.equal {
+				assert.Equal(t, NormalizeCommand(tc.left), NormalizeCommand(tc.right))
+			} else {
+				assert.NotEqual(t, NormalizeCommand(tc.left), NormalizeCommand(tc.right))
+			}
+		})
+	}
+}
@@ -1,11 +1,34 @@
 package run
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// observation_limit bounds how much of each output of a command is told to
+// the model.
+const observation_limit = 2048
 
 type RunOutput struct {
 	error          error
 	errorMessage   string
 	successMessage string
+	executed       bool
+	captured       bool
+	exitCode       int
+	signal         string
+	limit          string
+	duration       time.Duration
+	stdout         string
+	stderr         string
+	truncated      bool
+	session        SessionChange
+	hosts          []*HostResult
 }
 
 func NewRunOutput(error error, errorMessage string, successMessage string) RunOutput {
@@ -16,6 +39,64 @@ func NewRunOutput(error error, errorMessage string, successMessage string) RunOu
 	}
 }
 
+// NewExecutionRunOutput returns the output of execution, which ran with err.
+func NewExecutionRunOutput(execution *Execution, err error) RunOutput {
+	output := RunOutput{
+		error:     err,
+		executed:  true,
+		captured:  execution.IsCaptured(),
+		exitCode:  execution.GetExitCode(),
+		signal:    execution.GetSignal(),
+		limit:     execution.GetLimit(),
+		duration:  execution.GetDuration(),
+		stdout:    cleanOutput(execution.GetStdout()),
+		stderr:    cleanOutput(execution.GetStderr()),
+		truncated: execution.IsTruncated(),
+		session:   execution.GetSessionChange(),
+	}
+
+	if output.limit != "" {
+		if output.error == nil {
+			// the command handled the termination and exited by itself
+			output.error = errors.New(describeLimit(output.limit, output.exitCode))
+		}
+		output.errorMessage = fmt.Sprintf("Command stopped (%s)", output.GetSummary())
+	} else if err != nil {
+		output.errorMessage = fmt.Sprintf("Command failed (%s)", output.GetSummary())
+	} else {
+		output.successMessage = fmt.Sprintf("Command executed successfully (%s)", output.GetSummary())
+	}
+
+	return output
+}
+
+// NewFanOutRunOutput returns the output of fanOut, which ran with err on some
+// of its hosts. Its exit status is the first one that is not zero.
+func NewFanOutRunOutput(fanOut *FanOut, err error) RunOutput {
+	output := RunOutput{
+		error:    err,
+		executed: true,
+		captured: true,
+		exitCode: 0,
+		duration: fanOut.GetDuration(),
+		hosts:    fanOut.GetResults(),
+	}
+	for _, result := range output.hosts {
+		if result.HasError() && output.exitCode == 0 {
+			output.exitCode = result.GetExitCode()
+		}
+		output.truncated = output.truncated || result.IsTruncated()
+	}
+
+	if err != nil {
+		output.errorMessage = fmt.Sprintf("Command failed (%s)", output.GetSummary())
+	} else {
+		output.successMessage = fmt.Sprintf("Command executed successfully (%s)", output.GetSummary())
+	}
+
+	return output
+}
+
 func (o RunOutput) HasError() bool {
 	return o.error != nil
 }
@@ -27,3 +108,163 @@ func (o RunOutput) GetErrorMessage() string {
 func (o RunOutput) GetSuccessMessage() string {
 	return o.successMessage
 }
+
+// IsExecuted reports whether the output is the one of a command that ran,
+// the only kind that carries an exit status and output.
+func (o RunOutput) IsExecuted() bool {
+	return o.executed
+}
+
+// IsCaptured reports whether the output of the command was captured, which it
+// is not for interactive programs.
+func (o RunOutput) IsCaptured() bool {
+	return o.captured
+}
+
+// GetExitCode returns the exit status of the command, -1 when it was killed
+// by a signal or did not start.
+func (o RunOutput) GetExitCode() int {
+	return o.exitCode
+}
+
+// GetSignal returns the name of the signal that killed the command, if any.
+func (o RunOutput) GetSignal() string {
+	return o.signal
+}
+
+// GetLimit returns the limit the command was killed for going over, such as
+// "timeout of 30s", if any.
+func (o RunOutput) GetLimit() string {
+	return o.limit
+}
+
+func (o RunOutput) GetDuration() time.Duration {
+	return o.duration
+}
+
+// GetStdout returns the end of what the command printed on its terminal,
+// without escape sequences.
+func (o RunOutput) GetStdout() string {
+	return o.stdout
+}
+
+// GetStderr returns the end of what the command printed on its stderr.
+func (o RunOutput) GetStderr() string {
+	return o.stderr
+}
+
+// IsTruncated reports whether the beginning of the output was dropped.
+func (o RunOutput) IsTruncated() bool {
+	return o.truncated
+}
+
+// GetSessionChange returns what the command changed of the working
+// directory and environment of its session.
+func (o RunOutput) GetSessionChange() SessionChange {
+	return o.session
+}
+
+// GetHosts returns how the command went on each host, when it ran on
+// several.
+func (o RunOutput) GetHosts() []*HostResult {
+	return o.hosts
+}
+
+// describeLimit tells how a command that went over limit ended: killed by it,
+// or exiting by itself with exitCode, such as a program that could not
+// allocate memory beyond its limit.
+func describeLimit(limit string, exitCode int) string {
+	if exitCode < 0 {
+		return fmt.Sprintf("killed by the %s", limit)
+	}
+
+	return fmt.Sprintf("exit %d, over the %s", exitCode, limit)
+}
+
+// GetSummary returns how the command ended and how long it took, such as
+// "exit 0, 1.2s".
+func (o RunOutput) GetSummary() string {
+	duration := o.duration.Round(100 * time.Millisecond)
+	if o.duration < time.Second {
+		duration = o.duration.Round(time.Millisecond)
+	}
+
+	if len(o.hosts) > 0 {
+		var hosts []string
+		for _, result := range o.hosts {
+			hosts = append(hosts, fmt.Sprintf("%s %s", result.GetHost(), result.GetSummary()))
+		}
+		return fmt.Sprintf("%s, %s", strings.Join(hosts, ", "), duration)
+	}
+	if o.limit != "" {
+		return fmt.Sprintf("%s, %s", describeLimit(o.limit, o.exitCode), duration)
+	}
+	if o.signal != "" {
+		return fmt.Sprintf("killed by %s, %s", o.signal, duration)
+	}
+	return fmt.Sprintf("exit %d, %s", o.exitCode, duration)
+}
+
+// GetObservation returns what the model is told of the run: how it ended and
+// the end of its output.
+func (o RunOutput) GetObservation() string {
+	if !o.executed {
+		if o.HasError() {
+			return o.GetErrorMessage()
+		}
+		return o.GetSuccessMessage()
+	}
+
+	var observation strings.Builder
+	observation.WriteString(o.GetSummary())
+	if !o.session.IsEmpty() {
+		fmt.Fprintf(&observation, "\n(%s)", o.session)
+	}
+	if !o.captured {
+		observation.WriteString("\n(interactive program, output not captured)")
+		return observation.String()
+	}
+
+	if len(o.hosts) > 0 {
+		// Each host gets its share of the observation
+		limit := max(observation_limit/len(o.hosts), 256)
+		for _, result := range o.hosts {
+			if output := strings.TrimSpace(result.GetOutput()); output != "" {
+				fmt.Fprintf(&observation, "\n%s:\n%s", result.GetHost(), tailText(output, limit))
+			}
+		}
+		return observation.String()
+	}
+
+	for _, stream := range []struct {
+		name   string
+		output string
+	}{{"stdout", o.stdout}, {"stderr", o.stderr}} {
+		output := strings.TrimSpace(stream.output)
+		if output == "" {
+			continue
+		}
+		fmt.Fprintf(&observation, "\n%s:\n%s", stream.name, tailText(output, observation_limit))
+	}
+
+	return observation.String()
+}
+
+// tailText returns the last limit bytes of output, marking the cut.
+func tailText(output string, limit int) string {
+	if len(output) <= limit {
+		return output
+	}
+
+	start := len(output) - limit
+	for start < len(output) && !utf8.RuneStart(output[start]) {
+		start++
+	}
+	return "..." + output[start:]
+}
+
+// cleanOutput removes the escape sequences and carriage returns a terminal
+// output is made of.
+func cleanOutput(output string) string {
+	return strings.ReplaceAll(ansi.Strip(output), "\r\n", "\n")
+}
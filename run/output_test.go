@@ -2,7 +2,9 @@ package run
 
 import (
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -11,6 +13,11 @@ func TestRunOutput(t *testing.T) {
 	t.Run("HasError", testHasError)
 	t.Run("GetErrorMessage", testGetErrorMessage)
 	t.Run("GetSuccessMessage", testGetSuccessMessage)
+	t.Run("NewExecutionRunOutput", testNewExecutionRunOutput)
+	t.Run("NewExecutionRunOutputError", testNewExecutionRunOutputError)
+	t.Run("NewExecutionRunOutputLimit", testNewExecutionRunOutputLimit)
+	t.Run("GetObservation", testGetObservation)
+	t.Run("GetObservationNotCaptured", testGetObservationNotCaptured)
 }
 
 func testHasError(t *testing.T) {
@@ -40,3 +47,68 @@ func testGetSuccessMessage(t *testing.T) {
 
 	assert.Equal(t, expectedSuccessMessage, actualSuccessMessage, "The success messages should be the same.")
 }
+
+// newTestExecutionResult returns an execution that ran with the given
+// outcome.
+func newTestExecutionResult(exitCode int, signal string, stdout string, stderr string) *Execution {
+	execution := NewExecution("make", NewShell("sh", false), 4096)
+	execution.exitCode = exitCode
+	execution.signal = signal
+	execution.duration = 1234 * time.Millisecond
+	execution.stdoutBuffer.Write([]byte(stdout))
+	execution.stderrBuffer.Write([]byte(stderr))
+	return execution
+}
+
+func testNewExecutionRunOutput(t *testing.T) {
+	execution := newTestExecutionResult(0, "", "\x1b[32mok\x1b[0m\r\n", "")
+
+	runOutput := NewExecutionRunOutput(execution, nil)
+
+	assert.False(t, runOutput.HasError(), "RunOutput should not have an error.")
+	assert.True(t, runOutput.IsExecuted(), "RunOutput should be the one of a command.")
+	assert.Equal(t, 0, runOutput.GetExitCode(), "The exit codes should be the same.")
+	assert.Equal(t, 1234*time.Millisecond, runOutput.GetDuration(), "The durations should be the same.")
+	assert.Equal(t, "ok\n", runOutput.GetStdout(), "The stdout should be cleaned of escape sequences.")
+	assert.Equal(t, "Command executed successfully (exit 0, 1.2s)", runOutput.GetSuccessMessage())
+}
+
+func testNewExecutionRunOutputError(t *testing.T) {
+	execution := newTestExecutionResult(-1, "killed", "", "")
+
+	runOutput := NewExecutionRunOutput(execution, errors.New("signal: killed"))
+
+	assert.True(t, runOutput.HasError(), "RunOutput should have an error.")
+	assert.Equal(t, "killed", runOutput.GetSignal(), "The signals should be the same.")
+	assert.Equal(t, "Command failed (killed by killed, 1.2s): signal: killed", runOutput.GetErrorMessage())
+}
+
+func testNewExecutionRunOutputLimit(t *testing.T) {
+	execution := newTestExecutionResult(-1, "terminated", "", "")
+	execution.enforcer.limit = "timeout of 1s"
+
+	runOutput := NewExecutionRunOutput(execution, errors.New("signal: terminated"))
+
+	assert.True(t, runOutput.HasError(), "RunOutput should have an error.")
+	assert.Equal(t, "timeout of 1s", runOutput.GetLimit(), "The limits should be the same.")
+	assert.Equal(t, "Command stopped (killed by the timeout of 1s, 1.2s): signal: terminated", runOutput.GetErrorMessage())
+	assert.True(t, strings.HasPrefix(runOutput.GetObservation(), "killed by the timeout of 1s, 1.2s"), "The model should be told about the limit.")
+}
+
+func testGetObservation(t *testing.T) {
+	execution := newTestExecutionResult(2, "", "building\n", strings.Repeat("e", 3000))
+
+	observation := NewExecutionRunOutput(execution, errors.New("exit status 2")).GetObservation()
+
+	assert.True(t, strings.HasPrefix(observation, "exit 2, 1.2s\nstdout:\nbuilding\nstderr:\n..."), "The observation should hold the status and the outputs.")
+	assert.Len(t, observation, len("exit 2, 1.2s\nstdout:\nbuilding\nstderr:\n...")+2048, "The outputs should be bounded.")
+}
+
+func testGetObservationNotCaptured(t *testing.T) {
+	execution := newTestExecutionResult(0, "", "", "")
+	execution.interactive = true
+
+	observation := NewExecutionRunOutput(execution, nil).GetObservation()
+
+	assert.Equal(t, "exit 0, 1.2s\n(interactive program, output not captured)", observation)
+}
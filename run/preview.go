@@ -0,0 +1,487 @@
+package run
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// preview_output_limit bounds how much of the output of a previewed
+	// command is kept.
+	preview_output_limit = 4096
+	// preview_diff_limit is the size above which the lines of a modified file
+	// are not compared.
+	preview_diff_limit = 1 << 20
+	// preview_diff_edits is how many lines may differ for a diff to be made,
+	// only the changed lines being counted beyond.
+	preview_diff_edits = 2000
+	// preview_diff_context is how many unchanged lines surround the changes
+	// in a diff.
+	preview_diff_context = 3
+)
+
+type ChangeKind int
+
+const (
+	CreatedChange ChangeKind = iota
+	ModifiedChange
+	DeletedChange
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case CreatedChange:
+		return "created"
+	case ModifiedChange:
+		return "modified"
+	default:
+		return "deleted"
+	}
+}
+
+// FileChange is a change a previewed command made to a file of the current
+// directory.
+type FileChange struct {
+	kind    ChangeKind
+	path    string
+	added   int
+	removed int
+	binary  bool
+	diff    string
+}
+
+func (c FileChange) GetKind() ChangeKind {
+	return c.kind
+}
+
+// GetPath returns the path of the file relative to the current directory,
+// ending with a slash for directories.
+func (c FileChange) GetPath() string {
+	return c.path
+}
+
+// GetAdded returns how many lines a modification added.
+func (c FileChange) GetAdded() int {
+	return c.added
+}
+
+// GetRemoved returns how many lines a modification removed.
+func (c FileChange) GetRemoved() int {
+	return c.removed
+}
+
+// GetDiff returns the unified diff of the lines of a text file created or
+// modified, empty when its lines were not compared or there are too many
+// changes.
+func (c FileChange) GetDiff() string {
+	return c.diff
+}
+
+// IsBinary reports whether the lines of a modified file were not compared,
+// because it is binary or too large.
+func (c FileChange) IsBinary() bool {
+	return c.binary
+}
+
+// PreviewOutput is what a command did when run in a sandbox.
+type PreviewOutput struct {
+	error    error
+	command  string
+	exitCode int
+	output   string
+	changes  []FileChange
+}
+
+func (o PreviewOutput) HasError() bool {
+	return o.error != nil
+}
+
+func (o PreviewOutput) GetError() error {
+	return o.error
+}
+
+func (o PreviewOutput) GetCommand() string {
+	return o.command
+}
+
+func (o PreviewOutput) GetExitCode() int {
+	return o.exitCode
+}
+
+// GetOutput returns the end of what the command printed in the sandbox.
+func (o PreviewOutput) GetOutput() string {
+	return o.output
+}
+
+// GetChanges returns the changes made to the current directory, by path.
+func (o PreviewOutput) GetChanges() []FileChange {
+	return o.changes
+}
+
+// collectChanges compares the upper directory of an overlay, where the
+// changes made to the directory lower were written, to lower.
+func collectChanges(upper string, lower string) ([]FileChange, error) {
+	var changes []FileChange
+
+	err := filepath.WalkDir(upper, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(upper, path)
+		if err != nil || relative == "." {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		lowerPath := filepath.Join(lower, relative)
+		lowerInfo, lowerErr := os.Lstat(lowerPath)
+		exists := lowerErr == nil
+
+		switch {
+		case isWhiteout(info):
+			if exists {
+				changes = append(changes, deletedFiles(lower, relative)...)
+			}
+		case info.IsDir():
+			switch {
+			case !exists:
+				changes = append(changes, FileChange{kind: CreatedChange, path: relative + "/"})
+			case !lowerInfo.IsDir():
+				changes = append(changes, FileChange{kind: DeletedChange, path: relative})
+				changes = append(changes, FileChange{kind: CreatedChange, path: relative + "/"})
+			case isOpaque(path):
+				// the directory was replaced, what it held is gone unless
+				// written again
+				for _, change := range deletedFiles(lower, relative)[1:] {
+					if _, err := os.Lstat(filepath.Join(upper, change.path)); err != nil {
+						changes = append(changes, change)
+					}
+				}
+			}
+		default:
+			switch {
+			case !exists:
+				changes = append(changes, createdFile(path, info, relative))
+			case lowerInfo.IsDir():
+				changes = append(changes, deletedFiles(lower, relative)...)
+				changes = append(changes, FileChange{kind: CreatedChange, path: relative})
+			default:
+				if change, ok := compareFiles(lowerPath, path, relative); ok {
+					changes = append(changes, change)
+				}
+			}
+		}
+
+		return nil
+	})
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return strings.TrimSuffix(changes[i].path, "/") < strings.TrimSuffix(changes[j].path, "/")
+	})
+
+	return changes, err
+}
+
+// deletedFiles returns the deletion of relative in lower and, when it is a
+// directory, of everything it holds.
+func deletedFiles(lower string, relative string) []FileChange {
+	var changes []FileChange
+	filepath.WalkDir(filepath.Join(lower, relative), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		path, _ = filepath.Rel(lower, path)
+		if entry.IsDir() {
+			path += "/"
+		}
+		changes = append(changes, FileChange{kind: DeletedChange, path: path})
+		return nil
+	})
+
+	return changes
+}
+
+// createdFile returns the creation of the file relative, written at path, with
+// its lines as added ones when it is a text file.
+func createdFile(path string, info os.FileInfo, relative string) FileChange {
+	change := FileChange{kind: CreatedChange, path: relative}
+	if !info.Mode().IsRegular() || info.Size() > preview_diff_limit {
+		return change
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil || bytes.IndexByte(content, 0) >= 0 {
+		return change
+	}
+	change.added, change.removed, change.diff = diffFiles("", string(content))
+
+	return change
+}
+
+// compareFiles returns the modification of the file relative, from before to
+// after, and whether there is one: a file rewritten as is is not modified.
+func compareFiles(before string, after string, relative string) (FileChange, bool) {
+	change := FileChange{kind: ModifiedChange, path: relative}
+
+	beforeInfo, beforeErr := os.Lstat(before)
+	afterInfo, afterErr := os.Lstat(after)
+	if beforeErr != nil || afterErr != nil {
+		return change, true
+	}
+	if beforeInfo.Size() > preview_diff_limit || afterInfo.Size() > preview_diff_limit {
+		change.binary = true
+		return change, beforeInfo.Size() != afterInfo.Size() || beforeInfo.Mode() != afterInfo.Mode() ||
+			!beforeInfo.ModTime().Equal(afterInfo.ModTime())
+	}
+
+	old, beforeErr := readFileOrLink(before, beforeInfo)
+	new, afterErr := readFileOrLink(after, afterInfo)
+	if beforeErr != nil || afterErr != nil {
+		return change, true
+	}
+	if bytes.Equal(old, new) {
+		return change, beforeInfo.Mode() != afterInfo.Mode()
+	}
+
+	if bytes.IndexByte(old, 0) >= 0 || bytes.IndexByte(new, 0) >= 0 {
+		change.binary = true
+		return change, true
+	}
+	change.added, change.removed, change.diff = diffFiles(string(old), string(new))
+
+	return change, true
+}
+
+// diffFiles returns how many lines of new are not in old, how many lines of
+// old are not in new, and the unified diff from old to new, empty when there
+// are too many changes for it.
+func diffFiles(old string, new string) (int, int, string) {
+	lines, ok := diffLines(splitLines(old), splitLines(new))
+	if !ok {
+		added, removed := countChangedLines(old, new)
+		return added, removed, ""
+	}
+
+	added, removed := 0, 0
+	for _, line := range lines {
+		switch line.kind {
+		case '+':
+			added++
+		case '-':
+			removed++
+		}
+	}
+
+	return added, removed, unifiedDiff(lines, preview_diff_context)
+}
+
+func splitLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+func readFileOrLink(path string, info os.FileInfo) ([]byte, error) {
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		return []byte(target), err
+	}
+
+	return os.ReadFile(path)
+}
+
+// countChangedLines returns how many lines of new are not in old, and how many
+// lines of old are not in new.
+func countChangedLines(old string, new string) (int, int) {
+	counts := make(map[string]int)
+	for _, line := range strings.SplitAfter(old, "\n") {
+		if line != "" {
+			counts[line]++
+		}
+	}
+
+	added := 0
+	for _, line := range strings.SplitAfter(new, "\n") {
+		if line == "" {
+			continue
+		}
+		if counts[line] > 0 {
+			counts[line]--
+		} else {
+			added++
+		}
+	}
+
+	removed := 0
+	for _, count := range counts {
+		removed += count
+	}
+
+	return added, removed
+}
+
+// diffLine is a line of a diff: kept (' '), removed ('-') or added ('+').
+type diffLine struct {
+	kind byte
+	text string
+}
+
+// diffLines returns the shortest edit script from a to b, found with the
+// algorithm of Myers, and whether it was: it is not when more than
+// preview_diff_edits lines differ.
+func diffLines(a []string, b []string) ([]diffLine, bool) {
+	n, m := len(a), len(b)
+	// trace[d][k+d] is the furthest x reached on the diagonal k = x - y with
+	// d edits
+	var trace [][]int
+	for d := 0; d <= n+m && d <= preview_diff_edits; d++ {
+		v := make([]int, 2*d+1)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			switch {
+			case d == 0:
+				x = 0
+			case k == -d || k != d && trace[d-1][k-1+d-1] < trace[d-1][k+1+d-1]:
+				x = trace[d-1][k+1+d-1]
+			default:
+				x = trace[d-1][k-1+d-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+d] = x
+			if x >= n && y >= m {
+				return backtrackDiff(a, b, append(trace, v)), true
+			}
+		}
+		trace = append(trace, v)
+	}
+
+	return nil, false
+}
+
+// backtrackDiff walks trace back from the ends of a and b to their starts,
+// returning the edits in order.
+func backtrackDiff(a []string, b []string, trace [][]int) []diffLine {
+	x, y := len(a), len(b)
+	var lines []diffLine
+	for d := len(trace) - 1; d > 0; d-- {
+		previous := trace[d-1]
+		k := x - y
+		previousK := k - 1
+		if k == -d || k != d && previous[k-1+d-1] < previous[k+1+d-1] {
+			previousK = k + 1
+		}
+		previousX := previous[previousK+d-1]
+		previousY := previousX - previousK
+
+		for x > previousX && y > previousY {
+			lines = append(lines, diffLine{' ', a[x-1]})
+			x--
+			y--
+		}
+		if x == previousX {
+			lines = append(lines, diffLine{'+', b[y-1]})
+			y--
+		} else {
+			lines = append(lines, diffLine{'-', a[x-1]})
+			x--
+		}
+	}
+	for x > 0 {
+		lines = append(lines, diffLine{' ', a[x-1]})
+		x--
+	}
+
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+
+	return lines
+}
+
+// unifiedDiff writes lines as the hunks of a unified diff, each change
+// surrounded by context unchanged lines.
+func unifiedDiff(lines []diffLine, context int) string {
+	var diff strings.Builder
+	for start := 0; start < len(lines); {
+		first := start
+		for first < len(lines) && lines[first].kind == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+
+		// the hunk goes on while the changes are close enough for their
+		// contexts to meet
+		last := first
+		for i := first + 1; i < len(lines) && i <= last+2*context+1; i++ {
+			if lines[i].kind != ' ' {
+				last = i
+			}
+		}
+		from, to := first-context, last+context+1
+		if from < start {
+			from = start
+		}
+		if to > len(lines) {
+			to = len(lines)
+		}
+
+		oldStart, newStart := 1, 1
+		for _, line := range lines[:from] {
+			if line.kind != '+' {
+				oldStart++
+			}
+			if line.kind != '-' {
+				newStart++
+			}
+		}
+		oldCount, newCount := 0, 0
+		for _, line := range lines[from:to] {
+			if line.kind != '+' {
+				oldCount++
+			}
+			if line.kind != '-' {
+				newCount++
+			}
+		}
+
+		diff.WriteString(fmt.Sprintf("@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount)))
+		for _, line := range lines[from:to] {
+			diff.WriteByte(line.kind)
+			diff.WriteString(strings.TrimSuffix(line.text, "\n"))
+			diff.WriteByte('\n')
+		}
+		start = to
+	}
+
+	return diff.String()
+}
+
+// hunkRange writes the range of lines of a hunk, the line before it when it
+// is empty.
+func hunkRange(start int, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprintf("%d", start)
+	default:
+		return fmt.Sprintf("%d,%d", start, count)
+	}
+}
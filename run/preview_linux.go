@@ -0,0 +1,163 @@
+//go:build linux
+
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// preview_timeout bounds how long a previewed command may run.
+const preview_timeout = 30 * time.Second
+
+// previewScript runs the command given as its arguments in the current
+// directory overlaid with the upper directory of the scratch one, everything
+// else being read-only but for a throwaway /tmp. It expects the scratch and
+// current directories as $1 and $2, and writes to file descriptor 3 once the
+// sandbox is ready: a mount that can't be made read-only stops it before the
+// command runs.
+const previewScript = `set -e
+scratch="$1"; cwd="$2"; shift 2
+mount --make-rprivate /
+mount --bind "$scratch" "$scratch"
+mount -t overlay overlay -o "userxattr,lowerdir=$cwd,upperdir=$scratch/upper,workdir=$scratch/work" "$cwd"
+awk '{print $5}' /proc/self/mountinfo | sort -r | while read -r mountpoint; do
+	mountpoint=$(printf '%b' "$mountpoint")
+	case "$mountpoint" in "$scratch"|"$cwd") continue ;; esac
+	mount -o remount,bind,ro "$mountpoint" || { echo "cannot make $mountpoint read-only" >&2; exit 1; }
+done
+case "$cwd" in /tmp|/tmp/*) ;; *) mount -t tmpfs tmpfs /tmp ;; esac
+cd "$cwd"
+echo ready >&3
+exec 3>&-
+exec "$@"`
+
+// PreviewCommand runs cmd in shell in a sandbox: a user, mount and network
+// namespace where the current directory cwd is overlaid, everything else is
+// read-only and only the loopback interface is left, and returns the changes
+// it would make to cwd. The command gets env, the environment of xang when it
+// is nil.
+func PreviewCommand(cmd string, shell Shell, cwd string, env []string) PreviewOutput {
+	output := PreviewOutput{command: cmd, exitCode: -1}
+
+	unshare, err := exec.LookPath("unshare")
+	if err != nil {
+		output.error = errors.New("sandbox preview needs unshare, from util-linux")
+		return output
+	}
+
+	scratch, err := os.MkdirTemp("", "xang-preview-")
+	if err != nil {
+		output.error = fmt.Errorf("failed to create sandbox: %w", err)
+		return output
+	}
+	defer removeScratch(scratch)
+
+	for _, dir := range []string{"upper", "work"} {
+		if err := os.Mkdir(filepath.Join(scratch, dir), 0700); err != nil {
+			output.error = fmt.Errorf("failed to create sandbox: %w", err)
+			return output
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), preview_timeout)
+	defer cancel()
+
+	ready, readyWriter, err := os.Pipe()
+	if err != nil {
+		output.error = fmt.Errorf("failed to create sandbox: %w", err)
+		return output
+	}
+	defer ready.Close()
+
+	args := []string{"--user", "--map-root-user", "--mount", "--net", "sh", "-c", previewScript, "xang-preview", scratch, cwd}
+	args = append(args, PrepareInteractiveCommand(cmd, shell).Args...)
+
+	buffer := newTailBuffer(preview_output_limit)
+	command := exec.CommandContext(ctx, unshare, args...)
+	command.Stdout = buffer
+	command.Stderr = buffer
+	command.Env = env
+	command.ExtraFiles = []*os.File{readyWriter}
+	command.WaitDelay = time.Second
+
+	err = command.Run()
+	readyWriter.Close()
+	output.output = strings.TrimSpace(cleanOutput(buffer.String()))
+	if command.ProcessState != nil {
+		output.exitCode = command.ProcessState.ExitCode()
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		output.error = fmt.Errorf("preview timed out after %s", preview_timeout)
+		return output
+	case err != nil && !errors.As(err, &exitErr):
+		output.error = fmt.Errorf("failed to run sandbox: %w", err)
+		return output
+	case !isReady(ready):
+		output.error = fmt.Errorf("failed to set up sandbox: %s", lastLine(output.output))
+		return output
+	}
+
+	output.changes, err = collectChanges(filepath.Join(scratch, "upper"), cwd)
+	if err != nil {
+		output.error = fmt.Errorf("failed to read sandbox changes: %w", err)
+	}
+
+	return output
+}
+
+// isReady reports whether the sandbox wrote that it was ready to ready.
+func isReady(ready *os.File) bool {
+	content, _ := io.ReadAll(ready)
+	return strings.TrimSpace(string(content)) == "ready"
+}
+
+// lastLine returns the last line of output, "no output" when empty.
+func lastLine(output string) string {
+	if output == "" {
+		return "no output"
+	}
+
+	return output[strings.LastIndex(output, "\n")+1:]
+}
+
+// removeScratch removes the sandbox, including the work directory the
+// overlay leaves without permissions.
+func removeScratch(scratch string) {
+	filepath.Walk(scratch, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			os.Chmod(path, 0700)
+		}
+		return nil
+	})
+	os.RemoveAll(scratch)
+}
+
+// isWhiteout reports whether info is the one of an overlay whiteout, which
+// marks a deleted file.
+func isWhiteout(info os.FileInfo) bool {
+	if info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && stat.Rdev == 0
+}
+
+// isOpaque reports whether the overlay directory path replaced the lower one
+// instead of merging with it.
+func isOpaque(path string) bool {
+	value := make([]byte, 1)
+	size, err := syscall.Getxattr(path, "user.overlay.opaque", value)
+	return err == nil && size == 1 && value[0] == 'y'
+}
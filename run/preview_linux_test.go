@@ -0,0 +1,73 @@
+//go:build linux
+
+package run
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewCommand(t *testing.T) {
+	t.Run("Changes", testPreviewCommandChanges)
+	t.Run("Network", testPreviewCommandNetwork)
+	t.Run("SetupFailure", testPreviewCommandSetupFailure)
+}
+
+func testPreviewCommandChanges(t *testing.T) {
+	cwd, outside := t.TempDir(), t.TempDir()
+	writeTestFiles(t, cwd, map[string]string{
+		"notes.txt": "one\ntwo\n",
+		"old/a.txt": "a\n",
+		"same.txt":  "same\n",
+	})
+
+	preview := PreviewCommand(
+		"sed -i 's/two/three/' notes.txt && rm -r old && touch new.txt && cp same.txt same.txt.bak && cp same.txt.bak same.txt && touch "+filepath.Join(outside, "escaped"),
+		NewShell("sh", false),
+		cwd,
+		nil,
+	)
+	if preview.HasError() {
+		t.Skipf("sandbox is not available: %s", preview.GetError())
+	}
+
+	expectedChanges := []FileChange{
+		{kind: CreatedChange, path: "new.txt"},
+		{kind: ModifiedChange, path: "notes.txt", added: 1, removed: 1, diff: "@@ -1,2 +1,2 @@\n one\n-two\n+three\n"},
+		{kind: DeletedChange, path: "old/"},
+		{kind: DeletedChange, path: "old/a.txt"},
+		{kind: CreatedChange, path: "same.txt.bak", added: 1, diff: "@@ -0,0 +1 @@\n+same\n"},
+	}
+	assert.Equal(t, expectedChanges, preview.GetChanges(), "The changes should be the same.")
+
+	content, err := os.ReadFile(filepath.Join(cwd, "notes.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "one\ntwo\n", string(content), "The current directory should not change.")
+	assert.NoFileExists(t, filepath.Join(outside, "escaped"), "Nothing outside of the current directory should change.")
+}
+
+func testPreviewCommandNetwork(t *testing.T) {
+	preview := PreviewCommand("tail -n +3 /proc/net/dev | cut -d: -f1", NewShell("sh", false), t.TempDir(), nil)
+	if preview.HasError() {
+		t.Skipf("sandbox is not available: %s", preview.GetError())
+	}
+
+	assert.Equal(t, "lo", strings.TrimSpace(preview.GetOutput()), "Only the loopback interface should be left.")
+}
+
+func testPreviewCommandSetupFailure(t *testing.T) {
+	if _, err := exec.LookPath("unshare"); err != nil {
+		t.Skip("sandbox is not available: no unshare")
+	}
+
+	preview := PreviewCommand("touch ran", NewShell("sh", false), filepath.Join(t.TempDir(), "missing"), nil)
+
+	require.True(t, preview.HasError(), "A sandbox that can't be set up should fail the preview.")
+	assert.Empty(t, preview.GetChanges())
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+
+package run
+
+import (
+	"errors"
+	"os"
+)
+
+// PreviewCommand returns an error, sandboxes need Linux namespaces.
+func PreviewCommand(cmd string, shell Shell, cwd string, env []string) PreviewOutput {
+	return PreviewOutput{
+		error:    errors.New("sandbox preview is only supported on Linux"),
+		command:  cmd,
+		exitCode: -1,
+	}
+}
+
+func isWhiteout(info os.FileInfo) bool {
+	return false
+}
+
+func isOpaque(path string) bool {
+	return false
+}
@@ -0,0 +1,77 @@
+package run
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreview(t *testing.T) {
+	t.Run("CollectChanges", testCollectChanges)
+	t.Run("CountChangedLines", testCountChangedLines)
+	t.Run("DiffFiles", testDiffFiles)
+}
+
+// writeTestFiles writes files, by path relative to dir.
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	for path, content := range files {
+		path = filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	}
+}
+
+func testCollectChanges(t *testing.T) {
+	lower, upper := t.TempDir(), t.TempDir()
+	writeTestFiles(t, lower, map[string]string{
+		"main.go":     "package main\nfunc main() {}\n",
+		"README.md":   "# readme\n",
+		"data/a.json": "{}",
+	})
+	writeTestFiles(t, upper, map[string]string{
+		"main.go":      "package main\nfunc main() {\n}\n",
+		"README.md":    "# readme\n",
+		"data/b.json":  "{}",
+		"build/app.sh": "#!/bin/sh\n",
+	})
+
+	changes, err := collectChanges(upper, lower)
+	require.NoError(t, err)
+
+	expectedChanges := []FileChange{
+		{kind: CreatedChange, path: "build/"},
+		{kind: CreatedChange, path: "build/app.sh", added: 1, diff: "@@ -0,0 +1 @@\n+#!/bin/sh\n"},
+		{kind: CreatedChange, path: "data/b.json", added: 1, diff: "@@ -0,0 +1 @@\n+{}\n"},
+		{kind: ModifiedChange, path: "main.go", added: 2, removed: 1, diff: "@@ -1,2 +1,3 @@\n package main\n-func main() {}\n+func main() {\n+}\n"},
+	}
+	assert.Equal(t, expectedChanges, changes, "The changes should be the same.")
+}
+
+func testCountChangedLines(t *testing.T) {
+	added, removed := countChangedLines("a\nb\nc\n", "a\nB\nc\nd\n")
+
+	assert.Equal(t, 2, added, "The added lines should be counted.")
+	assert.Equal(t, 1, removed, "The removed lines should be counted.")
+}
+
+func testDiffFiles(t *testing.T) {
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	new := "1\ntwo\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+
+	added, removed, diff := diffFiles(old, new)
+
+	assert.Equal(t, 2, added, "The added lines should be counted.")
+	assert.Equal(t, 1, removed, "The removed lines should be counted.")
+	expectedDiff := "@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n" +
+		"@@ -10,3 +10,4 @@\n 10\n 11\n 12\n+13\n"
+	assert.Equal(t, expectedDiff, diff, "Distant changes should be in separate hunks.")
+
+	_, _, diff = diffFiles("a\nb\n", "b\na\n")
+	assert.Equal(t, "@@ -1,2 +1,2 @@\n-a\n b\n+a\n", diff, "Moved lines should be removed and added.")
+
+	_, _, diff = diffFiles("same\n", "same\n")
+	assert.Empty(t, diff, "Equal files should have no diff.")
+}
@@ -0,0 +1,324 @@
+package run
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sshProgram is the client remote commands run with, reading the user's ssh
+// configuration.
+var sshProgram = "ssh"
+
+// RemoteCommand returns the command running cmd on host over ssh, in the
+// login shell of the remote user. A terminal is allocated on the host when
+// tty is set, even when the input of ssh is not one: the output keeps its
+// colors and prompts, and the command is hung up on when ssh is killed
+// instead of running on. Password prompts are disabled, hosts are reached
+// with keys or an agent.
+func RemoteCommand(host string, cmd string, tty bool) *exec.Cmd {
+	terminal := "-T"
+	if tty {
+		terminal = "-tt"
+	}
+
+	return exec.Command(sshProgram, terminal, "-o", "BatchMode=yes", "-o", "ConnectTimeout=10", "--", host, cmd)
+}
+
+// HostResult is how a command run on several hosts went on one of them.
+type HostResult struct {
+	host     string
+	exitCode int
+	signal   string
+	limit    string
+	duration time.Duration
+	output   *tailBuffer
+	stopOnce sync.Once
+	err      error
+}
+
+func (r *HostResult) GetHost() string {
+	return r.host
+}
+
+// GetExitCode returns the exit status of the command on the host, 255 when
+// ssh failed to reach it, and -1 when it did not exit by itself.
+func (r *HostResult) GetExitCode() int {
+	return r.exitCode
+}
+
+// GetSignal returns the name of the signal that killed ssh, if any.
+func (r *HostResult) GetSignal() string {
+	return r.signal
+}
+
+// GetLimit returns the limit the command was stopped for going over on the
+// host, if any.
+func (r *HostResult) GetLimit() string {
+	return r.limit
+}
+
+func (r *HostResult) GetDuration() time.Duration {
+	return r.duration
+}
+
+// GetOutput returns the end of what the command printed on the host, its
+// stdout and stderr interleaved, without escape sequences.
+func (r *HostResult) GetOutput() string {
+	return cleanOutput(r.output.String())
+}
+
+func (r *HostResult) IsTruncated() bool {
+	return r.output.IsTruncated()
+}
+
+func (r *HostResult) HasError() bool {
+	return r.err != nil
+}
+
+// GetSummary returns how the command ended on the host, such as "exit 0".
+func (r *HostResult) GetSummary() string {
+	if r.limit != "" {
+		return fmt.Sprintf("killed by the %s", r.limit)
+	}
+	if r.signal != "" {
+		return fmt.Sprintf("killed by %s", r.signal)
+	}
+	if r.exitCode == 255 {
+		return "exit 255, ssh failed"
+	}
+	return fmt.Sprintf("exit %d", r.exitCode)
+}
+
+// FanOut runs a command on several hosts at once over ssh, a few of them at a
+// time, printing the lines of each host prefixed with its name and keeping
+// the end of its output. The timeout and output limits apply to each host,
+// the resource limits can't be set remotely.
+type FanOut struct {
+	command  string
+	hosts    []string
+	parallel int
+	limits   Limits
+	limit    int
+	stdout   io.Writer
+	stderr   io.Writer
+	results  []*HostResult
+	duration time.Duration
+}
+
+// NewFanOut returns the run of command on hosts, parallel of them at a time,
+// keeping the last limit bytes of the output of each.
+func NewFanOut(command string, hosts []string, parallel int, limit int) *FanOut {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	return &FanOut{
+		command:  command,
+		hosts:    hosts,
+		parallel: parallel,
+		limit:    limit,
+		stdout:   os.Stdout,
+		stderr:   os.Stderr,
+	}
+}
+
+// SetLimits sets the limits the command runs within on each host.
+func (f *FanOut) SetLimits(limits Limits) {
+	f.limits = limits
+}
+
+// SetStdin does nothing, commands run on several hosts have no input.
+func (f *FanOut) SetStdin(r io.Reader) {}
+
+func (f *FanOut) SetStdout(w io.Writer) {
+	f.stdout = w
+}
+
+func (f *FanOut) SetStderr(w io.Writer) {
+	f.stderr = w
+}
+
+// Run runs the command on every host between two blank lines. The error
+// tells on how many hosts it failed, if any.
+func (f *FanOut) Run() error {
+	fmt.Fprint(f.stdout, "\n\n")
+	start := time.Now()
+
+	width := 0
+	for _, host := range f.hosts {
+		width = max(width, len(host))
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, f.parallel)
+	f.results = make([]*HostResult, len(f.hosts))
+	for i, host := range f.hosts {
+		result := &HostResult{host: host, exitCode: -1, output: newTailBuffer(f.limit)}
+		f.results[i] = result
+		prefix := fmt.Sprintf("%-*s | ", width, host)
+
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			writer := &prefixWriter{mu: &mu, w: f.stdout, prefix: prefix}
+			f.runHost(result, io.MultiWriter(writer, result.output))
+			writer.Flush()
+		}()
+	}
+	wg.Wait()
+
+	f.duration = time.Since(start)
+	fmt.Fprint(f.stdout, "\n\n")
+
+	var failed []string
+	for _, result := range f.results {
+		if result.HasError() {
+			failed = append(failed, result.host)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed on %d of %d hosts: %s", len(failed), len(f.hosts), strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// runHost runs the command on the host of result, its output going to w.
+func (f *FanOut) runHost(result *HostResult, w io.Writer) {
+	// The terminal makes the command stop along with ssh, its input being
+	// empty
+	cmd := RemoteCommand(result.host, f.command, true)
+	watch := &outputWatch{limit: f.limits.Output}
+	stop := func(limit string) {
+		result.stopOnce.Do(func() {
+			result.limit = limit
+			if cmd.Process != nil {
+				cmd.Process.Kill()
+			}
+		})
+	}
+	watch.exceeded = func() {
+		stop(fmt.Sprintf("output limit of %s", formatSize(f.limits.Output)))
+	}
+	// A single writer for both, for its lines to be written one at a time
+	output := io.MultiWriter(w, watch)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.WaitDelay = output_drain_timeout
+
+	start := time.Now()
+	if result.err = cmd.Start(); result.err != nil {
+		fmt.Fprintf(w, "%s\n", result.err)
+		return
+	}
+	if f.limits.Timeout > 0 {
+		timer := time.AfterFunc(f.limits.Timeout, func() {
+			stop(fmt.Sprintf("timeout of %s", f.limits.Timeout))
+		})
+		defer timer.Stop()
+	}
+	result.err = cmd.Wait()
+	result.duration = time.Since(start)
+	if cmd.ProcessState != nil {
+		result.exitCode = cmd.ProcessState.ExitCode()
+		if result.limit == "" {
+			result.signal = signalName(cmd.ProcessState)
+		}
+	}
+	if result.limit != "" && result.err == nil {
+		result.err = fmt.Errorf("killed by the %s", result.limit)
+	}
+}
+
+func (f *FanOut) GetCommand() string {
+	return f.command
+}
+
+func (f *FanOut) GetHosts() []string {
+	return f.hosts
+}
+
+// GetResults returns how the command went on each host, in the order of the
+// hosts.
+func (f *FanOut) GetResults() []*HostResult {
+	return f.results
+}
+
+func (f *FanOut) GetDuration() time.Duration {
+	return f.duration
+}
+
+// prefixWriter writes the lines written to it to w, each starting with
+// prefix and ending with a newline alone, holding back the last line until
+// it is complete. The writers of several hosts share mu, for their lines not
+// to be mixed.
+type prefixWriter struct {
+	mu      *sync.Mutex
+	w       io.Writer
+	prefix  string
+	pending []byte
+}
+
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.pending = append(p.pending, data...)
+	for {
+		end := bytes.IndexByte(p.pending, '\n')
+		if end < 0 {
+			break
+		}
+		p.writeLine(append(bytes.TrimSuffix(p.pending[:end], []byte("\r")), '\n'))
+		p.pending = p.pending[end+1:]
+	}
+
+	return len(data), nil
+}
+
+// Flush writes the last line, when it did not end with a newline.
+func (p *prefixWriter) Flush() {
+	if len(p.pending) > 0 {
+		p.writeLine(append(p.pending, '\n'))
+		p.pending = nil
+	}
+}
+
+func (p *prefixWriter) writeLine(line []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.w, "%s%s", p.prefix, line)
+}
+
+// ResolveHosts returns the hosts target names, separated by commas, each
+// one the name of one of groups or a host, in order and without duplicates.
+func ResolveHosts(target string, groups map[string][]string) []string {
+	var hosts []string
+	seen := make(map[string]bool)
+	add := func(host string) {
+		if host = strings.TrimSpace(host); host != "" && !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+
+	for _, name := range strings.Split(target, ",") {
+		name = strings.TrimSpace(name)
+		if members, ok := groups[name]; ok {
+			for _, member := range members {
+				add(member)
+			}
+			continue
+		}
+		add(name)
+	}
+
+	return hosts
+}
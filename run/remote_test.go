@@ -0,0 +1,123 @@
+//go:build unix
+
+package run
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSsh runs the commands given to ssh locally, with HOST set to the host
+// they were meant for and SSH_FLAGS to the flags of ssh. The host down can't
+// be reached.
+const fakeSsh = `#!/bin/sh
+flags=
+while [ "$1" != "--" ]; do flags="$flags $1"; shift; done
+shift
+host=$1
+shift
+if [ "$host" = down ]; then
+	echo "ssh: connect to host down port 22: Connection refused" >&2
+	exit 255
+fi
+HOST=$host SSH_FLAGS=$flags exec sh -c "$*"
+`
+
+// useFakeSsh makes remote commands run with fakeSsh for the test.
+func useFakeSsh(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ssh")
+	require.NoError(t, os.WriteFile(path, []byte(fakeSsh), 0755))
+
+	previous := sshProgram
+	sshProgram = path
+	t.Cleanup(func() { sshProgram = previous })
+}
+
+func TestResolveHosts(t *testing.T) {
+	groups := map[string][]string{"web": {"web-01", "web-02"}}
+
+	assert.Equal(t, []string{"db-01"}, ResolveHosts("db-01", groups))
+	assert.Equal(t, []string{"web-01", "web-02", "db-01"}, ResolveHosts("web, db-01, web-02", groups), "Groups should be expanded without duplicates.")
+	assert.Empty(t, ResolveHosts(" , ", groups))
+}
+
+func TestFanOut(t *testing.T) {
+	t.Run("Run", testFanOutRun)
+	t.Run("RunTimeout", testFanOutRunTimeout)
+	t.Run("Terminal", testFanOutTerminal)
+	t.Run("Execution", testFanOutExecution)
+}
+
+func testFanOutRun(t *testing.T) {
+	useFakeSsh(t)
+	var stdout bytes.Buffer
+	fanOut := NewFanOut(`echo "disk on $HOST"; [ "$HOST" != web-02 ] || exit 3`, []string{"web-01", "web-02", "down"}, 2, 1024)
+	fanOut.SetStdout(&stdout)
+
+	err := fanOut.Run()
+
+	assert.EqualError(t, err, "failed on 2 of 3 hosts: web-02, down")
+	assert.Contains(t, stdout.String(), "web-01 | disk on web-01\n", "Each line should be prefixed with its host.")
+	assert.Contains(t, stdout.String(), "down   | ssh: connect to host down", "The hosts should be aligned.")
+
+	results := fanOut.GetResults()
+	require.Len(t, results, 3)
+	assert.Equal(t, "exit 0", results[0].GetSummary())
+	assert.Equal(t, "disk on web-01\n", results[0].GetOutput())
+	assert.Equal(t, 3, results[1].GetExitCode())
+	assert.Equal(t, "exit 255, ssh failed", results[2].GetSummary())
+
+	output := NewFanOutRunOutput(fanOut, err)
+	assert.True(t, output.HasError())
+	assert.Equal(t, 3, output.GetExitCode(), "The first failing exit status should be kept.")
+	assert.True(t, strings.HasPrefix(output.GetSummary(), "web-01 exit 0, web-02 exit 3, down exit 255, ssh failed, "))
+	assert.Contains(t, output.GetObservation(), "\nweb-02:\ndisk on web-02")
+}
+
+func testFanOutRunTimeout(t *testing.T) {
+	useFakeSsh(t)
+	var stdout bytes.Buffer
+	fanOut := NewFanOut("sleep 5", []string{"web-01"}, 1, 1024)
+	fanOut.SetStdout(&stdout)
+	fanOut.SetLimits(Limits{Timeout: 100 * time.Millisecond})
+
+	start := time.Now()
+	err := fanOut.Run()
+
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 4*time.Second, "The command should be stopped at its timeout.")
+	assert.Equal(t, "killed by the timeout of 100ms", fanOut.GetResults()[0].GetSummary())
+}
+
+func testFanOutTerminal(t *testing.T) {
+	useFakeSsh(t)
+	var stdout bytes.Buffer
+	fanOut := NewFanOut(`echo "$SSH_FLAGS"; printf 'done\r\n'`, []string{"web-01"}, 1, 1024)
+	fanOut.SetStdout(&stdout)
+
+	require.NoError(t, fanOut.Run())
+
+	assert.Contains(t, stdout.String(), " -tt ", "A terminal should be allocated for the command to stop along with ssh.")
+	assert.Contains(t, stdout.String(), "web-01 | done\n", "The carriage returns of the terminal should be dropped.")
+	assert.True(t, strings.HasSuffix(fanOut.GetResults()[0].GetOutput(), "done\n"), "The output should not keep the carriage returns.")
+}
+
+func testFanOutExecution(t *testing.T) {
+	useFakeSsh(t)
+	var stdout, stderr bytes.Buffer
+	execution := newTestExecution(`echo "on $HOST"; exit 2`, &stdout, &stderr)
+	execution.SetHost("web-01")
+
+	err := execution.Run()
+
+	assert.Error(t, err)
+	assert.Equal(t, 2, execution.GetExitCode(), "The remote exit status should be kept.")
+	assert.Contains(t, execution.GetStdout(), "on web-01")
+}
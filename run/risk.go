@@ -0,0 +1,494 @@
+package run
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+type RiskLevel int
+
+const (
+	NoRisk RiskLevel = iota
+	LowRisk
+	MediumRisk
+	HighRisk
+	CriticalRisk
+)
+
+func (l RiskLevel) String() string {
+	switch l {
+	case LowRisk:
+		return "low"
+	case MediumRisk:
+		return "medium"
+	case HighRisk:
+		return "high"
+	case CriticalRisk:
+		return "critical"
+	default:
+		return "none"
+	}
+}
+
+// ParseRiskLevel returns the risk level named s, such as "high".
+func ParseRiskLevel(s string) (RiskLevel, error) {
+	for level := NoRisk; level <= CriticalRisk; level++ {
+		if strings.EqualFold(strings.TrimSpace(s), level.String()) {
+			return level, nil
+		}
+	}
+
+	return NoRisk, fmt.Errorf("unknown risk level %q", s)
+}
+
+// RiskRule is a user rule that raises the risk of the commands running
+// Program, when set, and matching the regular expression Pattern, when set.
+type RiskRule struct {
+	Program string `mapstructure:"program"`
+	Pattern string `mapstructure:"pattern"`
+	Level   string `mapstructure:"level"`
+	Reason  string `mapstructure:"reason"`
+}
+
+// Validate reports whether the rule can match commands.
+func (r RiskRule) Validate() error {
+	if r.Program == "" && r.Pattern == "" {
+		return fmt.Errorf("risk rule needs a program or a pattern")
+	}
+	if _, err := ParseRiskLevel(r.Level); err != nil {
+		return err
+	}
+	if _, err := regexp.Compile(r.Pattern); err != nil {
+		return fmt.Errorf("invalid risk rule pattern: %w", err)
+	}
+
+	return nil
+}
+
+// Risk is something a command does that deserves attention.
+type Risk struct {
+	level   RiskLevel
+	program string
+	reason  string
+}
+
+func (r Risk) GetLevel() RiskLevel {
+	return r.level
+}
+
+// GetProgram returns the program that takes the risk, if known.
+func (r Risk) GetProgram() string {
+	return r.program
+}
+
+func (r Risk) GetReason() string {
+	return r.reason
+}
+
+// RiskAssessment holds the risks of a command, the highest first.
+type RiskAssessment struct {
+	risks []Risk
+}
+
+func (a RiskAssessment) GetRisks() []Risk {
+	return a.risks
+}
+
+// GetLevel returns the level of the highest risk of the command.
+func (a RiskAssessment) GetLevel() RiskLevel {
+	if len(a.risks) == 0 {
+		return NoRisk
+	}
+
+	return a.risks[0].level
+}
+
+// GetConfirmation returns what must be typed to run the command: the name of
+// the program for high risks, "yes" for critical ones, and nothing when a
+// single key press is enough.
+func (a RiskAssessment) GetConfirmation() string {
+	switch a.GetLevel() {
+	case CriticalRisk:
+		return "yes"
+	case HighRisk:
+		if program := a.risks[0].program; program != "" {
+			return program
+		}
+		return "yes"
+	default:
+		return ""
+	}
+}
+
+// criticalPaths are the directories whose recursive deletion or change of
+// permissions breaks the system or loses the user's data.
+var criticalPaths = map[string]bool{
+	"": true, "~": true, "$HOME": true,
+	"/bin": true, "/boot": true, "/dev": true, "/etc": true, "/home": true, "/lib": true, "/lib64": true,
+	"/opt": true, "/proc": true, "/root": true, "/sbin": true, "/sys": true, "/usr": true, "/var": true,
+}
+
+// scriptInterpreters run the script given on their standard input.
+var scriptInterpreters = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "fish": true,
+	"python": true, "python3": true, "perl": true, "ruby": true,
+}
+
+var (
+	blockDevicePattern    = regexp.MustCompile(`^/dev/(sd|hd|vd|xvd|nvme|mmcblk|disk|md|dm-|loop)`)
+	downloadSubstPattern  = regexp.MustCompile(`(\$\(|<\(|` + "`" + `)\s*(curl|wget)\b`)
+	harmlessWriteTargets  = map[string]bool{"/dev/null": true, "/dev/stdout": true, "/dev/stderr": true, "/dev/tty": true}
+	writeRedirectOperands = map[syntax.RedirOperator]bool{
+		syntax.RdrOut: true, syntax.AppOut: true, syntax.ClbOut: true, syntax.RdrAll: true, syntax.AppAll: true,
+	}
+)
+
+// AssessRisk parses cmd and returns its risks, such as recursive deletes,
+// writes to block devices, downloaded scripts, commands run as root, force
+// pushes and writes outside of cwd, along with the risks of the user rules
+// it matches.
+func AssessRisk(cmd string, cwd string, rules []RiskRule) RiskAssessment {
+	analyzer := &riskAnalyzer{cwd: cwd, programs: make(map[string]bool)}
+	if home, err := os.UserHomeDir(); err == nil {
+		analyzer.home = home
+	}
+
+	file, err := syntax.NewParser().Parse(strings.NewReader(cmd), "")
+	if err != nil {
+		analyzer.add(MediumRisk, "", "the command could not be analyzed")
+	} else {
+		syntax.Walk(file, analyzer.visit)
+	}
+
+	for _, rule := range rules {
+		analyzer.apply(cmd, rule)
+	}
+
+	sort.SliceStable(analyzer.risks, func(i, j int) bool {
+		return analyzer.risks[i].level > analyzer.risks[j].level
+	})
+
+	return RiskAssessment{risks: analyzer.risks}
+}
+
+type riskAnalyzer struct {
+	cwd      string
+	home     string
+	programs map[string]bool
+	risks    []Risk
+}
+
+func (a *riskAnalyzer) add(level RiskLevel, program string, reason string) {
+	for _, risk := range a.risks {
+		if risk.level == level && risk.reason == reason {
+			return
+		}
+	}
+
+	a.risks = append(a.risks, Risk{level: level, program: program, reason: reason})
+}
+
+func (a *riskAnalyzer) visit(node syntax.Node) bool {
+	switch node := node.(type) {
+	case *syntax.CallExpr:
+		a.checkCall(node)
+	case *syntax.Stmt:
+		for _, redirect := range node.Redirs {
+			a.checkRedirect(redirect)
+		}
+	case *syntax.BinaryCmd:
+		if node.Op == syntax.Pipe || node.Op == syntax.PipeAll {
+			a.checkPipe(node)
+		}
+	}
+
+	return true
+}
+
+func (a *riskAnalyzer) checkCall(call *syntax.CallExpr) {
+	words := make([]string, 0, len(call.Args))
+	for _, arg := range call.Args {
+		words = append(words, wordText(arg))
+	}
+
+	name, args, elevated := callProgram(literalWords(call))
+	if name == "" {
+		return
+	}
+	args = words[len(words)-len(args):]
+	a.programs[name] = true
+
+	if elevated {
+		a.add(MediumRisk, "", "runs as root")
+	}
+
+	switch {
+	case name == "su":
+		a.add(MediumRisk, "", "runs as root")
+	case name == "rm":
+		a.checkDelete(args)
+	case name == "dd":
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "of=") && blockDevicePattern.MatchString(strings.TrimPrefix(arg, "of=")) {
+				a.add(CriticalRisk, name, fmt.Sprintf("overwrites the block device %s", strings.TrimPrefix(arg, "of=")))
+			}
+		}
+	case strings.HasPrefix(name, "mkfs") || name == "mke2fs" || name == "mkswap" || name == "wipefs":
+		a.add(CriticalRisk, name, "formats a device")
+	case name == "chmod" || name == "chown" || name == "chgrp":
+		a.checkPermissions(name, args)
+	case name == "git":
+		a.checkPush(args)
+	case name == "tee" || name == "touch" || name == "mkdir":
+		for _, arg := range operands(args) {
+			a.checkWrite(arg)
+		}
+	case name == "cp" || name == "mv" || name == "install" || name == "ln" || name == "rsync":
+		if targets := operands(args); len(targets) > 1 {
+			a.checkWrite(targets[len(targets)-1])
+		}
+	case scriptInterpreters[name]:
+		for _, arg := range args {
+			if downloadSubstPattern.MatchString(arg) {
+				a.add(HighRisk, name, "runs a downloaded script")
+			}
+		}
+	}
+}
+
+func (a *riskAnalyzer) checkDelete(args []string) {
+	if !hasFlag(args, "r", "R", "--recursive") {
+		a.add(LowRisk, "rm", "deletes files")
+		return
+	}
+
+	for _, target := range operands(args) {
+		if isCriticalPath(target) {
+			a.add(CriticalRisk, "rm", fmt.Sprintf("deletes %s recursively", target))
+			return
+		}
+	}
+	if hasFlag(args, "--no-preserve-root") {
+		a.add(CriticalRisk, "rm", "deletes recursively without protecting /")
+		return
+	}
+
+	a.add(HighRisk, "rm", "deletes recursively")
+}
+
+func (a *riskAnalyzer) checkPermissions(name string, args []string) {
+	if !hasFlag(args, "R", "--recursive") {
+		return
+	}
+
+	for _, target := range operands(args) {
+		if isCriticalPath(target) {
+			a.add(CriticalRisk, name, fmt.Sprintf("changes %s recursively", target))
+			return
+		}
+	}
+
+	a.add(MediumRisk, name, "changes ownership or permissions recursively")
+}
+
+func (a *riskAnalyzer) checkPush(args []string) {
+	if len(args) == 0 || args[0] != "push" {
+		return
+	}
+
+	for _, arg := range args[1:] {
+		switch {
+		case arg == "-f" || arg == "--force" || strings.HasPrefix(arg, "+"):
+			a.add(HighRisk, "git", "force pushes, rewriting the remote history")
+			return
+		case strings.HasPrefix(arg, "--force-with-lease") || strings.HasPrefix(arg, "--force-if-includes"):
+			a.add(MediumRisk, "git", "force pushes, rewriting the remote history")
+			return
+		}
+	}
+}
+
+func (a *riskAnalyzer) checkRedirect(redirect *syntax.Redirect) {
+	if !writeRedirectOperands[redirect.Op] || redirect.Word == nil {
+		return
+	}
+
+	target := wordText(redirect.Word)
+	if blockDevicePattern.MatchString(target) {
+		a.add(CriticalRisk, "", fmt.Sprintf("overwrites the block device %s", target))
+		return
+	}
+
+	a.checkWrite(target)
+}
+
+// checkPipe flags scripts downloaded and piped into an interpreter.
+func (a *riskAnalyzer) checkPipe(pipe *syntax.BinaryCmd) {
+	downloads := false
+	syntax.Walk(pipe.X, func(node syntax.Node) bool {
+		if call, ok := node.(*syntax.CallExpr); ok {
+			name, _, _ := callProgram(literalWords(call))
+			downloads = downloads || name == "curl" || name == "wget"
+		}
+		return !downloads
+	})
+	if !downloads {
+		return
+	}
+
+	if call, ok := pipe.Y.Cmd.(*syntax.CallExpr); ok {
+		if name, _, _ := callProgram(literalWords(call)); scriptInterpreters[name] {
+			a.add(HighRisk, name, "pipes a downloaded script into "+name)
+		}
+	}
+}
+
+// checkWrite flags writes to target when it is outside of the current
+// directory and of the temporary one.
+func (a *riskAnalyzer) checkWrite(target string) {
+	if a.cwd == "" || target == "" || harmlessWriteTargets[target] || strings.HasPrefix(target, "/dev/fd/") {
+		return
+	}
+
+	path := target
+	switch {
+	case path == "~" || strings.HasPrefix(path, "~/"):
+		if a.home == "" {
+			return
+		}
+		path = filepath.Join(a.home, strings.TrimPrefix(path, "~"))
+	case strings.ContainsAny(path, "$`*?["):
+		// an expansion, the path cannot be known
+		return
+	case !filepath.IsAbs(path):
+		path = filepath.Join(a.cwd, path)
+	}
+
+	path = filepath.Clean(path)
+	for _, dir := range []string{a.cwd, os.TempDir()} {
+		if isWithin(path, filepath.Clean(dir)) {
+			return
+		}
+	}
+
+	a.add(MediumRisk, "", fmt.Sprintf("writes outside of the current directory, to %s", target))
+}
+
+// apply adds the risk of rule when cmd matches it.
+func (a *riskAnalyzer) apply(cmd string, rule RiskRule) {
+	if rule.Validate() != nil {
+		return
+	}
+	if rule.Program != "" && !a.programs[rule.Program] {
+		return
+	}
+	if rule.Pattern != "" && !regexp.MustCompile(rule.Pattern).MatchString(cmd) {
+		return
+	}
+
+	level, _ := ParseRiskLevel(rule.Level)
+	if level == NoRisk {
+		return
+	}
+	reason := rule.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("matches the rule %s", strings.TrimSpace(rule.Program+" "+rule.Pattern))
+	}
+
+	a.add(level, rule.Program, reason)
+}
+
+// wordText returns the value of word when it is made of literals and quoted
+// literals, else its source, such as "$HOME/bin".
+func wordText(word *syntax.Word) string {
+	var text strings.Builder
+	for _, part := range word.Parts {
+		switch part := part.(type) {
+		case *syntax.Lit:
+			text.WriteString(part.Value)
+		case *syntax.SglQuoted:
+			text.WriteString(part.Value)
+		case *syntax.DblQuoted:
+			if len(part.Parts) > 1 {
+				return printWord(word)
+			}
+			if len(part.Parts) == 1 {
+				lit, ok := part.Parts[0].(*syntax.Lit)
+				if !ok {
+					return printWord(word)
+				}
+				text.WriteString(lit.Value)
+			}
+		default:
+			return printWord(word)
+		}
+	}
+
+	return text.String()
+}
+
+func printWord(word *syntax.Word) string {
+	var source bytes.Buffer
+	syntax.NewPrinter().Print(&source, word)
+	return source.String()
+}
+
+// literalWords returns the words of call, empty for those that are not plain
+// literals.
+func literalWords(call *syntax.CallExpr) []string {
+	words := make([]string, 0, len(call.Args))
+	for _, arg := range call.Args {
+		words = append(words, arg.Lit())
+	}
+
+	return words
+}
+
+// operands returns the arguments that are not flags.
+func operands(args []string) []string {
+	var operands []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			operands = append(operands, arg)
+		}
+	}
+
+	return operands
+}
+
+// hasFlag reports whether args hold one of flags, either long ones such as
+// "--recursive" or short ones, alone or grouped such as "-rf".
+func hasFlag(args []string, flags ...string) bool {
+	for _, arg := range args {
+		for _, flag := range flags {
+			switch {
+			case strings.HasPrefix(flag, "--") && arg == flag:
+				return true
+			case !strings.HasPrefix(flag, "--") && strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") &&
+				strings.Contains(arg[1:], flag):
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isCriticalPath reports whether target is one of the critical paths, quoted
+// or not, such as "$HOME", ${HOME}/ or /etc/*.
+func isCriticalPath(target string) bool {
+	target = strings.NewReplacer(`"`, "", `'`, "", "${HOME}", "$HOME").Replace(target)
+	return target != "" && criticalPaths[strings.TrimSuffix(strings.TrimSuffix(target, "*"), "/")]
+}
+
+func isWithin(path string, dir string) bool {
+	relative, err := filepath.Rel(dir, path)
+	return err == nil && relative != ".." && !strings.HasPrefix(relative, "../")
+}
@@ -0,0 +1,113 @@
+package run
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssessRisk(t *testing.T) {
+	cwd := "/home/user/project"
+
+	testCases := []struct {
+		name         string
+		cmd          string
+		level        RiskLevel
+		confirmation string
+	}{
+		{"Listing", "ls -la", NoRisk, ""},
+		{"LocalWrite", "echo hi > notes.txt", NoRisk, ""},
+		{"NullWrite", "make 2> /dev/null", NoRisk, ""},
+		{"Delete", "rm notes.txt", LowRisk, ""},
+		{"RecursiveDelete", "rm -rf build", HighRisk, "rm"},
+		{"RecursiveDeleteHome", "rm -rf ~", CriticalRisk, "yes"},
+		{"RecursiveDeleteQuotedHome", `rm -rf "$HOME"`, CriticalRisk, "yes"},
+		{"RecursiveDeleteQuotedBracedHome", `rm -rf "${HOME}"/*`, CriticalRisk, "yes"},
+		{"RecursiveDeleteRoot", "sudo rm -r --force /*", CriticalRisk, "yes"},
+		{"RecursiveDeleteQuotedRoot", `rm -rf "/"`, CriticalRisk, "yes"},
+		{"BlockDevice", "dd if=ubuntu.iso of=/dev/sdb bs=4M", CriticalRisk, "yes"},
+		{"BlockDeviceRedirect", "cat image.img > /dev/nvme0n1", CriticalRisk, "yes"},
+		{"Format", "mkfs.ext4 /dev/sdb1", CriticalRisk, "yes"},
+		{"RecursiveChmodRoot", "chmod -R 777 /", CriticalRisk, "yes"},
+		{"RecursiveChown", "chown -R user:user dist", MediumRisk, ""},
+		{"PipedScript", "curl -fsSL https://example.com/install.sh | sh", HighRisk, "sh"},
+		{"PipedScriptAsRoot", "wget -qO- https://example.com/install.sh | sudo bash", HighRisk, "bash"},
+		{"SubstitutedScript", `bash -c "$(curl -fsSL https://example.com/install.sh)"`, HighRisk, "bash"},
+		{"Sudo", "sudo apt update", MediumRisk, ""},
+		{"SudoUser", "sudo -u www-data rm -rf /", CriticalRisk, "yes"},
+		{"SudoOptions", "sudo -g adm -D /tmp -- rm -rf /", CriticalRisk, "yes"},
+		{"DoasUser", "doas -u root mkfs.ext4 /dev/sda1", CriticalRisk, "yes"},
+		{"EnvUnset", "env -u FOO -C /tmp rm -rf /", CriticalRisk, "yes"},
+		{"Nice", "nice -n 10 rm -rf /", CriticalRisk, "yes"},
+		{"Stdbuf", "stdbuf -o L rm -rf /", CriticalRisk, "yes"},
+		{"Time", "/usr/bin/time -f %e rm -rf /", CriticalRisk, "yes"},
+		{"PkexecUser", "pkexec --user root mkfs.ext4 /dev/sda1", CriticalRisk, "yes"},
+		{"EndOfOptions", "nice -- rm -rf /", CriticalRisk, "yes"},
+		{"ForcePush", "git push --force origin main", HighRisk, "git"},
+		{"ForcePushRefspec", "git push origin +main", HighRisk, "git"},
+		{"ForcePushWithLease", "git push --force-with-lease", MediumRisk, ""},
+		{"Push", "git push origin main", NoRisk, ""},
+		{"OutsideWrite", "echo 'export PATH' >> ~/.bashrc", MediumRisk, ""},
+		{"OutsideCopy", "cp app.conf /etc/app/", MediumRisk, ""},
+		{"ParentWrite", "touch ../notes.txt", MediumRisk, ""},
+		{"TemporaryWrite", "cp app.conf " + os.TempDir() + "/app.conf", NoRisk, ""},
+		{"Unparsable", "echo 'broken", MediumRisk, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("HOME", "/home/user")
+
+			assessment := AssessRisk(tc.cmd, cwd, nil)
+
+			assert.Equal(t, tc.level, assessment.GetLevel(), "The risk levels should be the same.")
+			assert.Equal(t, tc.confirmation, assessment.GetConfirmation(), "The confirmations should be the same.")
+		})
+	}
+}
+
+func TestAssessRiskRules(t *testing.T) {
+	t.Run("Program", testAssessRiskRulesProgram)
+	t.Run("Pattern", testAssessRiskRulesPattern)
+	t.Run("Validate", testRiskRuleValidate)
+}
+
+func testAssessRiskRulesProgram(t *testing.T) {
+	rules := []RiskRule{{Program: "kubectl", Pattern: `\bdelete\b`, Level: "high", Reason: "deletes cluster resources"}}
+
+	assessment := AssessRisk("kubectl delete ns staging", "/tmp", rules)
+	require.Len(t, assessment.GetRisks(), 1)
+
+	assert.Equal(t, HighRisk, assessment.GetLevel(), "The rule should raise the risk.")
+	assert.Equal(t, "deletes cluster resources", assessment.GetRisks()[0].GetReason(), "The reason of the rule should be kept.")
+	assert.Equal(t, "kubectl", assessment.GetConfirmation(), "The program of the rule should be typed.")
+
+	assessment = AssessRisk("kubectl get ns", "/tmp", rules)
+	assert.Equal(t, NoRisk, assessment.GetLevel(), "The rule should not match.")
+}
+
+func testAssessRiskRulesPattern(t *testing.T) {
+	rules := []RiskRule{{Pattern: `(?i)drop\s+table`, Level: "critical"}}
+
+	assessment := AssessRisk(`psql -c "DROP TABLE users"`, "/tmp", rules)
+
+	assert.Equal(t, CriticalRisk, assessment.GetLevel(), "The rule should raise the risk.")
+	assert.Equal(t, "yes", assessment.GetConfirmation(), "A critical risk should require yes.")
+}
+
+func testRiskRuleValidate(t *testing.T) {
+	assert.NoError(t, RiskRule{Program: "terraform", Level: "medium"}.Validate())
+	assert.Error(t, RiskRule{Level: "medium"}.Validate(), "A rule should match something.")
+	assert.Error(t, RiskRule{Program: "terraform", Level: "severe"}.Validate(), "The level should be known.")
+	assert.Error(t, RiskRule{Pattern: "(", Level: "low"}.Validate(), "The pattern should compile.")
+}
+
+func TestParseRiskLevel(t *testing.T) {
+	level, err := ParseRiskLevel(" High ")
+
+	require.NoError(t, err)
+	assert.Equal(t, HighRisk, level, "The risk levels should be the same.")
+	assert.Equal(t, "high", level.String(), "The risk level names should be the same.")
+}
@@ -58,16 +58,16 @@ func RunCommand(name string, args ...string) (string, error) {
     return string(out), err
 }
 
-// PrepareInteractiveCommand wraps cmdStr in a bash -c invocation that prints
+// PrepareInteractiveCommand wraps cmdStr in an invocation of shell that prints
 // a leading and trailing newline around the command output.
-func PrepareInteractiveCommand(cmdStr string) *exec.Cmd {
+func PrepareInteractiveCommand(cmdStr string, shell Shell) *exec.Cmd {
     fullCmd := fmt.Sprintf("echo \"\n\";%s; echo \"\n\";", cmdStr)
-    return exec.Command("bash", "-c", fullCmd)
+    return shell.Command(fullCmd)
 }
 
-// PrepareEditSettingsCommand wraps cmdStr in a bash -c invocation that prints
-// a trailing newline after the command completes.
-func PrepareEditSettingsCommand(cmdStr string) *exec.Cmd {
+// PrepareEditSettingsCommand wraps cmdStr in an invocation of shell that
+// prints a trailing newline after the command completes.
+func PrepareEditSettingsCommand(cmdStr string, shell Shell) *exec.Cmd {
     fullCmd := fmt.Sprintf("%s; echo \"\n\";", cmdStr)
-    return exec.Command("bash", "-c", fullCmd)
+    return shell.Command(fullCmd)
 }
@@ -58,6 +58,7 @@ import (
 func TestRun(t *testing.T) {
     t.Run("RunCommand", testRunCommand)
     t.Run("PrepareInteractiveCommand", testPrepareInteractiveCommand)
+    t.Run("PrepareInteractiveCommandWithShell", testPrepareInteractiveCommandWithShell)
     t.Run("PrepareEditSettingsCommand", testPrepareEditSettingsCommand)
 }
 
@@ -69,7 +70,7 @@ func testRunCommand(t *testing.T) {
 }
 
 func testPrepareInteractiveCommand(t *testing.T) {
-    cmd := PrepareInteractiveCommand("echo 'Hello, World!'")
+    cmd := PrepareInteractiveCommand("echo 'Hello, World!'", NewShell("bash", false))
 
     expectedCmd := exec.Command(
         "bash",
@@ -80,8 +81,21 @@ func testPrepareInteractiveCommand(t *testing.T) {
     assert.Equal(t, expectedCmd.Args, cmd.Args, "The command arguments should be the same.")
 }
 
+func testPrepareInteractiveCommandWithShell(t *testing.T) {
+    cmd := PrepareInteractiveCommand("ls", NewShell("fish", true))
+
+    expectedCmd := exec.Command(
+        "fish",
+        "-i",
+        "-c",
+        "echo \"\n\";ls; echo \"\n\";",
+    )
+
+    assert.Equal(t, expectedCmd.Args, cmd.Args, "The command arguments should be the same.")
+}
+
 func testPrepareEditSettingsCommand(t *testing.T) {
-    cmd := PrepareEditSettingsCommand("nano yo.json")
+    cmd := PrepareEditSettingsCommand("nano yo.json", NewShell("bash", false))
 
     expectedCmd := exec.Command(
         "bash",
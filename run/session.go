@@ -0,0 +1,299 @@
+package run
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// session_state_variable names the file a command writes the state it
+// leaves to.
+const session_state_variable = "XANG_STATE"
+
+// sessionManagedVariables are set by the shells themselves, and not carried
+// from a command to the next.
+var sessionManagedVariables = map[string]bool{
+	"_": true, "SHLVL": true, "PWD": true, "OLDPWD": true, "COLUMNS": true, "LINES": true,
+	session_state_variable: true,
+}
+
+var (
+	sessionVariablePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	sessionLinePattern     = regexp.MustCompile(`^[^=\s]+=`)
+)
+
+// SessionChange is what a command changed of the state of the session.
+type SessionChange struct {
+	directory string
+	set       []string
+	unset     []string
+}
+
+// GetDirectory returns the working directory the command moved to, if it
+// changed.
+func (c SessionChange) GetDirectory() string {
+	return c.directory
+}
+
+// GetSet returns the environment variables the command set or changed.
+func (c SessionChange) GetSet() []string {
+	return c.set
+}
+
+// GetUnset returns the environment variables the command removed.
+func (c SessionChange) GetUnset() []string {
+	return c.unset
+}
+
+func (c SessionChange) IsEmpty() bool {
+	return c.directory == "" && len(c.set) == 0 && len(c.unset) == 0
+}
+
+// String describes the change, such as "working directory is now /src, set
+// VIRTUAL_ENV, PATH".
+func (c SessionChange) String() string {
+	var parts []string
+	if c.directory != "" {
+		parts = append(parts, fmt.Sprintf("working directory is now %s", c.directory))
+	}
+	if len(c.set) > 0 {
+		parts = append(parts, fmt.Sprintf("set %s", strings.Join(c.set, ", ")))
+	}
+	if len(c.unset) > 0 {
+		parts = append(parts, fmt.Sprintf("unset %s", strings.Join(c.unset, ", ")))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// Session carries the working directory and the exported environment a
+// command leaves to the next ones, as if they all ran in a single shell: a
+// cd, an export or the activation of a virtual environment holds for the
+// commands that follow. The state is kept apart from the one of the xang
+// process, and given to the commands, previews and jobs it starts.
+type Session struct {
+	mu        sync.Mutex
+	start     string
+	startEnv  map[string]string
+	directory string
+	env       map[string]string
+}
+
+// NewSession returns a session starting from the current working directory
+// and environment.
+func NewSession() *Session {
+	directory, _ := os.Getwd()
+
+	return &Session{
+		start:     directory,
+		startEnv:  currentEnvironment(),
+		directory: directory,
+		env:       currentEnvironment(),
+	}
+}
+
+// GetDirectory returns the working directory of the session.
+func (s *Session) GetDirectory() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.directory
+}
+
+// Getenv returns the value of the environment variable name in the session,
+// empty when it is not set.
+func (s *Session) Getenv(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.env[name]
+}
+
+// GetEnvironment returns the environment of the session, as name=value
+// entries sorted by name.
+func (s *Session) GetEnvironment() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	env := make([]string, 0, len(s.env))
+	for name, value := range s.env {
+		env = append(env, name+"="+value)
+	}
+	sort.Strings(env)
+
+	return env
+}
+
+// GetChanges returns what changed since the session started.
+func (s *Session) GetChanges() SessionChange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var change SessionChange
+	if s.directory != s.start {
+		change.directory = s.directory
+	}
+	change.set, change.unset = diffEnvironments(s.startEnv, s.env)
+
+	return change
+}
+
+// prepare makes cmd start from the working directory and the environment of
+// the session.
+func (s *Session) prepare(cmd *exec.Cmd) {
+	cmd.Dir = s.GetDirectory()
+	cmd.Env = append(s.GetEnvironment(), "PWD="+cmd.Dir)
+}
+
+// wrap returns cmd made to write the working directory and the environment
+// it leaves to the file named by XANG_STATE, whichever way it exits. Each
+// command runs in a shell of its own, so only the working directory and the
+// exported variables are carried: functions, aliases, shell options and
+// unexported variables are lost. POSIX shells have a single EXIT trap, so
+// trap is aliased for the one of cmd to run before the state is written
+// instead of replacing it; fish runs every fish_exit handler.
+func (s *Session) wrap(cmd string, dialect Dialect) string {
+	if dialect == FishDialect {
+		return fmt.Sprintf(`function __xang_state --on-event fish_exit
+    begin; pwd; if env -0 >/dev/null 2>&1; echo 0; env -0; else; echo n; env; end; end > $%[1]s 2>/dev/null
+end
+%[2]s`, session_state_variable, cmd)
+	}
+
+	// zsh only runs external programs with command
+	builtin, aliases := "command", ""
+	switch dialect {
+	case BashDialect:
+		builtin, aliases = "builtin", "shopt -s expand_aliases\n"
+	case ZshDialect:
+		builtin = "builtin"
+	}
+
+	return fmt.Sprintf(`xang_exit_trap=
+%[2]s trap 'xang_status=$?; (exit $xang_status); eval "$xang_exit_trap"; { pwd; if env -0 >/dev/null 2>&1; then echo 0; env -0; else echo n; env; fi; } > "$%[1]s" 2>/dev/null; exit $xang_status' EXIT
+xang_trap() {
+  [ "$1" = -- ] && shift
+  if [ "$#" -lt 2 ]; then %[2]s trap "$@"; return; fi
+  xang_action=$1; shift
+  for xang_signal in "$@"; do
+    case $xang_signal in
+      0|EXIT|exit|SIGEXIT) if [ "$xang_action" = - ]; then xang_exit_trap=; else xang_exit_trap=$xang_action; fi ;;
+      *) %[2]s trap -- "$xang_action" "$xang_signal" ;;
+    esac
+  done
+}
+%[3]salias trap=xang_trap
+%[4]s`, session_state_variable, builtin, aliases, cmd)
+}
+
+// apply moves the session to the state written to path by a wrapped
+// command, returning what changed. A command that did not write its state,
+// such as one that was killed, changes nothing.
+func (s *Session) apply(path string) (SessionChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SessionChange{}, err
+	}
+	directory, env, ok := parseSessionState(data)
+	if !ok {
+		return SessionChange{}, nil
+	}
+
+	var change SessionChange
+	if directory != "" && directory != s.directory {
+		s.directory = directory
+		change.directory = directory
+	}
+
+	change.set, change.unset = diffEnvironments(s.env, env)
+	for _, name := range change.set {
+		s.env[name] = env[name]
+	}
+	for _, name := range change.unset {
+		delete(s.env, name)
+	}
+
+	return change, nil
+}
+
+// parseSessionState reads the working directory and the environment written
+// by a wrapped command: the directory on the first line, then 0 and the
+// variables separated by NUL, or n and one variable per line, the lines that
+// do not start a variable continuing the previous one.
+func parseSessionState(data []byte) (string, map[string]string, bool) {
+	directory, rest, ok := bytes.Cut(data, []byte("\n"))
+	if !ok {
+		return "", nil, false
+	}
+	format, rest, ok := bytes.Cut(rest, []byte("\n"))
+	if !ok {
+		return "", nil, false
+	}
+
+	var entries []string
+	if string(format) == "0" {
+		for _, entry := range bytes.Split(rest, []byte{0}) {
+			if len(entry) > 0 {
+				entries = append(entries, string(entry))
+			}
+		}
+	} else {
+		for _, line := range strings.Split(strings.TrimSuffix(string(rest), "\n"), "\n") {
+			if sessionLinePattern.MatchString(line) || len(entries) == 0 {
+				entries = append(entries, line)
+			} else {
+				entries[len(entries)-1] += "\n" + line
+			}
+		}
+	}
+
+	env := make(map[string]string)
+	for _, entry := range entries {
+		if name, value, ok := strings.Cut(entry, "="); ok && sessionVariablePattern.MatchString(name) {
+			env[name] = value
+		}
+	}
+
+	return string(directory), env, true
+}
+
+// currentEnvironment returns the environment of the xang process.
+func currentEnvironment() map[string]string {
+	env := make(map[string]string)
+	for _, entry := range os.Environ() {
+		if name, value, ok := strings.Cut(entry, "="); ok {
+			env[name] = value
+		}
+	}
+
+	return env
+}
+
+// diffEnvironments returns the variables set or changed, and the ones
+// removed, going from before to after, sorted. The variables the shells
+// manage and the ones they can't export are left out.
+func diffEnvironments(before map[string]string, after map[string]string) ([]string, []string) {
+	var set, unset []string
+	for name, value := range after {
+		if previous, ok := before[name]; (!ok || previous != value) && !sessionManagedVariables[name] {
+			set = append(set, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok && !sessionManagedVariables[name] && sessionVariablePattern.MatchString(name) {
+			unset = append(unset, name)
+		}
+	}
+	sort.Strings(set)
+	sort.Strings(unset)
+
+	return set, unset
+}
@@ -0,0 +1,122 @@
+//go:build unix
+
+package run
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession(t *testing.T) {
+	t.Run("Carry", testSessionCarry)
+	t.Run("ExitCode", testSessionExitCode)
+	t.Run("Unset", testSessionUnset)
+	t.Run("ExitTrap", testSessionExitTrap)
+	t.Run("ParseState", testSessionParseState)
+}
+
+// newTestSession returns a session starting in a temporary directory, the
+// working directory of the test being restored once it is over.
+func newTestSession(t *testing.T) (*Session, string) {
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.Chdir(cwd)
+	})
+
+	directory, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, os.Mkdir(filepath.Join(directory, "build"), 0755))
+	require.NoError(t, os.Chdir(directory))
+
+	return NewSession(), directory
+}
+
+func runTestSession(t *testing.T, session *Session, cmd string) (*Execution, error) {
+	var stdout, stderr bytes.Buffer
+	execution := newTestExecution(cmd, &stdout, &stderr)
+	execution.SetSession(session)
+	return execution, execution.Run()
+}
+
+func testSessionCarry(t *testing.T) {
+	session, directory := newTestSession(t)
+
+	execution, err := runTestSession(t, session, "cd build && export XANG_TEST_VENV=venv")
+	require.NoError(t, err)
+
+	change := execution.GetSessionChange()
+	assert.Equal(t, filepath.Join(directory, "build"), change.GetDirectory(), "The new working directory should be reported.")
+	assert.Equal(t, []string{"XANG_TEST_VENV"}, change.GetSet(), "The exported variable should be reported.")
+	assert.Equal(t, "working directory is now "+filepath.Join(directory, "build")+", set XANG_TEST_VENV", change.String())
+
+	execution, err = runTestSession(t, session, `echo "$(pwd) $XANG_TEST_VENV"`)
+	require.NoError(t, err)
+	assert.Contains(t, execution.GetStdout(), filepath.Join(directory, "build")+" venv", "The next command should start from the state left.")
+	assert.True(t, execution.GetSessionChange().IsEmpty(), "A command changing nothing should report no change.")
+	assert.Equal(t, []string{"XANG_TEST_VENV"}, session.GetChanges().GetSet(), "The session should know what changed since it started.")
+	assert.Equal(t, "venv", session.Getenv("XANG_TEST_VENV"), "The session should keep the exported variable.")
+
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, directory, cwd, "The working directory of xang should be left.")
+	_, ok := os.LookupEnv("XANG_TEST_VENV")
+	assert.False(t, ok, "The environment of xang should be left.")
+}
+
+func testSessionExitCode(t *testing.T) {
+	session, directory := newTestSession(t)
+
+	execution, err := runTestSession(t, session, "cd build; exit 4")
+
+	assert.Error(t, err, "A non-zero status should be an error.")
+	assert.Equal(t, 4, execution.GetExitCode(), "The exit status should be kept.")
+	assert.Equal(t, filepath.Join(directory, "build"), session.GetDirectory(), "The state should be kept when the command exits.")
+}
+
+func testSessionUnset(t *testing.T) {
+	t.Setenv("XANG_TEST_UNSET", "1")
+	session, _ := newTestSession(t)
+
+	execution, err := runTestSession(t, session, "unset XANG_TEST_UNSET")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"XANG_TEST_UNSET"}, execution.GetSessionChange().GetUnset())
+	assert.NotContains(t, session.GetEnvironment(), "XANG_TEST_UNSET=1", "The variable should be removed from the session.")
+	assert.Equal(t, "1", os.Getenv("XANG_TEST_UNSET"), "The environment of xang should be left.")
+
+	execution, err = runTestSession(t, session, `echo "${XANG_TEST_UNSET-unset}"`)
+	require.NoError(t, err)
+	assert.Contains(t, execution.GetStdout(), "unset", "The next command should not see the variable.")
+}
+
+func testSessionExitTrap(t *testing.T) {
+	session, directory := newTestSession(t)
+
+	execution, err := runTestSession(t, session, `trap 'echo "cleaning up $?"' EXIT; cd build; exit 3`)
+
+	assert.Error(t, err)
+	assert.Equal(t, 3, execution.GetExitCode(), "The exit status should be kept.")
+	assert.Contains(t, execution.GetStdout(), "cleaning up 3", "The trap of the command should run, with its exit status.")
+	assert.Equal(t, filepath.Join(directory, "build"), session.GetDirectory(), "The trap of the command should not drop the state.")
+}
+
+func testSessionParseState(t *testing.T) {
+	directory, env, ok := parseSessionState([]byte("/src\n0\nA=1\x00B=two\nlines\x00"))
+	require.True(t, ok)
+	assert.Equal(t, "/src", directory)
+	assert.Equal(t, map[string]string{"A": "1", "B": "two\nlines"}, env)
+
+	directory, env, ok = parseSessionState([]byte("/src\nn\nA=1\nB=two\nlines\nBASH_FUNC_f%%=() {  true\n}\n"))
+	require.True(t, ok)
+	assert.Equal(t, "/src", directory)
+	assert.Equal(t, map[string]string{"A": "1", "B": "two\nlines"}, env, "Lines not starting a variable should continue the previous one.")
+
+	_, _, ok = parseSessionState(nil)
+	assert.False(t, ok, "An empty state should be ignored.")
+}
@@ -0,0 +1,70 @@
+package run
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// fallbackShells are tried in order when neither the configured nor the
+// detected shell can be found.
+var fallbackShells = []string{"bash", "sh"}
+
+// Shell is the shell generated commands are executed with.
+type Shell struct {
+	name        string
+	dialect     Dialect
+	interactive bool
+}
+
+func NewShell(name string, interactive bool) Shell {
+	return Shell{
+		name:        name,
+		dialect:     GetDialectFromString(name),
+		interactive: interactive,
+	}
+}
+
+// ResolveShell returns the configured override when it is set, else the
+// detected shell, falling back to bash and then sh when the candidates are not
+// installed.
+func ResolveShell(detected string, override string, interactive bool) Shell {
+	candidates := append([]string{override, detected}, fallbackShells...)
+	for _, candidate := range candidates {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "" {
+			continue
+		}
+		if _, err := exec.LookPath(candidate); err == nil {
+			return NewShell(candidate, interactive)
+		}
+	}
+
+	return NewShell(fallbackShells[len(fallbackShells)-1], interactive)
+}
+
+func (s Shell) GetName() string {
+	return s.name
+}
+
+func (s Shell) GetDialect() Dialect {
+	return s.dialect
+}
+
+func (s Shell) IsInteractive() bool {
+	return s.interactive
+}
+
+// Args returns the flags that make the shell run cmd, so that user aliases
+// and functions are loaded when the shell is interactive.
+func (s Shell) Args(cmd string) []string {
+	if s.interactive {
+		return []string{"-i", "-c", cmd}
+	}
+
+	return []string{"-c", cmd}
+}
+
+// Command returns the command running cmd in the shell.
+func (s Shell) Command(cmd string) *exec.Cmd {
+	return exec.Command(s.name, s.Args(cmd)...)
+}
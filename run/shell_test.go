@@ -0,0 +1,46 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShell(t *testing.T) {
+	t.Run("NewShell", testNewShell)
+	t.Run("Args", testShellArgs)
+	t.Run("ResolveShell", testResolveShell)
+}
+
+func testNewShell(t *testing.T) {
+	shell := NewShell("/usr/bin/zsh", true)
+
+	assert.Equal(t, "/usr/bin/zsh", shell.GetName(), "The shell name should be the same.")
+	assert.Equal(t, ZshDialect, shell.GetDialect(), "The shell dialect should be detected from its name.")
+	assert.True(t, shell.IsInteractive(), "The shell should be interactive.")
+}
+
+func testShellArgs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		shell    Shell
+		expected []string
+	}{
+		{"Bash", NewShell("bash", false), []string{"-c", "ls"}},
+		{"Fish", NewShell("fish", false), []string{"-c", "ls"}},
+		{"InteractiveZsh", NewShell("zsh", true), []string{"-i", "-c", "ls"}},
+		{"InteractiveSh", NewShell("sh", true), []string{"-i", "-c", "ls"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.shell.Args("ls"), "The shell arguments should match the expected value.")
+		})
+	}
+}
+
+func testResolveShell(t *testing.T) {
+	assert.Equal(t, "sh", ResolveShell("bash", "sh", false).GetName(), "The override should win over the detected shell.")
+	assert.Equal(t, "sh", ResolveShell("sh", "", false).GetName(), "The detected shell should be used without override.")
+	assert.NotEqual(t, "xang-missing-shell", ResolveShell("xang-missing-shell", "", false).GetName(), "Missing shells should fall back.")
+}
@@ -0,0 +1,73 @@
+package run
+
+import (
+	"fmt"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// ValidateSyntax returns an error when cmd is not valid in dialect: it does
+// not parse, or it spans several lines outside of quotes and heredocs. Fish
+// commands are not checked, and zsh ones, parsed as bash, only fail on input
+// missing its end, such as an unclosed quote.
+func ValidateSyntax(cmd string, dialect Dialect) error {
+	file, ok, err := parseDialect(cmd, dialect)
+	if !ok {
+		return nil
+	}
+	if err != nil {
+		if dialect == ZshDialect && !syntax.IsIncomplete(err) {
+			return nil
+		}
+		return err
+	}
+
+	if line, ok := findStrayNewline(cmd, file); ok {
+		return fmt.Errorf("%d:1: the command continues on a new line, it should be a single line", line)
+	}
+
+	return nil
+}
+
+// findStrayNewline returns the line following the first newline of cmd that
+// is not quoted nor escaped, and whether there is one. The newlines of a
+// command with heredocs are all allowed, the heredocs needing them.
+func findStrayNewline(cmd string, file *syntax.File) (uint, bool) {
+	var quoted [][2]uint
+	heredoc := false
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch n := node.(type) {
+		case *syntax.SglQuoted, *syntax.DblQuoted:
+			quoted = append(quoted, [2]uint{n.Pos().Offset(), n.End().Offset()})
+			return false
+		case *syntax.Redirect:
+			heredoc = heredoc || n.Hdoc != nil
+		}
+		return true
+	})
+	if heredoc {
+		return 0, false
+	}
+
+	trimmed := strings.TrimRight(cmd, " \t\n")
+	line := uint(1)
+	for offset := 0; offset < len(trimmed); offset++ {
+		if trimmed[offset] != '\n' {
+			continue
+		}
+		line++
+		if offset > 0 && trimmed[offset-1] == '\\' {
+			continue
+		}
+		inQuotes := false
+		for _, r := range quoted {
+			inQuotes = inQuotes || (uint(offset) >= r[0] && uint(offset) < r[1])
+		}
+		if !inQuotes {
+			return line, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,42 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSyntax(t *testing.T) {
+	testCases := []struct {
+		name    string
+		cmd     string
+		dialect Dialect
+		valid   bool
+	}{
+		{"Simple", "ls -la | grep go", BashDialect, true},
+		{"UnclosedQuote", "echo 'hello", BashDialect, false},
+		{"UnclosedDoubleQuote", `grep "foo bar`, ZshDialect, false},
+		{"UnclosedSubshell", "(cd /tmp && ls", PosixDialect, false},
+		{"StrayNewline", "cd /tmp\nls", BashDialect, false},
+		{"TrailingNewline", "ls\n", BashDialect, true},
+		{"QuotedNewline", "echo 'one\ntwo'", BashDialect, true},
+		{"EscapedNewline", "ls \\\n  -la", BashDialect, true},
+		{"Heredoc", "cat <<EOF > notes.txt\nhello\nEOF", BashDialect, true},
+		{"UnclosedHeredoc", "cat <<EOF > notes.txt\nhello", BashDialect, false},
+		{"BashOnlyInPosix", "diff <(ls a) <(ls b)", PosixDialect, false},
+		{"ZshOnly", `print -l ${(f)"$(ls)"}`, ZshDialect, true},
+		{"Fish", "for f in *.log; gzip $f; end", FishDialect, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateSyntax(tc.cmd, tc.dialect)
+
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
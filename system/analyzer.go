@@ -3,6 +3,7 @@ package system
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 
@@ -14,6 +15,7 @@ import (
 const APPLICATION_NAME = "Xang"
 
 type Analysis struct {
+	host            string
 	operatingSystem OperatingSystem
 	distribution    string
 	shell           string
@@ -21,12 +23,20 @@ type Analysis struct {
 	username        string
 	editor          string
 	configFile      string
+	dataDirectory   string
+	packageManager  PackageManager
 }
 
 func (a *Analysis) GetApplicationName() string {
 	return APPLICATION_NAME
 }
 
+// GetHost returns the host the analysis is the one of, empty for the local
+// system.
+func (a *Analysis) GetHost() string {
+	return a.host
+}
+
 func (a *Analysis) GetOperatingSystem() OperatingSystem {
 	return a.operatingSystem
 }
@@ -55,6 +65,14 @@ func (a *Analysis) GetConfigFile() string {
 	return a.configFile
 }
 
+func (a *Analysis) GetDataDirectory() string {
+	return a.dataDirectory
+}
+
+func (a *Analysis) GetPackageManager() PackageManager {
+	return a.packageManager
+}
+
 func Analyse() *Analysis {
 	return &Analysis{
 		operatingSystem: GetOperatingSystem(),
@@ -64,6 +82,8 @@ func Analyse() *Analysis {
 		username:        GetUsername(),
 		editor:          GetEditor(),
 		configFile:      GetConfigFile(),
+		dataDirectory:   GetDataDirectory(),
+		packageManager:  GetPackageManager(),
 	}
 }
 
@@ -134,3 +154,14 @@ func GetConfigFile() string {
 		strings.ToLower(APPLICATION_NAME),
 	)
 }
+
+// GetDataDirectory returns the directory xang keeps its data in, following the
+// XDG base directory specification.
+func GetDataDirectory() string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(GetHomeDirectory(), ".local", "share")
+	}
+
+	return filepath.Join(dataHome, strings.ToLower(APPLICATION_NAME))
+}
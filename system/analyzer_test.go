@@ -10,6 +10,7 @@ import (
 func TestSystem(t *testing.T) {
 	t.Run("GetOperatingSystem", testGetOperatingSystem)
 	t.Run("Analyse", testAnalyse)
+	t.Run("GetDataDirectory", testGetDataDirectory)
 }
 
 func testGetOperatingSystem(t *testing.T) {
@@ -26,4 +27,11 @@ func testAnalyse(t *testing.T) {
 	assert.NotEmpty(t, analysis.GetHomeDirectory(), "Home directory should not be empty.")
 	assert.NotEmpty(t, analysis.GetUsername(), "Username should not be empty.")
 	assert.NotEmpty(t, analysis.GetConfigFile(), "Config file should not be empty.")
+	assert.NotEmpty(t, analysis.GetDataDirectory(), "Data directory should not be empty.")
+}
+
+func testGetDataDirectory(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", "/tmp/xang-data")
+
+	assert.Equal(t, "/tmp/xang-data/xang", GetDataDirectory(), "The data directory should follow XDG_DATA_HOME.")
 }
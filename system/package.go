@@ -0,0 +1,129 @@
+package system
+
+import (
+	"os/exec"
+	"strings"
+)
+
+type PackageManager int
+
+const (
+	UnknownPackageManager PackageManager = iota
+	AptPackageManager
+	DnfPackageManager
+	PacmanPackageManager
+	ApkPackageManager
+	BrewPackageManager
+	NixPackageManager
+)
+
+func (m PackageManager) String() string {
+	switch m {
+	case AptPackageManager:
+		return "apt"
+	case DnfPackageManager:
+		return "dnf"
+	case PacmanPackageManager:
+		return "pacman"
+	case ApkPackageManager:
+		return "apk"
+	case BrewPackageManager:
+		return "brew"
+	case NixPackageManager:
+		return "nix"
+	default:
+		return "unknown"
+	}
+}
+
+// packageManagerBinaries are the binaries of the package managers, the ones
+// of distributions first, since brew and nix may come on top of them.
+var packageManagerBinaries = []struct {
+	binary  string
+	manager PackageManager
+}{
+	{"apt-get", AptPackageManager},
+	{"dnf", DnfPackageManager},
+	{"pacman", PacmanPackageManager},
+	{"apk", ApkPackageManager},
+	{"brew", BrewPackageManager},
+	{"nix", NixPackageManager},
+}
+
+// packageNames are the packages providing binaries, by package manager, when
+// they are not named after them. UnknownPackageManager is the name for the
+// others.
+var packageNames = map[string]map[PackageManager]string{
+	"rg":       {UnknownPackageManager: "ripgrep"},
+	"fd":       {UnknownPackageManager: "fd", AptPackageManager: "fd-find", DnfPackageManager: "fd-find"},
+	"ag":       {UnknownPackageManager: "the_silver_searcher", AptPackageManager: "silversearcher-ag"},
+	"http":     {UnknownPackageManager: "httpie"},
+	"convert":  {UnknownPackageManager: "imagemagick", DnfPackageManager: "ImageMagick"},
+	"dig":      {UnknownPackageManager: "bind", AptPackageManager: "dnsutils", DnfPackageManager: "bind-utils", ApkPackageManager: "bind-tools", NixPackageManager: "dnsutils"},
+	"nslookup": {UnknownPackageManager: "bind", AptPackageManager: "dnsutils", DnfPackageManager: "bind-utils", ApkPackageManager: "bind-tools", NixPackageManager: "dnsutils"},
+	"pip3":     {UnknownPackageManager: "python3-pip", PacmanPackageManager: "python-pip", ApkPackageManager: "py3-pip", BrewPackageManager: "python", NixPackageManager: "python3Packages.pip"},
+	"7z":       {UnknownPackageManager: "p7zip", AptPackageManager: "p7zip-full"},
+	"gh":       {UnknownPackageManager: "gh", PacmanPackageManager: "github-cli", ApkPackageManager: "github-cli"},
+	"btm":      {UnknownPackageManager: "bottom"},
+	"delta":    {UnknownPackageManager: "git-delta"},
+}
+
+// GetPackageManager returns the package manager of the system, the one of
+// the distribution when there are several.
+func GetPackageManager() PackageManager {
+	for _, candidate := range packageManagerBinaries {
+		if _, err := exec.LookPath(candidate.binary); err == nil {
+			return candidate.manager
+		}
+	}
+
+	return UnknownPackageManager
+}
+
+// GetPackage returns the package of the package manager providing binary.
+func (m PackageManager) GetPackage(binary string) string {
+	names, ok := packageNames[binary]
+	if !ok {
+		return binary
+	}
+	if name, ok := names[m]; ok {
+		return name
+	}
+
+	return names[UnknownPackageManager]
+}
+
+// InstallCommand returns the command installing the packages providing
+// binaries, to be run with the configured elevation tool unless the package
+// manager installs for the user. It returns an empty string when the package
+// manager is unknown.
+func (m PackageManager) InstallCommand(binaries []string) string {
+	packages := make([]string, 0, len(binaries))
+	for _, binary := range binaries {
+		name := m.GetPackage(binary)
+		if m == NixPackageManager {
+			name = "nixpkgs#" + name
+		}
+		packages = append(packages, name)
+	}
+
+	var install string
+	switch m {
+	case AptPackageManager:
+		install = "apt-get install"
+	case DnfPackageManager:
+		install = "dnf install"
+	case PacmanPackageManager:
+		install = "pacman -S"
+	case ApkPackageManager:
+		install = "apk add"
+	case BrewPackageManager:
+		return "brew install " + strings.Join(packages, " ")
+	case NixPackageManager:
+		return "nix profile install " + strings.Join(packages, " ")
+	default:
+		return ""
+	}
+
+	return install + " " + strings.Join(packages, " ")
+}
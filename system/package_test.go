@@ -0,0 +1,64 @@
+package system
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackageManager(t *testing.T) {
+	t.Run("String", testPackageManagerString)
+	t.Run("GetPackage", testPackageManagerGetPackage)
+	t.Run("InstallCommand", testPackageManagerInstallCommand)
+}
+
+func testPackageManagerString(t *testing.T) {
+	testCases := []struct {
+		name           string
+		packageManager PackageManager
+		expected       string
+	}{
+		{"Unknown", UnknownPackageManager, "unknown"},
+		{"Apt", AptPackageManager, "apt"},
+		{"Dnf", DnfPackageManager, "dnf"},
+		{"Pacman", PacmanPackageManager, "pacman"},
+		{"Apk", ApkPackageManager, "apk"},
+		{"Brew", BrewPackageManager, "brew"},
+		{"Nix", NixPackageManager, "nix"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.packageManager.String(), "The string representation should match the expected value.")
+		})
+	}
+}
+
+func testPackageManagerGetPackage(t *testing.T) {
+	assert.Equal(t, "jq", AptPackageManager.GetPackage("jq"), "A binary should be its own package by default.")
+	assert.Equal(t, "ripgrep", BrewPackageManager.GetPackage("rg"), "rg should come from ripgrep.")
+	assert.Equal(t, "fd-find", AptPackageManager.GetPackage("fd"), "fd should come from fd-find on Debian.")
+	assert.Equal(t, "fd", PacmanPackageManager.GetPackage("fd"), "fd should come from fd on Arch.")
+}
+
+func testPackageManagerInstallCommand(t *testing.T) {
+	testCases := []struct {
+		name           string
+		packageManager PackageManager
+		expected       string
+	}{
+		{"Apt", AptPackageManager, "apt-get install jq ripgrep"},
+		{"Dnf", DnfPackageManager, "dnf install jq ripgrep"},
+		{"Pacman", PacmanPackageManager, "pacman -S jq ripgrep"},
+		{"Apk", ApkPackageManager, "apk add jq ripgrep"},
+		{"Brew", BrewPackageManager, "brew install jq ripgrep"},
+		{"Nix", NixPackageManager, "nix profile install nixpkgs#jq nixpkgs#ripgrep"},
+		{"Unknown", UnknownPackageManager, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.packageManager.InstallCommand([]string{"jq", "rg"}))
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/Praatibh/xang/run"
+)
+
+// remote_probe prints what the analysis of a host needs, one line each: the
+// kernel, the distribution, the login shell, the home directory, the user,
+// the editor and the package manager. It is run by sh whatever the login
+// shell, and holds no single quote for it to be quoted as is.
+const remote_probe = `uname -s; (. /etc/os-release 2>/dev/null; echo "$PRETTY_NAME"); echo "$SHELL"; echo "$HOME"; id -un; echo "$EDITOR"; ` +
+	`for m in apt-get dnf pacman apk brew nix; do if command -v $m >/dev/null 2>&1; then break; fi; m=; done; echo "$m"`
+
+// AnalyseRemote returns the analysis of host, reached over ssh, for the
+// commands generated for it to fit its system. The configuration and data of
+// xang stay the local ones.
+func AnalyseRemote(host string) (*Analysis, error) {
+	output, err := run.RemoteCommand(host, "sh -c '"+remote_probe+"'", false).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			err = errors.New(strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("failed to analyse %s: %w", host, err)
+	}
+
+	return parseRemoteAnalysis(host, string(output)), nil
+}
+
+// parseRemoteAnalysis reads the output of remote_probe run on host.
+func parseRemoteAnalysis(host string, output string) *Analysis {
+	lines := strings.Split(output, "\n")
+	line := func(i int) string {
+		if i < len(lines) {
+			return strings.TrimSpace(lines[i])
+		}
+		return ""
+	}
+
+	analysis := &Analysis{
+		host:            host,
+		operatingSystem: UnknownOperatingSystem,
+		distribution:    line(1),
+		shell:           path.Base(line(2)),
+		homeDirectory:   line(3),
+		username:        line(4),
+		editor:          line(5),
+		configFile:      GetConfigFile(),
+		dataDirectory:   GetDataDirectory(),
+		packageManager:  UnknownPackageManager,
+	}
+	switch line(0) {
+	case "Linux":
+		analysis.operatingSystem = LinuxOperatingSystem
+	case "Darwin":
+		analysis.operatingSystem = MacOperatingSystem
+	}
+	if line(2) == "" {
+		analysis.shell = ""
+	}
+	for _, candidate := range packageManagerBinaries {
+		if candidate.binary == line(6) {
+			analysis.packageManager = candidate.manager
+		}
+	}
+
+	return analysis
+}
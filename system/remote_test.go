@@ -0,0 +1,46 @@
+package system
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteAnalysis(t *testing.T) {
+	t.Run("Parse", testRemoteAnalysisParse)
+	t.Run("Probe", testRemoteAnalysisProbe)
+}
+
+func testRemoteAnalysisParse(t *testing.T) {
+	analysis := parseRemoteAnalysis("web-01", "Linux\nDebian GNU/Linux 12 (bookworm)\n/usr/bin/zsh\n/home/deploy\ndeploy\n\napt-get\n")
+
+	assert.Equal(t, "web-01", analysis.GetHost())
+	assert.Equal(t, LinuxOperatingSystem, analysis.GetOperatingSystem())
+	assert.Equal(t, "Debian GNU/Linux 12 (bookworm)", analysis.GetDistribution())
+	assert.Equal(t, "zsh", analysis.GetShell(), "The shell should be named without its path.")
+	assert.Equal(t, "/home/deploy", analysis.GetHomeDirectory())
+	assert.Equal(t, "deploy", analysis.GetUsername())
+	assert.Empty(t, analysis.GetEditor())
+	assert.Equal(t, AptPackageManager, analysis.GetPackageManager())
+	assert.Equal(t, GetDataDirectory(), analysis.GetDataDirectory(), "The data of xang should stay local.")
+
+	analysis = parseRemoteAnalysis("box", "Darwin\n")
+	assert.Equal(t, MacOperatingSystem, analysis.GetOperatingSystem())
+	assert.Empty(t, analysis.GetShell(), "A missing shell should stay empty.")
+	assert.Equal(t, UnknownPackageManager, analysis.GetPackageManager())
+}
+
+func testRemoteAnalysisProbe(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+	assert.NotContains(t, remote_probe, "'", "The probe should be quoted as is.")
+
+	output, err := exec.Command("sh", "-c", remote_probe).Output()
+	require.NoError(t, err)
+
+	assert.Len(t, strings.Split(string(output), "\n"), 8, "The probe should print one line for each value.")
+}
@@ -0,0 +1,160 @@
+package ui
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Praatibh/xang/audit"
+	"github.com/Praatibh/xang/config"
+	"github.com/Praatibh/xang/run"
+	"github.com/Praatibh/xang/system"
+)
+
+// IsAudit reports whether args, without the name of the program, are the ones
+// of xang audit [flags]. A prompt may start with the word audit, the
+// subcommand is alone or followed by flags.
+func IsAudit(args []string) bool {
+	return len(args) >= 1 && args[0] == "audit" && (len(args) == 1 || strings.HasPrefix(args[1], "-"))
+}
+
+// NewAuditLog returns the audit log of the configuration, in
+// $XDG_DATA_HOME/xang/audit.jsonl unless another path is set.
+func NewAuditLog(auditConfig config.AuditConfig) *audit.Log {
+	path := auditConfig.GetPath()
+	if path == "" {
+		path = filepath.Join(system.GetDataDirectory(), "audit.jsonl")
+	}
+
+	return audit.NewLog(path, auditConfig.GetMaxSize(), auditConfig.GetMaxBackups())
+}
+
+// RunAudit writes the entries of the audit log selected by the flags of
+// xang audit. It returns the exit code of xang audit: 1 when the log can't be
+// read, 2 when the flags or the configuration can't be.
+func RunAudit(w io.Writer, args []string) int {
+	loaded, err := config.NewConfig()
+	if err != nil {
+		fmt.Fprintf(w, "error: %s\n", err)
+		return 2
+	}
+
+	return writeAudit(w, NewAuditLog(loaded.GetAuditConfig()), args, time.Now())
+}
+
+func writeAudit(w io.Writer, log *audit.Log, args []string, now time.Time) int {
+	flagSet := flag.NewFlagSet("xang audit", flag.ContinueOnError)
+	flagSet.SetOutput(w)
+
+	var since, until, text string
+	var statuses stringsFlag
+	var asJson bool
+	flagSet.StringVar(&since, "since", "", "entries from this date, or this long ago such as 24h")
+	flagSet.StringVar(&until, "until", "", "entries up to this date, or this long ago such as 1h")
+	flagSet.Var(&statuses, "status", "entries with this status, repeat or separate with commas")
+	flagSet.StringVar(&text, "text", "", "entries whose prompt, command or explanation contain this text")
+	flagSet.BoolVar(&asJson, "json", false, "write the entries as JSON lines")
+	if err := flagSet.Parse(args); err != nil {
+		return 2
+	}
+
+	filter := audit.Filter{Text: text}
+	var err error
+	if filter.Since, err = parseAuditTime(since, now); err != nil {
+		fmt.Fprintf(w, "error: invalid since: %s\n", err)
+		return 2
+	}
+	if filter.Until, err = parseAuditTime(until, now); err != nil {
+		fmt.Fprintf(w, "error: invalid until: %s\n", err)
+		return 2
+	}
+	for _, value := range statuses {
+		for _, name := range strings.Split(value, ",") {
+			status, err := audit.ParseStatus(name)
+			if err != nil {
+				fmt.Fprintf(w, "error: %s\n", err)
+				return 2
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+
+	entries, err := log.Query(filter)
+	if err != nil {
+		fmt.Fprintf(w, "error: %s\n", err)
+		return 1
+	}
+
+	encoder := json.NewEncoder(w)
+	for _, entry := range entries {
+		if asJson {
+			encoder.Encode(entry)
+			continue
+		}
+		fmt.Fprintln(w, describeAuditEntry(entry))
+	}
+
+	return 0
+}
+
+// parseAuditTime reads a date such as 2026-05-01, a time such as
+// 2026-05-01T10:00:00Z, or a duration such as 24h or 7d meaning that long
+// before now. An empty value is the zero time.
+func parseAuditTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+		if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return parsed, nil
+		}
+	}
+	if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil && strings.HasSuffix(value, "d") && days >= 0 {
+		return now.AddDate(0, 0, -days), nil
+	}
+	duration, err := run.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither a date nor a duration", value)
+	}
+
+	return now.Add(-duration), nil
+}
+
+// describeAuditEntry writes entry on a line, such as "2026-05-01 10:00:00
+// executed alice@web-01 /srv (exit 0, 1.2s): rm -rf build".
+func describeAuditEntry(entry audit.Entry) string {
+	var details []string
+	if entry.ExitCode != nil {
+		details = append(details, fmt.Sprintf("exit %d", *entry.ExitCode))
+	}
+	if entry.DurationMs > 0 {
+		details = append(details, (time.Duration(entry.DurationMs) * time.Millisecond).String())
+	}
+	if entry.Edited {
+		details = append(details, "edited")
+	}
+	if entry.Background {
+		details = append(details, "background")
+	}
+	if entry.Root {
+		details = append(details, "as root")
+	}
+	if len(entry.Target) > 0 {
+		details = append(details, "on "+strings.Join(entry.Target, ", "))
+	}
+	if entry.Reason != "" {
+		details = append(details, entry.Reason)
+	}
+
+	line := fmt.Sprintf("%s  %-9s  %s@%s  %s", entry.Time.Local().Format("2006-01-02 15:04:05"), entry.Status, entry.User, entry.Host, entry.Cwd)
+	if len(details) > 0 {
+		line += fmt.Sprintf(" (%s)", strings.Join(details, ", "))
+	}
+
+	return line + ": " + entry.Command
+}
@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Praatibh/xang/audit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsAudit(t *testing.T) {
+	assert.True(t, IsAudit([]string{"audit"}))
+	assert.True(t, IsAudit([]string{"audit", "--status", "cancelled"}))
+	assert.False(t, IsAudit([]string{"audit", "my", "nginx", "config"}))
+}
+
+func TestWriteAudit(t *testing.T) {
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.Local)
+	log := audit.NewLog(filepath.Join(t.TempDir(), "audit.jsonl"), 0, 0)
+	exitCode := 1
+	for _, entry := range []audit.Entry{
+		{Time: now.AddDate(0, 0, -3), Status: audit.CancelledStatus, Command: "rm -rf /srv/old", User: "alice", Host: "web-01", Cwd: "/srv"},
+		{Time: now.Add(-time.Hour), Status: audit.FailedStatus, Command: "make test", ExitCode: &exitCode, DurationMs: 1500, Edited: true, User: "alice", Host: "web-01", Cwd: "/src"},
+		{Time: now.Add(-time.Minute), Status: audit.ExecutedStatus, Command: "ls /srv", User: "alice", Host: "web-01", Cwd: "/srv"},
+	} {
+		require.NoError(t, log.Write(entry))
+	}
+
+	var output strings.Builder
+	code := writeAudit(&output, log, []string{"--since", "2d", "--status", "failed,cancelled"}, now)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, now.Add(-time.Hour).Format("2006-01-02 15:04:05")+"  failed     alice@web-01  /src (exit 1, 1.5s, edited): make test\n", output.String())
+
+	output.Reset()
+	code = writeAudit(&output, log, []string{"--text", "SRV", "--json"}, now)
+	assert.Equal(t, 0, code)
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	require.Len(t, lines, 2, "The text should be matched regardless of case.")
+	assert.Contains(t, lines[0], `"status":"cancelled"`)
+
+	output.Reset()
+	code = writeAudit(&output, log, []string{"--status", "lost"}, now)
+	assert.Equal(t, 2, code)
+	assert.Equal(t, "error: unknown audit status \"lost\"\n", output.String())
+}
+
+func TestParseAuditTime(t *testing.T) {
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.Local)
+
+	parsed, err := parseAuditTime("2026-05-01", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 5, 1, 0, 0, 0, 0, time.Local), parsed)
+
+	parsed, err = parseAuditTime("24h", now)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(-24*time.Hour), parsed)
+
+	_, err = parseAuditTime("yesterday", now)
+	assert.EqualError(t, err, `"yesterday" is neither a date nor a duration`)
+}
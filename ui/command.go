@@ -0,0 +1,45 @@
+package ui
+
+import "strings"
+
+// slashCommands are the names of the REPL commands.
+var slashCommands = map[string]bool{
+	"compare": true,
+	"usage":   true,
+	"jobs":    true,
+	"undo":    true,
+}
+
+// SlashCommand is a REPL command, such as /compare, typed instead of a prompt.
+type SlashCommand struct {
+	name string
+	args []string
+}
+
+// ParseSlashCommand parses input as a slash command, reporting whether it is
+// one. Only the REPL commands are: a lone slash, a path such as /etc/hosts or
+// a prompt such as "/tmp is full, clean it" is sent to the model.
+func ParseSlashCommand(input string) (SlashCommand, bool) {
+	fields := strings.Fields(input)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return SlashCommand{}, false
+	}
+
+	name := strings.ToLower(strings.TrimPrefix(fields[0], "/"))
+	if !slashCommands[name] {
+		return SlashCommand{}, false
+	}
+
+	return SlashCommand{
+		name: name,
+		args: fields[1:],
+	}, true
+}
+
+func (c SlashCommand) GetName() string {
+	return c.name
+}
+
+func (c SlashCommand) GetArgs() []string {
+	return c.args
+}
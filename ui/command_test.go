@@ -0,0 +1,37 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSlashCommand(t *testing.T) {
+	testCases := []struct {
+		input string
+		ok    bool
+		name  string
+		args  []string
+	}{
+		{"/compare gemini-2.5-flash gemini-2.5-pro", true, "compare", []string{"gemini-2.5-flash", "gemini-2.5-pro"}},
+		{"  /Compare  ", true, "compare", []string{}},
+		{"list files in /tmp", false, "", nil},
+		{"/jobs kill 2", true, "jobs", []string{"kill", "2"}},
+		{"/etc/hosts", false, "", nil},
+		{"/tmp is full, clean it", false, "", nil},
+		{"/", false, "", nil},
+		{"", false, "", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			command, ok := ParseSlashCommand(tc.input)
+
+			assert.Equal(t, tc.ok, ok)
+			if tc.ok {
+				assert.Equal(t, tc.name, command.GetName())
+				assert.Equal(t, tc.args, command.GetArgs())
+			}
+		})
+	}
+}
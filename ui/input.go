@@ -7,22 +7,57 @@ import (
 	"io"
 	"os"
 	"strings"
+
+	"github.com/Praatibh/xang/run"
 )
 
 type UiInput struct {
-	runMode    RunMode
-	promptMode PromptMode
-	args       string
-	pipe       string
+	runMode       RunMode
+	promptMode    PromptMode
+	args          string
+	pipe          string
+	sourceDialect run.Dialect
+	targetDialect run.Dialect
+	compareModels []string
+	target        string
+}
+
+// stringsFlag is a flag that can be repeated, collecting every value.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
 }
 
 func NewUIInput() (*UiInput, error) {
-	flagSet := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	// a prompt may start with the word compare, the subcommand is followed by -m
+	name, arguments := os.Args[0], os.Args[1:]
+	compare := len(arguments) > 1 && arguments[0] == "compare" && strings.HasPrefix(arguments[1], "-m")
+	if compare {
+		name, arguments = name+" compare", arguments[1:]
+	}
+
+	flagSet := flag.NewFlagSet(name, flag.ExitOnError)
 
 	var exec, chat bool
-	flagSet.BoolVar(&exec, "e", false, "exec prompt mode")
-	flagSet.BoolVar(&chat, "c", false, "chat prompt mode")
-	err := flagSet.Parse(os.Args[1:])
+	var source, target string
+	var models stringsFlag
+	var hosts string
+	flagSet.StringVar(&hosts, "target", "", "host, group of hosts or comma separated list of them to run commands on over ssh")
+	if compare {
+		flagSet.Var(&models, "m", "model to compare, repeat for each model")
+	} else {
+		flagSet.BoolVar(&exec, "e", false, "exec prompt mode")
+		flagSet.BoolVar(&chat, "c", false, "chat prompt mode")
+		flagSet.StringVar(&target, "t", "", "translate prompt mode, to the given dialect (bash, zsh, fish or sh)")
+		flagSet.StringVar(&source, "s", "", "dialect to translate from, detected when empty")
+	}
+	err := flagSet.Parse(arguments)
 	if err != nil {
 		fmt.Println("Error parsing flags:", err)
 		return nil, err
@@ -61,18 +96,30 @@ func NewUIInput() (*UiInput, error) {
 		runMode = CliMode
 	}
 
+	if compare && len(models) < 2 {
+		err := fmt.Errorf("compare needs at least two models, given with -m")
+		fmt.Println("Error parsing flags:", err)
+		return nil, err
+	}
+
 	promptMode := DefaultPromptMode
-	if exec && !chat {
+	if compare || exec && !chat {
 		promptMode = ExecPromptMode
 	} else if !exec && chat {
 		promptMode = ChatPromptMode
+	} else if !exec && !chat && target != "" {
+		promptMode = TranslatePromptMode
 	}
 
 	return &UiInput{
-		runMode:    runMode,
-		promptMode: promptMode,
-		args:       strings.Join(args, " "),
-		pipe:       pipe,
+		runMode:       runMode,
+		promptMode:    promptMode,
+		args:          strings.Join(args, " "),
+		pipe:          pipe,
+		sourceDialect: run.GetDialectFromString(source),
+		targetDialect: run.GetDialectFromString(target),
+		compareModels: models,
+		target:        hosts,
 	}, nil
 }
 
@@ -91,3 +138,23 @@ func (i *UiInput) GetArgs() string {
 func (i *UiInput) GetPipe() string {
 	return i.pipe
 }
+
+func (i *UiInput) GetSourceDialect() run.Dialect {
+	return i.sourceDialect
+}
+
+func (i *UiInput) GetTargetDialect() run.Dialect {
+	return i.targetDialect
+}
+
+// GetTarget returns the hosts or groups of hosts commands run on, empty for
+// the local system.
+func (i *UiInput) GetTarget() string {
+	return i.target
+}
+
+// GetCompareModels returns the models every exec prompt is sent to side by
+// side, if any.
+func (i *UiInput) GetCompareModels() []string {
+	return i.compareModels
+}
@@ -4,6 +4,8 @@ import (
 	"os"
 	"testing"
 
+	"github.com/Praatibh/xang/run"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -12,6 +14,8 @@ func TestUiInput(t *testing.T) {
 	t.Run("GetRunMode", testGetRunMode)
 	t.Run("GetPromptMode", testGetPromptMode)
 	t.Run("GetArgs", testGetArgs)
+	t.Run("GetTargetDialect", testGetTargetDialect)
+	t.Run("GetCompareModels", testGetCompareModels)
 }
 
 func testNewUIInput(t *testing.T) {
@@ -50,3 +54,31 @@ func testGetArgs(t *testing.T) {
 	uiInput, _ := NewUIInput()
 	assert.Equal(t, "arg1 arg2", uiInput.GetArgs(), "Args should be 'arg1 arg2'.")
 }
+
+func testGetTargetDialect(t *testing.T) {
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{"cmd", "-t", "fish", "-s", "bash", "export", "A=1"}
+	uiInput, _ := NewUIInput()
+	assert.Equal(t, TranslatePromptMode, uiInput.GetPromptMode(), "PromptMode should be TranslatePromptMode.")
+	assert.Equal(t, run.FishDialect, uiInput.GetTargetDialect(), "TargetDialect should be FishDialect.")
+	assert.Equal(t, run.BashDialect, uiInput.GetSourceDialect(), "SourceDialect should be BashDialect.")
+}
+
+func testGetCompareModels(t *testing.T) {
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{"cmd", "compare", "-m", "gemini-2.5-flash", "-m", "gemini-2.5-pro", "list", "files"}
+	uiInput, err := NewUIInput()
+	assert.NoError(t, err, "NewUIInput should not return an error.")
+	assert.Equal(t, []string{"gemini-2.5-flash", "gemini-2.5-pro"}, uiInput.GetCompareModels(), "CompareModels should be the given models.")
+	assert.Equal(t, ExecPromptMode, uiInput.GetPromptMode(), "PromptMode should be ExecPromptMode.")
+	assert.Equal(t, "list files", uiInput.GetArgs(), "Args should be 'list files'.")
+
+	os.Args = []string{"cmd", "compare", "these", "two", "files"}
+	uiInput, _ = NewUIInput()
+	assert.Empty(t, uiInput.GetCompareModels(), "A prompt starting with compare should not be a comparison.")
+	assert.Equal(t, "compare these two files", uiInput.GetArgs(), "Args should be the whole prompt.")
+}
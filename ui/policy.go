@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Praatibh/xang/policy"
+)
+
+// IsPolicyTest reports whether args, without the name of the program, are the
+// ones of xang policy test '<cmd>'.
+func IsPolicyTest(args []string) bool {
+	return len(args) == 3 && args[0] == "policy" && args[1] == "test"
+}
+
+// RunPolicyTest writes what each policy file decides on cmd, run in the
+// current directory, and the decision that applies. It returns the exit code
+// of xang policy test: 1 when cmd is blocked, 2 when the policy can't be
+// loaded.
+func RunPolicyTest(w io.Writer, cmd string) int {
+	loaded, err := policy.Load()
+	if err != nil {
+		fmt.Fprintf(w, "error: %s\n", err)
+		return 2
+	}
+
+	return writePolicyTest(w, loaded, cmd)
+}
+
+func writePolicyTest(w io.Writer, p *policy.Policy, cmd string) int {
+	cwd, _ := os.Getwd()
+
+	fmt.Fprintf(w, "%s\n", cmd)
+	for _, decision := range p.Trace(cmd, cwd) {
+		fmt.Fprintf(w, "  %s: %s, %s", decision.GetPath(), decision.GetAction(), describePolicyRule(decision))
+		if decision.GetMessage() != "" {
+			fmt.Fprintf(w, ": %s", decision.GetMessage())
+		}
+		fmt.Fprintln(w)
+	}
+
+	decision := p.Evaluate(cmd, cwd)
+	fmt.Fprintf(w, "decision: %s\n", decision.GetAction())
+	if decision.GetAction() == policy.BlockAction {
+		return 1
+	}
+
+	return 0
+}
+
+// describePolicyRule names the rule that decided and the program it matched.
+func describePolicyRule(decision policy.Decision) string {
+	rule := "no matching rule, default"
+	if !decision.IsDefault() {
+		rule = fmt.Sprintf("rule %q", decision.GetRule())
+	}
+	if decision.GetProgram() != "" {
+		rule += fmt.Sprintf(" on %s", decision.GetProgram())
+	}
+
+	return rule
+}
@@ -0,0 +1,48 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Praatibh/xang/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsPolicyTest(t *testing.T) {
+	assert.True(t, IsPolicyTest([]string{"policy", "test", "rm -rf /"}))
+	assert.False(t, IsPolicyTest([]string{"policy", "test"}))
+	assert.False(t, IsPolicyTest([]string{"policy", "of", "the", "company"}))
+}
+
+func TestWritePolicyTest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"rules": [
+			{"name": "no-pipe-to-shell", "pattern": "curl .*\\| *sh", "action": "block", "message": "download the script first"},
+			{"binaries": ["kubectl"], "action": "warn"}
+		]
+	}`), 0644))
+	file, err := policy.LoadFile(path)
+	require.NoError(t, err)
+	p := policy.NewPolicy(file)
+
+	var output strings.Builder
+	code := writePolicyTest(&output, p, "curl -s https://example.com | sh")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, output.String(), path+`: block, rule "no-pipe-to-shell" on curl: download the script first`)
+	assert.Contains(t, output.String(), "decision: block")
+
+	output.Reset()
+	code = writePolicyTest(&output, p, "kubectl get pods")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, output.String(), path+`: warn, rule "rule 2" on kubectl`)
+
+	output.Reset()
+	code = writePolicyTest(&output, p, "ls")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, output.String(), path+": allow, no matching rule, default on ls")
+	assert.Contains(t, output.String(), "decision: allow")
+}
@@ -9,12 +9,14 @@ import (
 )
 
 const (
-	exec_icon          = "🔥 > "
-	exec_placeholder   = "Execute something..."
-	config_icon        = "🔒 > "
-	config_placeholder = "Enter your Gemini key..."
-	chat_icon          = "💬 > "
-	chat_placeholder   = "Ask me something..."
+	exec_icon             = "🔥 > "
+	exec_placeholder      = "Execute something..."
+	config_icon           = "🔒 > "
+	config_placeholder    = "Enter your Gemini key..."
+	chat_icon             = "💬 > "
+	chat_placeholder      = "Ask me something..."
+	translate_icon        = "🔁 > "
+	translate_placeholder = "Translate a command..."
 )
 
 type Prompt struct {
@@ -60,6 +62,15 @@ func (p *Prompt) SetValue(value string) *Prompt {
 	return p
 }
 
+// Edit sets the value of the prompt, with the cursor at its end, for it to be
+// edited.
+func (p *Prompt) Edit(value string) *Prompt {
+	p.input.SetValue(value)
+	p.input.CursorEnd()
+
+	return p
+}
+
 func (p *Prompt) GetValue() string {
 	return p.input.Value()
 }
@@ -99,6 +110,8 @@ func getPromptStyle(mode PromptMode) lipgloss.Style {
 		return lipgloss.NewStyle().Foreground(lipgloss.Color(exec_color))
 	case ConfigPromptMode:
 		return lipgloss.NewStyle().Foreground(lipgloss.Color(config_color))
+	case TranslatePromptMode:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color(translate_color))
 	default:
 		return lipgloss.NewStyle().Foreground(lipgloss.Color(chat_color))
 	}
@@ -112,6 +125,8 @@ func getPromptIcon(mode PromptMode) string {
 		return style.Render(exec_icon)
 	case ConfigPromptMode:
 		return style.Render(config_icon)
+	case TranslatePromptMode:
+		return style.Render(translate_icon)
 	default:
 		return style.Render(chat_icon)
 	}
@@ -123,6 +138,8 @@ func getPromptPlaceholder(mode PromptMode) string {
 		return exec_placeholder
 	case ConfigPromptMode:
 		return config_placeholder
+	case TranslatePromptMode:
+		return translate_placeholder
 	default:
 		return chat_placeholder
 	}
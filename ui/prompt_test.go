@@ -9,6 +9,7 @@ import (
 
 func TestUIPrompt(t *testing.T) {
 	t.Run("Prompt", testPrompt)
+	t.Run("PromptEdit", testPromptEdit)
 	t.Run("PromptStyle", testPromptStyle)
 	t.Run("PromptIcon", testPromptIcon)
 	t.Run("PromptPlaceholder", testPromptPlaceholder)
@@ -23,6 +24,7 @@ func testPrompt(t *testing.T) {
 		{"Exec", ExecPromptMode, ""},
 		{"Config", ConfigPromptMode, ""},
 		{"Chat", ChatPromptMode, ""},
+		{"Translate", TranslatePromptMode, ""},
 	}
 
 	for _, tc := range testCases {
@@ -34,6 +36,15 @@ func testPrompt(t *testing.T) {
 	}
 }
 
+func testPromptEdit(t *testing.T) {
+	p := NewPrompt(ExecPromptMode)
+	p.SetValue("y")
+	p.Edit("ls -la /tmp")
+
+	assert.Equal(t, "ls -la /tmp", p.GetValue(), "The prompt value should be the edited one.")
+	assert.Equal(t, len("ls -la /tmp"), p.input.Position(), "The cursor should be at the end of the value.")
+}
+
 func testPromptStyle(t *testing.T) {
 	testCases := []struct {
 		name      string
@@ -43,6 +54,7 @@ func testPromptStyle(t *testing.T) {
 		{"Exec", ExecPromptMode, getPromptStyle},
 		{"Config", ConfigPromptMode, getPromptStyle},
 		{"Chat", ChatPromptMode, getPromptStyle},
+		{"Translate", TranslatePromptMode, getPromptStyle},
 	}
 
 	for _, tc := range testCases {
@@ -62,6 +74,7 @@ func testPromptIcon(t *testing.T) {
 		{"Exec", ExecPromptMode, getPromptIcon},
 		{"Config", ConfigPromptMode, getPromptIcon},
 		{"Chat", ChatPromptMode, getPromptIcon},
+		{"Translate", TranslatePromptMode, getPromptIcon},
 	}
 
 	for _, tc := range testCases {
@@ -81,6 +94,7 @@ func testPromptPlaceholder(t *testing.T) {
 		{"Exec", ExecPromptMode, getPromptPlaceholder},
 		{"Config", ConfigPromptMode, getPromptPlaceholder},
 		{"Chat", ChatPromptMode, getPromptPlaceholder},
+		{"Translate", TranslatePromptMode, getPromptPlaceholder},
 	}
 
 	for _, tc := range testCases {
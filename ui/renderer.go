@@ -6,13 +6,14 @@ import (
 )
 
 const (
-	exec_color    = "#ffa657"
-	config_color  = "#ffffff"
-	chat_color    = "#66b3ff"
-	help_color    = "#aaaaaa"
-	error_color   = "#cc3333"
-	warning_color = "#ffcc00"
-	success_color = "#46b946"
+	exec_color      = "#ffa657"
+	config_color    = "#ffffff"
+	chat_color      = "#66b3ff"
+	translate_color = "#d2a8ff"
+	help_color      = "#aaaaaa"
+	error_color     = "#cc3333"
+	warning_color   = "#ffcc00"
+	success_color   = "#46b946"
 )
 
 type Renderer struct {
@@ -76,12 +77,22 @@ func (r *Renderer) RenderConfigMessage() string {
 func (r *Renderer) RenderHelpMessage() string {
 	help := "**Help**\n"
 	help += "- `↑`/`↓` : navigate in history\n"
-	help += "- `tab`   : switch between `🔥 exec` and `💬 chat` prompt modes\n"
+	help += "- `tab`   : switch between `🔥 exec`, `💬 chat` and `🔁 translate` prompt modes\n"
 	help += "- `ctrl+h`: show help\n"
 	help += "- `ctrl+s`: edit settings\n"
+	help += "- `p` or `ctrl+p`: preview a command in a sandbox before confirming it\n"
+	help += "- `e` or `ctrl+e`: edit a command before confirming it\n"
+	help += "- `l` or `ctrl+l`: set the limits of a command before confirming it\n"
+	help += "- `b` or `ctrl+b`: confirm a command and run it in the background\n"
+	help += "- `ctrl+f`: search the prompt history\n"
 	help += "- `ctrl+r`: clear terminal and reset discussion history\n"
 	help += "- `ctrl+l`: clear terminal but keep discussion history\n"
 	help += "- `ctrl+c`: exit or interrupt command execution\n"
+	help += "\n**Commands**\n"
+	help += "- `/compare <model> <model>...`: send exec prompts to several models side by side, `/compare` alone stops\n"
+	help += "- `/usage`: show the requests, tokens, fallbacks and escalations of each model\n"
+	help += "- `/jobs`: list the background jobs, `/jobs tail|attach|kill <job>` to follow or stop one\n"
+	help += "- `/undo [count]`: put back the files changed by the last commands, `/undo list` lists them\n"
 
 	return help
 }
@@ -0,0 +1,358 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/Praatibh/xang/history"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	search_icon        = "🔎 > "
+	search_placeholder = "Search the history..."
+	// search_results is how many matches the overlay shows at once.
+	search_results = 8
+)
+
+// searchModes are the mode filters of the search, cycled through in order,
+// DefaultPromptMode matching every mode.
+var searchModes = []PromptMode{DefaultPromptMode, ExecPromptMode, ChatPromptMode, TranslatePromptMode}
+
+// historyMatch is an entry of the history matching the query, with the
+// positions of the runes of its prompt that matched.
+type historyMatch struct {
+	entry     history.Entry
+	score     int
+	positions []int
+}
+
+// HistorySearch is an overlay fuzzy searching the prompt history, the most
+// recent entries first, filtered by mode and by working directory.
+type HistorySearch struct {
+	entries  []history.Entry
+	query    textinput.Model
+	mode     PromptMode
+	cwd      string
+	here     bool
+	matches  []historyMatch
+	selected int
+}
+
+// NewHistorySearch returns the search of entries, the oldest first, as the
+// history keeps them, cwd being the directory the directory filter keeps.
+// Only the most recent of the entries of a prompt in a mode is searched.
+func NewHistorySearch(entries []history.Entry, cwd string) *HistorySearch {
+	query := textinput.New()
+	query.Placeholder = search_placeholder
+	query.Prompt = search_icon
+	query.Focus()
+
+	seen := make(map[string]bool)
+	var recent []history.Entry
+	for i := len(entries) - 1; i >= 0; i-- {
+		key := entries[i].Mode + "\x00" + entries[i].Prompt
+		if !seen[key] {
+			seen[key] = true
+			recent = append(recent, entries[i])
+		}
+	}
+
+	s := &HistorySearch{
+		entries: recent,
+		query:   query,
+		mode:    DefaultPromptMode,
+		cwd:     cwd,
+	}
+	s.filter()
+
+	return s
+}
+
+// Update edits the query with msg, searching again when it changed.
+func (s *HistorySearch) Update(msg tea.Msg) (*HistorySearch, tea.Cmd) {
+	value := s.query.Value()
+	var updateCmd tea.Cmd
+	s.query, updateCmd = s.query.Update(msg)
+	if s.query.Value() != value {
+		s.filter()
+	}
+
+	return s, updateCmd
+}
+
+// SetQuery searches for query.
+func (s *HistorySearch) SetQuery(query string) *HistorySearch {
+	s.query.SetValue(query)
+	s.filter()
+
+	return s
+}
+
+// CycleMode moves to the next mode filter.
+func (s *HistorySearch) CycleMode() *HistorySearch {
+	for i, mode := range searchModes {
+		if mode == s.mode {
+			s.mode = searchModes[(i+1)%len(searchModes)]
+			break
+		}
+	}
+	s.filter()
+
+	return s
+}
+
+// ToggleDirectory keeps only the entries of the working directory, or all of
+// them again.
+func (s *HistorySearch) ToggleDirectory() *HistorySearch {
+	s.here = !s.here
+	s.filter()
+
+	return s
+}
+
+// Previous selects the match above the selected one.
+func (s *HistorySearch) Previous() *HistorySearch {
+	if s.selected > 0 {
+		s.selected--
+	}
+
+	return s
+}
+
+// Next selects the match below the selected one.
+func (s *HistorySearch) Next() *HistorySearch {
+	if s.selected < len(s.matches)-1 {
+		s.selected++
+	}
+
+	return s
+}
+
+// GetSelected returns the entry selected, if any matches.
+func (s *HistorySearch) GetSelected() (history.Entry, bool) {
+	if s.selected >= len(s.matches) {
+		return history.Entry{}, false
+	}
+
+	return s.matches[s.selected].entry, true
+}
+
+// GetMatches returns the entries matching the query and filters, best first.
+func (s *HistorySearch) GetMatches() []history.Entry {
+	entries := make([]history.Entry, 0, len(s.matches))
+	for _, match := range s.matches {
+		entries = append(entries, match.entry)
+	}
+
+	return entries
+}
+
+// filter matches the entries against the query and filters, the best
+// matches first and the most recent first among equal ones, and selects the
+// first.
+func (s *HistorySearch) filter() {
+	query := s.query.Value()
+	s.matches = s.matches[:0]
+	for _, entry := range s.entries {
+		if s.mode != DefaultPromptMode && entry.Mode != s.mode.String() {
+			continue
+		}
+		if s.here && filepath.Clean(entry.Cwd) != filepath.Clean(s.cwd) {
+			continue
+		}
+		score, positions, ok := fuzzyMatch(query, entry.Prompt)
+		if !ok {
+			continue
+		}
+		s.matches = append(s.matches, historyMatch{entry: entry, score: score, positions: positions})
+	}
+
+	// insertion sort keeps the recency order among equal scores
+	for i := 1; i < len(s.matches); i++ {
+		for j := i; j > 0 && s.matches[j].score > s.matches[j-1].score; j-- {
+			s.matches[j], s.matches[j-1] = s.matches[j-1], s.matches[j]
+		}
+	}
+	s.selected = 0
+}
+
+// View renders the query, the filters, the matches around the selected one,
+// its prompt highlighted where it matched, and a preview of the command the
+// selected prompt produced.
+func (s *HistorySearch) View(renderer *Renderer) string {
+	var view strings.Builder
+	view.WriteString(s.query.View())
+
+	filters := "all modes"
+	if s.mode != DefaultPromptMode {
+		filters = fmt.Sprintf("%s mode", s.mode)
+	}
+	if s.here {
+		filters += ", this directory"
+	} else {
+		filters += ", all directories"
+	}
+	view.WriteString(fmt.Sprintf("\n%s\n", renderer.RenderHelp(fmt.Sprintf("  %d of %d, %s", len(s.matches), len(s.entries), filters))))
+
+	if len(s.matches) == 0 {
+		view.WriteString(renderer.RenderHelp("  no prompt matches"))
+	}
+	start := 0
+	if s.selected >= search_results {
+		start = s.selected - search_results + 1
+	}
+	for i := start; i < len(s.matches) && i < start+search_results; i++ {
+		match := s.matches[i]
+		marker := "  "
+		if i == s.selected {
+			marker = "▸ "
+		}
+		view.WriteString(fmt.Sprintf("%s%s %s\n", marker, getPromptStyle(GetPromptModeFromString(match.entry.Mode)).Render(getPromptIcon(GetPromptModeFromString(match.entry.Mode))), highlightMatch(match.entry.Prompt, match.positions, i == s.selected)))
+	}
+
+	if selected, ok := s.GetSelected(); ok {
+		view.WriteString("\n" + renderHistoryPreview(renderer, selected))
+	}
+	view.WriteString("\n" + renderer.RenderHelp("enter to run again, ctrl+e to edit, tab for the mode, ctrl+d for this directory, esc to close"))
+
+	return view.String()
+}
+
+// renderHistoryPreview renders the command entry produced and what came of
+// it, along with when and where its prompt was typed.
+func renderHistoryPreview(renderer *Renderer, entry history.Entry) string {
+	var preview strings.Builder
+	switch {
+	case entry.Command != "" && entry.Outcome == history.ExecutedOutcome:
+		preview.WriteString(renderer.RenderSuccess(fmt.Sprintf("  ✓ %s", entry.Command)))
+	case entry.Command != "" && entry.Outcome == history.FailedOutcome:
+		preview.WriteString(renderer.RenderError(fmt.Sprintf("  ✗ %s", entry.Command)))
+	case entry.Command != "":
+		preview.WriteString(renderer.RenderWarning(fmt.Sprintf("  %s (%s)", entry.Command, entry.Outcome)))
+	default:
+		preview.WriteString(renderer.RenderHelp("  no command"))
+	}
+
+	var details []string
+	if !entry.Time.IsZero() {
+		details = append(details, entry.Time.Local().Format("2006-01-02 15:04"))
+	}
+	if entry.Cwd != "" {
+		details = append(details, entry.Cwd)
+	}
+	if len(details) > 0 {
+		preview.WriteString("\n" + renderer.RenderHelp("  "+strings.Join(details, ", ")))
+	}
+
+	return preview.String() + "\n"
+}
+
+// highlightMatch renders prompt with the runes at positions emphasised.
+func highlightMatch(prompt string, positions []int, selected bool) string {
+	style := lipgloss.NewStyle()
+	if selected {
+		style = style.Bold(true)
+	}
+	matched := style.Foreground(lipgloss.Color(warning_color))
+
+	highlighted := make(map[int]bool, len(positions))
+	for _, position := range positions {
+		highlighted[position] = true
+	}
+
+	var rendered strings.Builder
+	for i, r := range []rune(prompt) {
+		if highlighted[i] {
+			rendered.WriteString(matched.Render(string(r)))
+		} else {
+			rendered.WriteString(style.Render(string(r)))
+		}
+	}
+
+	return rendered.String()
+}
+
+// fuzzyMatch reports whether the runes of query appear in text in order,
+// regardless of case, spaces in query being ignored. The score rewards runes
+// matched one after the other and at the start of words, and penalises the
+// runes skipped and a late start; positions are the ones of the runes of text
+// in the best match. An empty query matches everything.
+func fuzzyMatch(query string, text string) (int, []int, bool) {
+	needle := []rune(strings.ToLower(strings.Join(strings.Fields(query), "")))
+	if len(needle) == 0 {
+		return 0, nil, true
+	}
+	haystack := []rune(strings.ToLower(text))
+
+	var best []int
+	bestScore := 0
+	for start, r := range haystack {
+		if r != needle[0] {
+			continue
+		}
+		positions := matchFrom(needle, haystack, start)
+		if positions == nil {
+			// no later start can match either
+			break
+		}
+		if score := scoreMatch(haystack, positions); best == nil || score > bestScore {
+			best, bestScore = positions, score
+		}
+	}
+	if best == nil {
+		return 0, nil, false
+	}
+
+	return bestScore, best, true
+}
+
+// matchFrom returns the positions of the runes of needle in haystack, the
+// first one at start and the others as early as possible, then moved as late
+// as possible before the next one for them to be close together. It returns
+// nil when they do not all appear.
+func matchFrom(needle []rune, haystack []rune, start int) []int {
+	positions := make([]int, 0, len(needle))
+	for i, j := start, 0; i < len(haystack) && j < len(needle); i++ {
+		if haystack[i] == needle[j] {
+			positions = append(positions, i)
+			j++
+		}
+	}
+	if len(positions) < len(needle) {
+		return nil
+	}
+	for j := len(needle) - 2; j > 0; j-- {
+		for i := positions[j+1] - 1; i > positions[j]; i-- {
+			if haystack[i] == needle[j] {
+				positions[j] = i
+				break
+			}
+		}
+	}
+
+	return positions
+}
+
+func scoreMatch(haystack []rune, positions []int) int {
+	score := 0
+	for j, position := range positions {
+		score += 1
+		if j > 0 && position == positions[j-1]+1 {
+			score += 5
+		}
+		if position == 0 || !unicode.IsLetter(haystack[position-1]) && !unicode.IsDigit(haystack[position-1]) {
+			score += 3
+		}
+		if j > 0 {
+			score -= position - positions[j-1] - 1
+		}
+	}
+
+	return score - positions[0]/4
+}
@@ -0,0 +1,125 @@
+package ui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Praatibh/xang/history"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUISearch(t *testing.T) {
+	t.Run("FuzzyMatch", testSearchFuzzyMatch)
+	t.Run("Ranking", testSearchRanking)
+	t.Run("Filters", testSearchFilters)
+	t.Run("Dedupe", testSearchDedupe)
+	t.Run("Selection", testSearchSelection)
+}
+
+func newTestSearchEntries() []history.Entry {
+	now := time.Now()
+	return []history.Entry{
+		{Prompt: "list docker containers", Mode: "exec", Cwd: "/srv", Time: now.Add(-4 * time.Hour), Command: "docker ps", Outcome: history.ExecutedOutcome},
+		{Prompt: "what is a docker volume", Mode: "chat", Cwd: "/srv", Time: now.Add(-3 * time.Hour)},
+		{Prompt: "delete stopped containers", Mode: "exec", Cwd: "/home/bob", Time: now.Add(-2 * time.Hour), Command: "docker container prune", Outcome: history.CancelledOutcome},
+		{Prompt: "show disk usage", Mode: "exec", Cwd: "/home/bob", Time: now.Add(-1 * time.Hour), Command: "df -h", Outcome: history.FailedOutcome},
+	}
+}
+
+func testSearchFuzzyMatch(t *testing.T) {
+	testCases := []struct {
+		name      string
+		query     string
+		text      string
+		matched   bool
+		positions []int
+	}{
+		{"Empty", "", "anything", true, nil},
+		{"Subsequence", "dkr", "docker", true, []int{0, 3, 5}},
+		{"CaseInsensitive", "DOCK", "docker", true, []int{0, 1, 2, 3}},
+		{"Spaces", "ls dir", "list directory", true, []int{0, 2, 5, 6, 7}},
+		{"Tight", "ps", "pull images ps", true, []int{12, 13}},
+		{"Missing", "xyz", "docker", false, nil},
+		{"Order", "rd", "docker", false, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, positions, matched := fuzzyMatch(tc.query, tc.text)
+			assert.Equal(t, tc.matched, matched)
+			assert.Equal(t, tc.positions, positions)
+		})
+	}
+
+	contiguous, _, _ := fuzzyMatch("disk", "show disk usage")
+	scattered, _, _ := fuzzyMatch("disk", "delete stopped containers")
+	assert.Greater(t, contiguous, scattered, "Contiguous matches should score higher.")
+}
+
+func testSearchRanking(t *testing.T) {
+	s := NewHistorySearch(newTestSearchEntries(), "/srv")
+
+	assert.Equal(t, []string{"show disk usage", "delete stopped containers", "what is a docker volume", "list docker containers"}, searchPrompts(s), "An empty query should list the most recent entries first.")
+
+	s.SetQuery("docker")
+	assert.Equal(t, []string{"list docker containers", "what is a docker volume"}, searchPrompts(s), "Earlier matches should rank first.")
+
+	s.SetQuery("containers")
+	assert.Equal(t, []string{"delete stopped containers", "list docker containers"}, searchPrompts(s), "Equal matches should keep the most recent first.")
+
+	s.SetQuery("cont")
+	assert.Equal(t, "delete stopped containers", searchPrompts(s)[0])
+}
+
+func testSearchFilters(t *testing.T) {
+	s := NewHistorySearch(newTestSearchEntries(), "/srv")
+
+	s.CycleMode()
+	assert.Equal(t, []string{"show disk usage", "delete stopped containers", "list docker containers"}, searchPrompts(s), "The exec filter should keep exec prompts.")
+
+	s.CycleMode()
+	assert.Equal(t, []string{"what is a docker volume"}, searchPrompts(s), "The chat filter should keep chat prompts.")
+
+	s.CycleMode().CycleMode().ToggleDirectory()
+	assert.Equal(t, []string{"what is a docker volume", "list docker containers"}, searchPrompts(s), "The directory filter should keep the prompts of the directory.")
+
+	s.ToggleDirectory()
+	assert.Len(t, searchPrompts(s), 4, "The filters should be removable.")
+}
+
+func testSearchDedupe(t *testing.T) {
+	entries := append(newTestSearchEntries(), history.Entry{Prompt: "list docker containers", Mode: "exec", Command: "docker ps -a", Time: time.Now()})
+	s := NewHistorySearch(entries, "/srv")
+
+	s.SetQuery("list docker")
+	assert.Len(t, searchPrompts(s), 1, "A prompt repeated in the same mode should be listed once.")
+	selected, ok := s.GetSelected()
+	assert.True(t, ok)
+	assert.Equal(t, "docker ps -a", selected.Command, "The most recent entry of the prompt should be kept.")
+}
+
+func testSearchSelection(t *testing.T) {
+	s := NewHistorySearch(newTestSearchEntries(), "/srv")
+
+	s.Previous()
+	selected, _ := s.GetSelected()
+	assert.Equal(t, "show disk usage", selected.Prompt, "The selection should not go above the first match.")
+
+	s.Next().Next().Next().Next().Next()
+	selected, _ = s.GetSelected()
+	assert.Equal(t, "list docker containers", selected.Prompt, "The selection should not go below the last match.")
+
+	s.SetQuery("nothing like this")
+	_, ok := s.GetSelected()
+	assert.False(t, ok, "Nothing should be selected without matches.")
+	assert.Contains(t, s.View(NewRenderer()), "no prompt matches")
+}
+
+func searchPrompts(s *HistorySearch) []string {
+	var prompts []string
+	for _, entry := range s.GetMatches() {
+		prompts = append(prompts, entry.Prompt)
+	}
+	return prompts
+}
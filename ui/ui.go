@@ -4,13 +4,18 @@ import (
     "fmt"
     "os"
     "os/exec"
+    "path/filepath"
+    "strconv"
     "strings"
     "time"
 
     "github.com/Praatibh/xang/ai"
+    "github.com/Praatibh/xang/audit"
     "github.com/Praatibh/xang/config"
     "github.com/Praatibh/xang/history"
+    "github.com/Praatibh/xang/policy"
     "github.com/Praatibh/xang/run"
+    "github.com/Praatibh/xang/system"
 
     "github.com/charmbracelet/bubbles/spinner"
     "github.com/charmbracelet/bubbles/textinput"
@@ -21,17 +26,35 @@ import (
 )
 
 type UiState struct {
-    error       error
-    runMode     RunMode
-    promptMode  PromptMode
-    configuring bool
-    querying    bool
-    confirming  bool
-    executing   bool
-    args        string
-    pipe        string
-    buffer      string
-    command     string
+    error         error
+    runMode       RunMode
+    promptMode    PromptMode
+    configuring   bool
+    querying      bool
+    confirming    bool
+    executing     bool
+    args          string
+    pipe          string
+    buffer        string
+    command       string
+    suggestion    string
+    editing       bool
+    limits        run.Limits
+    limiting      bool
+    sourceDialect run.Dialect
+    targetDialect run.Dialect
+    compareModels []string
+    comparing     bool
+    comparison    ai.EngineCompareOutput
+    confirmation  string
+    install       string
+    affected      []string
+    prompt        string
+    audit         audit.Entry
+    target        string
+    elevation     run.Elevation
+    historyEntry  history.Entry
+    searching     bool
 }
 
 type UiDimensions struct {
@@ -53,22 +76,34 @@ type Ui struct {
     config     *config.Config
     engine     *ai.Engine
     history    *history.History
+    policy     *policy.Policy
+    jobs       *run.Jobs
+    journal    *run.Journal
+    session    *run.Session
+    auditLog   *audit.Log
+    auditError error
+    search     *HistorySearch
 }
 
 func NewUi(input *UiInput) *Ui {
     return &Ui{
         state: UiState{
-            error:       nil,
-            runMode:     input.GetRunMode(),
-            promptMode:  input.GetPromptMode(),
-            configuring: false,
-            querying:    false,
-            confirming:  false,
-            executing:   false,
-            args:        input.GetArgs(),
-            pipe:        input.GetPipe(),
-            buffer:      "",
-            command:     "",
+            error:         nil,
+            runMode:       input.GetRunMode(),
+            promptMode:    input.GetPromptMode(),
+            configuring:   false,
+            querying:      false,
+            confirming:    false,
+            executing:     false,
+            args:          input.GetArgs(),
+            pipe:          input.GetPipe(),
+            buffer:        "",
+            command:       "",
+            sourceDialect: input.GetSourceDialect(),
+            targetDialect: input.GetTargetDialect(),
+            compareModels: input.GetCompareModels(),
+            target:        input.GetTarget(),
+            comparing:     false,
         },
         dimensions: UiDimensions{
             150,
@@ -84,12 +119,38 @@ func NewUi(input *UiInput) *Ui {
             character: NewAnimeCharacter(),
         },
         history: history.NewHistory(),
+        jobs:    run.NewJobs(filepath.Join(system.GetDataDirectory(), "jobs")),
     }
 }
 
 // Animation ticker message for character updates
 type characterAnimationMsg struct{}
 
+// jobDoneMsg is the outcome of a background job, along with the audit and
+// history entries of its command and the command the model suggested for it.
+type jobDoneMsg struct {
+    output       run.JobOutput
+    entry        audit.Entry
+    historyEntry history.Entry
+    suggestion   string
+}
+
+// jobStartedMsg is whether a background job started, along with the audit
+// and history entries of its command and the command the model suggested.
+type jobStartedMsg struct {
+    job          *run.Job
+    err          error
+    entry        audit.Entry
+    historyEntry history.Entry
+    suggestion   string
+}
+
+// undoFailedMsg is why the paths a command affects could not be saved to the
+// undo journal.
+type undoFailedMsg struct {
+    err error
+}
+
 func characterAnimationTick() tea.Cmd {
     return tea.Tick(500*time.Millisecond, func(t time.Time) tea.Msg {
         return characterAnimationMsg{}
@@ -161,6 +222,13 @@ func (u *Ui) Init() tea.Cmd {
         }
     }
 
+    if err := u.applyTarget(config); err != nil {
+        return tea.Sequence(
+            tea.Println(u.components.renderer.RenderError(fmt.Sprintf("Target error: %v", err))),
+            tea.Quit,
+        )
+    }
+
     // Try to create engine with better error handling
     engine, err := ai.NewEngine(ai.ExecEngineMode, config)
     if err != nil {
@@ -224,13 +292,16 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
         )
     // keyboard
     case tea.KeyMsg:
+        if u.state.searching && msg.Type != tea.KeyCtrlC {
+            return u.updateSearch(msg)
+        }
         switch msg.Type {
         // quit
         case tea.KeyCtrlC:
             return u, tea.Quit
         // history
         case tea.KeyUp, tea.KeyDown:
-            if !u.state.querying && !u.state.confirming {
+            if !u.state.querying && !u.state.confirming && !u.state.comparing {
                 var input *string
                 if msg.Type == tea.KeyUp {
                     input = u.history.GetPrevious()
@@ -252,17 +323,15 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
             }
         // switch mode
         case tea.KeyTab:
-            if !u.state.querying && !u.state.confirming {
-                if u.state.promptMode == ChatPromptMode {
-                    u.state.promptMode = ExecPromptMode
-                    u.components.prompt.SetMode(ExecPromptMode)
-                    u.engine.SetMode(ai.ExecEngineMode)
-                } else {
-                    u.state.promptMode = ChatPromptMode
-                    u.components.prompt.SetMode(ChatPromptMode)
-                    u.engine.SetMode(ai.ChatEngineMode)
+            if !u.state.querying && !u.state.confirming && !u.state.comparing {
+                switch u.state.promptMode {
+                case ExecPromptMode:
+                    u.switchMode(ChatPromptMode)
+                case ChatPromptMode:
+                    u.switchMode(TranslatePromptMode)
+                default:
+                    u.switchMode(ExecPromptMode)
                 }
-                u.engine.Reset()
                 u.components.character.SetExpression("working") // Character shows working state
                 u.components.prompt, promptCmd = u.components.prompt.Update(msg)
                 cmds = append(
@@ -276,32 +345,22 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
             if u.state.configuring {
                 return u, u.finishConfig(u.components.prompt.GetValue())
             }
-            if !u.state.querying && !u.state.confirming {
+            if u.state.editing {
+                return u.finishEditing(msg)
+            }
+            if u.state.limiting {
+                return u.finishLimits()
+            }
+            if u.state.confirming && u.state.confirmation != "" && !u.state.querying {
+                if strings.TrimSpace(u.components.prompt.GetValue()) == u.state.confirmation {
+                    return u.confirmExecution(promptCmd)
+                }
+                return u.cancelExecution(msg)
+            }
+            if !u.state.querying && !u.state.confirming && !u.state.comparing {
                 input := u.components.prompt.GetValue()
                 if input != "" {
-                    inputPrint := u.components.prompt.AsString()
-                    u.history.Add(input)
-                    u.components.prompt.SetValue("")
-                    u.components.prompt.Blur()
-                    u.components.character.SetExpression("thinking") // Character starts thinking
-                    u.components.prompt, promptCmd = u.components.prompt.Update(msg)
-                    if u.state.promptMode == ChatPromptMode {
-                        cmds = append(
-                            cmds,
-                            promptCmd,
-                            tea.Println(u.renderWithCharacter(inputPrint)),
-                            u.startChatStream(input),
-                            u.awaitChatStream(),
-                        )
-                    } else {
-                        cmds = append(
-                            cmds,
-                            promptCmd,
-                            tea.Println(u.renderWithCharacter(inputPrint)),
-                            u.startExec(input),
-                            u.components.spinner.Tick,
-                        )
-                    }
+                    cmds = append(cmds, u.submitPrompt(input, msg)...)
                 } else {
                     // Empty input - character looks confused
                     u.components.character.SetExpression("confused")
@@ -310,7 +369,7 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
         // help
         case tea.KeyCtrlH:
-            if !u.state.configuring && !u.state.querying && !u.state.confirming {
+            if !u.state.configuring && !u.state.querying && !u.state.confirming && !u.state.comparing {
                 u.components.character.SetExpression("celebrating") // Character celebrates helping
                 u.components.prompt, promptCmd = u.components.prompt.Update(msg)
                 cmds = append(
@@ -321,9 +380,12 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
                 )
             }
 
-        // clear
+        // clear, or limits when confirming
         case tea.KeyCtrlL:
-            if !u.state.querying && !u.state.confirming {
+            if u.state.confirming && !u.state.querying && !u.state.editing && !u.state.limiting {
+                return u.editLimits()
+            }
+            if !u.state.querying && !u.state.confirming && !u.state.comparing {
                 u.components.character.SetExpression("idle")
                 u.components.prompt, promptCmd = u.components.prompt.Update(msg)
                 cmds = append(
@@ -336,8 +398,8 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
         // reset
         case tea.KeyCtrlR:
-            if !u.state.querying && !u.state.confirming {
-                u.history.Reset()
+            if !u.state.querying && !u.state.confirming && !u.state.comparing {
+                u.history.Rewind()
                 u.engine.Reset()
                 u.components.character.SetExpression("sleepy") // Character briefly shows tired from reset
                 u.components.prompt.SetValue("")
@@ -355,9 +417,21 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
                 }()
             }
 
+        // search the history
+        case tea.KeyCtrlF:
+            if u.state.runMode == ReplMode && !u.state.configuring && !u.state.querying && !u.state.confirming && !u.state.comparing && !u.state.executing {
+                return u.startSearch()
+            }
+
+        // preview in a sandbox
+        case tea.KeyCtrlP:
+            if u.state.confirming && !u.state.querying && !u.state.editing && !u.state.limiting && !u.isRemote() {
+                return u, u.previewCommand()
+            }
+
         // edit settings
         case tea.KeyCtrlS:
-            if !u.state.querying && !u.state.confirming && !u.state.configuring && !u.state.executing {
+            if !u.state.querying && !u.state.confirming && !u.state.comparing && !u.state.configuring && !u.state.executing {
                 u.state.executing = true
                 u.state.buffer = ""
                 u.state.command = ""
@@ -372,46 +446,78 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
             }
 
         default:
-            if u.state.confirming {
-                if strings.ToLower(msg.String()) == "y" {
-                    u.state.confirming = false
-                    u.state.executing = true
-                    u.state.buffer = ""
-                    u.components.character.SetExpression("working")
-                    u.components.prompt.SetValue("")
+            if u.state.comparing {
+                u.state.comparing = false
+                choice, err := strconv.Atoi(msg.String())
+                if err == nil {
+                    output, err := u.engine.ChooseComparison(u.state.comparison, choice-1)
+                    if err == nil {
+                        u.components.character.SetExpression("curious")
+                        return u, func() tea.Msg {
+                            return *output
+                        }
+                    }
+                }
+                u.components.character.SetExpression("confused")
+                u.components.prompt.SetValue("")
+                u.components.prompt.Focus()
+                if u.state.runMode == CliMode {
                     return u, tea.Sequence(
-                        promptCmd,
-                        u.execCommand(u.state.command),
+                        tea.Println(u.renderWithCharacter(fmt.Sprintf("\n%s\n", u.components.renderer.RenderWarning("[cancel]")))),
+                        tea.Quit,
                     )
-                } else {
-                    u.state.confirming = false
-                    u.state.executing = false
-                    u.state.buffer = ""
-                    u.components.character.SetExpression("confused") // Character is confused about cancellation
-                    u.components.prompt, promptCmd = u.components.prompt.Update(msg)
-                    u.components.prompt.SetValue("")
-                    u.components.prompt.Focus()
-                    if u.state.runMode == ReplMode {
-                        cmds = append(
-                            cmds,
-                            promptCmd,
-                            tea.Println(u.renderWithCharacter(fmt.Sprintf("\n%s\n", u.components.renderer.RenderWarning("[cancel]")))),
-                            textinput.Blink,
-                        )
-                        // Return to idle after brief confusion
-                        go func() {
-                            time.Sleep(1500 * time.Millisecond)
-                            u.components.character.SetExpression("idle")
-                        }()
-                    } else {
-                        return u, tea.Sequence(
-                            promptCmd,
-                            tea.Println(u.renderWithCharacter(fmt.Sprintf("\n%s\n", u.components.renderer.RenderWarning("[cancel]")))),
-                            tea.Quit,
-                        )
+                }
+                cmds = append(
+                    cmds,
+                    tea.Println(u.renderWithCharacter(fmt.Sprintf("\n%s\n", u.components.renderer.RenderWarning("[cancel]")))),
+                    textinput.Blink,
+                )
+            } else if u.state.confirming && u.state.querying {
+                // A preview is running
+            } else if (u.state.editing || u.state.limiting) && msg.Type == tea.KeyEsc {
+                return u.resumeConfirmation()
+            } else if u.state.editing || u.state.limiting {
+                u.components.prompt, promptCmd = u.components.prompt.Update(msg)
+                cmds = append(
+                    cmds,
+                    promptCmd,
+                )
+            } else if u.state.confirming && u.state.confirmation != "" && msg.Type == tea.KeyCtrlE {
+                return u.editCommand()
+            } else if u.state.confirming && u.state.confirmation != "" && msg.Type == tea.KeyCtrlB && !u.isRemote() {
+                if strings.TrimSpace(u.components.prompt.GetValue()) == u.state.confirmation {
+                    return u.startJob()
+                }
+                return u.cancelExecution(msg)
+            } else if u.state.confirming && u.state.confirmation != "" {
+                // The confirmation is typed in the prompt, enter checks it
+                u.components.prompt, promptCmd = u.components.prompt.Update(msg)
+                cmds = append(
+                    cmds,
+                    promptCmd,
+                )
+            } else if u.state.confirming {
+                switch strings.ToLower(msg.String()) {
+                case "y":
+                    return u.confirmExecution(promptCmd)
+                case "p":
+                    if !u.isRemote() {
+                        return u, u.previewCommand()
+                    }
+                case "e":
+                    return u.editCommand()
+                case "l":
+                    return u.editLimits()
+                case "b":
+                    if !u.isRemote() {
+                        return u.startJob()
+                    }
+                case "i":
+                    if u.state.install != "" {
+                        return u.queueInstall()
                     }
                 }
-                u.state.command = ""
+                return u.cancelExecution(msg)
             } else {
                 u.components.prompt.Focus()
                 u.components.prompt, promptCmd = u.components.prompt.Update(msg)
@@ -425,17 +531,83 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
     // engine exec feedback
     case ai.EngineExecOutput:
         u.state.querying = false
-        var output string
-        if msg.IsExecutable() {
+        var output, audited string
+        var decision policy.Decision
+        var policyErr error
+        var elevation run.Elevation
+        if u.state.promptMode != TranslatePromptMode && msg.IsExecutable() {
+            u.state.audit = audit.Entry{
+                Id:          audit.NewId(),
+                Prompt:      u.state.prompt,
+                Model:       msg.GetModel(),
+                Suggestion:  msg.GetCommand(),
+                Explanation: msg.GetExplanation(),
+                Target:      u.config.GetTarget(),
+            }
+            audited = u.recordAudit(audit.GeneratedStatus, msg.GetCommand())
+            elevation = u.elevate(msg.GetCommand(), msg.NeedsRoot(), true)
+            u.state.audit.Suggestion = elevation.GetCommand()
+            u.state.audit.Root = elevation.IsElevated()
+            decision, policyErr = u.evaluatePolicy(elevation.GetCommand())
+        }
+        if u.state.promptMode == TranslatePromptMode {
+            u.components.character.SetExpression("happy")
+            output = u.renderTranslation(msg)
+            u.components.prompt.Focus()
+            if u.state.runMode == CliMode {
+                return u, tea.Sequence(
+                    tea.Println(u.renderWithCharacter(output)),
+                    tea.Quit,
+                )
+            }
+        } else if msg.IsExecutable() && u.isElevationForbidden(elevation) {
+            output = u.forbidElevation(elevation)
+            if u.state.runMode == CliMode {
+                return u, tea.Sequence(
+                    tea.Println(u.renderWithCharacter(output)),
+                    tea.Quit,
+                )
+            }
+        } else if msg.IsExecutable() && (policyErr != nil || decision.GetAction() == policy.BlockAction) {
+            output = u.blockCommand(elevation.GetCommand(), decision, policyErr)
+            if u.state.runMode == CliMode {
+                return u, tea.Sequence(
+                    tea.Println(u.renderWithCharacter(output)),
+                    tea.Quit,
+                )
+            }
+        } else if msg.IsExecutable() {
             u.state.confirming = true
-            u.state.command = msg.GetCommand()
+            u.state.command = elevation.GetCommand()
+            u.state.suggestion = elevation.GetCommand()
+            u.state.elevation = elevation
+            u.state.limits = u.config.GetRunConfig().GetLimits()
             u.components.character.SetExpression("curious") // Character is curious about execution
             output = u.components.renderer.RenderContent(fmt.Sprintf("`%s`", u.state.command))
-            output += fmt.Sprintf("  %s\n\n  confirm execution? [y/N]", u.components.renderer.RenderHelp(msg.GetExplanation()))
-            u.components.prompt.Blur()
+            output += fmt.Sprintf("  %s\n", u.components.renderer.RenderHelp(msg.GetExplanation()))
+            output += u.renderFallback(msg.GetModel(), msg.IsFallback())
+            output += u.renderEscalation(msg)
+            output += u.renderVote(msg)
+            output += u.renderSyntax(msg)
+            output += audited
+            output += u.askConfirmation(decision)
+        } else if msg.IsInvalid() {
+            u.components.character.SetExpression("confused")
+            output = u.components.renderer.RenderContent(fmt.Sprintf("`%s`", msg.GetCommand()))
+            output += fmt.Sprintf("  %s\n", u.components.renderer.RenderHelp(msg.GetExplanation()))
+            output += u.renderFallback(msg.GetModel(), msg.IsFallback())
+            output += u.renderSyntax(msg)
+            u.components.prompt.Focus()
+            if u.state.runMode == CliMode {
+                return u, tea.Sequence(
+                    tea.Println(u.renderWithCharacter(output)),
+                    tea.Quit,
+                )
+            }
         } else {
             u.components.character.SetExpression("happy")
             output = u.components.renderer.RenderContent(msg.GetExplanation())
+            output += u.renderFallback(msg.GetModel(), msg.IsFallback())
             u.components.prompt.Focus()
             if u.state.runMode == CliMode {
                 return u, tea.Sequence(
@@ -450,12 +622,51 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
             textinput.Blink,
             tea.Println(u.renderWithCharacter(output)),
         )
+    // sandbox preview feedback
+    case run.PreviewOutput:
+        u.state.querying = false
+        u.components.character.SetExpression("curious")
+        output := u.renderPreview(msg)
+        output += u.renderConfirmation()
+        return u, tea.Println(u.renderWithCharacter(output))
+    // undo journal feedback
+    case undoFailedMsg:
+        return u, tea.Println(u.renderWithCharacter(fmt.Sprintf("\n%s", u.components.renderer.RenderWarning(fmt.Sprintf("[no undo: %s]", msg.err)))))
+    // background job feedback
+    case jobStartedMsg:
+        return u.finishJobStart(msg)
+    case jobDoneMsg:
+        output := msg.output.GetRunOutput()
+        if msg.entry.Id != "" {
+            // The prompt has moved on, the outcome is audited silently
+            u.recordOutcome(msg.entry, output)
+        }
+        u.completeHistory(msg.historyEntry, msg.output.GetCommand(), getHistoryOutcome(output))
+        u.observe(msg.output.GetCommand(), msg.suggestion, msg.historyEntry.Prompt, output)
+        var notice string
+        if output.HasError() {
+            notice = u.components.renderer.RenderError(fmt.Sprintf("[%s: %s]", output.GetErrorMessage(), msg.output.GetCommand()))
+        } else {
+            notice = u.components.renderer.RenderSuccess(fmt.Sprintf("[%s: %s]", output.GetSuccessMessage(), msg.output.GetCommand()))
+        }
+        return u, tea.Println(u.renderWithCharacter(fmt.Sprintf("\n%s\n", notice)))
+    // engine comparison feedback
+    case ai.EngineCompareOutput:
+        u.state.querying = false
+        u.state.comparing = true
+        u.state.comparison = msg
+        u.components.character.SetExpression("curious")
+        u.components.prompt.Blur()
+        output := u.renderComparison(msg)
+        output += fmt.Sprintf("\n  pick an answer to confirm? [1-%d]", len(msg.GetResults()))
+        return u, tea.Println(u.renderWithCharacter(output))
     // engine chat stream feedback
     case ai.EngineChatStreamOutput:
         if msg.IsLast() {
             u.state.querying = false
             u.components.character.SetExpression("celebrating") // Character celebrates completion
             output := u.components.renderer.RenderContent(u.state.buffer)
+            output += u.renderFallback(msg.GetModel(), msg.IsFallback())
             u.state.buffer = ""
             u.components.prompt.Focus()
             if u.state.runMode == CliMode {
@@ -487,9 +698,16 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
         u.components.prompt.Focus()
         
         var output string
+        output += u.completeHistory(u.state.historyEntry, u.state.audit.Command, getHistoryOutcome(msg))
+        u.state.historyEntry = history.Entry{}
+        if u.state.audit.Id != "" {
+            output += u.recordOutcome(u.state.audit, msg)
+            u.state.audit = audit.Entry{}
+        }
         if msg.HasError() {
             u.components.character.SetExpression("error") // Character shows error
-            output = u.components.renderer.RenderError(fmt.Sprintf("\n%s\n", msg.GetErrorMessage()))
+            output = u.components.renderer.RenderError(fmt.Sprintf("\n%s\n", msg.GetErrorMessage())) + output
+            output += u.renderSessionChange(msg.GetSessionChange())
             // Return to idle after showing error
             go func() {
                 time.Sleep(2 * time.Second)
@@ -497,7 +715,8 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
             }()
         } else {
             u.components.character.SetExpression("celebrating") // Character celebrates success
-            output = u.components.renderer.RenderSuccess(fmt.Sprintf("\n%s\n", msg.GetSuccessMessage()))
+            output = u.components.renderer.RenderSuccess(fmt.Sprintf("\n%s\n", msg.GetSuccessMessage())) + output
+            output += u.renderSessionChange(msg.GetSessionChange())
             // Return to idle after celebrating
             go func() {
                 time.Sleep(2 * time.Second)
@@ -532,6 +751,127 @@ func (u *Ui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
     return u, tea.Batch(cmds...)
 }
 
+// submitPrompt sends input, typed in the prompt or picked from the history,
+// to the model in the current mode, or runs it when it is a REPL command.
+func (u *Ui) submitPrompt(input string, msg tea.Msg) []tea.Cmd {
+    var cmds []tea.Cmd
+    var promptCmd tea.Cmd
+    inputPrint := u.components.prompt.AsString() + u.recordHistory(input)
+    u.components.prompt.SetValue("")
+    u.components.prompt.Blur()
+    u.components.character.SetExpression("thinking") // Character starts thinking
+    u.components.prompt, promptCmd = u.components.prompt.Update(msg)
+    if command, ok := ParseSlashCommand(input); ok {
+        u.components.prompt.Focus()
+        cmds = append(
+            cmds,
+            promptCmd,
+            tea.Println(u.renderWithCharacter(inputPrint)),
+            u.runSlashCommand(command),
+            textinput.Blink,
+        )
+    } else if u.state.promptMode == ChatPromptMode {
+        cmds = append(
+            cmds,
+            promptCmd,
+            tea.Println(u.renderWithCharacter(inputPrint)),
+            u.startChatStream(input),
+            u.awaitChatStream(),
+        )
+    } else if u.state.promptMode == TranslatePromptMode {
+        cmds = append(
+            cmds,
+            promptCmd,
+            tea.Println(u.renderWithCharacter(inputPrint)),
+            u.startTranslate(input),
+            u.components.spinner.Tick,
+        )
+    } else if len(u.state.compareModels) > 0 {
+        cmds = append(
+            cmds,
+            promptCmd,
+            tea.Println(u.renderWithCharacter(inputPrint)),
+            u.startCompare(input),
+            u.components.spinner.Tick,
+        )
+    } else {
+        cmds = append(
+            cmds,
+            promptCmd,
+            tea.Println(u.renderWithCharacter(inputPrint)),
+            u.startExec(input),
+            u.components.spinner.Tick,
+        )
+    }
+
+    return cmds
+}
+
+// switchMode makes mode the one of the prompt and of the engine, starting a
+// new discussion.
+func (u *Ui) switchMode(mode PromptMode) {
+    u.state.promptMode = mode
+    u.components.prompt.SetMode(mode)
+    u.engine.SetMode(getEngineMode(mode))
+    u.engine.Reset()
+}
+
+// startSearch opens the search of the prompt history, starting from what is
+// typed in the prompt.
+func (u *Ui) startSearch() (tea.Model, tea.Cmd) {
+    u.search = NewHistorySearch(u.history.GetEntries(), u.getWorkingDirectory()).SetQuery(u.components.prompt.GetValue())
+    u.state.searching = true
+    u.components.prompt.Blur()
+    u.components.character.SetExpression("curious")
+
+    return u, textinput.Blink
+}
+
+// updateSearch handles the keys typed while searching the history: the
+// arrows select a prompt, tab and ctrl+d change the filters, enter runs the
+// selected prompt again in its mode, ctrl+e puts it in the prompt to be
+// edited and esc closes the search. The other keys edit the query.
+func (u *Ui) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+    var searchCmd tea.Cmd
+    switch msg.Type {
+    case tea.KeyUp:
+        u.search.Previous()
+    case tea.KeyDown:
+        u.search.Next()
+    case tea.KeyTab:
+        u.search.CycleMode()
+    case tea.KeyCtrlD:
+        u.search.ToggleDirectory()
+    case tea.KeyEsc:
+        u.state.searching = false
+        u.components.character.SetExpression("idle")
+        u.components.prompt.Focus()
+        return u, textinput.Blink
+    case tea.KeyEnter, tea.KeyCtrlE:
+        entry, ok := u.search.GetSelected()
+        if !ok {
+            return u, nil
+        }
+        u.state.searching = false
+        if mode := GetPromptModeFromString(entry.Mode); mode != u.state.promptMode && mode != DefaultPromptMode && mode != ConfigPromptMode {
+            u.switchMode(mode)
+        }
+        u.history.Rewind()
+        u.components.prompt.Focus()
+        if msg.Type == tea.KeyCtrlE {
+            u.components.character.SetExpression("working")
+            u.components.prompt.Edit(entry.Prompt)
+            return u, textinput.Blink
+        }
+        u.components.prompt.SetValue(entry.Prompt)
+        return u, tea.Batch(u.submitPrompt(entry.Prompt, msg)...)
+    default:
+        u.search, searchCmd = u.search.Update(msg)
+    }
+
+    return u, searchCmd
+}
+
 func (u *Ui) View() string {
     // CRITICAL FIX: Always wrap content in renderWithCharacter so animation is visible
     
@@ -548,7 +888,15 @@ func (u *Ui) View() string {
         return u.renderWithCharacter(configView)
     }
 
-    if !u.state.querying && !u.state.confirming && !u.state.executing {
+    if u.state.searching {
+        return u.renderWithCharacter(u.search.View(u.components.renderer))
+    }
+
+    if !u.state.querying && !u.state.confirming && !u.state.comparing && !u.state.executing {
+        return u.renderWithCharacter(u.renderSession() + u.components.prompt.View())
+    }
+
+    if u.state.confirming && (u.state.confirmation != "" || u.state.editing || u.state.limiting) && !u.state.querying {
         return u.renderWithCharacter(u.components.prompt.View())
     }
 
@@ -584,12 +932,7 @@ func (u *Ui) startRepl(config *config.Config) tea.Cmd {
                 u.state.promptMode = GetPromptModeFromString(config.GetUserConfig().GetDefaultPromptMode())
             }
 
-            engineMode := ai.ExecEngineMode
-            if u.state.promptMode == ChatPromptMode {
-                engineMode = ai.ChatEngineMode
-            }
-
-            engine, err := ai.NewEngine(engineMode, config)
+            engine, err := ai.NewEngine(getEngineMode(u.state.promptMode), config)
             if err != nil {
                 return err
             }
@@ -597,8 +940,16 @@ func (u *Ui) startRepl(config *config.Config) tea.Cmd {
             if u.state.pipe != "" {
                 engine.SetPipe(u.state.pipe)
             }
+            engine.SetDialects(u.state.sourceDialect, u.state.targetDialect)
 
             u.engine = engine
+            u.history = openHistory(config)
+            u.auditLog, u.auditError = openAuditLog(config)
+            if len(config.GetTarget()) == 0 {
+                u.session = run.NewSession()
+                u.jobs.SetSession(u.session)
+                u.engine.SetSession(u.session)
+            }
             u.state.buffer = "Welcome \n\n"
             u.state.command = ""
             u.components.prompt = NewPrompt(u.state.promptMode)
@@ -617,17 +968,14 @@ func (u *Ui) startRepl(config *config.Config) tea.Cmd {
 
 func (u *Ui) startCli(config *config.Config) tea.Cmd {
     u.config = config
+    u.history = openHistory(config)
+    u.auditLog, u.auditError = openAuditLog(config)
 
     if u.state.promptMode == DefaultPromptMode {
         u.state.promptMode = GetPromptModeFromString(config.GetUserConfig().GetDefaultPromptMode())
     }
 
-    engineMode := ai.ExecEngineMode
-    if u.state.promptMode == ChatPromptMode {
-        engineMode = ai.ChatEngineMode
-    }
-
-    engine, err := ai.NewEngine(engineMode, config)
+    engine, err := ai.NewEngine(getEngineMode(u.state.promptMode), config)
     if err != nil {
         u.state.error = err
         return nil
@@ -636,16 +984,26 @@ func (u *Ui) startCli(config *config.Config) tea.Cmd {
     if u.state.pipe != "" {
         engine.SetPipe(u.state.pipe)
     }
+    engine.SetDialects(u.state.sourceDialect, u.state.targetDialect)
 
     u.engine = engine
     u.state.querying = true
     u.state.confirming = false
     u.state.buffer = ""
     u.state.command = ""
+    u.state.prompt = u.state.args
     u.components.character.SetExpression("thinking") // Character starts thinking
+    warning := u.printWarning(u.recordHistory(u.state.args))
 
-    if u.state.promptMode == ExecPromptMode {
+    if u.state.promptMode == ExecPromptMode && len(u.state.compareModels) > 0 {
+        return tea.Batch(
+            warning,
+            u.components.spinner.Tick,
+            u.startCompare(u.state.args),
+        )
+    } else if u.state.promptMode == ExecPromptMode {
         return tea.Batch(
+            warning,
             u.components.spinner.Tick,
             func() tea.Msg {
                 output, err := u.engine.ExecCompletion(u.state.args)
@@ -657,8 +1015,15 @@ func (u *Ui) startCli(config *config.Config) tea.Cmd {
                 return *output
             },
         )
+    } else if u.state.promptMode == TranslatePromptMode {
+        return tea.Batch(
+            warning,
+            u.components.spinner.Tick,
+            u.startTranslate(u.state.args),
+        )
     } else {
         return tea.Batch(
+            warning,
             u.startChatStream(u.state.args),
             u.awaitChatStream(),
         )
@@ -690,9 +1055,14 @@ func (u *Ui) finishConfig(key string) tea.Cmd {
         u.components.character.SetExpression("error")
         return nil
     }
+    if err := u.applyTarget(config); err != nil {
+        u.state.error = err
+        u.components.character.SetExpression("error")
+        return nil
+    }
 
     u.config = config
-    engine, err := ai.NewEngine(ai.ExecEngineMode, config)
+    engine, err := ai.NewEngine(getEngineMode(u.state.promptMode), config)
     if err != nil {
         u.state.error = err
         u.components.character.SetExpression("error")
@@ -702,8 +1072,11 @@ func (u *Ui) finishConfig(key string) tea.Cmd {
     if u.state.pipe != "" {
         engine.SetPipe(u.state.pipe)
     }
+    engine.SetDialects(u.state.sourceDialect, u.state.targetDialect)
 
     u.engine = engine
+    u.history = openHistory(config)
+    u.auditLog, u.auditError = openAuditLog(config)
     u.components.character.SetExpression("celebrating") // Character celebrates successful config
 
     if u.state.runMode == ReplMode {
@@ -730,6 +1103,7 @@ func (u *Ui) finishConfig(key string) tea.Cmd {
             u.state.querying = true
             u.state.configuring = false
             u.state.buffer = ""
+            u.state.prompt = u.state.args
             u.components.character.SetExpression("thinking")
             
             return tea.Sequence(
@@ -745,6 +1119,12 @@ func (u *Ui) finishConfig(key string) tea.Cmd {
                     return *output
                 },
             )
+        } else if u.state.promptMode == TranslatePromptMode {
+            return tea.Sequence(
+                tea.Println(u.renderWithCharacter(u.components.renderer.RenderSuccess("\n[settings ok]"))),
+                u.components.spinner.Tick,
+                u.startTranslate(u.state.args),
+            )
         } else {
             return tea.Batch(
                 u.startChatStream(u.state.args),
@@ -761,6 +1141,8 @@ func (u *Ui) startExec(input string) tea.Cmd {
         u.state.buffer = ""
         u.state.command = ""
 
+        u.state.prompt = input
+
         output, err := u.engine.ExecCompletion(input)
         u.state.querying = false
         if err != nil {
@@ -771,6 +1153,42 @@ func (u *Ui) startExec(input string) tea.Cmd {
     }
 }
 
+func (u *Ui) startCompare(input string) tea.Cmd {
+    return func() tea.Msg {
+        u.state.querying = true
+        u.state.confirming = false
+        u.state.buffer = ""
+        u.state.command = ""
+
+        u.state.prompt = input
+
+        output, err := u.engine.CompareCompletion(input, u.state.compareModels)
+        u.state.querying = false
+        if err != nil {
+            return err
+        }
+
+        return *output
+    }
+}
+
+func (u *Ui) startTranslate(input string) tea.Cmd {
+    return func() tea.Msg {
+        u.state.querying = true
+        u.state.confirming = false
+        u.state.buffer = ""
+        u.state.command = ""
+
+        output, err := u.engine.TranslateCompletion(input)
+        u.state.querying = false
+        if err != nil {
+            return err
+        }
+
+        return *output
+    }
+}
+
 func (u *Ui) startChatStream(input string) tea.Cmd {
     return func() tea.Msg {
         u.state.querying = true
@@ -795,70 +1213,1266 @@ func (u *Ui) awaitChatStream() tea.Cmd {
     }
 }
 
-func (u *Ui) execCommand(input string) tea.Cmd {
-    u.state.querying = false
+// confirmExecution runs the command waiting for confirmation.
+func (u *Ui) confirmExecution(promptCmd tea.Cmd) (tea.Model, tea.Cmd) {
     u.state.confirming = false
+    u.state.confirmation = ""
     u.state.executing = true
+    u.state.buffer = ""
+    u.components.character.SetExpression("working")
+    u.components.prompt.SetValue("")
+    audited := u.recordAudit(audit.ConfirmedStatus, u.state.command)
+    u.state.audit.Command = u.state.command
+    return u, tea.Sequence(
+        promptCmd,
+        u.printWarning(audited),
+        u.execCommand(u.state.command),
+    )
+}
 
-    return tea.ExecProcess(run.PrepareInteractiveCommand(input), func(err error) tea.Msg {
-        u.state.executing = false
-        u.state.command = ""
+// previewCommand runs the command waiting for confirmation in a sandbox, to
+// show the changes it would make to the current directory.
+func (u *Ui) previewCommand() tea.Cmd {
+    u.state.querying = true
+    u.components.character.SetExpression("thinking")
+
+    command := u.state.command
+    shell := u.config.GetExecutionShell()
+    cwd := u.getWorkingDirectory()
+    var env []string
+    if u.session != nil {
+        env = u.session.GetEnvironment()
+    }
+    return tea.Batch(
+        u.components.spinner.Tick,
+        func() tea.Msg {
+            return run.PreviewCommand(command, shell, cwd, env)
+        },
+    )
+}
 
-        if err != nil {
-            return run.NewRunOutput(err, fmt.Sprintf("Command failed: %v", err), "")
-        }
+// askConfirmation rates the risk of the command waiting for confirmation and
+// renders how to confirm it, typed when the risk or the policy decision call
+// for it.
+func (u *Ui) askConfirmation(decision policy.Decision) string {
+    cwd := u.getCommandDirectory()
+    risk := run.AssessRisk(u.state.command, cwd, u.config.GetRunConfig().GetRiskRules())
+    output := u.renderRisk(risk)
+    output += u.renderTarget()
+    output += u.renderElevation()
+    output += u.renderMissing()
+    output += u.renderAffected(cwd)
+    output += u.renderPolicy(decision, nil)
+    output += u.renderLimits()
+    u.state.confirmation = risk.GetConfirmation()
+    if decision.GetAction() == policy.ConfirmAction && u.state.confirmation == "" {
+        u.state.confirmation = "yes"
+    }
+    output += u.renderConfirmation()
+    u.components.prompt.SetValue("")
+    if u.state.confirmation != "" {
+        u.components.prompt.Focus()
+    } else {
+        u.components.prompt.Blur()
+    }
 
-        return run.NewRunOutput(nil, "", "Command executed successfully")
-    })
+    return output
 }
 
-func (u *Ui) editSettings() tea.Cmd {
-    u.state.querying = false
-    u.state.confirming = false
-    u.state.executing = true
+// queueInstall puts the install command of the missing programs ahead of the
+// command waiting for confirmation, asking again to confirm both.
+func (u *Ui) queueInstall() (tea.Model, tea.Cmd) {
+    command := fmt.Sprintf("%s && %s", u.state.install, u.state.command)
+    if u.state.command == u.state.suggestion {
+        // The install is xang's own suggestion, not an edit of the user
+        u.state.suggestion = command
+        u.state.audit.Suggestion = command
+    }
+    u.state.install = ""
 
-    // Create command for editing settings
-    configPath := u.config.GetSystemConfig().GetConfigFile()
-    editor := u.config.GetSystemConfig().GetEditor()
-    
-    // Fallback to nano if no editor is set
-    if editor == "" {
-        editor = "nano"
+    output := u.components.renderer.RenderContent(fmt.Sprintf("`%s`", command))
+    return u.reconfirm(u.elevate(command, false, false), output)
+}
+
+// blockCommand renders why the policy blocks command, and tells the model.
+func (u *Ui) blockCommand(command string, decision policy.Decision, err error) string {
+    u.components.character.SetExpression("error")
+    output := u.components.renderer.RenderContent(fmt.Sprintf("`%s`", command))
+    output += u.renderPolicy(decision, err)
+    u.state.audit.Reason = describePolicyBlock(decision, err)
+    output += u.recordAudit(audit.BlockedStatus, command)
+    output += u.completeHistory(u.state.historyEntry, command, history.BlockedOutcome)
+    u.state.audit = audit.Entry{}
+    u.state.historyEntry = history.Entry{}
+    u.engine.AppendToolTurn(command, describePolicyBlock(decision, err))
+    u.components.prompt.Focus()
+
+    return output
+}
+
+// elevate finds whether cmd needs root, hint being set when the model says
+// so. Unless rewrite is unset, as for commands the user typed, the programs
+// that need root are run with the configured tool.
+func (u *Ui) elevate(cmd string, hint bool, rewrite bool) run.Elevation {
+    tool := u.config.GetRunConfig().GetElevationTool()
+    if !rewrite {
+        tool = ""
     }
 
-    cmd := exec.Command(editor, configPath)
-    cmd.Stdin = os.Stdin
-    cmd.Stdout = os.Stdout
-    cmd.Stderr = os.Stderr
+    return run.ElevateCommand(cmd, tool, hint, u.isRoot())
+}
 
-    return tea.ExecProcess(cmd, func(err error) tea.Msg {
-        u.state.executing = false
-        u.state.command = ""
+// isRoot reports whether the commands run as root, on the hosts of the target
+// when there is one.
+func (u *Ui) isRoot() bool {
+    if u.isRemote() {
+        return u.config.GetSystemConfig().GetUsername() == "root"
+    }
 
-        if err != nil {
-            return run.NewRunOutput(err, "Settings edit failed", "")
-        }
+    return os.Geteuid() == 0
+}
 
-        // Reload config after editing
-        newConfig, configErr := config.NewConfig()
-        if configErr != nil {
-            return run.NewRunOutput(configErr, "Failed to reload config", "")
-        }
+// isElevationForbidden reports whether elevation is refused by the
+// configuration, the command needing root or using an elevation tool.
+func (u *Ui) isElevationForbidden(elevation run.Elevation) bool {
+    return u.config.GetRunConfig().IsElevationForbidden() && (elevation.NeedsRoot() || elevation.IsElevated())
+}
 
-        u.config = newConfig
-        
-        // Recreate engine with new config
-        engine, engineErr := ai.NewEngine(ai.ExecEngineMode, newConfig)
-        if engineErr != nil {
-            return run.NewRunOutput(engineErr, "Failed to recreate engine", "")
-        }
-        
-        if u.state.pipe != "" {
-            engine.SetPipe(u.state.pipe)
-        }
-        
-        u.engine = engine
+// forbidElevation renders why the command of elevation is not run, and tells
+// the model.
+func (u *Ui) forbidElevation(elevation run.Elevation) string {
+    reason := "⛔ blocked, running commands as root is forbidden by the configuration"
+    if elevation.NeedsRoot() {
+        reason = fmt.Sprintf("%s, and it needs root: %s", reason, strings.Join(elevation.GetReasons(), ", "))
+    }
 
-        return run.NewRunOutput(nil, "", "Settings updated successfully")
-    })
-}
\ No newline at end of file
+    u.components.character.SetExpression("error")
+    output := u.components.renderer.RenderContent(fmt.Sprintf("`%s`", elevation.GetCommand()))
+    output += fmt.Sprintf("\n  %s\n", u.components.renderer.RenderError(reason))
+    u.state.audit.Reason = reason
+    output += u.recordAudit(audit.BlockedStatus, elevation.GetCommand())
+    output += u.completeHistory(u.state.historyEntry, elevation.GetCommand(), history.BlockedOutcome)
+    u.state.audit = audit.Entry{}
+    u.state.historyEntry = history.Entry{}
+    u.state.elevation = run.Elevation{}
+    u.engine.AppendToolTurn(elevation.GetCommand(), reason)
+    u.components.prompt.Focus()
+
+    return output
+}
+
+// renderElevation warns that the command waiting for confirmation runs as
+// root, or that it needs root but is not elevated, as edited commands are
+// not, and why.
+func (u *Ui) renderElevation() string {
+    elevation := u.state.elevation
+    if !elevation.IsElevated() && !elevation.NeedsRoot() {
+        return ""
+    }
+
+    warning := "⚠ will run as root"
+    if !elevation.IsElevated() && !u.isRoot() {
+        warning = "⚠ needs root, will not be elevated"
+    }
+    if elevation.NeedsRoot() {
+        warning = fmt.Sprintf("%s: %s", warning, strings.Join(elevation.GetReasons(), ", "))
+    }
+    rendered := fmt.Sprintf("\n  %s\n", u.components.renderer.RenderWarning(warning))
+    if hosts := u.config.GetTarget(); elevation.IsElevated() && len(hosts) > 1 {
+        // The hosts run at once without input, the tool can't prompt
+        rendered += fmt.Sprintf("  %s\n", u.components.renderer.RenderWarning(fmt.Sprintf("⚠ no password can be typed on %d hosts at once, %s must not ask for one", len(hosts), u.config.GetRunConfig().GetElevationTool())))
+    }
+
+    return rendered
+}
+
+// editCommand puts the command waiting for confirmation in the prompt, to be
+// edited before it is confirmed.
+func (u *Ui) editCommand() (tea.Model, tea.Cmd) {
+    u.state.editing = true
+    u.state.confirmation = ""
+    u.components.character.SetExpression("working")
+    u.components.prompt.Edit(u.state.command)
+    u.components.prompt.Focus()
+
+    return u, textinput.Blink
+}
+
+// finishEditing asks to confirm the edited command, checked again against the
+// policy. An emptied command is cancelled.
+func (u *Ui) finishEditing(msg tea.Msg) (tea.Model, tea.Cmd) {
+    command := strings.TrimSpace(u.components.prompt.GetValue())
+    if command == "" {
+        return u.cancelExecution(msg)
+    }
+
+    u.state.editing = false
+    output := u.components.renderer.RenderContent(fmt.Sprintf("`%s`", command))
+
+    return u.reconfirm(u.elevate(command, false, false), output)
+}
+
+// reconfirm checks the command of elevation against the configuration and
+// the policy again, blocking it or asking again to confirm it after output.
+func (u *Ui) reconfirm(elevation run.Elevation, output string) (tea.Model, tea.Cmd) {
+    command := elevation.GetCommand()
+    u.state.command = command
+    u.state.elevation = elevation
+    u.state.audit.Root = elevation.IsElevated()
+    decision, err := u.evaluatePolicy(command)
+    if u.isElevationForbidden(elevation) || err != nil || decision.GetAction() == policy.BlockAction {
+        u.state.confirming = false
+        u.state.command = ""
+        u.state.suggestion = ""
+        u.state.install = ""
+        if u.isElevationForbidden(elevation) {
+            output = u.forbidElevation(elevation)
+        } else {
+            output = u.blockCommand(command, decision, err)
+        }
+        u.components.prompt.SetValue("")
+        if u.state.runMode == CliMode {
+            return u, tea.Sequence(
+                tea.Println(u.renderWithCharacter(output)),
+                tea.Quit,
+            )
+        }
+        return u, tea.Sequence(
+            textinput.Blink,
+            tea.Println(u.renderWithCharacter(output)),
+        )
+    }
+
+    u.components.character.SetExpression("curious")
+    output += u.askConfirmation(decision)
+    return u, tea.Sequence(
+        textinput.Blink,
+        tea.Println(u.renderWithCharacter(output)),
+    )
+}
+
+// editLimits puts the limits of the command waiting for confirmation in the
+// prompt, to be overridden for this command.
+func (u *Ui) editLimits() (tea.Model, tea.Cmd) {
+    u.state.limiting = true
+    u.state.confirmation = ""
+    u.components.character.SetExpression("working")
+    u.components.prompt.Edit(u.state.limits.String())
+    u.components.prompt.Focus()
+
+    return u, tea.Sequence(
+        textinput.Blink,
+        tea.Println(u.renderWithCharacter(fmt.Sprintf("\n  %s", u.components.renderer.RenderHelp("set timeout, cpu, memory, files and output, or none, then enter:")))),
+    )
+}
+
+// finishLimits asks again to confirm the command waiting for confirmation,
+// with the limits set in the prompt.
+func (u *Ui) finishLimits() (tea.Model, tea.Cmd) {
+    limits, err := run.ParseLimits(u.components.prompt.GetValue(), u.state.limits)
+    if err != nil {
+        u.components.character.SetExpression("confused")
+        return u, tea.Println(u.renderWithCharacter(fmt.Sprintf("\n  %s", u.components.renderer.RenderError(err.Error()))))
+    }
+
+    u.state.limits = limits
+    return u.resumeConfirmation()
+}
+
+// resumeConfirmation stops editing the command or its limits, asking again
+// to confirm the command.
+func (u *Ui) resumeConfirmation() (tea.Model, tea.Cmd) {
+    u.state.editing = false
+    u.state.limiting = false
+
+    return u.reconfirm(u.state.elevation, "")
+}
+
+// cancelExecution drops the command waiting for confirmation.
+func (u *Ui) cancelExecution(msg tea.Msg) (tea.Model, tea.Cmd) {
+    var promptCmd tea.Cmd
+
+    audited := u.recordAudit(audit.CancelledStatus, u.state.command)
+    audited += u.completeHistory(u.state.historyEntry, u.state.command, history.CancelledOutcome)
+    u.state.audit = audit.Entry{}
+    u.state.historyEntry = history.Entry{}
+    u.state.confirming = false
+    u.state.confirmation = ""
+    u.state.editing = false
+    u.state.limiting = false
+    u.state.executing = false
+    u.state.buffer = ""
+    u.state.command = ""
+    u.state.suggestion = ""
+    u.state.affected = nil
+    u.components.character.SetExpression("confused") // Character is confused about cancellation
+    u.components.prompt, promptCmd = u.components.prompt.Update(msg)
+    u.components.prompt.SetValue("")
+    u.components.prompt.Focus()
+    if u.state.runMode == CliMode {
+        return u, tea.Sequence(
+            promptCmd,
+            u.printWarning(audited),
+            tea.Println(u.renderWithCharacter(fmt.Sprintf("\n%s\n", u.components.renderer.RenderWarning("[cancel]")))),
+            tea.Quit,
+        )
+    }
+
+    // Return to idle after brief confusion
+    go func() {
+        time.Sleep(1500 * time.Millisecond)
+        u.components.character.SetExpression("idle")
+    }()
+    return u, tea.Batch(
+        promptCmd,
+        tea.Sequence(u.printWarning(audited), tea.Println(u.renderWithCharacter(fmt.Sprintf("\n%s\n", u.components.renderer.RenderWarning("[cancel]"))))),
+        textinput.Blink,
+    )
+}
+
+func (u *Ui) execCommand(input string) tea.Cmd {
+    u.state.querying = false
+    u.state.confirming = false
+    u.state.executing = true
+
+    suggestion, prompt := u.state.suggestion, u.state.historyEntry.Prompt
+    saved := u.saveAffected(input)
+    finish := func(output run.RunOutput) tea.Msg {
+        u.state.executing = false
+        u.state.command = ""
+        u.state.suggestion = ""
+
+        u.observe(input, suggestion, prompt, output)
+        return output
+    }
+
+    hosts := u.config.GetTarget()
+    if len(hosts) > 1 {
+        fanOut := run.NewFanOut(input, hosts, u.config.GetRemoteConfig().GetParallel(), u.config.GetRunConfig().GetOutputLimit())
+        fanOut.SetLimits(u.state.limits)
+        return tea.Sequence(saved, tea.Exec(fanOut, func(err error) tea.Msg {
+            return finish(run.NewFanOutRunOutput(fanOut, err))
+        }))
+    }
+
+    execution := run.NewExecution(input, u.config.GetExecutionShell(), u.config.GetRunConfig().GetOutputLimit())
+    execution.SetLimits(u.state.limits)
+    if len(hosts) == 1 {
+        execution.SetHost(hosts[0])
+    } else if u.session != nil {
+        execution.SetSession(u.session)
+    }
+
+    return tea.Sequence(saved, tea.Exec(execution, func(err error) tea.Msg {
+        return finish(run.NewExecutionRunOutput(execution, err))
+    }))
+}
+
+// saveAffected returns the command saving the paths command affects to the
+// undo journal, to run before it, which tells why when they can't be.
+func (u *Ui) saveAffected(command string) tea.Cmd {
+    affected := u.state.affected
+    u.state.affected = nil
+    if len(affected) == 0 {
+        return nil
+    }
+
+    if u.journal == nil {
+        u.journal = NewUndoJournal(u.config.GetUndoConfig())
+    }
+    journal := u.journal
+    return func() tea.Msg {
+        if _, err := journal.Record(command, affected); err != nil {
+            return undoFailedMsg{err: err}
+        }
+        return nil
+    }
+}
+
+// recordAudit writes what became of command, the one offered by the model or
+// its edit, to the audit log when it is enabled. It returns a warning to
+// render when the entry can't be written.
+func (u *Ui) recordAudit(status audit.Status, command string) string {
+    entry := u.state.audit
+    entry.Status = status
+    entry.Command = command
+    entry.Edited = command != entry.Suggestion
+
+    return u.writeAudit(entry)
+}
+
+// recordOutcome writes how the confirmed command of entry ran to the audit
+// log when it is enabled.
+func (u *Ui) recordOutcome(entry audit.Entry, output run.RunOutput) string {
+    entry.Status = audit.ExecutedStatus
+    if output.HasError() {
+        entry.Status = audit.FailedStatus
+        entry.Reason = output.GetErrorMessage()
+    }
+    if output.IsExecuted() {
+        exitCode := output.GetExitCode()
+        entry.ExitCode = &exitCode
+        entry.DurationMs = output.GetDuration().Milliseconds()
+    }
+    entry.Edited = entry.Command != entry.Suggestion
+
+    return u.writeAudit(entry)
+}
+
+func (u *Ui) writeAudit(entry audit.Entry) string {
+    if u.auditLog == nil || entry.Id == "" {
+        return ""
+    }
+
+    if entry.Cwd == "" {
+        entry.Cwd = u.getCommandDirectory()
+    }
+    err := u.auditLog.Write(entry)
+    if err == nil && u.auditError != nil {
+        // Syslog could not be reached when the log was opened, which is told
+        // once
+        err, u.auditError = u.auditError, nil
+    }
+    if err != nil {
+        return fmt.Sprintf("\n  %s\n", u.components.renderer.RenderWarning(fmt.Sprintf("[no audit: %s]", err)))
+    }
+
+    return ""
+}
+
+// printWarning prints the warning of an audit or history entry that could
+// not be written, if any.
+func (u *Ui) printWarning(warning string) tea.Cmd {
+    if warning == "" {
+        return nil
+    }
+
+    return tea.Println(u.renderWithCharacter(warning))
+}
+
+// openHistory returns the prompt history kept in the file of config, in
+// $XDG_DATA_HOME/xang/history.jsonl unless another path is set, or one kept
+// in memory when it is disabled. A file that can't be read yet starts an
+// empty history, its writes reporting why.
+func openHistory(config *config.Config) *history.History {
+    historyConfig := config.GetHistoryConfig()
+    if !historyConfig.IsEnabled() {
+        return history.NewHistory()
+    }
+    path := historyConfig.GetPath()
+    if path == "" {
+        path = filepath.Join(system.GetDataDirectory(), "history.jsonl")
+    }
+    loaded, _ := history.NewFileHistory(path, historyConfig.GetMaxSize())
+
+    return loaded
+}
+
+// openAuditLog returns the audit log of config, nil when it is disabled,
+// along with why its entries can't be sent to syslog when they should be.
+func openAuditLog(config *config.Config) (*audit.Log, error) {
+    auditConfig := config.GetAuditConfig()
+    if !auditConfig.IsEnabled() {
+        return nil, nil
+    }
+    log := NewAuditLog(auditConfig)
+    if auditConfig.GetSyslog() {
+        return log, log.ForwardToSyslog()
+    }
+
+    return log, nil
+}
+
+// recordHistory adds input to the prompt history, along with the mode and the
+// working directory, returning a warning when it can't be written.
+func (u *Ui) recordHistory(input string) string {
+    entry, err := u.history.Record(history.Entry{
+        Prompt: input,
+        Mode:   u.state.promptMode.String(),
+        Cwd:    u.getWorkingDirectory(),
+    })
+    u.state.historyEntry = entry
+    if err != nil {
+        return fmt.Sprintf("\n  %s\n", u.components.renderer.RenderWarning(fmt.Sprintf("⚠ %s", err)))
+    }
+
+    return ""
+}
+
+// completeHistory sets the command entry of the prompt history produced and
+// what came of it, returning a warning when it can't be written.
+func (u *Ui) completeHistory(entry history.Entry, command string, outcome history.Outcome) string {
+    if entry.Prompt == "" {
+        return ""
+    }
+    if err := u.history.SetOutcome(entry, command, outcome); err != nil {
+        return fmt.Sprintf("\n  %s\n", u.components.renderer.RenderWarning(fmt.Sprintf("⚠ %s", err)))
+    }
+
+    return ""
+}
+
+// getHistoryOutcome returns the outcome of the prompt that produced the
+// command of output.
+func getHistoryOutcome(output run.RunOutput) history.Outcome {
+    if output.HasError() {
+        return history.FailedOutcome
+    }
+
+    return history.ExecutedOutcome
+}
+
+// observe tells the model what came of command, which the user may have
+// edited from the suggested one, and remembers it under prompt, the one it
+// was suggested for, if it succeeded.
+func (u *Ui) observe(command string, suggestion string, prompt string, output run.RunOutput) {
+    observation := output.GetObservation()
+    if suggestion != "" && suggestion != command {
+        // Tell the model about the correction, for it to learn from it
+        observation = fmt.Sprintf("the user edited the suggested command `%s` into this one before running it\n%s", suggestion, observation)
+    }
+    u.engine.AppendToolTurn(command, observation)
+    if !output.HasError() {
+        // Indexing needs an embedding request, keep it off the UI loop
+        go u.engine.Remember(prompt, command)
+    }
+}
+
+// startJob runs the command waiting for confirmation in the background, its
+// output going to a log file, and gives the prompt back.
+func (u *Ui) startJob() (tea.Model, tea.Cmd) {
+    command, suggestion := u.state.command, u.state.suggestion
+    u.state.audit.Background = true
+    audited := u.recordAudit(audit.ConfirmedStatus, command)
+    entry := u.state.audit
+    entry.Command = command
+    historyEntry := u.state.historyEntry
+    u.state.audit = audit.Entry{}
+    u.state.historyEntry = history.Entry{}
+    u.state.confirming = false
+    u.state.confirmation = ""
+    u.state.command = ""
+    u.state.suggestion = ""
+    u.components.prompt.SetValue("")
+    u.components.prompt.Focus()
+
+    // The job starts once its paths are saved to the undo journal
+    saved := u.saveAffected(command)
+    shell, limits := u.config.GetExecutionShell(), u.state.limits
+    started := func() tea.Msg {
+        job, err := u.jobs.Start(command, shell, limits)
+        return jobStartedMsg{job: job, err: err, entry: entry, historyEntry: historyEntry, suggestion: suggestion}
+    }
+
+    return u, tea.Batch(
+        tea.Sequence(saved, u.printWarning(audited), started),
+        textinput.Blink,
+    )
+}
+
+// finishJobStart renders whether the job of msg started, and waits for it to
+// end.
+func (u *Ui) finishJobStart(msg jobStartedMsg) (tea.Model, tea.Cmd) {
+    var output, audited string
+    var awaitCmd tea.Cmd
+    command := msg.entry.Command
+    job := msg.job
+    if msg.err != nil {
+        u.components.character.SetExpression("error")
+        output = u.components.renderer.RenderError(fmt.Sprintf("[failed to start job: %s]", msg.err))
+        if msg.entry.Id != "" {
+            audited += u.recordOutcome(msg.entry, run.NewRunOutput(msg.err, msg.err.Error(), ""))
+        }
+        audited += u.completeHistory(msg.historyEntry, command, history.FailedOutcome)
+    } else {
+        u.components.character.SetExpression("working")
+        output = u.components.renderer.RenderSuccess(fmt.Sprintf("[job %d started in the background, logging to %s]", job.GetId(), job.GetLogPath()))
+        entry, historyEntry, suggestion := msg.entry, msg.historyEntry, msg.suggestion
+        awaitCmd = func() tea.Msg {
+            return jobDoneMsg{output: job.GetOutput(), entry: entry, historyEntry: historyEntry, suggestion: suggestion}
+        }
+    }
+
+    if u.state.runMode == CliMode {
+        if msg.err == nil {
+            output += fmt.Sprintf("\n%s", u.components.renderer.RenderHelp("the job keeps running once xang exits"))
+        }
+        return u, tea.Sequence(
+            u.printWarning(audited),
+            tea.Println(u.renderWithCharacter(fmt.Sprintf("\n%s\n", output))),
+            tea.Quit,
+        )
+    }
+
+    return u, tea.Batch(
+        tea.Sequence(u.printWarning(audited), tea.Println(u.renderWithCharacter(fmt.Sprintf("\n%s\n", output)))),
+        awaitCmd,
+    )
+}
+
+// runJobsCommand lists the background jobs, or tails, attaches to or kills
+// one of them.
+func (u *Ui) runJobsCommand(args []string) tea.Cmd {
+    if len(args) == 0 {
+        return tea.Println(u.renderWithCharacter(fmt.Sprintf("\n%s\n", u.renderJobs())))
+    }
+
+    var job *run.Job
+    var err error
+    if len(args) < 2 {
+        err = fmt.Errorf("/jobs %s needs a job number", args[0])
+    } else if id, convErr := strconv.Atoi(args[1]); convErr != nil {
+        err = fmt.Errorf("invalid job number %q", args[1])
+    } else {
+        job, err = u.jobs.GetJob(id)
+    }
+
+    var output string
+    if err == nil {
+        switch args[0] {
+        case "tail":
+            lines := 20
+            if len(args) > 2 {
+                if lines, err = strconv.Atoi(args[2]); err != nil || lines < 1 {
+                    err = fmt.Errorf("invalid number of lines %q", args[2])
+                }
+            }
+            var tail string
+            if err == nil {
+                tail, err = job.Tail(lines)
+            }
+            output = fmt.Sprintf("%s\n%s", u.components.renderer.RenderHelp(fmt.Sprintf("job %d, %s: %s", job.GetId(), job.GetStatus(), job.GetCommand())), tail)
+        case "attach":
+            return tea.Exec(run.NewJobAttachment(job), func(err error) tea.Msg {
+                if err != nil {
+                    return run.NewRunOutput(err, fmt.Sprintf("Failed to attach to job %d", job.GetId()), "")
+                }
+                return nil
+            })
+        case "kill":
+            if err = job.Kill(); err == nil {
+                output = u.components.renderer.RenderSuccess(fmt.Sprintf("[job %d killed]", job.GetId()))
+            }
+        default:
+            err = fmt.Errorf("unknown /jobs command %q, expected tail, attach or kill", args[0])
+        }
+    }
+    if err != nil {
+        output = u.components.renderer.RenderError(fmt.Sprintf("[%s]", err))
+    }
+
+    return tea.Println(u.renderWithCharacter(fmt.Sprintf("\n%s\n", output)))
+}
+
+// renderJobs lists the background jobs of the session.
+func (u *Ui) renderJobs() string {
+    jobs := u.jobs.GetJobs()
+    if len(jobs) == 0 {
+        return u.components.renderer.RenderHelp("[no jobs]")
+    }
+
+    var rendered strings.Builder
+    for _, job := range jobs {
+        fmt.Fprintf(
+            &rendered,
+            "  %d  %-12s %8s  %s\n",
+            job.GetId(),
+            job.GetStatus(),
+            job.GetDuration().Round(time.Second),
+            job.GetCommand(),
+        )
+    }
+
+    return u.components.renderer.RenderHelp(strings.TrimSuffix(rendered.String(), "\n"))
+}
+
+func (u *Ui) editSettings() tea.Cmd {
+    u.state.querying = false
+    u.state.confirming = false
+    u.state.executing = true
+
+    // Create command for editing settings
+    configPath := u.config.GetSystemConfig().GetConfigFile()
+    editor := u.config.GetSystemConfig().GetEditor()
+    
+    // Fallback to nano if no editor is set
+    if editor == "" {
+        editor = "nano"
+    }
+
+    cmd := exec.Command(editor, configPath)
+    cmd.Stdin = os.Stdin
+    cmd.Stdout = os.Stdout
+    cmd.Stderr = os.Stderr
+
+    return tea.ExecProcess(cmd, func(err error) tea.Msg {
+        u.state.executing = false
+        u.state.command = ""
+
+        if err != nil {
+            return run.NewRunOutput(err, "Settings edit failed", "")
+        }
+
+        // Reload config after editing
+        newConfig, configErr := config.NewConfig()
+        if configErr != nil {
+            return run.NewRunOutput(configErr, "Failed to reload config", "")
+        }
+
+        u.config = newConfig
+        
+        // Recreate engine with new config
+        engine, engineErr := ai.NewEngine(ai.ExecEngineMode, newConfig)
+        if engineErr != nil {
+            return run.NewRunOutput(engineErr, "Failed to recreate engine", "")
+        }
+        
+        if u.state.pipe != "" {
+            engine.SetPipe(u.state.pipe)
+        }
+        engine.SetDialects(u.state.sourceDialect, u.state.targetDialect)
+        
+        u.engine = engine
+
+        return run.NewRunOutput(nil, "", "Settings updated successfully")
+    })
+}
+
+// renderTranslation renders a translated command with its explanation and the
+// constructs that could not be translated.
+func (u *Ui) renderTranslation(output ai.EngineExecOutput) string {
+    if output.GetCommand() == "" {
+        return u.components.renderer.RenderContent(output.GetExplanation())
+    }
+
+    rendered := u.components.renderer.RenderContent(
+        fmt.Sprintf("```%s\n%s\n```", u.engine.GetTargetDialect(), output.GetCommand()),
+    )
+    if output.GetExplanation() != "" {
+        rendered += fmt.Sprintf("  %s\n", u.components.renderer.RenderHelp(output.GetExplanation()))
+    }
+    for _, warning := range output.GetWarnings() {
+        rendered += fmt.Sprintf("\n  %s", u.components.renderer.RenderWarning(fmt.Sprintf("⚠ %s", warning)))
+    }
+
+    return rendered + "\n" + u.renderFallback(output.GetModel(), output.IsFallback())
+}
+
+// renderEscalation tells which model answered when the routed one gave an
+// answer that could not be used.
+func (u *Ui) renderEscalation(output ai.EngineExecOutput) string {
+    if !output.IsEscalated() {
+        return ""
+    }
+
+    return fmt.Sprintf("  %s\n", u.components.renderer.RenderWarning(fmt.Sprintf("↑ escalated from %s to %s", output.GetEscalatedFrom(), output.GetModel())))
+}
+
+// renderUsage renders the models used during the session.
+func (u *Ui) renderUsage() string {
+    models := u.engine.GetUsage()
+    if len(models) == 0 {
+        return u.components.renderer.RenderHelp("[no model used yet]")
+    }
+
+    table := "| Model | Requests | Prompt tokens | Output tokens | Fallbacks | Escalations |\n"
+    table += "|-------|----------|---------------|---------------|-----------|-------------|\n"
+    for _, model := range models {
+        table += fmt.Sprintf("| %s | %d | %d | %d | %d | %d |\n", model.Model, model.Requests, model.PromptTokens, model.CandidatesTokens, model.Fallbacks, model.Escalations)
+    }
+
+    return u.components.renderer.RenderContent(table)
+}
+
+// renderFallback tells which model answered when the configured one was out
+// of quota or unavailable.
+func (u *Ui) renderFallback(model string, fallback bool) string {
+    if !fallback {
+        return ""
+    }
+
+    return fmt.Sprintf("  %s\n", u.components.renderer.RenderWarning(fmt.Sprintf("↪ answered by fallback model %s", model)))
+}
+
+// renderVote renders how many samples agreed on the command, warning when
+// they disagreed too much to trust it.
+func (u *Ui) renderVote(output ai.EngineExecOutput) string {
+    if !output.IsVoted() {
+        return ""
+    }
+
+    agreed := int(output.GetAgreement()*float64(output.GetSamples()) + 0.5)
+    summary := fmt.Sprintf("%d/%d samples agree", agreed, output.GetSamples())
+    if output.GetAgreement() >= u.config.GetVotingConfig().GetMinAgreement() {
+        return fmt.Sprintf("  %s\n", u.components.renderer.RenderHelp(summary))
+    }
+
+    rendered := fmt.Sprintf("\n  %s\n", u.components.renderer.RenderWarning(fmt.Sprintf("⚠ low agreement: only %s, other candidates:", summary)))
+    for _, candidate := range output.GetCandidates() {
+        rendered += fmt.Sprintf("    %s\n", u.components.renderer.RenderWarning(candidate))
+    }
+
+    return rendered
+}
+
+// renderSyntax tells when the command is not valid shell syntax, and so not
+// offered for execution, or when the model had to be asked again for one
+// that parses.
+func (u *Ui) renderSyntax(output ai.EngineExecOutput) string {
+    if output.IsInvalid() && output.IsReprompted() {
+        return fmt.Sprintf("\n  %s\n", u.components.renderer.RenderError(fmt.Sprintf("✗ invalid shell syntax, even when asked again: %s", output.GetSyntaxError())))
+    }
+    if output.IsInvalid() {
+        return fmt.Sprintf("\n  %s\n", u.components.renderer.RenderError(fmt.Sprintf("✗ invalid shell syntax: %s", output.GetSyntaxError())))
+    }
+    if output.IsReprompted() {
+        return fmt.Sprintf("\n  %s\n", u.components.renderer.RenderHelp("the first command was not valid shell syntax, the model was asked again"))
+    }
+
+    return ""
+}
+
+// evaluatePolicy returns the decision of the policy on cmd, loading the
+// policy on first use.
+func (u *Ui) evaluatePolicy(cmd string) (policy.Decision, error) {
+    if u.policy == nil {
+        loaded, err := policy.Load()
+        if err != nil {
+            return policy.Decision{}, err
+        }
+        u.policy = loaded
+        if u.session != nil {
+            u.policy.SetGetenv(u.session.Getenv)
+        }
+    }
+
+    return u.policy.Evaluate(cmd, u.getCommandDirectory()), nil
+}
+
+// getWorkingDirectory returns the working directory the commands start from,
+// the one of the session once commands changed it.
+func (u *Ui) getWorkingDirectory() string {
+    if u.session != nil {
+        return u.session.GetDirectory()
+    }
+    cwd, _ := os.Getwd()
+
+    return cwd
+}
+
+// getCommandDirectory returns the working directory the generated commands
+// start from, the home directory of the remote user for the commands run
+// over ssh.
+func (u *Ui) getCommandDirectory() string {
+    if u.isRemote() {
+        return u.config.GetSystemConfig().GetHomeDirectory()
+    }
+
+    return u.getWorkingDirectory()
+}
+
+// renderPolicy renders why the policy blocks a command, asks for typed
+// confirmation or warns about it.
+func (u *Ui) renderPolicy(decision policy.Decision, err error) string {
+    if err != nil {
+        return fmt.Sprintf("\n  %s\n", u.components.renderer.RenderError(describePolicyBlock(decision, err)))
+    }
+
+    var rendered string
+    switch decision.GetAction() {
+    case policy.BlockAction:
+        rendered = u.components.renderer.RenderError(describePolicyBlock(decision, nil))
+    case policy.ConfirmAction:
+        rendered = u.components.renderer.RenderWarning(fmt.Sprintf("⚠ %s requires typed confirmation", describePolicyDecision(decision)))
+    case policy.WarnAction:
+        rendered = u.components.renderer.RenderWarning(fmt.Sprintf("⚠ %s warns", describePolicyDecision(decision)))
+    default:
+        return ""
+    }
+    if decision.GetMessage() != "" {
+        rendered += fmt.Sprintf("\n    %s", u.components.renderer.RenderWarning(decision.GetMessage()))
+    }
+
+    return fmt.Sprintf("\n  %s\n", rendered)
+}
+
+// describePolicyDecision names the rule of the policy that decided.
+func describePolicyDecision(decision policy.Decision) string {
+    if decision.IsDefault() {
+        return fmt.Sprintf("the default of the policy %s", decision.GetPath())
+    }
+
+    return fmt.Sprintf("the policy rule %q of %s", decision.GetRule(), decision.GetPath())
+}
+
+// describePolicyBlock explains why a command was not run.
+func describePolicyBlock(decision policy.Decision, err error) string {
+    if err != nil {
+        return fmt.Sprintf("⛔ blocked, the policy could not be loaded: %s", err)
+    }
+    if decision.GetProgram() != "" && decision.IsDefault() {
+        return fmt.Sprintf("⛔ %s is not allowed by %s", decision.GetProgram(), describePolicyDecision(decision))
+    }
+
+    return fmt.Sprintf("⛔ blocked by %s", describePolicyDecision(decision))
+}
+
+// renderConfirmation renders the question asked before running a command.
+func (u *Ui) renderConfirmation() string {
+    if u.isRemote() && u.state.confirmation != "" {
+        return fmt.Sprintf("\n  type %q then enter to confirm execution, ctrl+e to edit, ctrl+l for limits:", u.state.confirmation)
+    }
+    if u.isRemote() {
+        return "\n  confirm execution? [y/N, e to edit, l for limits]"
+    }
+    if u.state.confirmation != "" {
+        return fmt.Sprintf("\n  type %q then enter to confirm execution, ctrl+b for the background, ctrl+p to preview, ctrl+e to edit, ctrl+l for limits:", u.state.confirmation)
+    }
+    if u.state.install != "" {
+        return "\n  confirm execution? [y/N, i to install first, b for the background, p to preview, e to edit, l for limits]"
+    }
+
+    return "\n  confirm execution? [y/N, b for the background, p to preview, e to edit, l for limits]"
+}
+
+// isRemote reports whether the generated commands run on remote hosts, where
+// they can't be previewed, run in the background, nor looked into for
+// missing programs and files to save.
+func (u *Ui) isRemote() bool {
+    return u.config != nil && len(u.config.GetTarget()) > 0
+}
+
+// applyTarget makes config run the generated commands on the hosts of the
+// target given, if any, the first one being analysed for the commands to fit
+// its system.
+func (u *Ui) applyTarget(config *config.Config) error {
+    if u.state.target == "" {
+        return nil
+    }
+
+    hosts := config.GetRemoteConfig().ResolveTarget(u.state.target)
+    if len(hosts) == 0 {
+        return fmt.Errorf("no host to run commands on in %q", u.state.target)
+    }
+    analysis, err := system.AnalyseRemote(hosts[0])
+    if err != nil {
+        return err
+    }
+    config.SetTarget(hosts, analysis)
+
+    return nil
+}
+
+// renderTarget names the hosts the command waiting for confirmation runs on.
+func (u *Ui) renderTarget() string {
+    hosts := u.config.GetTarget()
+    if len(hosts) == 0 {
+        return ""
+    }
+    if len(hosts) == 1 {
+        return fmt.Sprintf("\n  %s\n", u.components.renderer.RenderWarning(fmt.Sprintf("runs on %s over ssh", hosts[0])))
+    }
+
+    return fmt.Sprintf("\n  %s\n", u.components.renderer.RenderWarning(fmt.Sprintf("runs on %d hosts over ssh: %s", len(hosts), strings.Join(hosts, ", "))))
+}
+
+// renderAffected lists the paths the command waiting for confirmation
+// deletes, moves or overwrites, which are saved to the undo journal once it
+// is confirmed.
+func (u *Ui) renderAffected(cwd string) string {
+    u.state.affected = nil
+    if !u.config.GetUndoConfig().IsEnabled() || u.isRemote() {
+        return ""
+    }
+    u.state.affected = run.FindAffectedPaths(u.state.command, cwd)
+    if len(u.state.affected) == 0 {
+        return ""
+    }
+
+    paths := make([]string, 0, len(u.state.affected))
+    for _, path := range u.state.affected {
+        if relative, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(relative, "..") {
+            path = relative
+        }
+        paths = append(paths, path)
+    }
+
+    return fmt.Sprintf("\n  %s\n", u.components.renderer.RenderHelp(fmt.Sprintf("saved for /undo: %s", strings.Join(paths, ", "))))
+}
+
+// runUndoCommand undoes the last commands, or lists the ones that can be.
+func (u *Ui) runUndoCommand(args []string) string {
+    if len(args) > 1 {
+        return u.components.renderer.RenderError("[/undo takes a number of commands or list]")
+    }
+    if u.journal == nil {
+        u.journal = NewUndoJournal(u.config.GetUndoConfig())
+    }
+
+    var output strings.Builder
+    if writeUndo(&output, u.journal, args) != 0 {
+        return u.components.renderer.RenderError(strings.TrimSpace(output.String()))
+    }
+
+    return u.components.renderer.RenderSuccess(strings.TrimSpace(output.String()))
+}
+
+// renderSessionChange renders what a command changed of the working
+// directory and environment the next commands start from.
+func (u *Ui) renderSessionChange(change run.SessionChange) string {
+    if change.IsEmpty() {
+        return ""
+    }
+
+    return fmt.Sprintf("%s\n", u.components.renderer.RenderHelp(fmt.Sprintf("[%s]", change)))
+}
+
+// renderSession renders the working directory of the session and the
+// environment variables changed in it, once commands changed them.
+func (u *Ui) renderSession() string {
+    if u.isRemote() {
+        return u.components.renderer.RenderHelp(fmt.Sprintf("🖥  %s", strings.Join(u.config.GetTarget(), ", "))) + "\n"
+    }
+    if u.session == nil {
+        return ""
+    }
+    changes := u.session.GetChanges()
+    if changes.IsEmpty() {
+        return ""
+    }
+
+    directory := u.session.GetDirectory()
+    if home := u.config.GetSystemConfig().GetHomeDirectory(); home != "" && (directory == home || strings.HasPrefix(directory, home+string(filepath.Separator))) {
+        directory = "~" + strings.TrimPrefix(directory, home)
+    }
+    status := fmt.Sprintf("📁 %s", directory)
+    var variables []string
+    for _, name := range changes.GetSet() {
+        variables = append(variables, "+"+name)
+    }
+    for _, name := range changes.GetUnset() {
+        variables = append(variables, "-"+name)
+    }
+    if len(variables) > 0 {
+        status += fmt.Sprintf("  env: %s", strings.Join(variables, " "))
+    }
+
+    return u.components.renderer.RenderHelp(status) + "\n"
+}
+
+// renderMissing lists the programs of the command waiting for confirmation
+// that can't be found, and keeps the command installing them with the
+// package manager of the system, unless it is already queued.
+func (u *Ui) renderMissing() string {
+    u.state.install = ""
+    if u.isRemote() {
+        return ""
+    }
+    path := os.Getenv("PATH")
+    if u.session != nil {
+        path = u.session.Getenv("PATH")
+    }
+    missing := run.FindMissingPrograms(u.state.command, u.getWorkingDirectory(), path)
+    if len(missing) == 0 {
+        return ""
+    }
+
+    var installable []string
+    for _, program := range missing {
+        if !strings.Contains(program, "/") {
+            installable = append(installable, program)
+        }
+    }
+    install := ""
+    var elevation run.Elevation
+    if len(installable) > 0 {
+        install = u.config.GetSystemConfig().GetPackageManager().InstallCommand(installable)
+    }
+    if install != "" {
+        elevation = u.elevate(install, false, true)
+        install = elevation.GetCommand()
+    }
+    if install != "" && strings.HasPrefix(u.state.command, install+" && ") {
+        return fmt.Sprintf("\n  %s\n", u.components.renderer.RenderHelp(fmt.Sprintf("installing %s first", strings.Join(installable, ", "))))
+    }
+
+    rendered := fmt.Sprintf("\n  %s\n", u.components.renderer.RenderWarning(fmt.Sprintf("⚠ not found: %s", strings.Join(missing, ", "))))
+    if install != "" && u.isElevationForbidden(elevation) {
+        rendered += fmt.Sprintf("    %s\n", u.components.renderer.RenderHelp(fmt.Sprintf("installing %s needs root, which is forbidden by the configuration", strings.Join(installable, ", "))))
+    } else if install != "" {
+        u.state.install = install
+        rendered += fmt.Sprintf("    %s\n", u.components.renderer.RenderHelp(fmt.Sprintf("install with: %s", install)))
+    }
+
+    return rendered
+}
+
+// renderLimits renders the limits the command waiting for confirmation runs
+// within, if any.
+func (u *Ui) renderLimits() string {
+    if u.state.limits == (run.Limits{}) {
+        return ""
+    }
+
+    return fmt.Sprintf("\n  %s\n", u.components.renderer.RenderHelp(fmt.Sprintf("limits: %s", u.state.limits)))
+}
+
+// preview_diff_lines is how many lines of the diff of a file a preview shows.
+const preview_diff_lines = 20
+
+// renderDiff renders the first lines of the diff of a file, colored by
+// whether they were added or removed.
+func (u *Ui) renderDiff(diff string) string {
+    if diff == "" {
+        return ""
+    }
+
+    lines := strings.Split(strings.TrimSuffix(diff, "\n"), "\n")
+    rendered := ""
+    for i, line := range lines {
+        if i == preview_diff_lines {
+            rendered += fmt.Sprintf("        %s\n", u.components.renderer.RenderHelp(fmt.Sprintf("… %d more lines", len(lines)-i)))
+            break
+        }
+        switch {
+        case strings.HasPrefix(line, "@@"):
+            rendered += fmt.Sprintf("        %s\n", u.components.renderer.RenderHelp(line))
+        case strings.HasPrefix(line, "+"):
+            rendered += fmt.Sprintf("        %s\n", u.components.renderer.RenderSuccess(line))
+        case strings.HasPrefix(line, "-"):
+            rendered += fmt.Sprintf("        %s\n", u.components.renderer.RenderError(line))
+        default:
+            rendered += fmt.Sprintf("        %s\n", line)
+        }
+    }
+
+    return rendered
+}
+
+// renderPreview renders the changes a command made in a sandbox, along with
+// the diff of the text files it created or modified.
+func (u *Ui) renderPreview(preview run.PreviewOutput) string {
+    if preview.HasError() {
+        return fmt.Sprintf("\n  %s\n", u.components.renderer.RenderError(fmt.Sprintf("[preview failed] %s", preview.GetError())))
+    }
+
+    rendered := fmt.Sprintf("\n  %s\n", u.components.renderer.RenderHelp(fmt.Sprintf("sandbox preview, exit %d:", preview.GetExitCode())))
+    if len(preview.GetChanges()) == 0 {
+        rendered += fmt.Sprintf("    %s\n", u.components.renderer.RenderHelp("no changes to the current directory"))
+    }
+    for _, change := range preview.GetChanges() {
+        switch change.GetKind() {
+        case run.CreatedChange:
+            rendered += fmt.Sprintf("    %s\n", u.components.renderer.RenderSuccess("+ "+change.GetPath()))
+        case run.ModifiedChange:
+            line := "~ " + change.GetPath()
+            if !change.IsBinary() {
+                line += fmt.Sprintf(" (+%d -%d)", change.GetAdded(), change.GetRemoved())
+            }
+            rendered += fmt.Sprintf("    %s\n", u.components.renderer.RenderWarning(line))
+        case run.DeletedChange:
+            rendered += fmt.Sprintf("    %s\n", u.components.renderer.RenderError("- "+change.GetPath()))
+        }
+        rendered += u.renderDiff(change.GetDiff())
+    }
+
+    if preview.GetExitCode() != 0 && preview.GetOutput() != "" {
+        lines := strings.Split(preview.GetOutput(), "\n")
+        if len(lines) > 5 {
+            lines = lines[len(lines)-5:]
+        }
+        for _, line := range lines {
+            rendered += fmt.Sprintf("    %s\n", u.components.renderer.RenderHelp(line))
+        }
+    }
+
+    return rendered
+}
+
+// renderRisk lists the risks of the command waiting for confirmation.
+func (u *Ui) renderRisk(risk run.RiskAssessment) string {
+    if risk.GetLevel() == run.NoRisk {
+        return ""
+    }
+
+    render := u.components.renderer.RenderWarning
+    if risk.GetLevel() >= run.HighRisk {
+        render = u.components.renderer.RenderError
+    }
+
+    rendered := fmt.Sprintf("\n  %s\n", render(fmt.Sprintf("⚠ %s risk:", risk.GetLevel())))
+    for _, r := range risk.GetRisks() {
+        rendered += fmt.Sprintf("    %s\n", render(r.GetReason()))
+    }
+
+    return rendered
+}
+
+// runSlashCommand runs a REPL command and prints its outcome.
+func (u *Ui) runSlashCommand(command SlashCommand) tea.Cmd {
+    var output string
+    switch command.GetName() {
+    case "compare":
+        if len(command.GetArgs()) == 1 {
+            output = u.components.renderer.RenderError("[compare needs at least two models]")
+            break
+        }
+        u.state.compareModels = command.GetArgs()
+        if len(u.state.compareModels) == 0 {
+            output = u.components.renderer.RenderSuccess("[compare off]")
+        } else {
+            output = u.components.renderer.RenderSuccess(fmt.Sprintf("[compare on: %s]", strings.Join(u.state.compareModels, ", ")))
+        }
+    case "usage":
+        output = u.renderUsage()
+    case "jobs":
+        u.components.character.SetExpression("idle")
+        return u.runJobsCommand(command.GetArgs())
+    case "undo":
+        output = u.runUndoCommand(command.GetArgs())
+    }
+
+    u.components.character.SetExpression("idle")
+    return tea.Println(u.renderWithCharacter(fmt.Sprintf("\n%s\n", output)))
+}
+
+// renderComparison renders the answers of the compared models side by side,
+// numbered so one can be picked.
+func (u *Ui) renderComparison(comparison ai.EngineCompareOutput) string {
+    results := comparison.GetResults()
+    contentWidth := u.dimensions.width - 24
+    columnWidth := contentWidth/len(results) - 2
+    if columnWidth < 24 {
+        columnWidth = 24
+    }
+
+    columns := make([]string, 0, len(results))
+    for i, result := range results {
+        column := fmt.Sprintf("%d. %s\n", i+1, result.Model)
+        column += u.components.renderer.RenderHelp(fmt.Sprintf("%s · %d → %d tokens", result.Latency.Round(10*time.Millisecond), result.PromptTokens, result.CandidatesTokens)) + "\n\n"
+        if result.Error != nil {
+            column += u.components.renderer.RenderError(result.Error.Error())
+        } else {
+            if result.Output.IsExecutable() {
+                column += u.components.renderer.RenderSuccess(result.Output.GetCommand()) + "\n"
+            } else if result.Output.IsInvalid() {
+                column += u.components.renderer.RenderError(fmt.Sprintf("%s\ninvalid syntax: %s", result.Output.GetCommand(), result.Output.GetSyntaxError())) + "\n"
+            }
+            column += u.components.renderer.RenderHelp(result.Output.GetExplanation())
+        }
+        columns = append(columns, lipgloss.NewStyle().Width(columnWidth).MarginRight(2).Render(column))
+    }
+
+    return "\n  " + lipgloss.JoinHorizontal(lipgloss.Top, columns...) + "\n"
+}
+
+func getEngineMode(mode PromptMode) ai.EngineMode {
+    switch mode {
+    case ChatPromptMode:
+        return ai.ChatEngineMode
+    case TranslatePromptMode:
+        return ai.TranslateEngineMode
+    default:
+        return ai.ExecEngineMode
+    }
+}
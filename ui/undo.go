@@ -0,0 +1,93 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Praatibh/xang/config"
+	"github.com/Praatibh/xang/run"
+	"github.com/Praatibh/xang/system"
+)
+
+// IsUndo reports whether args, without the name of the program, are the ones
+// of xang undo [count|list], other arguments being a prompt such as "undo
+// commit".
+func IsUndo(args []string) bool {
+	if len(args) == 0 || len(args) > 2 || args[0] != "undo" {
+		return false
+	}
+	if len(args) == 1 || args[1] == "list" {
+		return true
+	}
+	_, err := strconv.Atoi(args[1])
+
+	return err == nil
+}
+
+// NewUndoJournal returns the journal the files changed by commands are saved
+// to, in $XDG_DATA_HOME/xang/undo.
+func NewUndoJournal(undo config.UndoConfig) *run.Journal {
+	return run.NewJournal(filepath.Join(system.GetDataDirectory(), "undo"), undo.GetRetention(), undo.GetMaxSize())
+}
+
+// RunUndo undoes the commands of xang undo [count|list], or lists the ones
+// that can be. It returns the exit code of xang undo: 1 when undoing fails,
+// 2 when the configuration can't be read.
+func RunUndo(w io.Writer, args []string) int {
+	loaded, err := config.NewConfig()
+	if err != nil {
+		fmt.Fprintf(w, "error: %s\n", err)
+		return 2
+	}
+
+	return writeUndo(w, NewUndoJournal(loaded.GetUndoConfig()), args)
+}
+
+// writeUndo undoes the last count commands of journal, count being the only
+// one of args, 1 by default, or lists them when it is list.
+func writeUndo(w io.Writer, journal *run.Journal, args []string) int {
+	if len(args) == 1 && args[0] == "list" {
+		entries, err := journal.GetEntries()
+		if err != nil {
+			fmt.Fprintf(w, "error: %s\n", err)
+			return 1
+		}
+		if len(entries) == 0 {
+			fmt.Fprintln(w, "nothing to undo")
+		}
+		for i, entry := range entries {
+			fmt.Fprintf(w, "%d. %s  %s, %d paths\n", i+1, entry.GetTime().Format("2006-01-02 15:04:05"), entry.GetCommand(), len(entry.GetPaths()))
+		}
+		return 0
+	}
+
+	count := 1
+	if len(args) == 1 {
+		parsed, err := strconv.Atoi(args[0])
+		if err != nil || parsed < 1 {
+			fmt.Fprintf(w, "error: invalid number of commands %q\n", args[0])
+			return 1
+		}
+		count = parsed
+	}
+
+	undone, err := journal.Undo(count)
+	for _, entry := range undone {
+		fmt.Fprintf(w, "undone: %s\n", entry.GetCommand())
+		for _, path := range entry.Paths {
+			if path.Absent {
+				fmt.Fprintf(w, "  removed %s\n", path.Path)
+			} else {
+				fmt.Fprintf(w, "  restored %s\n", path.Path)
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(w, "error: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,52 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Praatibh/xang/run"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsUndo(t *testing.T) {
+	assert.True(t, IsUndo([]string{"undo"}))
+	assert.True(t, IsUndo([]string{"undo", "3"}))
+	assert.True(t, IsUndo([]string{"undo", "list"}))
+	assert.False(t, IsUndo([]string{"undo", "commit"}))
+	assert.False(t, IsUndo([]string{"undo", "the", "last", "commit"}))
+}
+
+func TestWriteUndo(t *testing.T) {
+	dir := t.TempDir()
+	notes, todo := filepath.Join(dir, "notes.txt"), filepath.Join(dir, "todo.txt")
+	require.NoError(t, os.WriteFile(notes, []byte("notes"), 0644))
+	journal := run.NewJournal(filepath.Join(t.TempDir(), "undo"), 0, 0)
+	_, err := journal.Record("mv notes.txt todo.txt", []string{notes, todo})
+	require.NoError(t, err)
+	require.NoError(t, os.Rename(notes, todo))
+
+	var output strings.Builder
+	code := writeUndo(&output, journal, []string{"list"})
+	assert.Equal(t, 0, code)
+	assert.Contains(t, output.String(), "1. ")
+	assert.Contains(t, output.String(), "mv notes.txt todo.txt, 2 paths")
+
+	output.Reset()
+	code = writeUndo(&output, journal, nil)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "undone: mv notes.txt todo.txt\n  restored "+notes+"\n  removed "+todo+"\n", output.String())
+	assert.FileExists(t, notes)
+
+	output.Reset()
+	code = writeUndo(&output, journal, nil)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "error: nothing to undo\n", output.String())
+
+	output.Reset()
+	code = writeUndo(&output, journal, []string{"zero"})
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "error: invalid number of commands \"zero\"\n", output.String())
+}